@@ -7,6 +7,7 @@ import (
 	"log"
 	"net/http"
 	"strconv"
+	"strings"
 
 	// "log/slog"
 	"os"
@@ -19,10 +20,26 @@ import (
 	"github.com/ZEGIFTED/MS.GoMonitor/monitors"
 	"github.com/ZEGIFTED/MS.GoMonitor/notifier"
 	"github.com/ZEGIFTED/MS.GoMonitor/pkg/constants"
+	"github.com/ZEGIFTED/MS.GoMonitor/pkg/discovery"
+	"github.com/ZEGIFTED/MS.GoMonitor/pkg/flows"
 	"github.com/ZEGIFTED/MS.GoMonitor/pkg/messaging"
-
+	"github.com/ZEGIFTED/MS.GoMonitor/pkg/plugins"
+	dnscheck "github.com/ZEGIFTED/MS.GoMonitor/pkg/plugins/dns_check"
+	elasticcheck "github.com/ZEGIFTED/MS.GoMonitor/pkg/plugins/elastic_check"
+	execcheck "github.com/ZEGIFTED/MS.GoMonitor/pkg/plugins/exec_check"
+	grpchealth "github.com/ZEGIFTED/MS.GoMonitor/pkg/plugins/grpc_health"
 	"github.com/ZEGIFTED/MS.GoMonitor/pkg/plugins/http_monitor"
+	kafkacheck "github.com/ZEGIFTED/MS.GoMonitor/pkg/plugins/kafka_check"
+	kubecheck "github.com/ZEGIFTED/MS.GoMonitor/pkg/plugins/kubernetes_check"
+	ldapcheck "github.com/ZEGIFTED/MS.GoMonitor/pkg/plugins/ldap_check"
+	mailcheck "github.com/ZEGIFTED/MS.GoMonitor/pkg/plugins/mail_check"
+	ntpcheck "github.com/ZEGIFTED/MS.GoMonitor/pkg/plugins/ntp_check"
+	"github.com/ZEGIFTED/MS.GoMonitor/pkg/plugins/path_monitor"
+	"github.com/ZEGIFTED/MS.GoMonitor/pkg/plugins/ping"
+	redischeck "github.com/ZEGIFTED/MS.GoMonitor/pkg/plugins/redis_check"
 	sslcheck "github.com/ZEGIFTED/MS.GoMonitor/pkg/plugins/ssl_checker"
+	websocketcheck "github.com/ZEGIFTED/MS.GoMonitor/pkg/plugins/websocket_check"
+	"github.com/ZEGIFTED/MS.GoMonitor/pkg/snmpprofiles"
 	"github.com/ZEGIFTED/MS.GoMonitor/pkg/utils"
 	mstypes "github.com/ZEGIFTED/MS.GoMonitor/types"
 	"github.com/joho/godotenv"
@@ -116,6 +133,89 @@ func NewServiceMonitor(db *sql.DB) *monitors.MonitoringEngine {
 
 	monitor.Plugins["http_monitor"] = &http_monitor.HTTPMonitorPlugin{}
 	monitor.Plugins["ssl_check"] = &sslcheck.SSLChecker{}
+	monitor.Plugins["dns_check"] = dnscheck.NewDNSChecker()
+	monitor.Plugins["ping"] = ping.NewPingPlugin()
+	monitor.Plugins["path_monitor"] = path_monitor.NewPathMonitorPlugin()
+	monitor.Plugins["postgres_check"] = plugins.NewPostgresMonitorPlugin()
+	monitor.Plugins["mssql_check"] = plugins.NewSQLServerMonitorPlugin()
+	monitor.Plugins["docker"] = plugins.NewDockerPlugin()
+	monitor.Plugins["redis_check"] = redischeck.NewRedisChecker()
+	monitor.Plugins["kafka_check"] = kafkacheck.NewKafkaChecker()
+	monitor.Plugins["elastic_check"] = elasticcheck.NewElasticChecker()
+	monitor.Plugins["kubernetes_check"] = kubecheck.NewKubernetesChecker()
+	monitor.Plugins["exec"] = execcheck.NewExecPlugin()
+	monitor.Plugins["grpc_health"] = grpchealth.NewGRPCHealthChecker()
+	monitor.Plugins["websocket_check"] = websocketcheck.NewWebSocketChecker()
+	monitor.Plugins["mail_check"] = mailcheck.NewMailChecker()
+	monitor.Plugins["ldap_check"] = ldapcheck.NewLDAPChecker()
+	monitor.Plugins["ntp_check"] = ntpcheck.NewNTPChecker()
+	monitor.Plugins["network_snmp"] = plugins.NewNetworkSNMPPlugin()
+
+	monitor.Heartbeats = monitors.NewHeartbeatRegistry()
+	monitor.Plugins[monitors.HeartbeatPluginName] = monitors.NewHeartbeatPlugin(monitor.Heartbeats)
+
+	if constants.SNMPTrapEnabled {
+		monitor.Traps = monitors.NewTrapReceiver(monitor, constants.SNMPTrapAddress)
+
+		if constants.SNMPTrapDefinitionsFile != "" {
+			if err := monitor.Traps.LoadDefinitions(constants.SNMPTrapDefinitionsFile); err != nil {
+				log.Printf("Failed to load SNMP trap definitions: %v", err)
+			}
+		}
+		if constants.SNMPTrapUsersFile != "" {
+			if err := monitor.Traps.LoadUsers(constants.SNMPTrapUsersFile); err != nil {
+				log.Printf("Failed to load SNMP trap users: %v", err)
+			}
+		}
+	}
+
+	if constants.SyslogEnabled {
+		monitor.Syslog = monitors.NewSyslogReceiver(monitor, constants.SyslogUDPAddress, constants.SyslogTCPAddress, constants.SyslogTLSAddress)
+
+		if constants.SyslogTLSAddress != "" {
+			if err := monitor.Syslog.LoadCertificate(constants.SyslogTLSCertFile, constants.SyslogTLSKeyFile); err != nil {
+				log.Printf("Failed to load syslog TLS certificate: %v", err)
+			}
+		}
+		if constants.SyslogRulesFile != "" {
+			if err := monitor.Syslog.LoadRules(constants.SyslogRulesFile); err != nil {
+				log.Printf("Failed to load syslog rules: %v", err)
+			}
+		}
+	}
+
+	monitor.Dependencies = monitors.NewDependencyTracker()
+	if constants.TopologyEnabled {
+		monitor.Topology = monitors.NewTopologyCollector(monitor, constants.TopologyRoot, constants.TopologySchedule)
+	}
+
+	if constants.SNMPProfilesDir != "" {
+		if err := snmpprofiles.Default.LoadDir(constants.SNMPProfilesDir); err != nil {
+			log.Printf("Failed to load SNMP profiles: %v", err)
+		}
+	}
+	if constants.SNMPMIBDirs != "" {
+		if err := snmpprofiles.LoadMIBDirs(strings.Split(constants.SNMPMIBDirs, ",")); err != nil {
+			log.Printf("Failed to load MIB files: %v", err)
+		}
+	}
+
+	if constants.DiscoveryConfigFile != "" {
+		config, err := discovery.LoadConfig(constants.DiscoveryConfigFile)
+		if err != nil {
+			log.Printf("Network discovery disabled: %v", err)
+		} else {
+			discoverer := discovery.New(monitor, config)
+			_, err := monitor.Cron.AddFunc(config.Schedule, func() {
+				if _, err := discoverer.Run(monitor.Ctx); err != nil {
+					log.Printf("Network discovery failed: %v", err)
+				}
+			})
+			if err != nil {
+				log.Printf("Failed to schedule network discovery: %v", err)
+			}
+		}
+	}
 
 	if false {
 		// monitor.Checkers[monitors.ServiceMonitorAgent] = &monitors.AgentServiceChecker{}
@@ -236,16 +336,6 @@ func main() {
 		}
 	}()
 
-	// handler := &monitors.NetworkManager{
-	// 	Community: "public",
-	// 	TrapPort:  162, // Standard SNMP trap port
-	// }
-
-	// trapErr := handler.StartListener()
-	// if err != nil {
-	// 	log.Fatalf("Failed to start trap handler: %v", trapErr)
-	// }
-
 	// Create the payload
 	//teamsMessage := messaging.TeamsMessage{
 	//	Type:       "MessageCard",
@@ -272,15 +362,36 @@ func main() {
 
 	// Create and start monitor
 	monitor := NewServiceMonitor(db)
+	http.Handle("/api/v1/heartbeat/", monitor.Heartbeats)
+	if monitor.Syslog != nil {
+		http.Handle("/api/v1/syslog/", monitor.Syslog)
+	}
 
 	if err := monitor.StartEngine(); err != nil {
 		log.Fatalf("Failed to Start Monitoring Engine: %v", err)
 	}
 
+	// The collector attributes exports to the services loaded by the engine
+	if constants.FlowEnabled {
+		var addresses []string
+		for _, address := range strings.Split(constants.FlowAddresses, ",") {
+			if address = strings.TrimSpace(address); address != "" {
+				addresses = append(addresses, address)
+			}
+		}
+
+		collector := flows.New(monitor, addresses)
+		if err := collector.Start(); err != nil {
+			log.Printf("Flow collector not started: %v", err)
+		} else {
+			http.Handle("/api/v1/flows/", collector)
+			defer collector.Stop()
+		}
+	}
+
 	// Wait for shutdown signal
 	<-shutdown
 	log.Println("Shutting down...")
-	// handler.StopListener()
 
 	// Graceful shutdown
 	monitor.StopEngine()
@@ -0,0 +1,263 @@
+package network
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"fmt"
+	"math"
+	"net"
+	"os"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+const (
+	protocolICMP     = 1
+	protocolIPv6ICMP = 58
+)
+
+// Pinger sends ICMP echo requests to a destination.
+// Privileged pingers use raw sockets (root or CAP_NET_RAW), unprivileged pingers
+// use datagram ICMP sockets which require net.ipv4.ping_group_range on Linux.
+type Pinger struct {
+	Count      int
+	Interval   time.Duration
+	Timeout    time.Duration // Per echo reply timeout
+	Size       int           // Payload size in bytes
+	Privileged bool
+}
+
+// PingResult holds the statistics of a ping run
+type PingResult struct {
+	Target     string
+	Address    string
+	Sent       int
+	Received   int
+	PacketLoss float64 // Percentage 0-100
+	RTTs       []time.Duration
+	MinRTT     time.Duration
+	AvgRTT     time.Duration
+	MaxRTT     time.Duration
+	Jitter     time.Duration // Mean deviation between consecutive round trips
+}
+
+func NewPinger() *Pinger {
+	return &Pinger{
+		Count:    5,
+		Interval: time.Second,
+		Timeout:  2 * time.Second,
+		Size:     32,
+	}
+}
+
+// Ping sends p.Count echo requests to destination and collects the round trip statistics.
+// An error is only returned when the probe could not be run at all; unanswered echoes are
+// reported through PacketLoss.
+func (p *Pinger) Ping(ctx context.Context, destination string) (PingResult, error) {
+	result := PingResult{Target: destination}
+
+	ipAddr, err := resolveIP(ctx, destination)
+	if err != nil {
+		return result, err
+	}
+	result.Address = ipAddr.String()
+
+	isIPv6 := ipAddr.IP.To4() == nil
+	conn, err := listenICMP(isIPv6, p.Privileged)
+	if err != nil {
+		return result, err
+	}
+	defer conn.Close()
+
+	dst := echoDestination(ipAddr, p.Privileged)
+	id := os.Getpid() & 0xffff
+	token := probeToken()
+	payload := make([]byte, max(p.Size, len(token)))
+	copy(payload, token)
+
+	for seq := 1; seq <= p.Count; seq++ {
+		if seq > 1 {
+			select {
+			case <-ctx.Done():
+				return finalizePingResult(result), ctx.Err()
+			case <-time.After(p.Interval):
+			}
+		}
+
+		msg, err := echoRequest(isIPv6, id, seq, payload)
+		if err != nil {
+			return result, err
+		}
+
+		start := time.Now()
+		if _, err := conn.WriteTo(msg, dst); err != nil {
+			return result, fmt.Errorf("failed to send ICMP echo to %s: %v", ipAddr, err)
+		}
+		result.Sent++
+
+		rtt, err := p.awaitReply(ctx, conn, isIPv6, id, seq, token, start)
+		if err != nil {
+			continue
+		}
+
+		result.Received++
+		result.RTTs = append(result.RTTs, rtt)
+	}
+
+	return finalizePingResult(result), nil
+}
+
+// awaitReply reads from conn until the echo reply for seq arrives or the timeout expires.
+// Raw sockets receive every ICMP packet on the host so replies are matched on id, seq and payload token.
+func (p *Pinger) awaitReply(ctx context.Context, conn *icmp.PacketConn, isIPv6 bool, id, seq int, token []byte, start time.Time) (time.Duration, error) {
+	deadline := start.Add(p.Timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	if err := conn.SetReadDeadline(deadline); err != nil {
+		return 0, err
+	}
+
+	proto := protocolICMP
+	if isIPv6 {
+		proto = protocolIPv6ICMP
+	}
+
+	reply := make([]byte, 1500)
+	for {
+		n, _, err := conn.ReadFrom(reply)
+		if err != nil {
+			return 0, err
+		}
+
+		rm, err := icmp.ParseMessage(proto, reply[:n])
+		if err != nil {
+			continue
+		}
+
+		if rm.Type != ipv4.ICMPTypeEchoReply && rm.Type != ipv6.ICMPTypeEchoReply {
+			continue
+		}
+
+		echo, ok := rm.Body.(*icmp.Echo)
+		if !ok || echo.Seq != seq || !bytes.HasPrefix(echo.Data, token) {
+			continue
+		}
+
+		// Datagram sockets get their echo identifier rewritten by the kernel
+		if p.Privileged && echo.ID != id {
+			continue
+		}
+
+		return time.Since(start), nil
+	}
+}
+
+func finalizePingResult(result PingResult) PingResult {
+	if result.Sent > 0 {
+		result.PacketLoss = float64(result.Sent-result.Received) / float64(result.Sent) * 100
+	}
+
+	if len(result.RTTs) == 0 {
+		return result
+	}
+
+	var total, deviation time.Duration
+	result.MinRTT = time.Duration(math.MaxInt64)
+	for i, rtt := range result.RTTs {
+		total += rtt
+		result.MinRTT = min(result.MinRTT, rtt)
+		result.MaxRTT = max(result.MaxRTT, rtt)
+
+		if i > 0 {
+			deviation += (rtt - result.RTTs[i-1]).Abs()
+		}
+	}
+
+	result.AvgRTT = total / time.Duration(len(result.RTTs))
+	if len(result.RTTs) > 1 {
+		result.Jitter = deviation / time.Duration(len(result.RTTs)-1)
+	}
+
+	return result
+}
+
+func resolveIP(ctx context.Context, destination string) (*net.IPAddr, error) {
+	if ip := net.ParseIP(destination); ip != nil {
+		return &net.IPAddr{IP: ip}, nil
+	}
+
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, destination)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve %s: %v", destination, err)
+	}
+
+	// Prefer IPv4 where available
+	for _, addr := range addrs {
+		if addr.IP.To4() != nil {
+			return &addr, nil
+		}
+	}
+
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("no addresses found for %s", destination)
+	}
+
+	return &addrs[0], nil
+}
+
+func listenICMP(isIPv6, privileged bool) (*icmp.PacketConn, error) {
+	network, address := "udp4", "0.0.0.0"
+	switch {
+	case isIPv6 && privileged:
+		network, address = "ip6:ipv6-icmp", "::"
+	case isIPv6:
+		network, address = "udp6", "::"
+	case privileged:
+		network = "ip4:icmp"
+	}
+
+	conn, err := icmp.ListenPacket(network, address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open ICMP socket (%s): %v", network, err)
+	}
+
+	return conn, nil
+}
+
+func echoDestination(ipAddr *net.IPAddr, privileged bool) net.Addr {
+	if privileged {
+		return ipAddr
+	}
+	return &net.UDPAddr{IP: ipAddr.IP, Zone: ipAddr.Zone}
+}
+
+func echoRequest(isIPv6 bool, id, seq int, payload []byte) ([]byte, error) {
+	var msgType icmp.Type = ipv4.ICMPTypeEcho
+	if isIPv6 {
+		msgType = ipv6.ICMPTypeEchoRequest
+	}
+
+	msg := icmp.Message{
+		Type: msgType, Code: 0,
+		Body: &icmp.Echo{ID: id, Seq: seq, Data: payload},
+	}
+
+	b, err := msg.Marshal(nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal ICMP message: %v", err)
+	}
+
+	return b, nil
+}
+
+// probeToken returns a random marker used to recognise replies to our own probes
+func probeToken() []byte {
+	token := make([]byte, 8)
+	_, _ = rand.Read(token)
+	return token
+}
@@ -1,94 +1,328 @@
 package network
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
 	"fmt"
 	"net"
-	"os"
+	"strings"
+	"sync"
 	"time"
 
 	"golang.org/x/net/icmp"
 	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
 )
 
+// Tracer discovers the hops towards a destination by sending ICMP echoes with an increasing TTL.
+// Intermediate routers answer with ICMP time exceeded which the kernel only hands to raw sockets,
+// so tracing needs root or CAP_NET_RAW unlike the unprivileged Pinger.
 type Tracer struct {
-	destination string
+	destination  string
+	MaxHops      int
+	ProbesPerHop int
+	Timeout      time.Duration // Per probe reply timeout
+	ResolveNames bool
+}
+
+// Hop is a single router on the path. RTTs holds one entry per answered probe.
+type Hop struct {
+	TTL      int             `json:"ttl"`
+	Address  string          `json:"address,omitempty"`
+	Hostname string          `json:"hostname,omitempty"`
+	RTTs     []time.Duration `json:"rtts"`
+	Lost     int             `json:"lost"`
+}
+
+// TraceResult is the path discovered towards a destination
+type TraceResult struct {
+	Destination string    `json:"destination"`
+	Address     string    `json:"address"`
+	Hops        []Hop     `json:"hops"`
+	Reached     bool      `json:"reached"`
+	TracedAt    time.Time `json:"tracedAt"`
 }
 
 func NewTracer(destination string) *Tracer {
-	return &Tracer{destination: destination}
+	return &Tracer{
+		destination:  destination,
+		MaxHops:      30,
+		ProbesPerHop: 3,
+		Timeout:      2 * time.Second,
+		ResolveNames: true,
+	}
 }
 
-func (t *Tracer) Traceroute() {
-	ipAddr, err := net.ResolveIPAddr("ip4", t.destination)
+// Traceroute probes every TTL up to MaxHops over a single ICMP socket and returns the structured path
+func (t *Tracer) Traceroute(ctx context.Context) (TraceResult, error) {
+	result := TraceResult{Destination: t.destination, TracedAt: time.Now()}
+
+	ipAddr, err := resolveIP(ctx, t.destination)
 	if err != nil {
-		fmt.Println("Failed to resolve destination:", err)
-		return
+		return result, err
 	}
+	result.Address = ipAddr.String()
 
-	fmt.Printf("Traceroute to %s (%s)\n", t.destination, ipAddr)
+	isIPv6 := ipAddr.IP.To4() == nil
+	conn, err := listenICMP(isIPv6, true)
+	if err != nil {
+		return result, err
+	}
+	defer conn.Close()
 
-	for ttl := 1; ttl <= 20; ttl++ {
-		conn, err := icmp.ListenPacket("ip4:icmp", "0.0.0.0")
-		if err != nil {
-			fmt.Println("Failed to listen for ICMP packets:", err)
-			return
-		}
-		defer conn.Close()
+	// Traces running at the same time share the raw socket traffic, each uses its own random ID and
+	// token to tell its replies apart
+	token := probeToken()
+	id := int(binary.BigEndian.Uint16(token))
+	reply := make([]byte, 1500)
 
-		conn.IPv4PacketConn().SetTTL(ttl)
+	for ttl := 1; ttl <= t.MaxHops; ttl++ {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
 
-		msg := icmp.Message{
-			Type: ipv4.ICMPTypeEcho, Code: 0,
-			Body: &icmp.Echo{
-				ID: os.Getpid() & 0xffff, Seq: ttl,
-				Data: []byte("HELLO"),
-			},
+		if err := setTTL(conn, isIPv6, ttl); err != nil {
+			return result, err
 		}
 
-		msgBytes, err := msg.Marshal(nil)
-		if err != nil {
-			fmt.Println("Failed to marshal ICMP message:", err)
-			return
+		hop := Hop{TTL: ttl}
+		reached := false
+
+		for probe := 0; probe < t.ProbesPerHop; probe++ {
+			seq := (ttl << 4) | probe
+			msg, err := echoRequest(isIPv6, id, seq, token)
+			if err != nil {
+				return result, err
+			}
+
+			start := time.Now()
+			if _, err := conn.WriteTo(msg, ipAddr); err != nil {
+				return result, fmt.Errorf("failed to send ICMP probe to %s: %v", ipAddr, err)
+			}
+
+			peer, final, err := t.awaitHopReply(conn, isIPv6, ipAddr.IP, id, seq, token, reply, start)
+			if err != nil {
+				hop.Lost++
+				continue
+			}
+
+			hop.Address = peer
+			hop.RTTs = append(hop.RTTs, time.Since(start))
+			reached = reached || final
 		}
 
-		start := time.Now()
-		if _, err := conn.WriteTo(msgBytes, ipAddr); err != nil {
-			fmt.Println("Failed to send ICMP message:", err)
-			return
+		result.Hops = append(result.Hops, hop)
+
+		if reached {
+			result.Reached = true
+			break
 		}
+	}
 
-		reply := make([]byte, 1500)
-		if err := conn.SetReadDeadline(time.Now().Add(3 * time.Second)); err != nil {
-			fmt.Println("Failed to set read deadline:", err)
-			return
+	if t.ResolveNames {
+		resolveHopNames(ctx, result.Hops)
+	}
+
+	return result, nil
+}
+
+// awaitHopReply waits for the time exceeded, unreachable or echo reply that belongs to probe seq.
+// It returns the responding address and whether the probe reached the destination.
+func (t *Tracer) awaitHopReply(conn *icmp.PacketConn, isIPv6 bool, destination net.IP, id, seq int, token, buf []byte, start time.Time) (string, bool, error) {
+	if err := conn.SetReadDeadline(start.Add(t.Timeout)); err != nil {
+		return "", false, err
+	}
+
+	proto := protocolICMP
+	if isIPv6 {
+		proto = protocolIPv6ICMP
+	}
+
+	for {
+		n, peer, err := conn.ReadFrom(buf)
+		if err != nil {
+			return "", false, err
 		}
 
-		n, peer, err := conn.ReadFrom(reply)
+		rm, err := icmp.ParseMessage(proto, buf[:n])
 		if err != nil {
-			fmt.Printf("%d\t*\n", ttl)
 			continue
 		}
 
-		duration := time.Since(start)
-		rm, err := icmp.ParseMessage(1, reply[:n])
-		if err != nil {
-			fmt.Println("Failed to parse ICMP message:", err)
-			return
+		peerIP := peer.String()
+		if ipAddr, ok := peer.(*net.IPAddr); ok {
+			peerIP = ipAddr.IP.String()
 		}
 
-		switch rm.Type {
-		case ipv4.ICMPTypeTimeExceeded:
-			fmt.Printf("%d\t%v\t%v\n", ttl, peer, duration)
-		case ipv4.ICMPTypeEchoReply:
-			fmt.Printf("%d\t%v\t%v\n", ttl, peer, duration)
-			return
-		default:
-			fmt.Printf("%d\t%v\t%v\tunexpected type %v\n", ttl, peer, duration, rm.Type)
+		switch body := rm.Body.(type) {
+		case *icmp.Echo:
+			if (rm.Type == ipv4.ICMPTypeEchoReply || rm.Type == ipv6.ICMPTypeEchoReply) &&
+				body.ID == id && body.Seq == seq && bytes.HasPrefix(body.Data, token) {
+				return peerIP, true, nil
+			}
+		case *icmp.TimeExceeded:
+			if quotedEchoMatches(body.Data, isIPv6, destination, id, seq, token) {
+				return peerIP, false, nil
+			}
+		case *icmp.DstUnreach:
+			// The destination (or a filtering router) refused the probe, there is nothing further to trace
+			if quotedEchoMatches(body.Data, isIPv6, destination, id, seq, token) {
+				return peerIP, true, nil
+			}
+		}
+	}
+}
+
+// quotedEchoMatches checks the original datagram quoted in an ICMP error against our probe: its
+// destination, echo ID and sequence, and the token when the router quoted enough of the payload.
+// RFC 792 routers quote only the first 8 bytes after the IP header, RFC 1812 ones more.
+func quotedEchoMatches(data []byte, isIPv6 bool, destination net.IP, id, seq int, token []byte) bool {
+	var headerLen int
+	var quotedDst net.IP
+	if isIPv6 {
+		if len(data) < ipv6.HeaderLen {
+			return false
+		}
+		headerLen, quotedDst = ipv6.HeaderLen, net.IP(data[24:40])
+	} else {
+		if len(data) < ipv4.HeaderLen {
+			return false
+		}
+		headerLen, quotedDst = int(data[0]&0x0f)*4, net.IP(data[16:20])
+		if headerLen < ipv4.HeaderLen {
+			return false
 		}
 	}
+
+	if len(data) < headerLen+8 || !quotedDst.Equal(destination) {
+		return false
+	}
+
+	echo := data[headerLen:]
+	if int(binary.BigEndian.Uint16(echo[4:6])) != id || int(binary.BigEndian.Uint16(echo[6:8])) != seq {
+		return false
+	}
+	if payload := echo[8:]; len(payload) >= len(token) && !bytes.HasPrefix(payload, token) {
+		return false
+	}
+	return true
 }
 
-func main() {
-	tracer := NewTracer("www.google.com")
-	tracer.Traceroute()
+func setTTL(conn *icmp.PacketConn, isIPv6 bool, ttl int) error {
+	if isIPv6 {
+		if err := conn.IPv6PacketConn().SetHopLimit(ttl); err != nil {
+			return fmt.Errorf("failed to set hop limit: %v", err)
+		}
+		return nil
+	}
+
+	if err := conn.IPv4PacketConn().SetTTL(ttl); err != nil {
+		return fmt.Errorf("failed to set TTL: %v", err)
+	}
+	return nil
+}
+
+// resolveHopNames performs the reverse lookups for all hops concurrently
+func resolveHopNames(ctx context.Context, hops []Hop) {
+	var wg sync.WaitGroup
+	for i := range hops {
+		if hops[i].Address == "" {
+			continue
+		}
+
+		wg.Add(1)
+		go func(hop *Hop) {
+			defer wg.Done()
+
+			lookupCtx, cancel := context.WithTimeout(ctx, 2*time.Second)
+			defer cancel()
+
+			names, err := net.DefaultResolver.LookupAddr(lookupCtx, hop.Address)
+			if err == nil && len(names) > 0 {
+				hop.Hostname = strings.TrimSuffix(names[0], ".")
+			}
+		}(&hops[i])
+	}
+	wg.Wait()
+}
+
+// AvgRTT returns the mean round trip of the answered probes
+func (h Hop) AvgRTT() time.Duration {
+	if len(h.RTTs) == 0 {
+		return 0
+	}
+
+	var total time.Duration
+	for _, rtt := range h.RTTs {
+		total += rtt
+	}
+	return total / time.Duration(len(h.RTTs))
+}
+
+func (h Hop) String() string {
+	if h.Address == "" {
+		return fmt.Sprintf("%d\t*", h.TTL)
+	}
+
+	name := h.Address
+	if h.Hostname != "" {
+		name = fmt.Sprintf("%s (%s)", h.Hostname, h.Address)
+	}
+
+	rtts := make([]string, 0, len(h.RTTs)+h.Lost)
+	for _, rtt := range h.RTTs {
+		rtts = append(rtts, rtt.Round(10*time.Microsecond).String())
+	}
+	for i := 0; i < h.Lost; i++ {
+		rtts = append(rtts, "*")
+	}
+
+	return fmt.Sprintf("%d\t%s\t%s", h.TTL, name, strings.Join(rtts, " "))
+}
+
+// Route returns the ordered hop addresses, "*" marking hops that did not answer
+func (r TraceResult) Route() []string {
+	route := make([]string, 0, len(r.Hops))
+	for _, hop := range r.Hops {
+		if hop.Address == "" {
+			route = append(route, "*")
+			continue
+		}
+		route = append(route, hop.Address)
+	}
+	return route
+}
+
+// Fingerprint identifies the route so paths can be compared over time
+func (r TraceResult) Fingerprint() string {
+	sum := sha1.Sum([]byte(strings.Join(r.Route(), ">")))
+	return hex.EncodeToString(sum[:])
+}
+
+// Summary renders the path one hop per line, as printed by traceroute
+func (r TraceResult) Summary() []string {
+	lines := make([]string, 0, len(r.Hops))
+	for _, hop := range r.Hops {
+		lines = append(lines, hop.String())
+	}
+	return lines
+}
+
+var lastPaths sync.Map // SystemMonitorId -> TraceResult
+
+// StorePath records the latest path traced for a monitored service
+func StorePath(systemMonitorId string, result TraceResult) {
+	lastPaths.Store(systemMonitorId, result)
+}
+
+// LastPath returns the latest path traced for a monitored service, if any
+func LastPath(systemMonitorId string) (TraceResult, bool) {
+	v, ok := lastPaths.Load(systemMonitorId)
+	if !ok {
+		return TraceResult{}, false
+	}
+	return v.(TraceResult), true
 }
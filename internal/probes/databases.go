@@ -0,0 +1,249 @@
+package probes
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+// tdsProber sends a TDS PRELOGIN packet and expects a SQL Server tabular response
+type tdsProber struct{}
+
+func (tdsProber) Probe(_ context.Context, conn net.Conn, _ Options) (Result, error) {
+	if _, err := conn.Write(tdsPrelogin()); err != nil {
+		return Result{}, fmt.Errorf("failed to send prelogin: %v", err)
+	}
+
+	header := make([]byte, 8)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return Result{}, fmt.Errorf("no prelogin response: %v", err)
+	}
+	if header[0] != 0x04 {
+		return Result{}, fmt.Errorf("not a SQL Server service (packet type 0x%02x)", header[0])
+	}
+
+	length := int(binary.BigEndian.Uint16(header[2:4]))
+	if length < 8 {
+		return Result{}, fmt.Errorf("invalid prelogin response length %d", length)
+	}
+
+	payload := make([]byte, length-8)
+	if _, err := io.ReadFull(conn, payload); err != nil {
+		return Result{}, fmt.Errorf("truncated prelogin response: %v", err)
+	}
+
+	result := Result{Details: map[string]any{}}
+	if version, ok := tdsServerVersion(payload); ok {
+		result.Banner = "Microsoft SQL Server " + version
+		result.Details["version"] = version
+	}
+
+	return result, nil
+}
+
+func (tdsProber) DefaultTimeout() time.Duration {
+	return 10 * time.Second
+}
+
+// tdsPrelogin builds a PRELOGIN message announcing that the client does not support encryption
+func tdsPrelogin() []byte {
+	// Option token, data offset, data length. Offsets are relative to the start of the payload.
+	options := []struct {
+		token byte
+		data  []byte
+	}{
+		{0x00, []byte{0, 0, 0, 0, 0, 0}}, // VERSION
+		{0x01, []byte{0x02}},             // ENCRYPTION: ENCRYPT_NOT_SUP
+		{0x02, []byte{0x00}},             // INSTOPT
+		{0x03, []byte{0, 0, 0, 0}},       // THREADID
+		{0x04, []byte{0x00}},             // MARS
+	}
+
+	offset := len(options)*5 + 1
+	var table, data []byte
+	for _, opt := range options {
+		table = append(table, opt.token, byte(offset>>8), byte(offset), 0, byte(len(opt.data)))
+		data = append(data, opt.data...)
+		offset += len(opt.data)
+	}
+	table = append(table, 0xff)
+
+	payload := append(table, data...)
+	length := len(payload) + 8
+
+	// Type PRELOGIN, status EOM, length, SPID, packet id, window
+	packet := []byte{0x12, 0x01, byte(length >> 8), byte(length), 0x00, 0x00, 0x01, 0x00}
+	return append(packet, payload...)
+}
+
+// tdsServerVersion extracts the VERSION option of a PRELOGIN response
+func tdsServerVersion(payload []byte) (string, bool) {
+	for i := 0; i+5 <= len(payload) && payload[i] != 0xff; i += 5 {
+		if payload[i] != 0x00 {
+			continue
+		}
+
+		offset := int(binary.BigEndian.Uint16(payload[i+1 : i+3]))
+		length := int(binary.BigEndian.Uint16(payload[i+3 : i+5]))
+		if length < 4 || offset+4 > len(payload) {
+			return "", false
+		}
+
+		v := payload[offset:]
+		return fmt.Sprintf("%d.%d.%d", v[0], v[1], binary.BigEndian.Uint16(v[2:4])), true
+	}
+
+	return "", false
+}
+
+// postgresProber sends an SSLRequest, which every PostgreSQL server answers with a single byte
+type postgresProber struct{}
+
+func (postgresProber) Probe(_ context.Context, conn net.Conn, _ Options) (Result, error) {
+	request := make([]byte, 8)
+	binary.BigEndian.PutUint32(request[0:4], 8)
+	binary.BigEndian.PutUint32(request[4:8], 80877103)
+
+	if _, err := conn.Write(request); err != nil {
+		return Result{}, fmt.Errorf("failed to send SSLRequest: %v", err)
+	}
+
+	reply := make([]byte, 1)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		return Result{}, fmt.Errorf("no SSLRequest response: %v", err)
+	}
+
+	switch reply[0] {
+	case 'S', 'N':
+		return Result{
+			Banner:  "PostgreSQL",
+			Details: map[string]any{"ssl": reply[0] == 'S'},
+		}, nil
+	case 'E':
+		// Servers older than 7.0 or poolers refusing the request still speak the protocol
+		return Result{Banner: "PostgreSQL", Details: map[string]any{"ssl": false}}, nil
+	}
+
+	return Result{}, fmt.Errorf("not a PostgreSQL service (reply 0x%02x)", reply[0])
+}
+
+func (postgresProber) DefaultTimeout() time.Duration {
+	return 10 * time.Second
+}
+
+// mysqlProber reads the initial handshake packet the server sends on connect
+type mysqlProber struct{}
+
+func (mysqlProber) Probe(_ context.Context, conn net.Conn, _ Options) (Result, error) {
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return Result{}, fmt.Errorf("no handshake received: %v", err)
+	}
+
+	length := int(header[0]) | int(header[1])<<8 | int(header[2])<<16
+	if length == 0 || length > 1<<16 {
+		return Result{}, fmt.Errorf("not a MySQL service (packet length %d)", length)
+	}
+
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(conn, payload); err != nil {
+		return Result{}, fmt.Errorf("truncated handshake: %v", err)
+	}
+
+	switch payload[0] {
+	case 0x0a:
+		version := string(payload[1:])
+		if end := strings.IndexByte(version, 0); end >= 0 {
+			version = version[:end]
+		}
+		return Result{Banner: "MySQL " + version, Details: map[string]any{"version": version}}, nil
+	case 0xff:
+		// The server refused the connection, e.g. host blocked or too many connections
+		if len(payload) >= 3 {
+			code := binary.LittleEndian.Uint16(payload[1:3])
+			return Result{}, fmt.Errorf("server error %d: %s", code, strings.TrimLeft(string(payload[3:]), "#"))
+		}
+	}
+
+	return Result{}, fmt.Errorf("not a MySQL service (protocol 0x%02x)", payload[0])
+}
+
+func (mysqlProber) DefaultTimeout() time.Duration {
+	return 10 * time.Second
+}
+
+// redisProber authenticates when credentials are configured and sends PING
+type redisProber struct{}
+
+func (redisProber) Probe(_ context.Context, conn net.Conn, opts Options) (Result, error) {
+	reader := bufio.NewReader(conn)
+
+	if opts.Password != "" {
+		args := []string{"AUTH", opts.Password}
+		if opts.Username != "" {
+			args = []string{"AUTH", opts.Username, opts.Password}
+		}
+
+		reply, err := redisCommand(conn, reader, args...)
+		if err != nil {
+			return Result{}, err
+		}
+		if !strings.HasPrefix(reply, "+OK") {
+			return Result{}, fmt.Errorf("authentication failed: %s", strings.TrimPrefix(reply, "-"))
+		}
+	}
+
+	reply, err := redisCommand(conn, reader, "PING")
+	if err != nil {
+		return Result{}, err
+	}
+
+	switch {
+	case strings.HasPrefix(reply, "+PONG"):
+		return Result{Banner: reply}, nil
+	case strings.HasPrefix(reply, "-NOAUTH"):
+		// The server is answering, it only refuses unauthenticated commands
+		return Result{Banner: reply, Details: map[string]any{"authRequired": true}}, nil
+	case strings.HasPrefix(reply, "-"):
+		return Result{Banner: reply}, fmt.Errorf("PING refused: %s", strings.TrimPrefix(reply, "-"))
+	}
+
+	return Result{Banner: reply}, fmt.Errorf("not a Redis service (reply %q)", reply)
+}
+
+func (redisProber) DefaultTimeout() time.Duration {
+	return 5 * time.Second
+}
+
+// redisCommand writes a RESP array command and returns the first reply line
+func redisCommand(conn net.Conn, reader *bufio.Reader, args ...string) (string, error) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+
+	if _, err := conn.Write([]byte(b.String())); err != nil {
+		return "", fmt.Errorf("failed to send %s: %v", args[0], err)
+	}
+
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return "", fmt.Errorf("no reply to %s: %v", args[0], err)
+	}
+
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+func init() {
+	Register("tds", tdsProber{})
+	Register("mssql", tdsProber{})
+	Register("postgres", postgresProber{})
+	Register("mysql", mysqlProber{})
+	Register("redis", redisProber{})
+}
@@ -0,0 +1,183 @@
+package probes
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"time"
+)
+
+// ldapResultCodes names the bind results worth reporting
+var ldapResultCodes = map[int]string{
+	0:  "success",
+	7:  "authMethodNotSupported",
+	8:  "strongerAuthRequired",
+	13: "confidentialityRequired",
+	49: "invalidCredentials",
+	51: "busy",
+	52: "unavailable",
+	53: "unwillingToPerform",
+}
+
+// ldapProber performs a simple bind (anonymous unless a bind DN is configured) and then unbinds
+type ldapProber struct{}
+
+func (ldapProber) Probe(_ context.Context, conn net.Conn, opts Options) (Result, error) {
+	bind := berTLV(0x60, concat(
+		berInteger(3),
+		berTLV(0x04, []byte(opts.Username)),
+		berTLV(0x80, []byte(opts.Password)),
+	))
+	if _, err := conn.Write(berTLV(0x30, concat(berInteger(1), bind))); err != nil {
+		return Result{}, fmt.Errorf("failed to send bind request: %v", err)
+	}
+
+	tag, message, err := readBER(conn)
+	if err != nil {
+		return Result{}, fmt.Errorf("no bind response: %v", err)
+	}
+	if tag != 0x30 {
+		return Result{}, fmt.Errorf("not an LDAP service (tag 0x%02x)", tag)
+	}
+
+	code, err := ldapBindResult(message)
+	if err != nil {
+		return Result{}, err
+	}
+
+	// UnbindRequest, the server closes the connection without replying
+	_, _ = conn.Write(berTLV(0x30, concat(berInteger(2), []byte{0x42, 0x00})))
+
+	name, ok := ldapResultCodes[code]
+	if !ok {
+		name = fmt.Sprintf("resultCode %d", code)
+	}
+
+	result := Result{Banner: "LDAP bind " + name, Details: map[string]any{"resultCode": code}}
+	if code != 0 {
+		return result, fmt.Errorf("bind failed: %s", name)
+	}
+
+	return result, nil
+}
+
+func (ldapProber) DefaultTimeout() time.Duration {
+	return 10 * time.Second
+}
+
+// ldapBindResult extracts the resultCode of a BindResponse LDAPMessage body
+func ldapBindResult(message []byte) (int, error) {
+	// messageID
+	tag, _, rest, err := splitBER(message)
+	if err != nil || tag != 0x02 {
+		return 0, fmt.Errorf("malformed LDAP message")
+	}
+
+	tag, op, _, err := splitBER(rest)
+	if err != nil || tag != 0x61 {
+		return 0, fmt.Errorf("unexpected LDAP response (tag 0x%02x)", tag)
+	}
+
+	tag, code, _, err := splitBER(op)
+	if err != nil || tag != 0x0a || len(code) == 0 {
+		return 0, fmt.Errorf("malformed bind response")
+	}
+
+	value := 0
+	for _, b := range code {
+		value = value<<8 | int(b)
+	}
+	return value, nil
+}
+
+func berLength(n int) []byte {
+	if n < 0x80 {
+		return []byte{byte(n)}
+	}
+
+	var b []byte
+	for ; n > 0; n >>= 8 {
+		b = append([]byte{byte(n)}, b...)
+	}
+	return append([]byte{0x80 | byte(len(b))}, b...)
+}
+
+func berTLV(tag byte, value []byte) []byte {
+	return append(append([]byte{tag}, berLength(len(value))...), value...)
+}
+
+func berInteger(v int) []byte {
+	return berTLV(0x02, []byte{byte(v)})
+}
+
+func concat(parts ...[]byte) []byte {
+	var out []byte
+	for _, p := range parts {
+		out = append(out, p...)
+	}
+	return out
+}
+
+// splitBER returns the tag and value of the first element of b and the bytes following it
+func splitBER(b []byte) (byte, []byte, []byte, error) {
+	if len(b) < 2 {
+		return 0, nil, nil, io.ErrUnexpectedEOF
+	}
+
+	tag, length, pos := b[0], int(b[1]), 2
+	if length&0x80 != 0 {
+		octets := length & 0x7f
+		if octets == 0 || octets > 4 || len(b) < 2+octets {
+			return 0, nil, nil, fmt.Errorf("invalid BER length")
+		}
+		length = 0
+		for _, o := range b[2 : 2+octets] {
+			length = length<<8 | int(o)
+		}
+		pos += octets
+	}
+
+	if len(b) < pos+length {
+		return 0, nil, nil, io.ErrUnexpectedEOF
+	}
+	return tag, b[pos : pos+length], b[pos+length:], nil
+}
+
+// readBER reads one complete BER element from the connection
+func readBER(r io.Reader) (byte, []byte, error) {
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return 0, nil, err
+	}
+
+	length := int(header[1])
+	if length&0x80 != 0 {
+		octets := length & 0x7f
+		if octets == 0 || octets > 4 {
+			return 0, nil, fmt.Errorf("invalid BER length")
+		}
+		lb := make([]byte, octets)
+		if _, err := io.ReadFull(r, lb); err != nil {
+			return 0, nil, err
+		}
+		length = 0
+		for _, o := range lb {
+			length = length<<8 | int(o)
+		}
+	}
+
+	if length > 1<<20 {
+		return 0, nil, fmt.Errorf("BER element too large (%d bytes)", length)
+	}
+
+	value := make([]byte, length)
+	if _, err := io.ReadFull(r, value); err != nil {
+		return 0, nil, err
+	}
+	return header[0], value, nil
+}
+
+func init() {
+	Register("ldap", ldapProber{})
+}
@@ -0,0 +1,230 @@
+package probes
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Prober speaks just enough of a protocol over an established connection to prove
+// the service behind a port is the expected one and is answering.
+type Prober interface {
+	// Probe runs the protocol exchange. The connection deadline is already set to the probe budget.
+	Probe(ctx context.Context, conn net.Conn, opts Options) (Result, error)
+
+	// DefaultTimeout is the time budget of a probe when none is configured
+	DefaultTimeout() time.Duration
+}
+
+// Options are the probe settings read from a service Configuration
+type Options struct {
+	Host       string
+	Port       int
+	Timeout    time.Duration
+	TLS        bool
+	SkipVerify bool
+	Username   string
+	Password   string
+	Send       string // Generic probe payload, escape sequences (\r\n) are expanded
+	Expect     string // Regular expression the response must match
+}
+
+// Result describes what the prober learned about the service
+type Result struct {
+	Protocol string
+	Banner   string
+	Latency  time.Duration
+	Details  map[string]any
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]Prober{}
+)
+
+// Register makes a prober available under name. Registering the same name twice replaces the prober.
+func Register(name string, prober Prober) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry[strings.ToLower(name)] = prober
+}
+
+// Get returns the prober registered under name
+func Get(name string) (Prober, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	p, ok := registry[strings.ToLower(name)]
+	return p, ok
+}
+
+// Names returns the registered prober names
+func Names() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Run dials host:port and executes the named prober within its timeout budget
+func Run(ctx context.Context, name string, opts Options) (Result, error) {
+	prober, ok := Get(name)
+	if !ok {
+		return Result{Protocol: name}, fmt.Errorf("unknown protocol probe %q (available: %s)", name, strings.Join(Names(), ", "))
+	}
+
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = prober.DefaultTimeout()
+	}
+	opts.Timeout = timeout
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	address := net.JoinHostPort(opts.Host, strconv.Itoa(opts.Port))
+
+	dialer := &net.Dialer{}
+	var conn net.Conn
+	var err error
+	if opts.TLS {
+		tlsDialer := &tls.Dialer{
+			NetDialer: dialer,
+			Config:    &tls.Config{ServerName: opts.Host, InsecureSkipVerify: opts.SkipVerify},
+		}
+		conn, err = tlsDialer.DialContext(ctx, "tcp", address)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", address)
+	}
+	if err != nil {
+		return Result{Protocol: name}, fmt.Errorf("connection to %s failed: %v", address, err)
+	}
+	defer conn.Close()
+
+	deadline, _ := ctx.Deadline()
+	if err := conn.SetDeadline(deadline); err != nil {
+		return Result{Protocol: name}, err
+	}
+
+	result, err := prober.Probe(ctx, conn, opts)
+	result.Protocol = name
+	result.Latency = time.Since(start)
+	if err != nil {
+		return result, fmt.Errorf("%s probe failed: %v", name, err)
+	}
+
+	return result, nil
+}
+
+// readLine reads a single CRLF/LF terminated line
+func readLine(conn net.Conn) (string, error) {
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil && line == "" {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// bannerProber checks that the server greets the client with a line starting with prefix
+type bannerProber struct {
+	prefix  string
+	timeout time.Duration
+	quit    string
+}
+
+func (b bannerProber) Probe(_ context.Context, conn net.Conn, opts Options) (Result, error) {
+	banner, err := readLine(conn)
+	if err != nil {
+		return Result{}, fmt.Errorf("no banner received: %v", err)
+	}
+
+	result := Result{Banner: banner}
+	if !strings.HasPrefix(banner, b.prefix) {
+		return result, fmt.Errorf("unexpected banner %q", banner)
+	}
+
+	if opts.Expect != "" {
+		if err := matchExpect(opts.Expect, banner); err != nil {
+			return result, err
+		}
+	}
+
+	if b.quit != "" {
+		_, _ = conn.Write([]byte(b.quit))
+	}
+
+	return result, nil
+}
+
+func (b bannerProber) DefaultTimeout() time.Duration {
+	return b.timeout
+}
+
+// genericProber sends an optional payload and matches the response against a regular expression
+type genericProber struct{}
+
+func (genericProber) Probe(_ context.Context, conn net.Conn, opts Options) (Result, error) {
+	if opts.Send != "" {
+		if _, err := conn.Write([]byte(unescape(opts.Send))); err != nil {
+			return Result{}, fmt.Errorf("failed to send payload: %v", err)
+		}
+	}
+
+	if opts.Expect == "" {
+		return Result{}, nil
+	}
+
+	buf := make([]byte, 4096)
+	n, err := conn.Read(buf)
+	if err != nil {
+		return Result{}, fmt.Errorf("no response received: %v", err)
+	}
+
+	response := string(buf[:n])
+	result := Result{Banner: strings.TrimSpace(response)}
+	return result, matchExpect(opts.Expect, response)
+}
+
+func (genericProber) DefaultTimeout() time.Duration {
+	return 5 * time.Second
+}
+
+func matchExpect(expect, response string) error {
+	re, err := regexp.Compile(expect)
+	if err != nil {
+		return fmt.Errorf("invalid expect pattern %q: %v", expect, err)
+	}
+	if !re.MatchString(response) {
+		return fmt.Errorf("response %q does not match %q", strings.TrimSpace(response), expect)
+	}
+	return nil
+}
+
+func unescape(s string) string {
+	if unquoted, err := strconv.Unquote(`"` + strings.ReplaceAll(s, `"`, `\"`) + `"`); err == nil {
+		return unquoted
+	}
+	return s
+}
+
+func init() {
+	Register("ssh", bannerProber{prefix: "SSH-", timeout: 5 * time.Second})
+	Register("smtp", bannerProber{prefix: "220", timeout: 10 * time.Second, quit: "QUIT\r\n"})
+	Register("ftp", bannerProber{prefix: "220", timeout: 10 * time.Second, quit: "QUIT\r\n"})
+	Register("imap", bannerProber{prefix: "* OK", timeout: 10 * time.Second, quit: "a1 LOGOUT\r\n"})
+	Register("pop3", bannerProber{prefix: "+OK", timeout: 10 * time.Second, quit: "QUIT\r\n"})
+	Register("generic", genericProber{})
+}
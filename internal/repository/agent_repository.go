@@ -146,12 +146,14 @@ func (a *AgentRepository) GetAgentContainerStats(agentHttpClient *http.Client, a
 		return mstypes.AgentContainerResponse{}, err
 	}
 
+	if resp.StatusCode != http.StatusOK {
+		return mstypes.AgentContainerResponse{}, fmt.Errorf("agent container API returned status %d", resp.StatusCode)
+	}
+
 	var apiResponse mstypes.AgentContainerResponse
 	if err_ := json.Unmarshal(body, &apiResponse); err_ != nil {
-		return mstypes.AgentContainerResponse{}, err
+		return mstypes.AgentContainerResponse{}, err_
 	}
 
-	log.Println("Agent Threshold API response", apiResponse)
-
 	return apiResponse, nil
 }
@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+)
+
+// FetchServiceDependencies returns the parents recorded for source, keyed by the child's SystemMonitorId
+func FetchServiceDependencies(db *sql.DB, source string) (map[string][]string, error) {
+	rows, err := db.Query(`SELECT "ChildId", "ParentId" FROM "ServiceDependencies" WHERE "Source" = $1`, source)
+	if err != nil {
+		return nil, fmt.Errorf("error querying service dependencies: %v", err)
+	}
+	defer rows.Close()
+
+	parents := make(map[string][]string)
+	for rows.Next() {
+		var child, parent string
+		if err := rows.Scan(&child, &parent); err != nil {
+			return nil, fmt.Errorf("error scanning service dependency: %v", err)
+		}
+		parents[child] = append(parents[child], parent)
+	}
+
+	return parents, rows.Err()
+}
+
+// ReplaceServiceDependencies swaps the parents recorded for source with the given ones
+func ReplaceServiceDependencies(db *sql.DB, source string, parents map[string][]string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		log.Printf("Error starting ReplaceServiceDependencies transaction: %v", err)
+		return err
+	}
+
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM "ServiceDependencies" WHERE "Source" = $1`, source); err != nil {
+		return fmt.Errorf("error clearing service dependencies: %v", err)
+	}
+
+	stmt, err := tx.Prepare(`
+		INSERT INTO "ServiceDependencies" ("ChildId", "ParentId", "Source", "UpdatedAt")
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT DO NOTHING
+	`)
+	if err != nil {
+		return fmt.Errorf("error preparing service dependency insert: %v", err)
+	}
+	defer stmt.Close()
+
+	now := time.Now()
+	for child, list := range parents {
+		for _, parent := range list {
+			if _, err := stmt.Exec(child, parent, source, now); err != nil {
+				return fmt.Errorf("error saving dependency of %s on %s: %v", child, parent, err)
+			}
+		}
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,86 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+
+	mstypes "github.com/ZEGIFTED/MS.GoMonitor/types"
+)
+
+// FetchDiscoveredHosts returns every host recorded by previous discovery runs
+func FetchDiscoveredHosts(db *sql.DB) ([]mstypes.DiscoveredHost, error) {
+	rows, err := db.Query(`SELECT "Details", "Present", "FirstSeen", "LastSeen" FROM "DiscoveredHosts"`)
+	if err != nil {
+		return nil, fmt.Errorf("error querying discovered hosts: %v", err)
+	}
+	defer rows.Close()
+
+	var hosts []mstypes.DiscoveredHost
+	for rows.Next() {
+		var details []byte
+		var host mstypes.DiscoveredHost
+		if err := rows.Scan(&details, &host.Present, &host.FirstSeen, &host.LastSeen); err != nil {
+			return nil, fmt.Errorf("error scanning discovered host: %v", err)
+		}
+		present, firstSeen, lastSeen := host.Present, host.FirstSeen, host.LastSeen
+		if err := json.Unmarshal(details, &host); err != nil {
+			log.Printf("Skipping discovered host with invalid details: %v", err)
+			continue
+		}
+		host.Present, host.FirstSeen, host.LastSeen = present, firstSeen, lastSeen
+		hosts = append(hosts, host)
+	}
+
+	return hosts, rows.Err()
+}
+
+// SyncDiscoveredHosts inserts new hosts and updates the ones seen before
+func SyncDiscoveredHosts(db *sql.DB, hosts []mstypes.DiscoveredHost) error {
+	if len(hosts) == 0 {
+		return nil
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		log.Printf("Error starting SyncDiscoveredHosts transaction: %v", err)
+		return err
+	}
+
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`
+		INSERT INTO "DiscoveredHosts" ("Address", "Range", "SystemMonitorId", "Present", "Details", "FirstSeen", "LastSeen")
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT ("Address") DO UPDATE SET
+			"Range" = EXCLUDED."Range",
+			"SystemMonitorId" = EXCLUDED."SystemMonitorId",
+			"Present" = EXCLUDED."Present",
+			"Details" = EXCLUDED."Details",
+			"LastSeen" = EXCLUDED."LastSeen"
+	`)
+	if err != nil {
+		return fmt.Errorf("error preparing discovered host upsert: %v", err)
+	}
+	defer stmt.Close()
+
+	for _, host := range hosts {
+		details, err := json.Marshal(host)
+		if err != nil {
+			return fmt.Errorf("error encoding discovered host %s: %v", host.Address, err)
+		}
+
+		var systemMonitorId sql.NullString
+		if host.SystemMonitorId != "" {
+			systemMonitorId = sql.NullString{String: host.SystemMonitorId, Valid: true}
+		}
+
+		_, err = stmt.Exec(host.Address, host.Range, systemMonitorId, host.Present, details, host.FirstSeen, host.LastSeen)
+		if err != nil {
+			return fmt.Errorf("error saving discovered host %s: %v", host.Address, err)
+		}
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,46 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+
+	mstypes "github.com/ZEGIFTED/MS.GoMonitor/types"
+)
+
+func SyncFlowAggregates(db *sql.DB, aggregates []mstypes.FlowAggregate) error {
+	if len(aggregates) == 0 {
+		return nil
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		log.Printf("Error starting SyncFlowAggregates transaction: %v", err)
+		return err
+	}
+
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`
+		INSERT INTO "FlowAggregates" ("SystemMonitorId", "Exporter", "IfIndex", "Direction", "Dimension", "Key", "Bytes", "Packets", "WindowStart", "WindowEnd")
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	`)
+	if err != nil {
+		return fmt.Errorf("error preparing flow aggregate insert: %v", err)
+	}
+	defer stmt.Close()
+
+	for _, a := range aggregates {
+		var systemMonitorId sql.NullString
+		if a.SystemMonitorId != "" {
+			systemMonitorId = sql.NullString{String: a.SystemMonitorId, Valid: true}
+		}
+
+		_, err = stmt.Exec(systemMonitorId, a.Exporter, a.IfIndex, a.Direction, a.Dimension, a.Key, a.Bytes, a.Packets, a.WindowStart, a.WindowEnd)
+		if err != nil {
+			return fmt.Errorf("error inserting flow aggregate for %s: %v", a.Exporter, err)
+		}
+	}
+
+	return tx.Commit()
+}
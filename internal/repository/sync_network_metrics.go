@@ -2,9 +2,9 @@ package repository
 
 import (
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"log"
-	"strings"
 
 	mstypes "github.com/ZEGIFTED/MS.GoMonitor/types"
 )
@@ -35,8 +35,8 @@ func SyncNetworkMetrics(db *sql.DB, Metrics []mstypes.NetworkDeviceMetric) error
 
 	netSyncQuery := `
 		MERGE INTO NetworkDeviceMetricData AS target
-		USING (VALUES %s) AS source (SystemMonitorId, DeviceName, MetricName, DeviceIP, MetricDescription, MetricValue, LastPoll)
-		ON target.DeviceIP = source.DeviceIP
+		USING (VALUES ($1, $2, $3, $4, $5, $6, CAST($7 AS DATETIME))) AS source (SystemMonitorId, DeviceName, MetricName, DeviceIP, MetricDescription, MetricValue, LastPoll)
+		ON target.DeviceIP = source.DeviceIP AND target.MetricName = source.MetricName
 		WHEN MATCHED THEN
 			UPDATE SET 
 			SystemMonitorId = source.SystemMonitorId,
@@ -58,28 +58,67 @@ func SyncNetworkMetrics(db *sql.DB, Metrics []mstypes.NetworkDeviceMetric) error
 			source.LastPoll);
 	`
 
-	// Prepare the metric values for the query
-	var metricValues []string
+	if len(Metrics) == 0 {
+		return tx.Commit()
+	}
+
+	stmt, err := tx.Prepare(netSyncQuery)
+	if err != nil {
+		return fmt.Errorf("error preparing network device metric upsert: %v", err)
+	}
+	defer stmt.Close()
+
 	for _, metric := range Metrics {
-		metricValues = append(metricValues, fmt.Sprintf("('%s', '%s', '%s', '%s', '%s', '%s', CAST('%s' AS DATETIME))",
+		_, err = stmt.Exec(
 			metric.SystemMonitorId,
 			metric.DeviceName,
 			metric.MetricName,
 			metric.DeviceIP,
 			metric.MetricDescription,
-			metric.MetricValue,
+			fmt.Sprint(metric.MetricValue),
 			metric.LastPoll,
-		))
+		)
+		if err != nil {
+			return fmt.Errorf("error upserting network device metric %s: %v", metric.MetricName, err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// SyncNetworkTableMetrics stores per-row samples of SNMP tables with their labels
+func SyncNetworkTableMetrics(db *sql.DB, metrics []mstypes.NetworkTableMetric) error {
+	if len(metrics) == 0 {
+		return nil
 	}
 
-	for v, i := range metricValues {
-		log.Println(v, i)
+	// Begin Sync Transaction
+	tx, err := db.Begin()
+	if err != nil {
+		log.Printf("Error starting SyncNetworkTableMetrics transaction: %v", err)
+		return err
+	}
+
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`
+		INSERT INTO "NetworkMetricData" ("SystemMonitorId", "DeviceIP", "MetricName", "Labels", "Value", "CollectedAt")
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`)
+	if err != nil {
+		return fmt.Errorf("error preparing network table metric insert: %v", err)
 	}
+	defer stmt.Close()
+
+	for _, m := range metrics {
+		labels, err := json.Marshal(m.Labels)
+		if err != nil {
+			return fmt.Errorf("error encoding labels of %s: %v", m.MetricName, err)
+		}
 
-	if len(metricValues) > 0 {
-		_, err = tx.Exec(fmt.Sprintf(netSyncQuery, strings.Join(metricValues, ",")))
+		_, err = stmt.Exec(m.SystemMonitorId, m.DeviceIP, m.MetricName, string(labels), m.Value, m.CollectedAt)
 		if err != nil {
-			return fmt.Errorf("error upserting network device metrics: %v", err)
+			return fmt.Errorf("error inserting network table metric %s: %v", m.MetricName, err)
 		}
 	}
 
@@ -0,0 +1,46 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	mstypes "github.com/ZEGIFTED/MS.GoMonitor/types"
+)
+
+func SyncNetworkPath(db *sql.DB, path mstypes.NetworkPathRecord) error {
+	_, err := db.Exec(`
+		INSERT INTO "NetworkPathHistory" ("SystemMonitorId", "Destination", "Address", "Fingerprint", "Hops", "Reached", "CollectedAt")
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`, path.SystemMonitorId, path.Destination, path.Address, path.Fingerprint, path.Hops, path.Reached, path.CollectedAt)
+
+	if err != nil {
+		return fmt.Errorf("error inserting network path for %s: %v", path.Destination, err)
+	}
+
+	return nil
+}
+
+// FetchLastNetworkPath returns the most recently stored path for a service and destination.
+// The boolean is false when no path has been recorded yet.
+func FetchLastNetworkPath(db *sql.DB, systemMonitorId, destination string) (mstypes.NetworkPathRecord, bool, error) {
+	path := mstypes.NetworkPathRecord{SystemMonitorId: systemMonitorId, Destination: destination}
+
+	err := db.QueryRow(`
+		SELECT "Address", "Fingerprint", "Hops", "Reached", "CollectedAt"
+		FROM "NetworkPathHistory"
+		WHERE "SystemMonitorId" = $1 AND "Destination" = $2
+		ORDER BY "CollectedAt" DESC
+		LIMIT 1
+	`, systemMonitorId, destination).Scan(&path.Address, &path.Fingerprint, &path.Hops, &path.Reached, &path.CollectedAt)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return path, false, nil
+	}
+
+	if err != nil {
+		return path, false, fmt.Errorf("error fetching last network path for %s: %v", destination, err)
+	}
+
+	return path, true, nil
+}
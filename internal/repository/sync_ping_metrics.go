@@ -0,0 +1,42 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+
+	mstypes "github.com/ZEGIFTED/MS.GoMonitor/types"
+)
+
+func SyncPingMetrics(db *sql.DB, metrics []mstypes.PingMetric) error {
+	if len(metrics) == 0 {
+		return nil
+	}
+
+	// Begin Sync Transaction
+	tx, err := db.Begin()
+	if err != nil {
+		log.Printf("Error starting SyncPingMetrics transaction: %v", err)
+		return err
+	}
+
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`
+		INSERT INTO "PingMetricData" ("SystemMonitorId", "Target", "Address", "Sent", "Received", "PacketLoss", "MinRtt", "AvgRtt", "MaxRtt", "Jitter", "CollectedAt")
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+	`)
+	if err != nil {
+		return fmt.Errorf("error preparing ping metric insert: %v", err)
+	}
+	defer stmt.Close()
+
+	for _, m := range metrics {
+		_, err = stmt.Exec(m.SystemMonitorId, m.Target, m.Address, m.Sent, m.Received, m.PacketLoss, m.MinRTT, m.AvgRTT, m.MaxRTT, m.Jitter, m.CollectedAt)
+		if err != nil {
+			return fmt.Errorf("error inserting ping metrics for %s: %v", m.Target, err)
+		}
+	}
+
+	return tx.Commit()
+}
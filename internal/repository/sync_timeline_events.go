@@ -0,0 +1,41 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+
+	mstypes "github.com/ZEGIFTED/MS.GoMonitor/types"
+)
+
+func SyncServiceTimelineEvents(db *sql.DB, events []mstypes.ServiceTimelineEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		log.Printf("Error starting SyncServiceTimelineEvents transaction: %v", err)
+		return err
+	}
+
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`
+		INSERT INTO "ServiceTimelineEvents" ("SystemMonitorId", "Source", "Severity", "Title", "Message", "OccurredAt")
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`)
+	if err != nil {
+		return fmt.Errorf("error preparing timeline event insert: %v", err)
+	}
+	defer stmt.Close()
+
+	for _, e := range events {
+		_, err = stmt.Exec(e.SystemMonitorId, e.Source, e.Severity, e.Title, e.Message, e.OccurredAt)
+		if err != nil {
+			return fmt.Errorf("error inserting timeline event for %s: %v", e.SystemMonitorId, err)
+		}
+	}
+
+	return tx.Commit()
+}
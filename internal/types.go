@@ -18,4 +18,6 @@ type ServiceAlertEvent struct {
 	AgentRepository repository.AgentRepository
 	ServiceStats    mstypes.ProcessResponse
 	AgentAPI        string
+	NetworkPath     []string // Last traced hops towards the service, if path monitoring is enabled
+	ObjectRef       string   // Object inside the service the alert is about (Kind/namespace/name), if any
 }
@@ -0,0 +1,141 @@
+package monitors
+
+import (
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ZEGIFTED/MS.GoMonitor/pkg/utils"
+)
+
+// maxDependencyDepth bounds the walk up the parent chain, parent relationships may contain loops
+const maxDependencyDepth = 16
+
+// DependencyTracker knows the parents of every service and which services are down, so alerts for a
+// service behind a failed parent can be suppressed. Parents configured on a service win over the ones
+// derived from the network topology.
+type DependencyTracker struct {
+	mu       sync.RWMutex
+	manual   map[string][]string  // SystemMonitorId to parent SystemMonitorIds from the "parents" configuration
+	topology map[string][]string  // SystemMonitorId to parent SystemMonitorIds derived from the topology
+	down     map[string]time.Time // SystemMonitorId to the time it was first seen failing
+}
+
+func NewDependencyTracker() *DependencyTracker {
+	return &DependencyTracker{
+		manual:   make(map[string][]string),
+		topology: make(map[string][]string),
+		down:     make(map[string]time.Time),
+	}
+}
+
+// SetManualParents reads the "parents" list of every service, entries are SystemMonitorIds or service
+// names
+func (d *DependencyTracker) SetManualParents(services []ServiceMonitorData) {
+	byName := make(map[string]string, len(services))
+	for _, service := range services {
+		byName[strings.ToLower(service.Name)] = service.SystemMonitorId.String()
+	}
+
+	manual := make(map[string][]string)
+	for _, service := range services {
+		for _, parent := range utils.ConfigStringSlice(service.Configuration, "parents") {
+			if id, ok := byName[strings.ToLower(parent)]; ok {
+				parent = id
+			}
+			if parent != service.SystemMonitorId.String() {
+				manual[service.SystemMonitorId.String()] = append(manual[service.SystemMonitorId.String()], parent)
+			}
+		}
+	}
+
+	d.mu.Lock()
+	d.manual = manual
+	d.mu.Unlock()
+}
+
+// SetTopologyParents replaces the parents derived from the network topology
+func (d *DependencyTracker) SetTopologyParents(parents map[string][]string) {
+	d.mu.Lock()
+	d.topology = parents
+	d.mu.Unlock()
+}
+
+// TopologyParents returns a copy of the parents derived from the network topology
+func (d *DependencyTracker) TopologyParents() map[string][]string {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	parents := make(map[string][]string, len(d.topology))
+	for id, p := range d.topology {
+		parents[id] = append([]string(nil), p...)
+	}
+	return parents
+}
+
+// Parents returns the direct parents of a service
+func (d *DependencyTracker) Parents(id string) []string {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.parents(id)
+}
+
+func (d *DependencyTracker) parents(id string) []string {
+	if parents, ok := d.manual[id]; ok {
+		return parents
+	}
+	return d.topology[id]
+}
+
+func (d *DependencyTracker) MarkDown(id string) {
+	d.mu.Lock()
+	if _, ok := d.down[id]; !ok {
+		d.down[id] = time.Now()
+	}
+	d.mu.Unlock()
+}
+
+func (d *DependencyTracker) MarkUp(id string) {
+	d.mu.Lock()
+	delete(d.down, id)
+	d.mu.Unlock()
+}
+
+// FailedAncestor returns the closest parent, grandparent and so on of a service that is down
+func (d *DependencyTracker) FailedAncestor(id string) (string, bool) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	visited := map[string]bool{id: true}
+	level := d.parents(id)
+	for depth := 0; depth < maxDependencyDepth && len(level) > 0; depth++ {
+		var next []string
+		for _, parent := range level {
+			if visited[parent] {
+				continue
+			}
+			visited[parent] = true
+
+			if _, down := d.down[parent]; down {
+				return parent, true
+			}
+			next = append(next, d.parents(parent)...)
+		}
+		level = next
+	}
+	return "", false
+}
+
+// suppressedByParent reports whether alerts for service are hidden because a parent is down, the
+// parent's own alert already covers the outage
+func (sm *MonitoringEngine) suppressedByParent(service ServiceMonitorData) bool {
+	if sm.Dependencies == nil {
+		return false
+	}
+	parent, ok := sm.Dependencies.FailedAncestor(service.SystemMonitorId.String())
+	if ok {
+		slog.Warn("Alert suppressed, parent is down", "service", service.Name, "parent", parent)
+	}
+	return ok
+}
@@ -16,6 +16,7 @@ import (
 	"time"
 
 	"github.com/ZEGIFTED/MS.GoMonitor/internal"
+	"github.com/ZEGIFTED/MS.GoMonitor/internal/network"
 	"github.com/ZEGIFTED/MS.GoMonitor/notifier"
 	"github.com/ZEGIFTED/MS.GoMonitor/pkg/constants"
 	"github.com/ZEGIFTED/MS.GoMonitor/pkg/utils"
@@ -48,6 +49,15 @@ func (sm *MonitoringEngine) StartEngine() error {
 			interval = constants.DefaultCronExpression
 		}
 
+		// Push-based services describe the job schedule in CheckInterval, the engine only looks for missed heartbeats
+		if IsHeartbeatService(serviceCopy) && sm.Heartbeats != nil {
+			if err := sm.Heartbeats.Register(serviceCopy); err != nil {
+				log.Printf("Failed to register heartbeat for service %s: %v", serviceCopy.Name, err)
+				continue
+			}
+			interval = heartbeatEvaluationInterval
+		}
+
 		_, err := sm.Cron.AddFunc(interval, func() {
 			// Check if context is cancelled before running check
 			if sm.Ctx.Err() != nil {
@@ -61,6 +71,44 @@ func (sm *MonitoringEngine) StartEngine() error {
 		}
 	}
 
+	if sm.Traps != nil {
+		sm.MU.RLock()
+		services := append([]ServiceMonitorData(nil), sm.Services...)
+		sm.MU.RUnlock()
+
+		go func() {
+			if err := sm.Traps.Start(services); err != nil {
+				slog.Error("SNMP trap receiver stopped", "error", err)
+			}
+		}()
+	}
+
+	if sm.Syslog != nil {
+		sm.MU.RLock()
+		services := append([]ServiceMonitorData(nil), sm.Services...)
+		sm.MU.RUnlock()
+
+		if err := sm.Syslog.Start(services); err != nil {
+			slog.Error("Syslog receiver not started", "error", err)
+		}
+	}
+
+	if sm.Topology != nil {
+		if err := sm.Topology.LoadDependencies(); err != nil {
+			log.Printf("Failed to load topology dependencies: %v", err)
+		}
+
+		collect := func() {
+			if _, err := sm.Topology.Run(sm.Ctx); err != nil {
+				slog.Warn("Topology collection failed", "error", err)
+			}
+		}
+		if _, err := sm.Cron.AddFunc(sm.Topology.Schedule, collect); err != nil {
+			log.Printf("Failed to schedule topology collection: %v", err)
+		}
+		go collect()
+	}
+
 	sm.Cron.Start()
 	sm.AlertHandler()
 	return nil
@@ -74,6 +122,12 @@ func (sm *MonitoringEngine) StopEngine() {
 
 	// Stop the cron scheduler
 	ctx := sm.Cron.Stop()
+	if sm.Traps != nil {
+		sm.Traps.Stop()
+	}
+	if sm.Syslog != nil {
+		sm.Syslog.Stop()
+	}
 	close(sm.Alerts)
 
 	// Cleanup all plugins
@@ -118,6 +172,10 @@ func (sm *MonitoringEngine) LoadServiceInventory() error {
 	sm.Services = services
 	sm.MU.Unlock()
 
+	if sm.Dependencies != nil {
+		sm.Dependencies.SetManualParents(services)
+	}
+
 	// Initialize plugins for all services
 	for _, service := range services {
 		if err := sm.initializeServicePlugins(service); err != nil {
@@ -384,11 +442,33 @@ func (sm *MonitoringEngine) CheckServices(service ServiceMonitorData) {
 	}
 	defer tx.Rollback()
 
-	mainStatus, err := sm.DefaultHealth.Check(sm.Ctx, sm.Db, service)
-	if err != nil {
-		log.Printf("[ERROR] Default check failed for %s: %v", service.Name, err)
-		sm.handleServiceFailure(service, mainStatus, err)
-		return
+	var mainStatus MonitoringResult
+	if IsHeartbeatService(service) {
+		// Nothing to connect to, the heartbeat plugin decides the health of push-based services
+		mainStatus = MonitoringResult{
+			SystemMonitorId: service.SystemMonitorId.String(),
+			ServicePluginID: HeartbeatPluginName,
+			HealthReport:    constants.GetStatusInfo(constants.Healthy, ""),
+			LastCheckTime:   time.Now(),
+		}
+	} else if sm.skipsTCPPrecheck(service) {
+		// ICMP and UDP services may have no TCP port open, their plugins decide whether they are reachable
+		mainStatus = MonitoringResult{
+			SystemMonitorId: service.SystemMonitorId.String(),
+			ServicePluginID: sm.DefaultHealth.Name(),
+			HealthReport:    constants.GetStatusInfo(constants.Healthy, ""),
+			LastCheckTime:   time.Now(),
+		}
+	} else {
+		mainStatus, err = sm.DefaultHealth.Check(sm.Ctx, sm.Db, service)
+		if err != nil {
+			log.Printf("[ERROR] Default check failed for %s: %v", service.Name, err)
+			if sm.Dependencies != nil {
+				sm.Dependencies.MarkDown(service.SystemMonitorId.String())
+			}
+			sm.handleServiceFailure(service, mainStatus, err)
+			return
+		}
 	}
 
 	var pluginStatuses []MonitoringResult
@@ -408,7 +488,9 @@ func (sm *MonitoringEngine) CheckServices(service ServiceMonitorData) {
 		}
 
 		pluginStatus, err := plugin.Check(sm.Ctx, sm.Db, service)
-		if err != nil {
+		if err != nil && len(pluginStatus.ObjectAlerts) > 0 {
+			sm.handleObjectAlerts(service, pluginStatus.ObjectAlerts)
+		} else if err != nil {
 			// PLUGIN [%s] CHECK Failed for %s.
 			sm.handleServiceFailure(service, pluginStatus, err)
 		}
@@ -418,10 +500,28 @@ func (sm *MonitoringEngine) CheckServices(service ServiceMonitorData) {
 	}
 
 	finalStatus := sm.mergeStatuses(&mainStatus, pluginStatuses)
+	// Dependents are suppressed on the merged result, a failing plugin takes the service down as well
+	if sm.Dependencies != nil {
+		if sm.isFailureStatus(finalStatus.HealthReport) {
+			sm.Dependencies.MarkDown(service.SystemMonitorId.String())
+		} else {
+			sm.Dependencies.MarkUp(service.SystemMonitorId.String())
+		}
+	}
 	sm.updateDatabase(tx, uuid.New(), service, finalStatus, pluginStatuses)
 	sm.StatusTracking.Store(service.Name, finalStatus)
 }
 
+// skipsTCPPrecheck reports whether one of the service's plugins does not need a TCP connection
+func (sm *MonitoringEngine) skipsTCPPrecheck(service ServiceMonitorData) bool {
+	for _, pluginName := range service.Plugins {
+		if plugin, ok := sm.Plugins[pluginName].(PrecheckExempt); ok && plugin.SkipsTCPPrecheck() {
+			return true
+		}
+	}
+	return false
+}
+
 func (sm *MonitoringEngine) isFailureStatus(status constants.StatusInfo) bool {
 	slog.Info("Checking if Engine Should Fail Service", "Flag", status.Flag)
 	return status.Flag != 1
@@ -530,6 +630,10 @@ func (sm *MonitoringEngine) handleServiceFailure(service ServiceMonitorData, cur
 		currentStatus.HealthReport = constants.GetStatusInfo(constants.Escalation, err.Error())
 	}
 
+	if sm.suppressedByParent(service) {
+		return
+	}
+
 	// Throttle alerts to avoid alert fatigue
 	if lastAlert, ok := sm.AlertCache.Load(serviceAlertIdentifier); !ok || func() bool {
 		lastAlertTime, valid := lastAlert.(time.Time)
@@ -537,7 +641,7 @@ func (sm *MonitoringEngine) handleServiceFailure(service ServiceMonitorData, cur
 	}() {
 		log.Println(serviceAlertIdentifier, currentStatus.FailureCount, constants.FailureThresholdCount, service.IsAcknowledged)
 		if currentStatus.FailureCount > constants.FailureThresholdCount && !service.IsAcknowledged {
-			sm.Alerts <- internal.ServiceAlertEvent{
+			alert := internal.ServiceAlertEvent{
 				SystemMonitorId: service.SystemMonitorId,
 				ServiceName:     service.Name,
 				Message:         fmt.Sprintf("%s is down: %s", service.Name, err.Error()),
@@ -547,6 +651,12 @@ func (sm *MonitoringEngine) handleServiceFailure(service ServiceMonitorData, cur
 				AgentAPI:        service.AgentAPIBaseURL,
 			}
 
+			if path, ok := network.LastPath(service.SystemMonitorId.String()); ok {
+				alert.NetworkPath = path.Summary()
+			}
+
+			sm.Alerts <- alert
+
 			serviceAlertMessage := notifier.NotiferEvent{
 				Title:      fmt.Sprintf("%s is down", service.Name),
 				Identifier: serviceAlertIdentifier,
@@ -564,6 +674,46 @@ func (sm *MonitoringEngine) handleServiceFailure(service ServiceMonitorData, cur
 	}
 }
 
+// handleObjectAlerts raises one alert per unhealthy object reported by a plugin, throttled per object
+func (sm *MonitoringEngine) handleObjectAlerts(service ServiceMonitorData, objects []ObjectAlert) {
+	if service.IsAcknowledged || sm.suppressedByParent(service) {
+		return
+	}
+
+	for _, object := range objects {
+		objectAlertIdentifier := service.SystemMonitorId.String() + "|" + service.Name + "|" + object.Reference() + "|" + object.Reason
+
+		if lastAlert, ok := sm.AlertCache.Load(objectAlertIdentifier); ok {
+			if lastAlertTime, valid := lastAlert.(time.Time); valid && time.Since(lastAlertTime) < constants.AlertThrottleTime {
+				continue
+			}
+		}
+		sm.AlertCache.Store(objectAlertIdentifier, time.Now())
+
+		message := fmt.Sprintf("%s %s: %s", object.Reference(), object.Reason, object.Message)
+		slog.Error("Service Object Unhealthy", "service", service.Name, "object", object.Reference(), "reason", object.Reason)
+
+		sm.Alerts <- internal.ServiceAlertEvent{
+			SystemMonitorId: service.SystemMonitorId,
+			ServiceName:     service.Name,
+			Message:         fmt.Sprintf("%s: %s", service.Name, message),
+			Device:          string(service.Device),
+			Severity:        object.Severity,
+			Timestamp:       time.Now(),
+			AgentRepository: service.AgentRepository,
+			AgentAPI:        service.AgentAPIBaseURL,
+			ObjectRef:       object.Reference(),
+		}
+
+		notifier.SendNotification(notifier.NotiferEvent{
+			Title:      fmt.Sprintf("%s %s is unhealthy", service.Name, object.Reference()),
+			Identifier: objectAlertIdentifier,
+			Message:    message,
+			Timestamp:  time.Now().Format(time.RFC3339),
+		})
+	}
+}
+
 func (sm *MonitoringEngine) handleServiceRecovery(service ServiceMonitorData, currentStatus *MonitoringResult) *MonitoringResult {
 	// Update status for healthy service
 	currentStatus.HealthReport = constants.GetStatusInfo(constants.Healthy, "Service is healthy and working optimal")
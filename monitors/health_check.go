@@ -8,11 +8,11 @@ import (
 	"log/slog"
 	"net"
 	"strconv"
-
-	"strings"
 	"time"
 
+	"github.com/ZEGIFTED/MS.GoMonitor/internal/probes"
 	"github.com/ZEGIFTED/MS.GoMonitor/pkg/constants"
+	"github.com/ZEGIFTED/MS.GoMonitor/pkg/utils"
 )
 
 // DefaultChecker implements Basic Monitoring Logic
@@ -53,12 +53,24 @@ func (hc *HealthCheck) Check(ctx context.Context, db *sql.DB, service ServiceMon
 	}
 	conn.Close()
 
-	// err = hc.telnetCheck(service.Host, service.Port, timeout)
-	// if err != nil {
-	// 	status.FailureCount++
-	// 	status.HealthReport = constants.GetStatusInfo(constants.Escalation, "")
-	// 	return status, fmt.Errorf("telnet check failed: %v", err)
-	// }
+	// Optionally prove the expected protocol is answering behind the port
+	if probe := utils.ConfigString(service.Configuration, "probe", ""); probe != "" {
+		result, err := probes.Run(ctx, probe, probeOptions(service))
+		if err != nil {
+			status.FailureCount++
+			status.HealthReport = constants.GetStatusInfo(constants.Escalation, err.Error())
+			return status, err
+		}
+
+		status.Details = map[string]any{
+			"probe":      result.Protocol,
+			"banner":     result.Banner,
+			"latency_ms": float64(result.Latency.Microseconds()) / 1000,
+		}
+		for k, v := range result.Details {
+			status.Details[k] = v
+		}
+	}
 
 	status.FailureCount = 0
 	status.HealthReport = constants.GetStatusInfo(constants.Healthy, "")
@@ -66,68 +78,22 @@ func (hc *HealthCheck) Check(ctx context.Context, db *sql.DB, service ServiceMon
 	return status, nil
 }
 
-func (p *HealthCheck) telnetCheck(host string, port int, timeout time.Duration) error {
-	// Reduce dial timeout to 75% of total to leave room for read
-	dialTimeout := timeout * 75 / 100
-	conn, err := net.DialTimeout("tcp", fmt.Sprintf("%s:%d", host, port), dialTimeout)
-	if err != nil {
-		return err
-	}
-	defer conn.Close()
-
-	// Set remaining time for read
-	remainingTimeout := timeout - dialTimeout
-	if remainingTimeout <= 0 {
-		return fmt.Errorf("insufficient time remaining for read")
+// probeOptions reads the protocol probe settings of a service. The probe gets its own time budget
+// ("probeTimeout"), falling back to the prober default rather than the TCP dial timeout.
+func probeOptions(service ServiceMonitorData) probes.Options {
+	cfg := service.Configuration
+
+	return probes.Options{
+		Host:       service.Host,
+		Port:       service.Port,
+		Timeout:    utils.ConfigDuration(cfg, "probeTimeout", 0),
+		TLS:        utils.ConfigBool(cfg, "probeTLS", false),
+		SkipVerify: utils.ConfigBool(cfg, "probeSkipVerify", false),
+		Username:   utils.ConfigString(cfg, "probeUsername", ""),
+		Password:   utils.ConfigString(cfg, "probePassword", ""),
+		Send:       utils.ConfigString(cfg, "probeSend", ""),
+		Expect:     utils.ConfigString(cfg, "probeExpect", ""),
 	}
-	conn.SetReadDeadline(time.Now().Add(remainingTimeout))
-
-	// Service-specific handling
-	switch port {
-	case 22: // SSH
-		_, err = conn.Write([]byte("\n"))
-		if err != nil {
-			return err
-		}
-		buf := make([]byte, 1024)
-		_, err = conn.Read(buf)
-		if err != nil {
-			return err
-		}
-		if !strings.Contains(string(buf), "SSH") {
-			return fmt.Errorf("not an SSH service")
-		}
-
-	case 1433: // SQL Server
-		// SQL Server needs a TDS pre-login packet to respond
-		// Basic TDS pre-login header (simplified)
-		prelogin := []byte{
-			0x12, 0x01, 0x00, 0x34, 0x00, 0x00, 0x00, 0x00,
-			0x00, 0x00, 0x15, 0x00, 0x06, 0x01, 0x00, 0x20,
-			0x00, 0x01, 0x02, 0x00, 0x21, 0x00, 0x01, 0x03,
-			0x00, 0x22, 0x00, 0x04, 0x04, 0x00, 0x26, 0x00,
-			0x01, 0xff, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
-		}
-		_, err = conn.Write(prelogin)
-		if err != nil {
-			return err
-		}
-		buf := make([]byte, 1024)
-		_, err = conn.Read(buf)
-		if err != nil {
-			return err
-		}
-		// Check for TDS response (first byte should be 0x04)
-		if len(buf) > 0 && buf[0] != 0x04 {
-			return fmt.Errorf("not a SQL Server service")
-		}
-
-	default:
-		// Generic check - just verify TCP connectivity
-		return nil
-	}
-
-	return nil
 }
 
 // Name returns the name of the default checker
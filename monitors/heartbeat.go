@@ -0,0 +1,311 @@
+package monitors
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ZEGIFTED/MS.GoMonitor/pkg/constants"
+	"github.com/ZEGIFTED/MS.GoMonitor/pkg/utils"
+	"github.com/robfig/cron/v3"
+)
+
+// Heartbeat signals a job can send
+const (
+	HeartbeatStart   = "start"
+	HeartbeatSuccess = "success"
+	HeartbeatFail    = "fail"
+)
+
+// HeartbeatPluginName is the plugin a push-based service lists in its Plugins
+const HeartbeatPluginName = "heartbeat"
+
+// heartbeatEvaluationInterval is how often push-based services are checked for missed heartbeats
+const heartbeatEvaluationInterval = "@every 1m"
+
+type heartbeatState struct {
+	ServiceName  string
+	Schedule     cron.Schedule
+	ScheduleErr  error // CheckInterval could not be parsed, the job is reported as misconfigured
+	Grace        time.Duration
+	MaxRuntime   time.Duration
+	RegisteredAt time.Time
+
+	LastPing    time.Time
+	LastSuccess time.Time
+	LastStart   time.Time
+	LastSignal  string
+	LastRuntime time.Duration
+	LastMessage string
+}
+
+// HeartbeatRegistry receives heartbeats from jobs that cannot be polled (cron jobs, batch processes,
+// hosts behind one-way firewalls) and decides whether each job is late according to its CheckInterval.
+type HeartbeatRegistry struct {
+	mu     sync.RWMutex
+	tokens map[string]*heartbeatState
+}
+
+func NewHeartbeatRegistry() *HeartbeatRegistry {
+	return &HeartbeatRegistry{tokens: make(map[string]*heartbeatState)}
+}
+
+// IsHeartbeatService reports whether a service is push-based
+func IsHeartbeatService(service ServiceMonitorData) bool {
+	for _, p := range service.Plugins {
+		if p == HeartbeatPluginName {
+			return true
+		}
+	}
+	return false
+}
+
+// HeartbeatToken returns the token a service pings with, the SystemMonitorId unless "heartbeatToken" is set
+func HeartbeatToken(service ServiceMonitorData) string {
+	return utils.ConfigString(service.Configuration, "heartbeatToken", service.SystemMonitorId.String())
+}
+
+// Register starts expecting heartbeats for a service. State is kept in memory, so after an engine
+// restart every job gets a full period plus grace before it is considered late.
+func (h *HeartbeatRegistry) Register(service ServiceMonitorData) error {
+	expr := service.CheckInterval
+	if expr == "" {
+		expr = constants.DefaultCronExpression
+	}
+
+	// The schedule is the job's own, falling back to the default would raise late alerts at the wrong times
+	schedule, scheduleErr := cron.ParseStandard(expr)
+	if scheduleErr != nil {
+		scheduleErr = fmt.Errorf("invalid heartbeat schedule %q: %v", expr, scheduleErr)
+		slog.Warn("Heartbeat schedule is invalid", "service", service.Name, "error", scheduleErr)
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	token := HeartbeatToken(service)
+	state, ok := h.tokens[token]
+	if !ok {
+		state = &heartbeatState{RegisteredAt: time.Now()}
+		h.tokens[token] = state
+	}
+
+	state.ServiceName = service.Name
+	state.Schedule = schedule
+	state.ScheduleErr = scheduleErr
+	state.Grace = utils.ConfigDuration(service.Configuration, "heartbeatGrace", 5*time.Minute)
+	state.MaxRuntime = utils.ConfigDuration(service.Configuration, "heartbeatMaxRuntime", 0)
+
+	return nil
+}
+
+// Record stores a signal. runtime is optional, for success and fail signals it is derived from the
+// preceding start signal when the job does not report it.
+func (h *HeartbeatRegistry) Record(token, signal string, runtime time.Duration, message string) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	state, ok := h.tokens[token]
+	if !ok {
+		return fmt.Errorf("unknown heartbeat token")
+	}
+
+	now := time.Now()
+	state.LastPing = now
+	state.LastSignal = signal
+	state.LastMessage = message
+
+	switch signal {
+	case HeartbeatStart:
+		state.LastStart = now
+		return nil
+	case HeartbeatSuccess:
+		state.LastSuccess = now
+	}
+
+	if runtime == 0 && !state.LastStart.IsZero() {
+		runtime = now.Sub(state.LastStart)
+	}
+	state.LastRuntime = runtime
+	state.LastStart = time.Time{}
+
+	return nil
+}
+
+// Evaluate returns the status flag of the job behind token at the given time and a description of the problem
+func (h *HeartbeatRegistry) Evaluate(token string, now time.Time) (int, string, map[string]any) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	state, ok := h.tokens[token]
+	if !ok {
+		return constants.InvalidConfiguration, "heartbeat service is not registered", nil
+	}
+
+	if state.ScheduleErr != nil {
+		return constants.InvalidConfiguration, state.ScheduleErr.Error(), nil
+	}
+
+	details := map[string]any{
+		"last_signal":    state.LastSignal,
+		"last_ping":      state.LastPing,
+		"last_success":   state.LastSuccess,
+		"last_runtime_s": state.LastRuntime.Seconds(),
+	}
+
+	if state.LastSignal == HeartbeatFail {
+		message := "job reported failure"
+		if state.LastMessage != "" {
+			message += ": " + state.LastMessage
+		}
+		return constants.Degraded, message, details
+	}
+
+	if !state.LastStart.IsZero() && state.MaxRuntime > 0 && now.Sub(state.LastStart) > state.MaxRuntime {
+		return constants.Degraded, fmt.Sprintf("job started %v ago and has not finished (max runtime %v)",
+			now.Sub(state.LastStart).Round(time.Second), state.MaxRuntime), details
+	}
+
+	reference := state.LastSuccess
+	if reference.IsZero() {
+		reference = state.RegisteredAt
+	}
+
+	deadline := state.Schedule.Next(reference).Add(state.Grace)
+	details["expected_by"] = deadline
+
+	// A job that started before its deadline is given another grace period (plus its max runtime) to finish
+	if !state.LastStart.IsZero() && state.LastStart.After(reference) {
+		if running := state.LastStart.Add(state.Grace + state.MaxRuntime); running.After(deadline) {
+			deadline = running
+		}
+	}
+
+	if now.After(deadline) {
+		last := "never"
+		if !state.LastSuccess.IsZero() {
+			last = state.LastSuccess.Format(time.RFC3339)
+		}
+		return constants.Degraded, fmt.Sprintf("no heartbeat received since %s, expected by %s", last, deadline.Format(time.RFC3339)), details
+	}
+
+	return constants.Healthy, "", details
+}
+
+// ServeHTTP handles POST /api/v1/heartbeat/{token}[/start|/success|/fail]. The signal may also be
+// given as ?status=, the job runtime in seconds as ?runtime= and a short message as the request body.
+func (h *HeartbeatRegistry) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost && r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/v1/heartbeat"), "/"), "/")
+	token := parts[0]
+	if token == "" {
+		http.Error(w, "missing heartbeat token", http.StatusNotFound)
+		return
+	}
+
+	signal := HeartbeatSuccess
+	if len(parts) > 1 {
+		signal = parts[1]
+	}
+	if s := r.URL.Query().Get("status"); s != "" {
+		signal = s
+	}
+	if signal != HeartbeatStart && signal != HeartbeatSuccess && signal != HeartbeatFail {
+		http.Error(w, "unknown heartbeat signal", http.StatusBadRequest)
+		return
+	}
+
+	var runtime time.Duration
+	if v := r.URL.Query().Get("runtime"); v != "" {
+		seconds, err := strconv.ParseFloat(v, 64)
+		if err != nil || seconds < 0 {
+			http.Error(w, "invalid runtime", http.StatusBadRequest)
+			return
+		}
+		runtime = time.Duration(seconds * float64(time.Second))
+	}
+
+	body, _ := io.ReadAll(io.LimitReader(r.Body, 1024))
+	message := strings.TrimSpace(string(body))
+
+	if err := h.Record(token, signal, runtime, message); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	slog.Info("Heartbeat received", "signal", signal, "runtime", runtime)
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]string{"status": "ok", "signal": signal})
+}
+
+// HeartbeatPlugin reports push-based services as failed when their heartbeat is late or a failure was signalled
+type HeartbeatPlugin struct {
+	registry *HeartbeatRegistry
+}
+
+func NewHeartbeatPlugin(registry *HeartbeatRegistry) *HeartbeatPlugin {
+	return &HeartbeatPlugin{registry: registry}
+}
+
+func (p *HeartbeatPlugin) Check(ctx context.Context, db *sql.DB, service ServiceMonitorData) (MonitoringResult, error) {
+	status := MonitoringResult{
+		SystemMonitorId: service.SystemMonitorId.String(),
+		ServicePluginID: p.Name(),
+		HealthReport:    constants.GetStatusInfo(constants.UnknownStatus, ""),
+		LastCheckTime:   time.Now(),
+	}
+
+	level, message, details := p.registry.Evaluate(HeartbeatToken(service), time.Now())
+	status.Details = details
+
+	if level != constants.Healthy {
+		status.FailureCount++
+		status.HealthReport = constants.GetStatusInfo(level, message)
+		return status, fmt.Errorf("%s", message)
+	}
+
+	status.FailureCount = 0
+	status.HealthReport = constants.GetStatusInfo(constants.Healthy, "")
+	status.LastServiceUpTime = time.Now()
+
+	return status, nil
+}
+
+func (p *HeartbeatPlugin) Name() string {
+	return HeartbeatPluginName
+}
+
+func (p *HeartbeatPlugin) Description() string {
+	return "Push-based heartbeat monitor for cron jobs and batch processes"
+}
+
+func (p *HeartbeatPlugin) SupportedTypes() []ServiceType {
+	return []ServiceType{
+		ServiceMonitorAgent,
+		ServiceMonitorWebModules,
+		ServiceMonitorSNMP,
+		ServiceMonitorServer,
+		ServiceMonitorDatabase,
+	}
+}
+
+func (p *HeartbeatPlugin) Initialize(config map[string]any) error {
+	return nil
+}
+
+func (p *HeartbeatPlugin) Cleanup() error {
+	return nil
+}
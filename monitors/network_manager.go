@@ -1,9 +1,11 @@
 package monitors
 
 import (
+	"encoding/hex"
 	"fmt"
 	"log"
 	"log/slog"
+	"math"
 	"net"
 
 	"strconv"
@@ -11,19 +13,28 @@ import (
 	"time"
 
 	"github.com/ZEGIFTED/MS.GoMonitor/pkg/constants"
+	"github.com/ZEGIFTED/MS.GoMonitor/pkg/utils"
 	mstypes "github.com/ZEGIFTED/MS.GoMonitor/types"
 	"github.com/gosnmp/gosnmp"
 )
 
 type NetworkManager struct {
 	SNMP         *gosnmp.GoSNMP
-	trap         *gosnmp.TrapListener
 	Target       string
+	Port         uint16 // Polling port, 161 when unset
 	Community    string
-	TrapPort     int
 	AuthUser     string
 	AuthPassword string
 	PrivPassword string
+
+	// SNMPv3 USM settings, see ParseSNMPDeviceConfig
+	AuthProtocol    gosnmp.SnmpV3AuthProtocol
+	PrivProtocol    gosnmp.SnmpV3PrivProtocol
+	SecurityLevel   gosnmp.SnmpV3MsgFlags
+	ContextName     string
+	ContextEngineID string
+
+	Counters *CounterStore // Previous counter samples, kept by the caller across polls
 }
 
 // Define a struct for SNMP metric configuration
@@ -32,9 +43,9 @@ type SNMPMetricConfig struct {
 	Description string `json:"description"`
 	MetricType  string `json:"metricType"`
 
-	IsCounter   bool   `json:"isCounter"`   // For metrics that need delta calculation
+	IsCounter   bool   `json:"isCounter"`   // Reported as a per-second rate between polls
 	Scale       int    `json:"scale"`       // Scaling factor (e.g., 1000 for KB, 1000000 for MB)
-	InterfaceID string `json:"interfaceId"` // For interface-specific metrics
+	InterfaceID string `json:"interfaceId"` // ifIndex of the row for interface table columns
 
 	Name string `json:"name"`
 	// Scale       float64 `json:"scale,omitempty"`
@@ -48,46 +59,221 @@ type SNMPDeviceConfig struct {
 	PrivPassword    string             `json:"privPassword"`
 	SNMPVersion     string             `json:"snmpVersion"`
 	SNMPMetrics     []SNMPMetricConfig `json:"snmpMetrics"`
+
+	Port            uint16 `json:"snmpPort"`
+	AuthProtocol    string `json:"authProtocol"`    // MD5, SHA, SHA224, SHA256, SHA384 or SHA512
+	PrivProtocol    string `json:"privProtocol"`    // DES, AES, AES192, AES256, AES192C or AES256C
+	SecurityLevel   string `json:"securityLevel"`   // noAuthNoPriv, authNoPriv or authPriv
+	ContextName     string `json:"contextName"`     // SNMPv3 context, e.g. a VLAN or VRF instance
+	ContextEngineID string `json:"contextEngineId"` // Hex, only needed when polling through a proxy agent
+}
+
+// SNMP versions as normalised by ParseSNMPDeviceConfig
+const (
+	SNMPVersion1  = "v1"
+	SNMPVersion2c = "v2c"
+	SNMPVersion3  = "v3"
+)
+
+// Defaults match the protocols the collector used before they were configurable
+const (
+	defaultSNMPAuthProtocol = "SHA512"
+	defaultSNMPPrivProtocol = "AES256"
+)
+
+var snmpSecurityLevels = map[string]gosnmp.SnmpV3MsgFlags{
+	"noauthnopriv": gosnmp.NoAuthNoPriv,
+	"authnopriv":   gosnmp.AuthNoPriv,
+	"authpriv":     gosnmp.AuthPriv,
+}
+
+// ParseSNMPDeviceConfig reads and validates the SNMP settings of a network device's Configuration.
+// When securityLevel is not set it follows from the passwords that are configured.
+func ParseSNMPDeviceConfig(cfg map[string]any) (SNMPDeviceConfig, error) {
+	config := SNMPDeviceConfig{
+		CommunityString: utils.ConfigString(cfg, "communityString", ""),
+		AuthUsernameV3:  utils.ConfigString(cfg, "authUsernameV3", ""),
+		AuthPasswordV3:  utils.ConfigString(cfg, "authPasswordV3", ""),
+		PrivPassword:    utils.ConfigString(cfg, "privPassword", ""),
+		AuthProtocol:    utils.ConfigString(cfg, "authProtocol", defaultSNMPAuthProtocol),
+		PrivProtocol:    utils.ConfigString(cfg, "privProtocol", defaultSNMPPrivProtocol),
+		SecurityLevel:   utils.ConfigString(cfg, "securityLevel", ""),
+		ContextName:     utils.ConfigString(cfg, "contextName", ""),
+		ContextEngineID: utils.ConfigString(cfg, "contextEngineId", ""),
+	}
+
+	port := utils.ConfigInt(cfg, "snmpPort", 161)
+	if port <= 0 || port > 65535 {
+		return config, fmt.Errorf("invalid snmpPort %d", port)
+	}
+	config.Port = uint16(port)
+
+	switch version := strings.ToLower(strings.TrimSpace(utils.ConfigString(cfg, "snmpVersion", "v2"))); version {
+	case "v1", "1":
+		config.SNMPVersion = SNMPVersion1
+	case "v2", "v2c", "2", "2c":
+		config.SNMPVersion = SNMPVersion2c
+	case "v3", "3":
+		config.SNMPVersion = SNMPVersion3
+	default:
+		return config, fmt.Errorf("unsupported snmpVersion %q", version)
+	}
+
+	if config.SNMPVersion != SNMPVersion3 {
+		return config, nil
+	}
+
+	if config.AuthUsernameV3 == "" {
+		return config, fmt.Errorf("SNMP v3 requires authUsernameV3")
+	}
+	if config.SecurityLevel == "" {
+		switch {
+		case config.PrivPassword != "":
+			config.SecurityLevel = "authPriv"
+		case config.AuthPasswordV3 != "":
+			config.SecurityLevel = "authNoPriv"
+		default:
+			config.SecurityLevel = "noAuthNoPriv"
+		}
+	}
+	level, ok := snmpSecurityLevels[strings.ToLower(config.SecurityLevel)]
+	if !ok {
+		return config, fmt.Errorf("unknown securityLevel %q, expected noAuthNoPriv, authNoPriv or authPriv", config.SecurityLevel)
+	}
+
+	// RFC 3414 section 11.2: passphrases shorter than 8 characters are rejected by agents
+	if level&gosnmp.AuthNoPriv != 0 {
+		if auth, err := ParseAuthProtocol(config.AuthProtocol); err != nil {
+			return config, err
+		} else if auth == gosnmp.NoAuth {
+			return config, fmt.Errorf("securityLevel %s requires an authProtocol", config.SecurityLevel)
+		}
+		if len(config.AuthPasswordV3) < 8 {
+			return config, fmt.Errorf("securityLevel %s requires an authPasswordV3 of at least 8 characters", config.SecurityLevel)
+		}
+	}
+	if level&gosnmp.AuthPriv == gosnmp.AuthPriv {
+		if priv, err := ParsePrivProtocol(config.PrivProtocol); err != nil {
+			return config, err
+		} else if priv == gosnmp.NoPriv {
+			return config, fmt.Errorf("securityLevel %s requires a privProtocol", config.SecurityLevel)
+		}
+		if len(config.PrivPassword) < 8 {
+			return config, fmt.Errorf("securityLevel %s requires a privPassword of at least 8 characters", config.SecurityLevel)
+		}
+	}
+
+	if config.ContextEngineID != "" {
+		if _, err := decodeEngineID(config.ContextEngineID); err != nil {
+			return config, fmt.Errorf("invalid contextEngineId: %v", err)
+		}
+	}
+
+	return config, nil
+}
+
+// NetworkManager returns a manager polling target with these settings, the configuration must have
+// been validated by ParseSNMPDeviceConfig
+func (c SNMPDeviceConfig) NetworkManager(target string) *NetworkManager {
+	nm := &NetworkManager{
+		Target:       target,
+		Port:         c.Port,
+		Community:    c.CommunityString,
+		AuthUser:     c.AuthUsernameV3,
+		AuthPassword: c.AuthPasswordV3,
+		PrivPassword: c.PrivPassword,
+		ContextName:  c.ContextName,
+	}
+
+	if c.SNMPVersion == SNMPVersion3 {
+		// MsgFlags is a bit field, authPriv also has the auth bit set
+		nm.SecurityLevel = snmpSecurityLevels[strings.ToLower(c.SecurityLevel)]
+		nm.AuthProtocol, nm.PrivProtocol = gosnmp.NoAuth, gosnmp.NoPriv
+		if nm.SecurityLevel&gosnmp.AuthNoPriv != 0 {
+			nm.AuthProtocol, _ = ParseAuthProtocol(c.AuthProtocol)
+		}
+		if nm.SecurityLevel&gosnmp.AuthPriv == gosnmp.AuthPriv {
+			nm.PrivProtocol, _ = ParsePrivProtocol(c.PrivProtocol)
+		}
+		nm.ContextEngineID, _ = decodeEngineID(c.ContextEngineID)
+	}
+
+	return nm
+}
+
+// decodeEngineID parses a hex engine ID such as "0x80001f8880..." or "80:00:1f:88:80:..."
+func decodeEngineID(value string) (string, error) {
+	value = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(value)), "0x")
+	value = strings.NewReplacer(":", "", " ", "").Replace(value)
+	if value == "" {
+		return "", nil
+	}
+
+	id, err := hex.DecodeString(value)
+	if err != nil {
+		return "", err
+	}
+	// RFC 3411 section 5: SnmpEngineID is 5 to 32 octets
+	if len(id) < 5 || len(id) > 32 {
+		return "", fmt.Errorf("engine ID must be 5 to 32 octets, got %d", len(id))
+	}
+	return string(id), nil
 }
 
 func (nm *NetworkManager) SNMPClient(deviceVersion string) *NetworkManager {
 	var SNMP gosnmp.GoSNMP
 
-	if strings.Contains(deviceVersion, "v2") {
+	port := nm.Port
+	if port == 0 {
+		port = 161
+	}
+
+	switch {
+	case deviceVersion == SNMPVersion1:
+		log.Println("Using SNMP v1")
+
+		SNMP = gosnmp.GoSNMP{
+			Target:    nm.Target,
+			Port:      port,
+			Community: nm.Community,
+			Version:   gosnmp.Version1,
+			Timeout:   time.Duration(15) * time.Second,
+			Retries:   constants.MaxRetries,
+		}
+	case strings.Contains(deviceVersion, "v2"):
 		log.Println("Using SNMP v2")
 
 		SNMP = gosnmp.GoSNMP{
 			Target:    nm.Target, // Replace with your device's IP
-			Port:      161,
+			Port:      port,
 			Community: nm.Community,
 			Version:   gosnmp.Version2c,
 			Timeout:   time.Duration(15) * time.Second,
 			Retries:   constants.MaxRetries,
 			// Logger:  gosnmp.NewLogger(log.New(os.Stdout, "", 0)),
 		}
-	} else {
+	default:
+		// The authoritative engine ID, boots and time are discovered by gosnmp with an
+		// unauthenticated request before the first authenticated one (RFC 3414 section 4)
 		SNMP = gosnmp.GoSNMP{
-			Target:        nm.Target,
-			Port:          uint16(nm.TrapPort),
-			Version:       gosnmp.Version3,
-			Timeout:       time.Duration(35) * time.Second,
-			SecurityModel: gosnmp.Default.SecurityModel,
-			MsgFlags:      gosnmp.AuthNoPriv, // Default to AuthNoPriv. Use AuthPriv for both authentication and privacy
+			Target:          nm.Target,
+			Port:            port,
+			Version:         gosnmp.Version3,
+			Timeout:         time.Duration(35) * time.Second,
+			SecurityModel:   gosnmp.UserSecurityModel,
+			MsgFlags:        nm.SecurityLevel,
+			ContextName:     nm.ContextName,
+			ContextEngineID: nm.ContextEngineID,
 			SecurityParameters: &gosnmp.UsmSecurityParameters{
 				UserName:                 nm.AuthUser,
-				AuthenticationProtocol:   gosnmp.SHA512, // Use SHA for authentication
+				AuthenticationProtocol:   nm.AuthProtocol,
 				AuthenticationPassphrase: nm.AuthPassword,
-				PrivacyProtocol:          gosnmp.AES256, // Use AES for privacy
+				PrivacyProtocol:          nm.PrivProtocol,
 				PrivacyPassphrase:        nm.PrivPassword,
-				AuthoritativeEngineTime:  uint32(time.Now().Unix()),
 			},
 			Retries: constants.MaxRetries,
 			// Logger:  gosnmp.NewLogger(log.New(os.Stdout, "", 0)),
 		}
-
-		if nm.PrivPassword != "" {
-			SNMP.MsgFlags = gosnmp.AuthPriv
-		}
 	}
 
 	return &NetworkManager{
@@ -95,64 +281,156 @@ func (nm *NetworkManager) SNMPClient(deviceVersion string) *NetworkManager {
 	}
 }
 
-func (nm *NetworkManager) CollectSNMPMetrics(snmp *gosnmp.GoSNMP, SystemMonitorId, Host string, metrics []SNMPMetricConfig) ([]mstypes.NetworkDeviceMetric, error) {
+// CollectSNMPMetrics polls the configured OIDs. Counters are reported as per-second rates, which needs
+// nm.Counters to hold the previous samples; octet counters of an interface also yield its utilization.
+func (nm *NetworkManager) CollectSNMPMetrics(snmp *gosnmp.GoSNMP, SystemMonitorId, Host string, metrics []SNMPMetricConfig) ([]mstypes.NetworkDeviceMetric, []InterfaceUtilization, error) {
 	var deviceMetrics []mstypes.NetworkDeviceMetric
+	var utilization []InterfaceUtilization
 	var oids []string
 	oidToMetric := make(map[string]SNMPMetricConfig)
 
 	// Prepare OIDs to query
+	hasCounters := false
 	for _, metric := range metrics {
-		oids = append(oids, metric.OID)
-		oidToMetric[metric.OID] = metric
+		oid := metric.FullOID()
+		oids = append(oids, oid)
+		oidToMetric[oid] = metric
+
+		if metric.IsCounter {
+			hasCounters = true
+			if _, ok := interfaceOctetColumns[metric.column()]; ok && metric.InterfaceID != "" {
+				oids = append(oids, oidIfSpeed+"."+metric.InterfaceID, oidIfHighSpeed+"."+metric.InterfaceID)
+			}
+		}
+	}
+	if hasCounters {
+		oids = append(oids, "."+oidSysUpTime)
 	}
 
-	// Perform SNMP Get request
-	packet, err := snmp.Get(oids)
-	if err != nil {
-		return nil, fmt.Errorf("SNMP get failed: %v", err)
+	// Perform SNMP Get request, agents reject requests with more than MaxOids variables
+	values := make(map[string]gosnmp.SnmpPDU)
+	for start := 0; start < len(oids); start += snmp.MaxOids {
+		packet, err := snmp.Get(oids[start:min(start+snmp.MaxOids, len(oids))])
+		if err != nil {
+			return nil, nil, fmt.Errorf("SNMP get failed: %v", err)
+		}
+		for _, variable := range packet.Variables {
+			values[variable.Name] = variable
+		}
 	}
 
+	polled := time.Now()
+	uptime, hasUptime := values["."+oidSysUpTime].Value.(uint32)
+
 	// Process results
-	for _, variable := range packet.Variables {
-		if metric, exists := oidToMetric[variable.Name]; exists {
-			value, err := nm.ConvertSNMPValue(variable)
-			if err != nil {
-				log.Printf("Failed to convert value for OID %s: %v", variable.Name, err)
+	for _, oid := range oids {
+		metric, exists := oidToMetric[oid]
+		variable, found := values[oid]
+		if !exists || !found {
+			continue
+		}
+
+		value, err := nm.ConvertSNMPValue(variable)
+		if err != nil {
+			log.Printf("Failed to convert value for OID %s: %v", variable.Name, err)
+			continue
+		}
+
+		if metric.IsCounter {
+			raw := gosnmp.ToBigInt(variable.Value)
+			if nm.Counters == nil || !raw.IsUint64() {
 				continue
 			}
 
-			// Apply scaling if configured
-			if metric.Scale > 0 {
-				if num, ok := value.(int); ok {
-					value = num / metric.Scale
-				} else if num, ok := value.(float64); ok {
-					value = num / float64(metric.Scale)
+			bits := 32
+			if variable.Type == gosnmp.Counter64 {
+				bits = 64
+			}
+			rate, ok := nm.Counters.Rate(Host, oid, CounterSample{Value: raw.Uint64(), Bits: bits, Uptime: uptime, HasUptime: hasUptime, Time: polled})
+			if !ok {
+				// First sample or counter reset, the next poll has a baseline
+				continue
+			}
+			value = rate
+
+			if direction, ok := interfaceOctetColumns[metric.column()]; ok && metric.InterfaceID != "" {
+				speed := interfaceSpeed(counterValue(values[oidIfSpeed+"."+metric.InterfaceID]), counterValue(values[oidIfHighSpeed+"."+metric.InterfaceID]))
+				if speed > 0 {
+					usage := InterfaceUtilization{
+						InterfaceID: metric.InterfaceID,
+						Direction:   direction,
+						Metric:      metric.Name,
+						BitsPerSec:  rate * 8,
+						SpeedBps:    speed,
+						Percent:     rate * 8 / speed * 100,
+					}
+					utilization = append(utilization, usage)
+
+					deviceMetrics = append(deviceMetrics, mstypes.NetworkDeviceMetric{
+						SystemMonitorId:   SystemMonitorId,
+						DeviceIP:          Host,
+						MetricName:        metric.Name + "Utilization",
+						MetricDescription: fmt.Sprintf("Interface %s %s utilization (%%)", metric.InterfaceID, direction),
+						MetricValue:       math.Round(usage.Percent*100) / 100,
+						LastPoll:          polled.Format(time.DateTime),
+					})
 				}
 			}
+		}
 
-			slog.Info("Device Metrics", SystemMonitorId, deviceMetrics)
-			deviceMetrics = append(deviceMetrics, mstypes.NetworkDeviceMetric{
-				SystemMonitorId: SystemMonitorId,
-				DeviceIP:        Host,
-				// DeviceType:      string(config.Device),
-				// MetricType:      metric.MetricType,
-				MetricName:        metric.Name,
-				MetricDescription: metric.Description,
-				MetricValue:       value,
-				LastPoll:          time.Now().Format(time.DateTime),
-			})
-
-			// results = append(results, mstypes.NetworkDeviceMetric{
-			//     OID:         variable.Name,
-			//     Description: metric.Description,
-			//     Value:       value,
-			//     MetricType:  metric.MetricType,
-			//     Timestamp:  time.Now(),
-			// })
+		// Apply scaling if configured
+		if metric.Scale > 0 {
+			switch num := value.(type) {
+			case int:
+				value = float64(num) / float64(metric.Scale)
+			case float64:
+				value = num / float64(metric.Scale)
+			case uint32:
+				value = float64(num) / float64(metric.Scale)
+			case uint64:
+				value = float64(num) / float64(metric.Scale)
+			}
 		}
+
+		slog.Info("Device Metrics", SystemMonitorId, deviceMetrics)
+		deviceMetrics = append(deviceMetrics, mstypes.NetworkDeviceMetric{
+			SystemMonitorId: SystemMonitorId,
+			DeviceIP:        Host,
+			// DeviceType:      string(config.Device),
+			// MetricType:      metric.MetricType,
+			MetricName:        metric.Name,
+			MetricDescription: metric.Description,
+			MetricValue:       value,
+			LastPoll:          polled.Format(time.DateTime),
+		})
+	}
+
+	return deviceMetrics, utilization, nil
+}
+
+// FullOID is the OID to poll with a leading dot, as gosnmp reports it. For interface metrics OID names
+// the table column and InterfaceID the ifIndex of the row.
+func (m SNMPMetricConfig) FullOID() string {
+	oid := m.column()
+	if m.InterfaceID != "" {
+		oid += "." + m.InterfaceID
 	}
+	return oid
+}
 
-	return deviceMetrics, nil
+func (m SNMPMetricConfig) column() string {
+	return "." + strings.TrimPrefix(strings.TrimSpace(m.OID), ".")
+}
+
+// counterValue reads an integer varbind, missing or non-numeric values are 0
+func counterValue(variable gosnmp.SnmpPDU) uint64 {
+	switch variable.Type {
+	case gosnmp.Integer, gosnmp.Counter32, gosnmp.Gauge32, gosnmp.Counter64, gosnmp.Uinteger32:
+		if v := gosnmp.ToBigInt(variable.Value); v.IsUint64() {
+			return v.Uint64()
+		}
+	}
+	return 0
 }
 
 func (nm *NetworkManager) GetSNMPValue(result []gosnmp.SnmpPDU) (string, string) {
@@ -211,41 +489,6 @@ func (nm *NetworkManager) ConvertSNMPValue(variable gosnmp.SnmpPDU) (interface{}
 	}
 }
 
-func (nm *NetworkManager) GetInterfaces(snmp *gosnmp.GoSNMP) ([]string, error) {
-	var interfaces []string
-
-	//err_ := snmp.Walk("1.3.6.1.2.1.2.2.1", func(variable gosnmp.SnmpPDU) error {
-	//	fmt.Printf("OID: %s, Value: %v\n", variable.Name, variable.Value)
-	//	return nil
-	//})
-	//if err_ != nil {
-	//	log.Fatalf("Error walking SNMP table: %v", err_)
-	//}
-
-	// Get interface descriptions
-	// Define the walk function
-	walkFn := func(pdu gosnmp.SnmpPDU) error {
-		// Create a single-element slice containing the PDU to pass to GetSNMPValue
-		result := []gosnmp.SnmpPDU{pdu}
-
-		// Get the human-readable value
-		name, value := nm.GetSNMPValue(result)
-
-		// Log the OID, value, and type
-		log.Printf("OID: %s, Value: %s -> %s, Type: %v", pdu.Name, name, value, pdu.Type)
-
-		return nil
-	}
-
-	// Get interface descriptions using BulkWalk with callback
-	err := snmp.BulkWalk(".1.3.6.1.2.1.25.2", walkFn)
-	if err != nil {
-		return nil, fmt.Errorf("BulkWalk error: %v", err)
-	}
-
-	return interfaces, nil
-}
-
 func (nm *NetworkManager) GetFormattedSNMPValue(result []gosnmp.SnmpPDU) (string, string) {
 	for _, variable := range result {
 		switch variable.Type {
@@ -292,81 +535,45 @@ func (nm *NetworkManager) formatTimeticks(ticks uint32) string {
 	return duration.Abs().String()
 }
 
-func (nm *NetworkManager) NewTrapClient() *gosnmp.TrapListener {
-	// Configure the SNMP trap listener
-
-	return &gosnmp.TrapListener{
-		OnNewTrap: nm.HandleTrap,
-		Params: &gosnmp.GoSNMP{
-			Port:               162, // Standard SNMP trap port
-			Transport:          "udp",
-			Version:            gosnmp.Version2c,
-			Timeout:            time.Duration(30) * time.Second,
-			Retries:            3,
-			ExponentialTimeout: true,
-			MaxOids:            gosnmp.MaxOids,
-			// Logger:               gosnmp.NewLogger(log.New(os.Stdout, "", 0)),
-			SecurityModel:      gosnmp.UserSecurityModel,
-			MsgFlags:           gosnmp.AuthPriv,
-			SecurityParameters: nil, // Set if using SNMPv3
-			ContextEngineID:    "",
-			ContextName:        "",
-			// IsAuthentic:          false,
-		},
-	}
+// snmpAuthProtocols and snmpPrivProtocols map configuration names to USM protocols
+var snmpAuthProtocols = map[string]gosnmp.SnmpV3AuthProtocol{
+	"":       gosnmp.NoAuth,
+	"NONE":   gosnmp.NoAuth,
+	"MD5":    gosnmp.MD5,
+	"SHA":    gosnmp.SHA,
+	"SHA1":   gosnmp.SHA,
+	"SHA224": gosnmp.SHA224,
+	"SHA256": gosnmp.SHA256,
+	"SHA384": gosnmp.SHA384,
+	"SHA512": gosnmp.SHA512,
 }
 
-// StartListener begins listening for SNMP traps
-func (nm *NetworkManager) StartListener() error {
-	// Configure GoSNMP parameters
-	gosnmp.Default.Port = uint16(nm.TrapPort)
-	gosnmp.Default.Community = nm.Community
-	gosnmp.Default.Version = gosnmp.Version2c
-
-	// Create a trap listener
-	trapListener := nm.NewTrapClient()
-	nm.trap = trapListener
-
-	// Start listening for traps
-	log.Printf("Starting SNMP trap listener on port %d", nm.TrapPort)
-
-	err := trapListener.Listen("0.0.0.0:162")
-
-	if err != nil {
-		return fmt.Errorf("trap listener error: %v", err)
-	}
-
-	return nil
+var snmpPrivProtocols = map[string]gosnmp.SnmpV3PrivProtocol{
+	"":        gosnmp.NoPriv,
+	"NONE":    gosnmp.NoPriv,
+	"DES":     gosnmp.DES,
+	"AES":     gosnmp.AES,
+	"AES128":  gosnmp.AES,
+	"AES192":  gosnmp.AES192,
+	"AES256":  gosnmp.AES256,
+	"AES192C": gosnmp.AES192C, // Cisco (3DES key extension)
+	"AES256C": gosnmp.AES256C,
 }
 
-func (nm *NetworkManager) StopListener() {
-	log.Println("Stopping Trap Listener...")
-	nm.trap.Close()
+// ParseAuthProtocol returns the USM authentication protocol for a name such as "SHA256"
+func ParseAuthProtocol(name string) (gosnmp.SnmpV3AuthProtocol, error) {
+	protocol, ok := snmpAuthProtocols[strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(name), "-", ""))]
+	if !ok {
+		return gosnmp.NoAuth, fmt.Errorf("unknown SNMPv3 authentication protocol %q", name)
+	}
+	return protocol, nil
 }
 
-// handleTrap processes incoming SNMP traps
-func (nm *NetworkManager) HandleTrap(packet *gosnmp.SnmpPacket, addr *net.UDPAddr) {
-	log.Printf("Received trap from %s:\n", addr.IP)
-
-	// Print basic trap information
-	log.Printf("SNMP Version: %d\n", packet.Version)
-	log.Printf("Community: %s\n", packet.Community)
-	log.Printf("PDU Type: %s\n", packet.PDUType)
-
-	// Process each variable binding in the trap
-	name, value := nm.GetFormattedSNMPValue(packet.Variables)
-
-	// Log the OID, value, and type
-	log.Printf("OID: %s, Value: %s", name, value)
-
-	log.Println("End of trap")
+// ParsePrivProtocol returns the USM privacy protocol for a name such as "AES256C"
+func ParsePrivProtocol(name string) (gosnmp.SnmpV3PrivProtocol, error) {
+	protocol, ok := snmpPrivProtocols[strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(name), "-", ""))]
+	if !ok {
+		return gosnmp.NoPriv, fmt.Errorf("unknown SNMPv3 privacy protocol %q", name)
+	}
+	return protocol, nil
 }
-
-// Example OID mappings for common traps
-// var trapOIDs = map[string]string{
-// 	"1.3.6.1.6.3.1.1.5.1":   "coldStart",
-// 	"1.3.6.1.6.3.1.1.5.2":   "warmStart",
-// 	"1.3.6.1.6.3.1.1.5.3":   "linkDown",
-// 	"1.3.6.1.6.3.1.1.5.4":   "linkUp",
-// 	"1.3.6.1.6.3.1.1.5.5":   "authenticationFailure",
-// }
@@ -0,0 +1,202 @@
+package monitors
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gosnmp/gosnmp"
+)
+
+func TestParseSNMPDeviceConfig(t *testing.T) {
+	tests := []struct {
+		name      string
+		cfg       map[string]any
+		wantErr   string
+		version   string
+		port      uint16
+		level     string
+		authProto string
+		privProto string
+	}{
+		{
+			name:    "defaults to v2c on port 161",
+			cfg:     map[string]any{"communityString": "public"},
+			version: SNMPVersion2c,
+			port:    161,
+		},
+		{
+			name:    "numeric v1 and custom port",
+			cfg:     map[string]any{"snmpVersion": "1", "snmpPort": 1161},
+			version: SNMPVersion1,
+			port:    1161,
+		},
+		{
+			name:    "unsupported version",
+			cfg:     map[string]any{"snmpVersion": "v4"},
+			wantErr: "unsupported snmpVersion",
+		},
+		{
+			name:    "port out of range",
+			cfg:     map[string]any{"snmpPort": 70000},
+			wantErr: "invalid snmpPort",
+		},
+		{
+			name:    "v3 without user",
+			cfg:     map[string]any{"snmpVersion": "v3"},
+			wantErr: "requires authUsernameV3",
+		},
+		{
+			name:      "v3 level follows from the privacy password",
+			cfg:       map[string]any{"snmpVersion": "v3", "authUsernameV3": "monitor", "authPasswordV3": "authpass1", "privPassword": "privpass1"},
+			version:   SNMPVersion3,
+			port:      161,
+			level:     "authPriv",
+			authProto: "SHA512",
+			privProto: "AES256",
+		},
+		{
+			name:      "v3 level follows from the auth password",
+			cfg:       map[string]any{"snmpVersion": "3", "authUsernameV3": "monitor", "authPasswordV3": "authpass1", "authProtocol": "sha-256"},
+			version:   SNMPVersion3,
+			port:      161,
+			level:     "authNoPriv",
+			authProto: "sha-256",
+			privProto: "AES256",
+		},
+		{
+			name:      "v3 without passwords",
+			cfg:       map[string]any{"snmpVersion": "v3", "authUsernameV3": "monitor"},
+			version:   SNMPVersion3,
+			port:      161,
+			level:     "noAuthNoPriv",
+			authProto: "SHA512",
+			privProto: "AES256",
+		},
+		{
+			name:    "unknown security level",
+			cfg:     map[string]any{"snmpVersion": "v3", "authUsernameV3": "monitor", "securityLevel": "authOnly"},
+			wantErr: "unknown securityLevel",
+		},
+		{
+			name:    "auth password shorter than 8 characters",
+			cfg:     map[string]any{"snmpVersion": "v3", "authUsernameV3": "monitor", "authPasswordV3": "short"},
+			wantErr: "authPasswordV3 of at least 8 characters",
+		},
+		{
+			name:    "privacy password shorter than 8 characters",
+			cfg:     map[string]any{"snmpVersion": "v3", "authUsernameV3": "monitor", "authPasswordV3": "authpass1", "privPassword": "short"},
+			wantErr: "privPassword of at least 8 characters",
+		},
+		{
+			name:    "unknown auth protocol",
+			cfg:     map[string]any{"snmpVersion": "v3", "authUsernameV3": "monitor", "authPasswordV3": "authpass1", "authProtocol": "SHA3"},
+			wantErr: "unknown SNMPv3 authentication protocol",
+		},
+		{
+			name:    "auth level with auth protocol none",
+			cfg:     map[string]any{"snmpVersion": "v3", "authUsernameV3": "monitor", "authPasswordV3": "authpass1", "authProtocol": "none"},
+			wantErr: "requires an authProtocol",
+		},
+		{
+			name:    "priv level with privacy protocol none",
+			cfg:     map[string]any{"snmpVersion": "v3", "authUsernameV3": "monitor", "securityLevel": "authPriv", "authPasswordV3": "authpass1", "privPassword": "privpass1", "privProtocol": "none"},
+			wantErr: "requires a privProtocol",
+		},
+		{
+			name:    "context engine ID too short",
+			cfg:     map[string]any{"snmpVersion": "v3", "authUsernameV3": "monitor", "contextEngineId": "0x0102"},
+			wantErr: "invalid contextEngineId",
+		},
+		{
+			name:      "context engine ID with separators",
+			cfg:       map[string]any{"snmpVersion": "v3", "authUsernameV3": "monitor", "contextEngineId": "80:00:1f:88:80:01"},
+			version:   SNMPVersion3,
+			port:      161,
+			level:     "noAuthNoPriv",
+			authProto: "SHA512",
+			privProto: "AES256",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := ParseSNMPDeviceConfig(tt.cfg)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseSNMPDeviceConfig: %v", err)
+			}
+
+			if config.SNMPVersion != tt.version {
+				t.Errorf("version = %q, want %q", config.SNMPVersion, tt.version)
+			}
+			if config.Port != tt.port {
+				t.Errorf("port = %d, want %d", config.Port, tt.port)
+			}
+			if config.SecurityLevel != tt.level {
+				t.Errorf("securityLevel = %q, want %q", config.SecurityLevel, tt.level)
+			}
+			if tt.version == SNMPVersion3 && (config.AuthProtocol != tt.authProto || config.PrivProtocol != tt.privProto) {
+				t.Errorf("protocols = %s/%s, want %s/%s", config.AuthProtocol, config.PrivProtocol, tt.authProto, tt.privProto)
+			}
+		})
+	}
+}
+
+func TestSNMPDeviceConfigNetworkManager(t *testing.T) {
+	tests := []struct {
+		name     string
+		cfg      map[string]any
+		level    gosnmp.SnmpV3MsgFlags
+		auth     gosnmp.SnmpV3AuthProtocol
+		priv     gosnmp.SnmpV3PrivProtocol
+		engineID string
+	}{
+		{
+			name:  "authPriv uses both protocols",
+			cfg:   map[string]any{"snmpVersion": "v3", "authUsernameV3": "monitor", "authPasswordV3": "authpass1", "privPassword": "privpass1", "authProtocol": "SHA256", "privProtocol": "AES256C"},
+			level: gosnmp.AuthPriv,
+			auth:  gosnmp.SHA256,
+			priv:  gosnmp.AES256C,
+		},
+		{
+			name:  "authNoPriv ignores the privacy protocol",
+			cfg:   map[string]any{"snmpVersion": "v3", "authUsernameV3": "monitor", "authPasswordV3": "authpass1", "authProtocol": "MD5", "privProtocol": "DES"},
+			level: gosnmp.AuthNoPriv,
+			auth:  gosnmp.MD5,
+			priv:  gosnmp.NoPriv,
+		},
+		{
+			name:     "noAuthNoPriv with a context engine ID",
+			cfg:      map[string]any{"snmpVersion": "v3", "authUsernameV3": "monitor", "contextEngineId": "0x80001F8880"},
+			level:    gosnmp.NoAuthNoPriv,
+			auth:     gosnmp.NoAuth,
+			priv:     gosnmp.NoPriv,
+			engineID: "\x80\x00\x1f\x88\x80",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := ParseSNMPDeviceConfig(tt.cfg)
+			if err != nil {
+				t.Fatalf("ParseSNMPDeviceConfig: %v", err)
+			}
+
+			nm := config.NetworkManager("10.0.0.1")
+			if nm.SecurityLevel != tt.level {
+				t.Errorf("security level = %v, want %v", nm.SecurityLevel, tt.level)
+			}
+			if nm.AuthProtocol != tt.auth || nm.PrivProtocol != tt.priv {
+				t.Errorf("protocols = %v/%v, want %v/%v", nm.AuthProtocol, nm.PrivProtocol, tt.auth, tt.priv)
+			}
+			if nm.ContextEngineID != tt.engineID {
+				t.Errorf("context engine ID = %x, want %x", nm.ContextEngineID, tt.engineID)
+			}
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package monitors
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	oidIfSpeed     = ".1.3.6.1.2.1.2.2.1.5"
+	oidIfHighSpeed = ".1.3.6.1.2.1.31.1.1.1.15"
+
+	counter32Max = 1 << 32
+)
+
+// interfaceOctetColumns are the IF-MIB octet counters interface utilization is derived from, with their direction
+var interfaceOctetColumns = map[string]string{
+	".1.3.6.1.2.1.2.2.1.10":    "in",  // ifInOctets
+	".1.3.6.1.2.1.2.2.1.16":    "out", // ifOutOctets
+	".1.3.6.1.2.1.31.1.1.1.6":  "in",  // ifHCInOctets
+	".1.3.6.1.2.1.31.1.1.1.10": "out", // ifHCOutOctets
+}
+
+// CounterSample is one reading of an SNMP counter together with the device's sysUpTime
+type CounterSample struct {
+	Value     uint64
+	Bits      int    // 32 for Counter32, 64 for Counter64
+	Uptime    uint32 // sysUpTime in hundredths of a second
+	HasUptime bool
+	Time      time.Time
+}
+
+// CounterStore keeps the previous sample of every counter per device so successive polls can be
+// turned into per-second rates. It is shared by all checks of a plugin and safe for concurrent use.
+type CounterStore struct {
+	mu      sync.Mutex
+	samples map[string]CounterSample
+}
+
+func NewCounterStore() *CounterStore {
+	return &CounterStore{samples: make(map[string]CounterSample)}
+}
+
+// Rate records sample and returns the per-second rate since the previous sample of the same counter.
+// No rate is returned for the first sample, after the device rebooted or when a 64-bit counter went
+// backwards (a counter discontinuity), the sample becomes the new baseline instead.
+func (c *CounterStore) Rate(device, oid string, sample CounterSample) (float64, bool) {
+	key := device + "|" + oid
+
+	c.mu.Lock()
+	previous, ok := c.samples[key]
+	c.samples[key] = sample
+	c.mu.Unlock()
+
+	if !ok {
+		return 0, false
+	}
+
+	elapsed := sample.Time.Sub(previous.Time).Seconds()
+	if sample.HasUptime && previous.HasUptime {
+		ticks, rebooted := uptimeDelta(previous, sample)
+		if rebooted {
+			return 0, false
+		}
+		// The agent's own clock is not affected by polling jitter
+		elapsed = float64(ticks) / 100
+	}
+	if elapsed <= 0 {
+		return 0, false
+	}
+
+	var delta uint64
+	switch {
+	case sample.Value >= previous.Value:
+		delta = sample.Value - previous.Value
+	case sample.Bits == 32 && previous.Value < counter32Max:
+		delta = sample.Value + counter32Max - previous.Value
+	default:
+		return 0, false
+	}
+
+	return float64(delta) / elapsed, true
+}
+
+// uptimeDelta returns the sysUpTime ticks between two samples. sysUpTime wraps after about 497 days, a
+// decrease is only taken as a wrap when the wall clock agrees, otherwise the device restarted.
+func uptimeDelta(previous, current CounterSample) (uint64, bool) {
+	if current.Uptime >= previous.Uptime {
+		return uint64(current.Uptime - previous.Uptime), false
+	}
+
+	wrapped := uint64(current.Uptime) + counter32Max - uint64(previous.Uptime)
+	wall := current.Time.Sub(previous.Time).Seconds()
+	if math.Abs(float64(wrapped)/100-wall) <= wall/10+5 {
+		return wrapped, false
+	}
+	return 0, true
+}
+
+// InterfaceUtilization is the bandwidth use of one interface direction
+type InterfaceUtilization struct {
+	InterfaceID   string  `json:"interface_id"`
+	InterfaceName string  `json:"interface_name,omitempty"`
+	Direction     string  `json:"direction"`
+	Metric        string  `json:"metric"`
+	BitsPerSec    float64 `json:"bits_per_second"`
+	SpeedBps      float64 `json:"speed_bps"`
+	Percent       float64 `json:"percent"`
+}
+
+// interfaceSpeed returns the interface speed in bits per second. ifSpeed saturates at 4294967295 for
+// interfaces faster than 4 Gbps, ifHighSpeed (in Mbps) is used for those.
+func interfaceSpeed(ifSpeed, ifHighSpeed uint64) float64 {
+	if ifHighSpeed > 0 && (ifSpeed == 0 || ifSpeed >= math.MaxUint32) {
+		return float64(ifHighSpeed) * 1e6
+	}
+	return float64(ifSpeed)
+}
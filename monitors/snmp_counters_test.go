@@ -0,0 +1,134 @@
+package monitors
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestCounterStoreRate(t *testing.T) {
+	t0 := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	sample := func(value uint64, bits int, uptime uint32, hasUptime bool, after time.Duration) CounterSample {
+		return CounterSample{Value: value, Bits: bits, Uptime: uptime, HasUptime: hasUptime, Time: t0.Add(after)}
+	}
+
+	tests := []struct {
+		name     string
+		previous CounterSample
+		current  CounterSample
+		want     float64
+		wantOK   bool
+	}{
+		{
+			name:     "increase timed by sysUpTime",
+			previous: sample(1000, 64, 10000, true, 0),
+			current:  sample(2000, 64, 11000, true, 12*time.Second), // poll jitter, agent saw 10s
+			want:     100,
+			wantOK:   true,
+		},
+		{
+			name:     "increase timed by wall clock without sysUpTime",
+			previous: sample(1000, 64, 0, false, 0),
+			current:  sample(3000, 64, 0, false, 20*time.Second),
+			want:     100,
+			wantOK:   true,
+		},
+		{
+			name:     "counter32 wrap",
+			previous: sample(counter32Max-100, 32, 10000, true, 0),
+			current:  sample(900, 32, 11000, true, 10*time.Second),
+			want:     100,
+			wantOK:   true,
+		},
+		{
+			name:     "counter64 going backwards is a discontinuity",
+			previous: sample(5000, 64, 10000, true, 0),
+			current:  sample(100, 64, 11000, true, 10*time.Second),
+			wantOK:   false,
+		},
+		{
+			name:     "counter32 reset to a value above the previous 32-bit range",
+			previous: sample(counter32Max+10, 32, 10000, true, 0),
+			current:  sample(5, 32, 11000, true, 10*time.Second),
+			wantOK:   false,
+		},
+		{
+			name:     "reboot resets sysUpTime",
+			previous: sample(900000, 32, 8640000, true, 0),
+			current:  sample(1000, 32, 3000, true, 60*time.Second),
+			wantOK:   false,
+		},
+		{
+			name:     "sysUpTime wrap after 497 days",
+			previous: sample(1000, 64, math.MaxUint32-999, true, 0),
+			current:  sample(3000, 64, 1000, true, 20*time.Second),
+			want:     100,
+			wantOK:   true,
+		},
+		{
+			name:     "no time elapsed",
+			previous: sample(1000, 64, 0, false, 0),
+			current:  sample(2000, 64, 0, false, 0),
+			wantOK:   false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := NewCounterStore()
+			if _, ok := store.Rate("10.0.0.1", "ifInOctets.1", tt.previous); ok {
+				t.Fatalf("first sample returned a rate")
+			}
+
+			got, ok := store.Rate("10.0.0.1", "ifInOctets.1", tt.current)
+			if ok != tt.wantOK {
+				t.Fatalf("ok = %v, want %v (rate %v)", ok, tt.wantOK, got)
+			}
+			if ok && math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("rate = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCounterStoreRebaselines(t *testing.T) {
+	t0 := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	store := NewCounterStore()
+
+	store.Rate("dev", "oid", CounterSample{Value: 5000, Bits: 64, Time: t0})
+	if _, ok := store.Rate("dev", "oid", CounterSample{Value: 100, Bits: 64, Time: t0.Add(10 * time.Second)}); ok {
+		t.Fatalf("discontinuity returned a rate")
+	}
+
+	got, ok := store.Rate("dev", "oid", CounterSample{Value: 1100, Bits: 64, Time: t0.Add(20 * time.Second)})
+	if !ok || got != 100 {
+		t.Fatalf("rate after discontinuity = %v, %v, want 100 from the new baseline", got, ok)
+	}
+
+	// Counters of other devices are tracked separately
+	if _, ok := store.Rate("other", "oid", CounterSample{Value: 1, Bits: 64, Time: t0}); ok {
+		t.Fatalf("first sample of another device returned a rate")
+	}
+}
+
+func TestInterfaceSpeed(t *testing.T) {
+	tests := []struct {
+		name                 string
+		ifSpeed, ifHighSpeed uint64
+		want                 float64
+	}{
+		{"ifSpeed only", 100_000_000, 0, 1e8},
+		{"ifSpeed below saturation is preferred", 1_000_000_000, 1000, 1e9},
+		{"saturated ifSpeed uses ifHighSpeed", math.MaxUint32, 10000, 1e10},
+		{"missing ifSpeed uses ifHighSpeed", 0, 40000, 4e10},
+		{"unknown speed", 0, 0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := interfaceSpeed(tt.ifSpeed, tt.ifHighSpeed); got != tt.want {
+				t.Errorf("interfaceSpeed(%d, %d) = %v, want %v", tt.ifSpeed, tt.ifHighSpeed, got, tt.want)
+			}
+		})
+	}
+}
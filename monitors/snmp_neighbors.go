@@ -0,0 +1,385 @@
+package monitors
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/gosnmp/gosnmp"
+)
+
+// SNMPv2-MIB, LLDP-MIB, CISCO-CDP-MIB, BRIDGE-MIB, Q-BRIDGE-MIB and IP-MIB columns used for topology mapping
+const (
+	oidSysName = ".1.3.6.1.2.1.1.5.0"
+
+	oidLldpLocPortID         = ".1.0.8802.1.1.2.1.3.7.1.3"
+	oidLldpLocPortDesc       = ".1.0.8802.1.1.2.1.3.7.1.4"
+	oidLldpRemChassisSubtype = ".1.0.8802.1.1.2.1.4.1.1.4"
+	oidLldpRemChassisID      = ".1.0.8802.1.1.2.1.4.1.1.5"
+	oidLldpRemPortSubtype    = ".1.0.8802.1.1.2.1.4.1.1.6"
+	oidLldpRemPortID         = ".1.0.8802.1.1.2.1.4.1.1.7"
+	oidLldpRemPortDesc       = ".1.0.8802.1.1.2.1.4.1.1.8"
+	oidLldpRemSysName        = ".1.0.8802.1.1.2.1.4.1.1.9"
+	oidLldpRemSysDesc        = ".1.0.8802.1.1.2.1.4.1.1.10"
+	oidLldpRemManAddrIf      = ".1.0.8802.1.1.2.1.4.2.1.3"
+
+	oidCdpCacheAddress    = ".1.3.6.1.4.1.9.9.23.1.2.1.1.4"
+	oidCdpCacheDeviceID   = ".1.3.6.1.4.1.9.9.23.1.2.1.1.6"
+	oidCdpCacheDevicePort = ".1.3.6.1.4.1.9.9.23.1.2.1.1.7"
+	oidCdpCachePlatform   = ".1.3.6.1.4.1.9.9.23.1.2.1.1.8"
+
+	oidDot1dBasePortIfIndex = ".1.3.6.1.2.1.17.1.4.1.2"
+	oidDot1dTpFdbPort       = ".1.3.6.1.2.1.17.4.3.1.2"
+	oidDot1dTpFdbStatus     = ".1.3.6.1.2.1.17.4.3.1.3"
+	oidDot1qTpFdbPort       = ".1.3.6.1.2.1.17.7.1.2.2.1.2"
+	oidDot1qTpFdbStatus     = ".1.3.6.1.2.1.17.7.1.2.2.1.3"
+
+	oidIpNetToMediaPhysAddress = ".1.3.6.1.2.1.4.22.1.2"
+	oidIpNetToMediaType        = ".1.3.6.1.2.1.4.22.1.4"
+)
+
+// LLDP chassis and port ID subtypes that carry a MAC or network address rather than a name
+const (
+	lldpChassisMAC     = 4
+	lldpChassisAddress = 5
+	lldpPortMAC        = 3
+	lldpPortAddress    = 4
+
+	fdbStatusSelf   = 4 // dot1dTpFdbStatus self, the bridge's own address
+	arpTypeInvalid  = 2 // ipNetToMediaType invalid
+	cdpAddressBytes = 4 // cdpCacheAddress holds an IPv4 address for address type ip(1)
+)
+
+// Neighbor is a device adjacent to a polled device as reported by LLDP or CDP
+type Neighbor struct {
+	Protocol  string `json:"protocol"` // lldp or cdp
+	LocalPort string `json:"localPort"`
+	ChassisID string `json:"chassisId,omitempty"`
+	SysName   string `json:"sysName,omitempty"`
+	PortID    string `json:"portId,omitempty"`
+	Platform  string `json:"platform,omitempty"`
+	Address   string `json:"address,omitempty"` // Management address
+}
+
+// FDBEntry is a MAC address learned on a bridge port
+type FDBEntry struct {
+	MAC  string `json:"mac"`
+	Port string `json:"port"`
+}
+
+// ARPEntry maps an IP address to the MAC address it was last seen with
+type ARPEntry struct {
+	IP   string `json:"ip"`
+	MAC  string `json:"mac"`
+	Port string `json:"port"`
+}
+
+// DeviceNeighbors is the layer 2 and 3 adjacency information of one device
+type DeviceNeighbors struct {
+	Neighbors []Neighbor `json:"neighbors"`
+	FDB       []FDBEntry `json:"fdb"`
+	ARP       []ARPEntry `json:"arp"`
+}
+
+// CollectNeighbors walks the LLDP and CDP neighbour tables, the bridge forwarding database and the ARP
+// cache. Tables the device does not implement are empty; only a failing walk is an error.
+func (nm *NetworkManager) CollectNeighbors(snmp *gosnmp.GoSNMP) (DeviceNeighbors, error) {
+	var result DeviceNeighbors
+
+	ifNames, err := nm.interfaceNames(snmp)
+	if err != nil {
+		return result, err
+	}
+	port := func(ifIndex string) string {
+		if name, ok := ifNames[ifIndex]; ok {
+			return name
+		}
+		return ifIndex
+	}
+
+	if result.Neighbors, err = nm.lldpNeighbors(snmp, port); err != nil {
+		return result, err
+	}
+	cdp, err := nm.cdpNeighbors(snmp, port)
+	if err != nil {
+		return result, err
+	}
+	result.Neighbors = append(result.Neighbors, cdp...)
+
+	if result.FDB, err = nm.forwardingDatabase(snmp, port); err != nil {
+		return result, err
+	}
+	if result.ARP, err = nm.arpCache(snmp, port); err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+func (nm *NetworkManager) interfaceNames(snmp *gosnmp.GoSNMP) (map[string]string, error) {
+	rows, err := nm.WalkTable(snmp, oidIfDescr, oidIfName)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make(map[string]string, len(rows))
+	for index, values := range rows {
+		if name := pduString(values[oidIfName]); name != "" {
+			names[index] = name
+		} else {
+			names[index] = pduString(values[oidIfDescr])
+		}
+	}
+	return names, nil
+}
+
+func (nm *NetworkManager) lldpNeighbors(snmp *gosnmp.GoSNMP, port func(string) string) ([]Neighbor, error) {
+	remote, err := nm.WalkTable(snmp, oidLldpRemChassisSubtype, oidLldpRemChassisID, oidLldpRemPortSubtype,
+		oidLldpRemPortID, oidLldpRemPortDesc, oidLldpRemSysName, oidLldpRemSysDesc)
+	if err != nil || len(remote) == 0 {
+		return nil, err
+	}
+
+	local, err := nm.WalkTable(snmp, oidLldpLocPortID, oidLldpLocPortDesc)
+	if err != nil {
+		return nil, err
+	}
+
+	// lldpRemManAddrTable is indexed by the remote entry followed by the address itself
+	addresses := make(map[string]string)
+	managed, err := nm.WalkTable(snmp, oidLldpRemManAddrIf)
+	if err != nil {
+		return nil, err
+	}
+	for index := range managed {
+		parts := strings.Split(index, ".")
+		if len(parts) < 9 || parts[3] != "1" || parts[4] != "4" {
+			continue // Only IPv4 management addresses
+		}
+		key := strings.Join(parts[:3], ".")
+		if _, ok := addresses[key]; !ok {
+			addresses[key] = strings.Join(parts[5:9], ".")
+		}
+	}
+
+	var neighbors []Neighbor
+	for index, values := range remote {
+		// lldpRemTimeMark.lldpRemLocalPortNum.lldpRemIndex
+		parts := strings.Split(index, ".")
+		if len(parts) != 3 {
+			continue
+		}
+
+		localPort := pduString(local[parts[1]][oidLldpLocPortDesc])
+		if localPort == "" {
+			localPort = pduString(local[parts[1]][oidLldpLocPortID])
+		}
+		if localPort == "" {
+			// Most agents number LLDP ports by ifIndex
+			localPort = port(parts[1])
+		}
+
+		neighbor := Neighbor{
+			Protocol:  "lldp",
+			LocalPort: localPort,
+			ChassisID: lldpID(values[oidLldpRemChassisID], int(counterValue(values[oidLldpRemChassisSubtype])), lldpChassisMAC, lldpChassisAddress),
+			PortID:    lldpID(values[oidLldpRemPortID], int(counterValue(values[oidLldpRemPortSubtype])), lldpPortMAC, lldpPortAddress),
+			SysName:   pduString(values[oidLldpRemSysName]),
+			Platform:  firstLine(pduString(values[oidLldpRemSysDesc])),
+			Address:   addresses[index],
+		}
+		if neighbor.PortID == "" {
+			neighbor.PortID = pduString(values[oidLldpRemPortDesc])
+		}
+		neighbors = append(neighbors, neighbor)
+	}
+
+	sortNeighbors(neighbors)
+	return neighbors, nil
+}
+
+func (nm *NetworkManager) cdpNeighbors(snmp *gosnmp.GoSNMP, port func(string) string) ([]Neighbor, error) {
+	rows, err := nm.WalkTable(snmp, oidCdpCacheAddress, oidCdpCacheDeviceID, oidCdpCacheDevicePort, oidCdpCachePlatform)
+	if err != nil {
+		return nil, err
+	}
+
+	var neighbors []Neighbor
+	for index, values := range rows {
+		// cdpCacheIfIndex.cdpCacheDeviceIndex
+		parts := strings.Split(index, ".")
+		if len(parts) != 2 {
+			continue
+		}
+
+		neighbor := Neighbor{
+			Protocol:  "cdp",
+			LocalPort: port(parts[0]),
+			SysName:   pduString(values[oidCdpCacheDeviceID]),
+			PortID:    pduString(values[oidCdpCacheDevicePort]),
+			Platform:  pduString(values[oidCdpCachePlatform]),
+		}
+		if address, ok := values[oidCdpCacheAddress].Value.([]byte); ok && len(address) == cdpAddressBytes {
+			neighbor.Address = net.IP(address).String()
+		}
+		neighbors = append(neighbors, neighbor)
+	}
+
+	sortNeighbors(neighbors)
+	return neighbors, nil
+}
+
+// forwardingDatabase reads the learned MAC addresses per port from BRIDGE-MIB, falling back to the
+// per-VLAN Q-BRIDGE-MIB table that VLAN-aware switches populate instead
+func (nm *NetworkManager) forwardingDatabase(snmp *gosnmp.GoSNMP, port func(string) string) ([]FDBEntry, error) {
+	bridgePorts, err := nm.WalkTable(snmp, oidDot1dBasePortIfIndex)
+	if err != nil {
+		return nil, err
+	}
+	bridgePort := func(number uint64) string {
+		if ifIndex := counterValue(bridgePorts[strconv.FormatUint(number, 10)][oidDot1dBasePortIfIndex]); ifIndex > 0 {
+			return port(strconv.FormatUint(ifIndex, 10))
+		}
+		return strconv.FormatUint(number, 10)
+	}
+
+	tables := []struct{ port, status string }{
+		{oidDot1dTpFdbPort, oidDot1dTpFdbStatus},
+		{oidDot1qTpFdbPort, oidDot1qTpFdbStatus},
+	}
+
+	seen := make(map[string]bool)
+	var entries []FDBEntry
+	for _, table := range tables {
+		rows, err := nm.WalkTable(snmp, table.port, table.status)
+		if err != nil {
+			return nil, err
+		}
+
+		for index, values := range rows {
+			number := counterValue(values[table.port])
+			if number == 0 || counterValue(values[table.status]) == fdbStatusSelf {
+				continue
+			}
+
+			// The Q-BRIDGE index starts with the FDB ID, the MAC is always the last six components
+			mac, ok := macFromIndex(index)
+			if !ok {
+				continue
+			}
+			entry := FDBEntry{MAC: mac, Port: bridgePort(number)}
+			if key := entry.MAC + "|" + entry.Port; !seen[key] {
+				seen[key] = true
+				entries = append(entries, entry)
+			}
+		}
+		if len(entries) > 0 {
+			break
+		}
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Port != entries[j].Port {
+			return entries[i].Port < entries[j].Port
+		}
+		return entries[i].MAC < entries[j].MAC
+	})
+	return entries, nil
+}
+
+func (nm *NetworkManager) arpCache(snmp *gosnmp.GoSNMP, port func(string) string) ([]ARPEntry, error) {
+	rows, err := nm.WalkTable(snmp, oidIpNetToMediaPhysAddress, oidIpNetToMediaType)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []ARPEntry
+	for index, values := range rows {
+		// ipNetToMediaIfIndex.ipNetToMediaNetAddress
+		parts := strings.SplitN(index, ".", 2)
+		if len(parts) != 2 || net.ParseIP(parts[1]) == nil {
+			continue
+		}
+		physical, ok := values[oidIpNetToMediaPhysAddress].Value.([]byte)
+		if !ok || len(physical) != 6 || counterValue(values[oidIpNetToMediaType]) == arpTypeInvalid {
+			continue
+		}
+		entries = append(entries, ARPEntry{IP: parts[1], MAC: formatMAC(physical), Port: port(parts[0])})
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].IP < entries[j].IP })
+	return entries, nil
+}
+
+// lldpID renders a chassis or port ID according to its subtype
+func lldpID(variable gosnmp.SnmpPDU, subtype, macSubtype, addressSubtype int) string {
+	value, ok := variable.Value.([]byte)
+	if !ok {
+		return ""
+	}
+
+	switch {
+	case subtype == macSubtype && len(value) == 6:
+		return formatMAC(value)
+	case subtype == addressSubtype && len(value) == 5 && value[0] == 1:
+		// IANA address family 1 (IPv4) followed by the address
+		return net.IP(value[1:]).String()
+	}
+	if printable(value) {
+		return strings.TrimRight(string(value), "\x00")
+	}
+	return formatMAC(value)
+}
+
+// macFromIndex reads a MAC address from the last six components of a table index
+func macFromIndex(index string) (string, bool) {
+	parts := strings.Split(index, ".")
+	if len(parts) < 6 {
+		return "", false
+	}
+
+	mac := make([]byte, 6)
+	for i, part := range parts[len(parts)-6:] {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 || n > 255 {
+			return "", false
+		}
+		mac[i] = byte(n)
+	}
+	return formatMAC(mac), true
+}
+
+func formatMAC(b []byte) string {
+	parts := make([]string, len(b))
+	for i, octet := range b {
+		parts[i] = fmt.Sprintf("%02x", octet)
+	}
+	return strings.Join(parts, ":")
+}
+
+func printable(b []byte) bool {
+	for _, r := range strings.TrimRight(string(b), "\x00") {
+		if !unicode.IsPrint(r) {
+			return false
+		}
+	}
+	return len(b) > 0
+}
+
+func firstLine(s string) string {
+	if i := strings.IndexAny(s, "\r\n"); i >= 0 {
+		return s[:i]
+	}
+	return s
+}
+
+func sortNeighbors(neighbors []Neighbor) {
+	sort.Slice(neighbors, func(i, j int) bool {
+		if neighbors[i].LocalPort != neighbors[j].LocalPort {
+			return neighbors[i].LocalPort < neighbors[j].LocalPort
+		}
+		return neighbors[i].SysName < neighbors[j].SysName
+	})
+}
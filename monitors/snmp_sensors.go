@@ -0,0 +1,479 @@
+package monitors
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ZEGIFTED/MS.GoMonitor/pkg/utils"
+	mstypes "github.com/ZEGIFTED/MS.GoMonitor/types"
+	"github.com/gosnmp/gosnmp"
+)
+
+// UPS-MIB (RFC 1628), ENTITY-MIB and ENTITY-SENSOR-MIB (RFC 3433) objects
+const (
+	oidUpsBatteryStatus       = ".1.3.6.1.2.1.33.1.2.1.0"
+	oidUpsSecondsOnBattery    = ".1.3.6.1.2.1.33.1.2.2.0"
+	oidUpsMinutesRemaining    = ".1.3.6.1.2.1.33.1.2.3.0"
+	oidUpsChargeRemaining     = ".1.3.6.1.2.1.33.1.2.4.0"
+	oidUpsBatteryVoltage      = ".1.3.6.1.2.1.33.1.2.5.0"
+	oidUpsBatteryTemperature  = ".1.3.6.1.2.1.33.1.2.7.0"
+	oidUpsOutputSource        = ".1.3.6.1.2.1.33.1.4.1.0"
+	oidUpsConfigInputVoltage  = ".1.3.6.1.2.1.33.1.9.1.0"
+	oidUpsInputFrequency      = ".1.3.6.1.2.1.33.1.3.3.1.2"
+	oidUpsInputVoltage        = ".1.3.6.1.2.1.33.1.3.3.1.3"
+	oidUpsOutputPercentLoad   = ".1.3.6.1.2.1.33.1.4.4.1.5"
+	oidEntPhysicalDescr       = ".1.3.6.1.2.1.47.1.1.1.1.2"
+	oidEntPhysicalName        = ".1.3.6.1.2.1.47.1.1.1.1.7"
+	oidEntPhySensorType       = ".1.3.6.1.2.1.99.1.1.1.1"
+	oidEntPhySensorScale      = ".1.3.6.1.2.1.99.1.1.1.2"
+	oidEntPhySensorPrecision  = ".1.3.6.1.2.1.99.1.1.1.3"
+	oidEntPhySensorValue      = ".1.3.6.1.2.1.99.1.1.1.4"
+	oidEntPhySensorOperStatus = ".1.3.6.1.2.1.99.1.1.1.5"
+	oidEntPhySensorUnits      = ".1.3.6.1.2.1.99.1.1.1.6"
+)
+
+// Sensor reading types
+const (
+	SensorBatteryCharge    = "batteryCharge"    // Percent
+	SensorBatteryStatus    = "batteryStatus"    // UPS-MIB upsBatteryStatus, 2 normal, 3 low, 4 depleted
+	SensorRuntimeRemaining = "runtimeRemaining" // Minutes
+	SensorOnBattery        = "onBattery"        // 1 while the load runs on battery
+	SensorInputVoltage     = "inputVoltage"     // Volts RMS of an input line
+	SensorOutputLoad       = "outputLoad"       // Percent of the rated capacity
+	SensorVoltage          = "voltage"
+	SensorCurrent          = "current"
+	SensorPower            = "power"
+	SensorFrequency        = "frequency"
+	SensorTemperature      = "temperature" // Celsius unless the device is configured for fahrenheit
+	SensorHumidity         = "humidity"    // Percent relative humidity
+	SensorFanSpeed         = "fanSpeed"
+	SensorAirflow          = "airflow"
+	SensorState            = "state" // 1 true, 0 false
+	SensorOther            = "other"
+)
+
+var upsBatteryStatuses = map[int]string{1: "unknown", 2: "normal", 3: "low", 4: "depleted"}
+
+var entitySensorStatuses = map[int]string{1: "ok", 2: "unavailable", 3: "nonoperational"}
+
+// entitySensorTypes maps the ENTITY-SENSOR-MIB EntitySensorDataType to the reading type and unit
+var entitySensorTypes = map[int]struct{ Type, Unit string }{
+	1:  {SensorOther, ""},
+	2:  {SensorOther, ""},
+	3:  {SensorVoltage, "V AC"},
+	4:  {SensorVoltage, "V DC"},
+	5:  {SensorCurrent, "A"},
+	6:  {SensorPower, "W"},
+	7:  {SensorFrequency, "Hz"},
+	8:  {SensorTemperature, "°C"},
+	9:  {SensorHumidity, "%RH"},
+	10: {SensorFanSpeed, "rpm"},
+	11: {SensorAirflow, "m³/min"},
+	12: {SensorState, ""},
+}
+
+// GetUPSSensors reads the battery, input and output groups of UPS-MIB. Objects the agent does not
+// implement are left out. When only the line tables cannot be walked the other readings are returned
+// together with the error.
+func (nm *NetworkManager) GetUPSSensors(snmp *gosnmp.GoSNMP) ([]mstypes.SensorReading, error) {
+	scalars := []string{
+		oidUpsBatteryStatus, oidUpsSecondsOnBattery, oidUpsMinutesRemaining, oidUpsChargeRemaining,
+		oidUpsBatteryVoltage, oidUpsBatteryTemperature, oidUpsOutputSource, oidUpsConfigInputVoltage,
+	}
+	packet, err := snmp.Get(scalars)
+	if err != nil {
+		return nil, fmt.Errorf("UPS-MIB get failed: %v", err)
+	}
+	values := make(map[string]gosnmp.SnmpPDU)
+	for _, variable := range packet.Variables {
+		switch variable.Type {
+		case gosnmp.NoSuchObject, gosnmp.NoSuchInstance, gosnmp.EndOfMibView, gosnmp.Null:
+			continue
+		}
+		values[variable.Name] = variable
+	}
+
+	var readings []mstypes.SensorReading
+	add := func(index, name, sensorType string, value float64, unit string, precision int) {
+		readings = append(readings, mstypes.SensorReading{
+			Source:    TableUPS,
+			Index:     index,
+			Name:      name,
+			Type:      sensorType,
+			Value:     roundTo(value, precision),
+			Unit:      unit,
+			Precision: precision,
+			Status:    "ok",
+		})
+	}
+
+	if v, ok := values[oidUpsChargeRemaining]; ok {
+		add("batteryCharge", "Battery charge", SensorBatteryCharge, float64(counterValue(v)), "%", 0)
+	}
+	if v, ok := values[oidUpsMinutesRemaining]; ok {
+		add("runtimeRemaining", "Runtime remaining", SensorRuntimeRemaining, float64(counterValue(v)), "min", 0)
+	}
+	if v, ok := values[oidUpsBatteryStatus]; ok {
+		code := int(counterValue(v))
+		add("batteryStatus", "Battery status", SensorBatteryStatus, float64(code), "", 0)
+		readings[len(readings)-1].Status = upsBatteryStatuses[code]
+	}
+
+	// upsOutputSource battery(5) is authoritative, upsSecondsOnBattery is 0 while on utility power
+	if v, ok := values[oidUpsOutputSource]; ok {
+		add("onBattery", "On battery", SensorOnBattery, boolValue(counterValue(v) == 5), "", 0)
+	} else if v, ok := values[oidUpsSecondsOnBattery]; ok {
+		add("onBattery", "On battery", SensorOnBattery, boolValue(counterValue(v) > 0), "", 0)
+	}
+
+	// 0.1 volt DC
+	if v, ok := values[oidUpsBatteryVoltage]; ok {
+		add("batteryVoltage", "Battery voltage", SensorVoltage, float64(counterValue(v))/10, "V DC", 1)
+	}
+	if v, ok := values[oidUpsBatteryTemperature]; ok {
+		if t, ok := v.Value.(int); ok {
+			add("batteryTemperature", "Battery temperature", SensorTemperature, float64(t), "°C", 0)
+		}
+	}
+
+	// The scalars are still worth reporting when the line tables cannot be walked
+	rows, err := nm.WalkTable(snmp, oidUpsInputVoltage, oidUpsInputFrequency, oidUpsOutputPercentLoad)
+	if err != nil {
+		return readings, fmt.Errorf("walking the UPS input and output tables: %v", err)
+	}
+	nominal := float64(counterValue(values[oidUpsConfigInputVoltage]))
+
+	indexes := make([]string, 0, len(rows))
+	for index := range rows {
+		indexes = append(indexes, index)
+	}
+	sortByIndex(indexes, func(index string) string { return index })
+
+	// The input and output tables share their line numbers
+	for _, line := range indexes {
+		row := rows[line]
+		if v, ok := row[oidUpsInputVoltage]; ok {
+			add("input."+line+".voltage", "Input "+line+" voltage", SensorInputVoltage, float64(counterValue(v)), "V", 0)
+			readings[len(readings)-1].Nominal = nominal
+		}
+		// 0.1 Hertz
+		if v, ok := row[oidUpsInputFrequency]; ok {
+			add("input."+line+".frequency", "Input "+line+" frequency", SensorFrequency, float64(counterValue(v))/10, "Hz", 1)
+		}
+	}
+	for _, line := range indexes {
+		if v, ok := rows[line][oidUpsOutputPercentLoad]; ok {
+			add("output."+line+".load", "Output "+line+" load", SensorOutputLoad, float64(counterValue(v)), "%", 0)
+		}
+	}
+
+	return readings, nil
+}
+
+// GetEntitySensors walks the ENTITY-SENSOR-MIB entPhySensorTable. Values are converted from the
+// sensor's scale and precision, e.g. 2345 millivolts with precision 1 is 0.2345 V.
+func (nm *NetworkManager) GetEntitySensors(snmp *gosnmp.GoSNMP) ([]mstypes.SensorReading, error) {
+	rows, err := nm.WalkTable(snmp, oidEntPhySensorType, oidEntPhySensorScale, oidEntPhySensorPrecision,
+		oidEntPhySensorValue, oidEntPhySensorOperStatus, oidEntPhySensorUnits, oidEntPhysicalName, oidEntPhysicalDescr)
+	if err != nil {
+		return nil, err
+	}
+
+	var readings []mstypes.SensorReading
+	for index, values := range rows {
+		variable, ok := values[oidEntPhySensorValue]
+		if !ok {
+			// An entity that is not a sensor
+			continue
+		}
+
+		raw, _ := variable.Value.(int)
+		kind := entitySensorTypes[int(counterValue(values[oidEntPhySensorType]))]
+		if kind.Type == "" {
+			kind.Type = SensorOther
+		}
+		// entPhySensorUnitsDisplay describes the units of the value as reported, including its scale
+		unit, scaled := kind.Unit, true
+		if unit == "" {
+			unit = pduString(values[oidEntPhySensorUnits])
+			scaled = unit == ""
+		}
+
+		name := pduString(values[oidEntPhysicalName])
+		if name == "" {
+			name = pduString(values[oidEntPhysicalDescr])
+		}
+		if name == "" {
+			name = "Sensor " + index
+		}
+
+		status := entitySensorStatuses[int(counterValue(values[oidEntPhySensorOperStatus]))]
+		if status == "" {
+			status = "ok"
+		}
+
+		value, precision := float64(raw), 0
+		if kind.Type == SensorState {
+			// TruthValue, true(1) and false(2)
+			value = boolValue(raw == 1)
+		} else {
+			// EntitySensorDataScale units(9) is 10^0, each step a factor of 1000
+			exponent := 0
+			if scale, ok := values[oidEntPhySensorScale]; ok && scaled {
+				exponent = 3 * (int(counterValue(scale)) - 9)
+			}
+			digits, _ := values[oidEntPhySensorPrecision].Value.(int)
+			value = float64(raw) * math.Pow10(exponent-digits)
+			precision = min(max(digits-exponent, 0), 9)
+		}
+
+		readings = append(readings, mstypes.SensorReading{
+			Source:    TableSensors,
+			Index:     index,
+			Name:      name,
+			Type:      kind.Type,
+			Value:     roundTo(value, precision),
+			Unit:      unit,
+			Precision: precision,
+			Status:    status,
+		})
+	}
+
+	sortByIndex(readings, func(r mstypes.SensorReading) string { return r.Index })
+	return readings, nil
+}
+
+// SensorMetrics stores every reading as a table metric named by its type
+func SensorMetrics(SystemMonitorId, Host string, readings []mstypes.SensorReading) []mstypes.NetworkTableMetric {
+	polled := time.Now()
+	metrics := make([]mstypes.NetworkTableMetric, 0, len(readings))
+	for _, reading := range readings {
+		labels := map[string]string{"sensorSource": reading.Source, "sensorIndex": reading.Index, "sensorName": reading.Name}
+		if reading.Unit != "" {
+			labels["unit"] = reading.Unit
+		}
+		metrics = append(metrics, mstypes.NetworkTableMetric{
+			SystemMonitorId: SystemMonitorId,
+			DeviceIP:        Host,
+			MetricName:      reading.Type,
+			Labels:          labels,
+			Value:           reading.Value,
+			CollectedAt:     polled,
+		})
+	}
+	return metrics
+}
+
+// SensorLimits are the thresholds of a sensor, a reading at or beyond a limit raises an alert. Unset
+// limits are not checked.
+type SensorLimits struct {
+	WarnLow      *float64 `json:"warnLow,omitempty"`
+	CriticalLow  *float64 `json:"criticalLow,omitempty"`
+	WarnHigh     *float64 `json:"warnHigh,omitempty"`
+	CriticalHigh *float64 `json:"criticalHigh,omitempty"`
+}
+
+func (l SensorLimits) isSet() bool {
+	return l.WarnLow != nil || l.CriticalLow != nil || l.WarnHigh != nil || l.CriticalHigh != nil
+}
+
+// merge returns l with the limits set in override replaced
+func (l SensorLimits) merge(override SensorLimits) SensorLimits {
+	for _, pair := range []struct{ dst, src **float64 }{
+		{&l.WarnLow, &override.WarnLow},
+		{&l.CriticalLow, &override.CriticalLow},
+		{&l.WarnHigh, &override.WarnHigh},
+		{&l.CriticalHigh, &override.CriticalHigh},
+	} {
+		if *pair.src != nil {
+			*pair.dst = *pair.src
+		}
+	}
+	return l
+}
+
+func limit(v float64) *float64 { return &v }
+
+// defaultSensorLimits are in the units the collectors report, temperatures in Celsius. The
+// environmental limits follow the ASHRAE allowable range for data centre equipment, temperature
+// limits are only applied to air and battery temperatures (see ambientSensor).
+var defaultSensorLimits = map[string]SensorLimits{
+	SensorBatteryCharge:    {WarnLow: limit(50), CriticalLow: limit(20)},
+	SensorBatteryStatus:    {WarnHigh: limit(3), CriticalHigh: limit(4)},
+	SensorRuntimeRemaining: {WarnLow: limit(15), CriticalLow: limit(5)},
+	SensorOnBattery:        {CriticalHigh: limit(1)},
+	SensorOutputLoad:       {WarnHigh: limit(80), CriticalHigh: limit(95)},
+	SensorTemperature:      {WarnHigh: limit(32), CriticalHigh: limit(40)},
+	SensorHumidity:         {WarnLow: limit(20), CriticalLow: limit(8), WarnHigh: limit(70), CriticalHigh: limit(80)},
+}
+
+// ambientSensorNames mark ENTITY-SENSOR temperatures of the air around a device. CPU, ASIC and
+// board sensors run far hotter and only get the limits configured for them.
+var ambientSensorNames = []string{"inlet", "intake", "ambient", "room", "environment", "probe"}
+
+// ambientSensor reports whether a temperature reading is checked against the default limits
+func ambientSensor(reading mstypes.SensorReading) bool {
+	if reading.Source == TableUPS {
+		return true
+	}
+	name := strings.ToLower(reading.Name)
+	for _, ambient := range ambientSensorNames {
+		if strings.Contains(name, ambient) {
+			return true
+		}
+	}
+	return false
+}
+
+// Input voltage limits relative to the nominal voltage, when none are configured
+const (
+	inputVoltageWarnDeviation     = 0.10
+	inputVoltageCriticalDeviation = 0.15
+)
+
+// SensorConfig holds the sensor settings of a device
+type SensorConfig struct {
+	TemperatureUnit string                  // celsius or fahrenheit
+	Thresholds      map[string]SensorLimits // By sensor type or sensor name, a name takes precedence
+}
+
+// ParseSensorConfig reads temperatureUnit and sensorThresholds from a device Configuration, e.g.
+// {"temperature": {"warnHigh": 27}, "Rack 4 inlet": {"criticalHigh": 30}}. Temperature thresholds are
+// given in the configured unit.
+func ParseSensorConfig(cfg map[string]any) (SensorConfig, error) {
+	config := SensorConfig{Thresholds: make(map[string]SensorLimits)}
+
+	switch unit := utils.ConfigString(cfg, "temperatureUnit", "celsius"); strings.ToLower(unit) {
+	case "celsius", "c":
+		config.TemperatureUnit = "celsius"
+	case "fahrenheit", "f":
+		config.TemperatureUnit = "fahrenheit"
+	default:
+		return config, fmt.Errorf("unknown temperatureUnit %q, expected celsius or fahrenheit", unit)
+	}
+
+	if thresholds := utils.ConfigMap(cfg, "sensorThresholds"); thresholds != nil {
+		data, _ := json.Marshal(thresholds)
+		if err := json.Unmarshal(data, &config.Thresholds); err != nil {
+			return config, fmt.Errorf("invalid sensorThresholds: %v", err)
+		}
+	}
+	return config, nil
+}
+
+// Apply converts the readings to the configured units and checks them against their thresholds. The
+// returned readings carry the severity of the limit they crossed.
+func (c SensorConfig) Apply(readings []mstypes.SensorReading) ([]mstypes.SensorReading, []ObjectAlert) {
+	var alerts []ObjectAlert
+	applied := make([]mstypes.SensorReading, 0, len(readings))
+
+	for _, reading := range readings {
+		limits := defaultSensorLimits[reading.Type]
+		if reading.Type == SensorTemperature && !ambientSensor(reading) {
+			limits = SensorLimits{}
+		}
+		if reading.Type == SensorTemperature && c.TemperatureUnit == "fahrenheit" {
+			reading.Value = roundTo(celsiusToFahrenheit(reading.Value), reading.Precision)
+			reading.Unit = "°F"
+			limits = limits.convert(celsiusToFahrenheit)
+		}
+		if reading.Type == SensorInputVoltage && reading.Nominal > 0 {
+			limits = SensorLimits{
+				WarnLow:      limit(reading.Nominal * (1 - inputVoltageWarnDeviation)),
+				CriticalLow:  limit(reading.Nominal * (1 - inputVoltageCriticalDeviation)),
+				WarnHigh:     limit(reading.Nominal * (1 + inputVoltageWarnDeviation)),
+				CriticalHigh: limit(reading.Nominal * (1 + inputVoltageCriticalDeviation)),
+			}
+		}
+		limits = limits.merge(c.Thresholds[reading.Type]).merge(c.Thresholds[reading.Name])
+
+		switch {
+		case reading.Status == "nonoperational":
+			reading.Severity = "warning"
+			alerts = append(alerts, ObjectAlert{Kind: "Sensor", Name: reading.Name, Reason: "SensorFailed", Message: "sensor is nonoperational", Severity: "warning"})
+		case reading.Status == "unavailable" || !limits.isSet():
+		default:
+			if alert, ok := limits.check(reading); ok {
+				reading.Severity = alert.Severity
+				alerts = append(alerts, alert)
+			}
+		}
+		applied = append(applied, reading)
+	}
+
+	return applied, alerts
+}
+
+// check returns the alert for the most severe limit the reading crossed
+func (l SensorLimits) check(reading mstypes.SensorReading) (ObjectAlert, bool) {
+	for _, bound := range []struct {
+		limit    *float64
+		high     bool
+		severity string
+	}{
+		{l.CriticalHigh, true, "critical"},
+		{l.CriticalLow, false, "critical"},
+		{l.WarnHigh, true, "warning"},
+		{l.WarnLow, false, "warning"},
+	} {
+		if bound.limit == nil || (bound.high && reading.Value < *bound.limit) || (!bound.high && reading.Value > *bound.limit) {
+			continue
+		}
+
+		alert := ObjectAlert{Kind: "Sensor", Name: reading.Name, Severity: bound.severity}
+		direction := "above"
+		if !bound.high {
+			direction = "below"
+		}
+		switch reading.Type {
+		case SensorOnBattery:
+			alert.Reason, alert.Message = "OnBattery", "load is running on battery"
+		case SensorBatteryStatus:
+			alert.Reason, alert.Message = "BatteryLow", "battery is "+reading.Status
+		default:
+			alert.Reason = "Low" + strings.ToUpper(reading.Type[:1]) + reading.Type[1:]
+			if bound.high {
+				alert.Reason = "High" + alert.Reason[3:]
+			}
+			alert.Message = fmt.Sprintf("%s (%s %s %s)", formatSensorValue(reading.Value, reading), bound.severity, direction, formatSensorValue(*bound.limit, reading))
+		}
+		return alert, true
+	}
+	return ObjectAlert{}, false
+}
+
+func (l SensorLimits) convert(fn func(float64) float64) SensorLimits {
+	for _, p := range []**float64{&l.WarnLow, &l.CriticalLow, &l.WarnHigh, &l.CriticalHigh} {
+		if *p != nil {
+			*p = limit(fn(**p))
+		}
+	}
+	return l
+}
+
+// formatSensorValue renders a value with the precision and unit of the reading, e.g. 23.5 °C
+func formatSensorValue(value float64, reading mstypes.SensorReading) string {
+	s := strconv.FormatFloat(value, 'f', reading.Precision, 64)
+	if reading.Unit != "" {
+		s += " " + reading.Unit
+	}
+	return s
+}
+
+func celsiusToFahrenheit(c float64) float64 { return c*9/5 + 32 }
+
+func roundTo(value float64, precision int) float64 {
+	p := math.Pow10(precision)
+	return math.Round(value*p) / p
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,293 @@
+package monitors
+
+import (
+	"strings"
+	"testing"
+
+	mstypes "github.com/ZEGIFTED/MS.GoMonitor/types"
+)
+
+func TestSensorConfigApply(t *testing.T) {
+	celsius := SensorConfig{TemperatureUnit: "celsius"}
+	ups := func(name, sensorType string, value float64, unit string) mstypes.SensorReading {
+		return mstypes.SensorReading{Source: TableUPS, Name: name, Type: sensorType, Value: value, Unit: unit, Precision: 1, Status: "ok"}
+	}
+	sensor := func(name, sensorType string, value float64, unit string) mstypes.SensorReading {
+		return mstypes.SensorReading{Source: TableSensors, Name: name, Type: sensorType, Value: value, Unit: unit, Precision: 1, Status: "ok"}
+	}
+
+	tests := []struct {
+		name        string
+		config      SensorConfig
+		reading     mstypes.SensorReading
+		wantValue   float64
+		wantUnit    string
+		wantReason  string // Empty when no alert is expected
+		wantMessage string
+		severity    string
+	}{
+		{
+			name:    "battery charge within limits",
+			config:  celsius,
+			reading: ups("Battery", SensorBatteryCharge, 60, "%"),
+		},
+		{
+			name:        "battery charge at the warning limit",
+			config:      celsius,
+			reading:     ups("Battery", SensorBatteryCharge, 50, "%"),
+			wantReason:  "LowBatteryCharge",
+			wantMessage: "50.0 % (warning below 50.0 %)",
+			severity:    "warning",
+		},
+		{
+			name:        "battery charge below the critical limit",
+			config:      celsius,
+			reading:     ups("Battery", SensorBatteryCharge, 10, "%"),
+			wantReason:  "LowBatteryCharge",
+			wantMessage: "10.0 % (critical below 20.0 %)",
+			severity:    "critical",
+		},
+		{
+			name:        "running on battery",
+			config:      celsius,
+			reading:     ups("Output source", SensorOnBattery, 1, ""),
+			wantReason:  "OnBattery",
+			wantMessage: "load is running on battery",
+			severity:    "critical",
+		},
+		{
+			name:        "battery status low",
+			config:      celsius,
+			reading:     mstypes.SensorReading{Source: TableUPS, Name: "Battery", Type: SensorBatteryStatus, Value: 3, Status: "low"},
+			wantReason:  "BatteryLow",
+			wantMessage: "battery is low",
+			severity:    "warning",
+		},
+		{
+			name:        "UPS battery temperature uses the default limits",
+			config:      celsius,
+			reading:     ups("Battery", SensorTemperature, 35, "°C"),
+			wantReason:  "HighTemperature",
+			wantMessage: "35.0 °C (warning above 32.0 °C)",
+			severity:    "warning",
+		},
+		{
+			name:    "CPU temperature has no default limits",
+			config:  celsius,
+			reading: sensor("CPU 1", SensorTemperature, 85, "°C"),
+		},
+		{
+			name:        "inlet temperature uses the default limits",
+			config:      celsius,
+			reading:     sensor("Rack 4 Inlet", SensorTemperature, 42, "°C"),
+			wantReason:  "HighTemperature",
+			wantMessage: "42.0 °C (critical above 40.0 °C)",
+			severity:    "critical",
+		},
+		{
+			name:        "CPU temperature with a limit configured by name",
+			config:      SensorConfig{TemperatureUnit: "celsius", Thresholds: map[string]SensorLimits{"CPU 1": {CriticalHigh: limit(80)}}},
+			reading:     sensor("CPU 1", SensorTemperature, 85, "°C"),
+			wantReason:  "HighTemperature",
+			wantMessage: "85.0 °C (critical above 80.0 °C)",
+			severity:    "critical",
+		},
+		{
+			name:        "fahrenheit converts the reading and the default limits",
+			config:      SensorConfig{TemperatureUnit: "fahrenheit"},
+			reading:     ups("Battery", SensorTemperature, 35, "°C"),
+			wantValue:   95,
+			wantUnit:    "°F",
+			wantReason:  "HighTemperature",
+			wantMessage: "95.0 °F (warning above 89.6 °F)",
+			severity:    "warning",
+		},
+		{
+			name:        "fahrenheit thresholds are not converted",
+			config:      SensorConfig{TemperatureUnit: "fahrenheit", Thresholds: map[string]SensorLimits{SensorTemperature: {WarnHigh: limit(80)}}},
+			reading:     sensor("Room", SensorTemperature, 28, "°C"),
+			wantValue:   82.4,
+			wantUnit:    "°F",
+			wantReason:  "HighTemperature",
+			wantMessage: "82.4 °F (warning above 80.0 °F)",
+			severity:    "warning",
+		},
+		{
+			name:        "input voltage relative to the nominal voltage",
+			config:      celsius,
+			reading:     mstypes.SensorReading{Source: TableUPS, Name: "Input 1", Type: SensorInputVoltage, Value: 200, Unit: "V", Nominal: 230, Status: "ok"},
+			wantReason:  "LowInputVoltage",
+			wantMessage: "200 V (warning below 207 V)",
+			severity:    "warning",
+		},
+		{
+			name:    "input voltage without a nominal voltage",
+			config:  celsius,
+			reading: mstypes.SensorReading{Source: TableUPS, Name: "Input 1", Type: SensorInputVoltage, Value: 150, Unit: "V", Status: "ok"},
+		},
+		{
+			name: "sensor name overrides the type threshold",
+			config: SensorConfig{TemperatureUnit: "celsius", Thresholds: map[string]SensorLimits{
+				SensorHumidity: {WarnHigh: limit(60)},
+				"Server room":  {WarnHigh: limit(68)},
+			}},
+			reading: sensor("Server room", SensorHumidity, 65, "%"),
+		},
+		{
+			name:        "type threshold replaces the default",
+			config:      SensorConfig{TemperatureUnit: "celsius", Thresholds: map[string]SensorLimits{SensorHumidity: {WarnHigh: limit(60)}}},
+			reading:     sensor("Hall", SensorHumidity, 65, "%"),
+			wantReason:  "HighHumidity",
+			wantMessage: "65.0 % (warning above 60.0 %)",
+			severity:    "warning",
+		},
+		{
+			name:        "nonoperational sensor",
+			config:      celsius,
+			reading:     mstypes.SensorReading{Source: TableSensors, Name: "Probe 2", Type: SensorTemperature, Status: "nonoperational"},
+			wantReason:  "SensorFailed",
+			wantMessage: "sensor is nonoperational",
+			severity:    "warning",
+		},
+		{
+			name:    "unavailable sensor is not checked",
+			config:  celsius,
+			reading: mstypes.SensorReading{Source: TableSensors, Name: "Probe 2", Type: SensorHumidity, Value: 0, Status: "unavailable"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			readings, alerts := tt.config.Apply([]mstypes.SensorReading{tt.reading})
+			if len(readings) != 1 {
+				t.Fatalf("%d readings, want 1", len(readings))
+			}
+			got := readings[0]
+
+			wantValue, wantUnit := tt.reading.Value, tt.reading.Unit
+			if tt.wantUnit != "" {
+				wantValue, wantUnit = tt.wantValue, tt.wantUnit
+			}
+			if got.Value != wantValue || got.Unit != wantUnit {
+				t.Errorf("reading = %v %s, want %v %s", got.Value, got.Unit, wantValue, wantUnit)
+			}
+			if got.Severity != tt.severity {
+				t.Errorf("reading severity = %q, want %q", got.Severity, tt.severity)
+			}
+
+			if tt.wantReason == "" {
+				if len(alerts) != 0 {
+					t.Fatalf("unexpected alerts %+v", alerts)
+				}
+				return
+			}
+			if len(alerts) != 1 {
+				t.Fatalf("%d alerts, want 1", len(alerts))
+			}
+			alert := alerts[0]
+			if alert.Kind != "Sensor" || alert.Name != tt.reading.Name || alert.Reason != tt.wantReason ||
+				alert.Message != tt.wantMessage || alert.Severity != tt.severity {
+				t.Errorf("alert = %+v, want %s %q %s", alert, tt.wantReason, tt.wantMessage, tt.severity)
+			}
+		})
+	}
+}
+
+func TestParseSensorConfig(t *testing.T) {
+	tests := []struct {
+		name     string
+		cfg      map[string]any
+		wantUnit string
+		wantErr  string
+		check    func(t *testing.T, config SensorConfig)
+	}{
+		{
+			name:     "defaults to celsius without thresholds",
+			cfg:      map[string]any{},
+			wantUnit: "celsius",
+		},
+		{
+			name:     "short fahrenheit unit",
+			cfg:      map[string]any{"temperatureUnit": "F"},
+			wantUnit: "fahrenheit",
+		},
+		{
+			name:    "unknown unit",
+			cfg:     map[string]any{"temperatureUnit": "kelvin"},
+			wantErr: "unknown temperatureUnit",
+		},
+		{
+			name: "thresholds by type and name",
+			cfg: map[string]any{"sensorThresholds": map[string]any{
+				"temperature":  map[string]any{"warnHigh": 27},
+				"Rack 4 inlet": map[string]any{"criticalHigh": 30.5, "warnLow": 10},
+			}},
+			wantUnit: "celsius",
+			check: func(t *testing.T, config SensorConfig) {
+				if l := config.Thresholds["temperature"]; l.WarnHigh == nil || *l.WarnHigh != 27 || l.CriticalHigh != nil {
+					t.Errorf("temperature limits = %+v", l)
+				}
+				if l := config.Thresholds["Rack 4 inlet"]; l.CriticalHigh == nil || *l.CriticalHigh != 30.5 || l.WarnLow == nil || *l.WarnLow != 10 {
+					t.Errorf("Rack 4 inlet limits = %+v", l)
+				}
+			},
+		},
+		{
+			name:     "thresholds given as a JSON string",
+			cfg:      map[string]any{"sensorThresholds": `{"humidity": {"criticalHigh": 75}}`},
+			wantUnit: "celsius",
+			check: func(t *testing.T, config SensorConfig) {
+				if l := config.Thresholds["humidity"]; l.CriticalHigh == nil || *l.CriticalHigh != 75 {
+					t.Errorf("humidity limits = %+v", l)
+				}
+			},
+		},
+		{
+			name:    "threshold that is not a number",
+			cfg:     map[string]any{"sensorThresholds": map[string]any{"humidity": map[string]any{"warnHigh": "damp"}}},
+			wantErr: "invalid sensorThresholds",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := ParseSensorConfig(tt.cfg)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseSensorConfig: %v", err)
+			}
+			if config.TemperatureUnit != tt.wantUnit {
+				t.Errorf("unit = %q, want %q", config.TemperatureUnit, tt.wantUnit)
+			}
+			if tt.check != nil {
+				tt.check(t, config)
+			}
+		})
+	}
+}
+
+func TestAmbientSensor(t *testing.T) {
+	tests := []struct {
+		source, name string
+		want         bool
+	}{
+		{TableUPS, "Battery", true},
+		{TableSensors, "Inlet Temp 1", true},
+		{TableSensors, "Ambient", true},
+		{TableSensors, "Env Probe 3", true},
+		{TableSensors, "CPU 1", false},
+		{TableSensors, "Board Temp", false},
+	}
+
+	for _, tt := range tests {
+		reading := mstypes.SensorReading{Source: tt.source, Name: tt.name, Type: SensorTemperature}
+		if got := ambientSensor(reading); got != tt.want {
+			t.Errorf("ambientSensor(%s %q) = %v, want %v", tt.source, tt.name, got, tt.want)
+		}
+	}
+}
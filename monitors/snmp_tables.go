@@ -0,0 +1,442 @@
+package monitors
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	mstypes "github.com/ZEGIFTED/MS.GoMonitor/types"
+	"github.com/gosnmp/gosnmp"
+)
+
+// IF-MIB ifTable and ifXTable columns
+const (
+	oidIfDescr         = ".1.3.6.1.2.1.2.2.1.2"
+	oidIfType          = ".1.3.6.1.2.1.2.2.1.3"
+	oidIfAdminStatus   = ".1.3.6.1.2.1.2.2.1.7"
+	oidIfOperStatus    = ".1.3.6.1.2.1.2.2.1.8"
+	oidIfInOctets      = ".1.3.6.1.2.1.2.2.1.10"
+	oidIfInDiscards    = ".1.3.6.1.2.1.2.2.1.13"
+	oidIfInErrors      = ".1.3.6.1.2.1.2.2.1.14"
+	oidIfOutOctets     = ".1.3.6.1.2.1.2.2.1.16"
+	oidIfOutDiscards   = ".1.3.6.1.2.1.2.2.1.19"
+	oidIfOutErrors     = ".1.3.6.1.2.1.2.2.1.20"
+	oidIfName          = ".1.3.6.1.2.1.31.1.1.1.1"
+	oidIfHCInOctets    = ".1.3.6.1.2.1.31.1.1.1.6"
+	oidIfHCOutOctets   = ".1.3.6.1.2.1.31.1.1.1.10"
+	oidIfAlias         = ".1.3.6.1.2.1.31.1.1.1.18"
+	oidHrStorageType   = ".1.3.6.1.2.1.25.2.3.1.2"
+	oidHrStorageDescr  = ".1.3.6.1.2.1.25.2.3.1.3"
+	oidHrStorageUnits  = ".1.3.6.1.2.1.25.2.3.1.4"
+	oidHrStorageSize   = ".1.3.6.1.2.1.25.2.3.1.5"
+	oidHrStorageUsed   = ".1.3.6.1.2.1.25.2.3.1.6"
+	oidHrProcessorLoad = ".1.3.6.1.2.1.25.3.3.1.2"
+)
+
+// SNMP tables the collector knows how to walk
+const (
+	TableInterfaces = "interfaces"
+	TableStorage    = "storage"
+	TableProcessors = "processors"
+	TableUPS        = "ups"     // UPS-MIB battery, input and output readings
+	TableSensors    = "sensors" // ENTITY-SENSOR-MIB sensors, e.g. of a PDU or an environmental probe
+)
+
+// hrStorageTypes names the HOST-RESOURCES-TYPES storage types
+var hrStorageTypes = map[string]string{
+	".1.3.6.1.2.1.25.2.1.1":  "other",
+	".1.3.6.1.2.1.25.2.1.2":  "ram",
+	".1.3.6.1.2.1.25.2.1.3":  "virtualMemory",
+	".1.3.6.1.2.1.25.2.1.4":  "fixedDisk",
+	".1.3.6.1.2.1.25.2.1.5":  "removableDisk",
+	".1.3.6.1.2.1.25.2.1.6":  "floppyDisk",
+	".1.3.6.1.2.1.25.2.1.7":  "compactDisc",
+	".1.3.6.1.2.1.25.2.1.8":  "ramDisk",
+	".1.3.6.1.2.1.25.2.1.9":  "flashMemory",
+	".1.3.6.1.2.1.25.2.1.10": "networkDisk",
+}
+
+// interfaceCounters are the per-interface counters reported as rates. The 64-bit ifXTable octet
+// counters are preferred over the ifTable ones, which wrap within seconds on fast links.
+var interfaceCounters = []struct {
+	Name      string
+	Columns   []string
+	Direction string // Set for the octet counters utilization is derived from
+}{
+	{Name: "ifInOctets", Columns: []string{oidIfHCInOctets, oidIfInOctets}, Direction: "in"},
+	{Name: "ifOutOctets", Columns: []string{oidIfHCOutOctets, oidIfOutOctets}, Direction: "out"},
+	{Name: "ifInErrors", Columns: []string{oidIfInErrors}},
+	{Name: "ifOutErrors", Columns: []string{oidIfOutErrors}},
+	{Name: "ifInDiscards", Columns: []string{oidIfInDiscards}},
+	{Name: "ifOutDiscards", Columns: []string{oidIfOutDiscards}},
+}
+
+// TableRows maps a row index (the OID suffix after the column) to the row's varbinds keyed by column OID
+type TableRows map[string]map[string]gosnmp.SnmpPDU
+
+// WalkTable walks each column and groups the values by row index. Columns the agent does not
+// implement are left out of the rows.
+func (nm *NetworkManager) WalkTable(snmp *gosnmp.GoSNMP, columns ...string) (TableRows, error) {
+	rows := make(TableRows)
+
+	for _, column := range columns {
+		column = "." + strings.TrimPrefix(column, ".")
+		walkFn := func(pdu gosnmp.SnmpPDU) error {
+			index := strings.TrimPrefix(pdu.Name, column+".")
+			if index == pdu.Name {
+				return nil
+			}
+			switch pdu.Type {
+			case gosnmp.NoSuchObject, gosnmp.NoSuchInstance, gosnmp.EndOfMibView, gosnmp.Null:
+				return nil
+			}
+
+			if rows[index] == nil {
+				rows[index] = make(map[string]gosnmp.SnmpPDU)
+			}
+			rows[index][column] = pdu
+			return nil
+		}
+
+		// GETBULK does not exist in SNMPv1
+		var err error
+		if snmp.Version == gosnmp.Version1 {
+			err = snmp.Walk(column, walkFn)
+		} else {
+			err = snmp.BulkWalk(column, walkFn)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("walk of %s failed: %v", column, err)
+		}
+	}
+
+	return rows, nil
+}
+
+// InterfaceRow is one row of the IF-MIB interface tables
+type InterfaceRow struct {
+	Index       string
+	Name        string
+	Alias       string
+	Descr       string
+	Type        int
+	AdminStatus int
+	OperStatus  int
+	SpeedBps    float64
+
+	values map[string]gosnmp.SnmpPDU
+}
+
+// Labels identify the interface in stored metrics
+func (r InterfaceRow) Labels() map[string]string {
+	labels := map[string]string{"ifIndex": r.Index, "ifName": r.Name}
+	if r.Alias != "" {
+		labels["ifAlias"] = r.Alias
+	}
+	if r.Descr != "" && r.Descr != r.Name {
+		labels["ifDescr"] = r.Descr
+	}
+	return labels
+}
+
+// InterfaceSelector chooses interfaces by regular expressions matched against ifName, ifAlias and ifDescr
+type InterfaceSelector struct {
+	Include *regexp.Regexp
+	Exclude *regexp.Regexp
+}
+
+// NewInterfaceSelector compiles the include and exclude expressions, empty expressions match everything
+// and nothing respectively
+func NewInterfaceSelector(include, exclude string) (InterfaceSelector, error) {
+	var selector InterfaceSelector
+	var err error
+	if include != "" {
+		if selector.Include, err = regexp.Compile(include); err != nil {
+			return selector, fmt.Errorf("invalid interfaceInclude: %v", err)
+		}
+	}
+	if exclude != "" {
+		if selector.Exclude, err = regexp.Compile(exclude); err != nil {
+			return selector, fmt.Errorf("invalid interfaceExclude: %v", err)
+		}
+	}
+	return selector, nil
+}
+
+func (s InterfaceSelector) Matches(r InterfaceRow) bool {
+	names := []string{r.Name, r.Alias, r.Descr}
+	matches := func(re *regexp.Regexp) bool {
+		for _, name := range names {
+			if name != "" && re.MatchString(name) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if s.Include != nil && !matches(s.Include) {
+		return false
+	}
+	return s.Exclude == nil || !matches(s.Exclude)
+}
+
+// GetInterfaces walks ifTable and ifXTable. Rows are named by ifName, falling back to ifDescr on
+// agents without ifXTable.
+func (nm *NetworkManager) GetInterfaces(snmp *gosnmp.GoSNMP) ([]InterfaceRow, error) {
+	rows, err := nm.WalkTable(snmp,
+		oidIfDescr, oidIfType, oidIfSpeed, oidIfAdminStatus, oidIfOperStatus,
+		oidIfInOctets, oidIfInDiscards, oidIfInErrors, oidIfOutOctets, oidIfOutDiscards, oidIfOutErrors,
+		oidIfName, oidIfHCInOctets, oidIfHCOutOctets, oidIfHighSpeed, oidIfAlias)
+	if err != nil {
+		return nil, err
+	}
+
+	var interfaces []InterfaceRow
+	for index, values := range rows {
+		row := InterfaceRow{
+			Index:       index,
+			Name:        pduString(values[oidIfName]),
+			Alias:       pduString(values[oidIfAlias]),
+			Descr:       pduString(values[oidIfDescr]),
+			Type:        int(counterValue(values[oidIfType])),
+			AdminStatus: int(counterValue(values[oidIfAdminStatus])),
+			OperStatus:  int(counterValue(values[oidIfOperStatus])),
+			SpeedBps:    interfaceSpeed(counterValue(values[oidIfSpeed]), counterValue(values[oidIfHighSpeed])),
+			values:      values,
+		}
+		if row.Name == "" {
+			row.Name = row.Descr
+		}
+		interfaces = append(interfaces, row)
+	}
+
+	sortByIndex(interfaces, func(r InterfaceRow) string { return r.Index })
+	return interfaces, nil
+}
+
+// CollectInterfaceMetrics walks the interface tables and reports status, counter rates and utilization of
+// the selected interfaces. Rates need the previous samples in nm.Counters, so the first poll only
+// reports status and speed.
+func (nm *NetworkManager) CollectInterfaceMetrics(snmp *gosnmp.GoSNMP, SystemMonitorId, Host string, selector InterfaceSelector) ([]mstypes.NetworkTableMetric, []InterfaceUtilization, error) {
+	uptime, hasUptime := nm.sysUpTime(snmp)
+
+	interfaces, err := nm.GetInterfaces(snmp)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	polled := time.Now()
+	var metrics []mstypes.NetworkTableMetric
+	var utilization []InterfaceUtilization
+
+	for _, row := range interfaces {
+		if !selector.Matches(row) {
+			continue
+		}
+
+		labels := row.Labels()
+		add := func(name string, value float64) {
+			metrics = append(metrics, mstypes.NetworkTableMetric{
+				SystemMonitorId: SystemMonitorId,
+				DeviceIP:        Host,
+				MetricName:      name,
+				Labels:          labels,
+				Value:           value,
+				CollectedAt:     polled,
+			})
+		}
+
+		add("ifOperStatus", float64(row.OperStatus))
+		add("ifAdminStatus", float64(row.AdminStatus))
+		add("ifSpeed", row.SpeedBps)
+
+		if nm.Counters == nil {
+			continue
+		}
+
+		for _, counter := range interfaceCounters {
+			column, variable, ok := firstColumn(row.values, counter.Columns)
+			if !ok {
+				continue
+			}
+
+			bits := 32
+			if variable.Type == gosnmp.Counter64 {
+				bits = 64
+			}
+			rate, ok := nm.Counters.Rate(Host, column+"."+row.Index, CounterSample{Value: counterValue(variable), Bits: bits, Uptime: uptime, HasUptime: hasUptime, Time: polled})
+			if !ok {
+				continue
+			}
+			add(counter.Name, rate)
+
+			// Interfaces that are down have no meaningful utilization
+			if counter.Direction == "" || row.SpeedBps <= 0 || row.OperStatus != 1 {
+				continue
+			}
+			usage := InterfaceUtilization{
+				InterfaceID:   row.Index,
+				InterfaceName: row.Name,
+				Direction:     counter.Direction,
+				Metric:        counter.Name,
+				BitsPerSec:    rate * 8,
+				SpeedBps:      row.SpeedBps,
+				Percent:       rate * 8 / row.SpeedBps * 100,
+			}
+			utilization = append(utilization, usage)
+			add("if"+strings.ToUpper(counter.Direction[:1])+counter.Direction[1:]+"Utilization", math.Round(usage.Percent*100)/100)
+		}
+	}
+
+	return metrics, utilization, nil
+}
+
+// StorageRow is one row of the HOST-RESOURCES hrStorageTable
+type StorageRow struct {
+	Index     string
+	Descr     string
+	Type      string
+	SizeBytes float64
+	UsedBytes float64
+}
+
+func (r StorageRow) UsedPercent() float64 {
+	if r.SizeBytes <= 0 {
+		return 0
+	}
+	return r.UsedBytes / r.SizeBytes * 100
+}
+
+// GetStorage walks hrStorageTable, sizes are converted from allocation units to bytes
+func (nm *NetworkManager) GetStorage(snmp *gosnmp.GoSNMP) ([]StorageRow, error) {
+	rows, err := nm.WalkTable(snmp, oidHrStorageType, oidHrStorageDescr, oidHrStorageUnits, oidHrStorageSize, oidHrStorageUsed)
+	if err != nil {
+		return nil, err
+	}
+
+	var storage []StorageRow
+	for index, values := range rows {
+		units := float64(counterValue(values[oidHrStorageUnits]))
+		storageType := strings.TrimPrefix(fmt.Sprint(values[oidHrStorageType].Value), ".")
+		if name, ok := hrStorageTypes["."+storageType]; ok {
+			storageType = name
+		}
+
+		storage = append(storage, StorageRow{
+			Index:     index,
+			Descr:     pduString(values[oidHrStorageDescr]),
+			Type:      storageType,
+			SizeBytes: float64(integer32Unsigned(values[oidHrStorageSize])) * units,
+			UsedBytes: float64(integer32Unsigned(values[oidHrStorageUsed])) * units,
+		})
+	}
+
+	sortByIndex(storage, func(r StorageRow) string { return r.Index })
+	return storage, nil
+}
+
+// CollectStorageMetrics reports size, usage and percentage used of every hrStorageTable entry
+func (nm *NetworkManager) CollectStorageMetrics(snmp *gosnmp.GoSNMP, SystemMonitorId, Host string) ([]mstypes.NetworkTableMetric, error) {
+	storage, err := nm.GetStorage(snmp)
+	if err != nil {
+		return nil, err
+	}
+
+	polled := time.Now()
+	var metrics []mstypes.NetworkTableMetric
+	for _, row := range storage {
+		labels := map[string]string{"hrStorageIndex": row.Index, "hrStorageDescr": row.Descr, "hrStorageType": row.Type}
+		for _, m := range []struct {
+			name  string
+			value float64
+		}{
+			{"hrStorageSize", row.SizeBytes},
+			{"hrStorageUsed", row.UsedBytes},
+			{"hrStorageUsedPercent", math.Round(row.UsedPercent()*100) / 100},
+		} {
+			metrics = append(metrics, mstypes.NetworkTableMetric{
+				SystemMonitorId: SystemMonitorId,
+				DeviceIP:        Host,
+				MetricName:      m.name,
+				Labels:          labels,
+				Value:           m.value,
+				CollectedAt:     polled,
+			})
+		}
+	}
+	return metrics, nil
+}
+
+// CollectProcessorMetrics reports hrProcessorLoad, the average load of each processor over the last minute
+func (nm *NetworkManager) CollectProcessorMetrics(snmp *gosnmp.GoSNMP, SystemMonitorId, Host string) ([]mstypes.NetworkTableMetric, error) {
+	rows, err := nm.WalkTable(snmp, oidHrProcessorLoad)
+	if err != nil {
+		return nil, err
+	}
+
+	polled := time.Now()
+	var metrics []mstypes.NetworkTableMetric
+	for index, values := range rows {
+		metrics = append(metrics, mstypes.NetworkTableMetric{
+			SystemMonitorId: SystemMonitorId,
+			DeviceIP:        Host,
+			MetricName:      "hrProcessorLoad",
+			Labels:          map[string]string{"hrDeviceIndex": index},
+			Value:           float64(counterValue(values[oidHrProcessorLoad])),
+			CollectedAt:     polled,
+		})
+	}
+
+	sortByIndex(metrics, func(m mstypes.NetworkTableMetric) string { return m.Labels["hrDeviceIndex"] })
+	return metrics, nil
+}
+
+func (nm *NetworkManager) sysUpTime(snmp *gosnmp.GoSNMP) (uint32, bool) {
+	packet, err := snmp.Get([]string{"." + oidSysUpTime})
+	if err != nil || len(packet.Variables) == 0 {
+		return 0, false
+	}
+	uptime, ok := packet.Variables[0].Value.(uint32)
+	return uptime, ok
+}
+
+func firstColumn(values map[string]gosnmp.SnmpPDU, columns []string) (string, gosnmp.SnmpPDU, bool) {
+	for _, column := range columns {
+		if variable, ok := values[column]; ok {
+			return column, variable, true
+		}
+	}
+	return "", gosnmp.SnmpPDU{}, false
+}
+
+func pduString(variable gosnmp.SnmpPDU) string {
+	if b, ok := variable.Value.([]byte); ok {
+		return strings.TrimRight(string(b), "\x00")
+	}
+	return ""
+}
+
+// integer32Unsigned reads hrStorageSize and hrStorageUsed, which some agents report as negative once
+// the value exceeds the Integer32 range
+func integer32Unsigned(variable gosnmp.SnmpPDU) uint64 {
+	if v, ok := variable.Value.(int); ok && v < 0 {
+		return uint64(uint32(int32(v)))
+	}
+	return counterValue(variable)
+}
+
+// sortByIndex orders rows by their numeric table index
+func sortByIndex[T any](rows []T, index func(T) string) {
+	key := func(row T) int {
+		n, err := strconv.Atoi(index(row))
+		if err != nil {
+			return math.MaxInt
+		}
+		return n
+	}
+	sort.SliceStable(rows, func(i, j int) bool { return key(rows[i]) < key(rows[j]) })
+}
@@ -0,0 +1,580 @@
+package monitors
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"log"
+	"log/slog"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ZEGIFTED/MS.GoMonitor/internal"
+	"github.com/ZEGIFTED/MS.GoMonitor/notifier"
+	"github.com/ZEGIFTED/MS.GoMonitor/pkg/constants"
+	"github.com/ZEGIFTED/MS.GoMonitor/pkg/secrets"
+	"github.com/ZEGIFTED/MS.GoMonitor/pkg/utils"
+	"github.com/gosnmp/gosnmp"
+)
+
+// Trap severities, info traps are logged but do not raise alerts
+const (
+	TrapSeverityCritical = "critical"
+	TrapSeverityWarning  = "warning"
+	TrapSeverityInfo     = "info"
+)
+
+const (
+	oidSnmpTrapOID     = "1.3.6.1.6.3.1.1.4.1.0"
+	oidSysUpTime       = "1.3.6.1.2.1.1.3.0"
+	oidSnmpTrapAddress = "1.3.6.1.6.3.18.1.3.0"
+	oidGenericTraps    = "1.3.6.1.6.3.1.1.5"
+)
+
+// TrapDefinition names a notification and decides how loudly it is alerted on
+type TrapDefinition struct {
+	OID         string `json:"oid"`
+	Name        string `json:"name"`
+	Severity    string `json:"severity"`
+	Description string `json:"description,omitempty"`
+}
+
+// wellKnownTraps covers SNMPv2-MIB generic traps and common standard and vendor notifications.
+// Entries from SNMP_TRAP_DEFINITIONS are added to (and override) these.
+var wellKnownTraps = []TrapDefinition{
+	{OID: "1.3.6.1.6.3.1.1.5.1", Name: "coldStart", Severity: TrapSeverityWarning, Description: "device restarted"},
+	{OID: "1.3.6.1.6.3.1.1.5.2", Name: "warmStart", Severity: TrapSeverityInfo, Description: "agent reinitialised"},
+	{OID: "1.3.6.1.6.3.1.1.5.3", Name: "linkDown", Severity: TrapSeverityCritical, Description: "interface went down"},
+	{OID: "1.3.6.1.6.3.1.1.5.4", Name: "linkUp", Severity: TrapSeverityInfo, Description: "interface came up"},
+	{OID: "1.3.6.1.6.3.1.1.5.5", Name: "authenticationFailure", Severity: TrapSeverityWarning, Description: "SNMP request with wrong credentials"},
+	{OID: "1.3.6.1.6.3.1.1.5.6", Name: "egpNeighborLoss", Severity: TrapSeverityCritical, Description: "EGP neighbour lost"},
+
+	{OID: "1.3.6.1.2.1.15.7.1", Name: "bgpEstablished", Severity: TrapSeverityInfo, Description: "BGP session established"},
+	{OID: "1.3.6.1.2.1.15.7.2", Name: "bgpBackwardTransition", Severity: TrapSeverityCritical, Description: "BGP session left the established state"},
+	{OID: "1.3.6.1.2.1.15.0.1", Name: "bgpEstablishedNotification", Severity: TrapSeverityInfo, Description: "BGP session established"},
+	{OID: "1.3.6.1.2.1.15.0.2", Name: "bgpBackwardTransNotification", Severity: TrapSeverityCritical, Description: "BGP session left the established state"},
+	{OID: "1.3.6.1.2.1.47.2.0.1", Name: "entConfigChange", Severity: TrapSeverityInfo, Description: "entity configuration changed"},
+
+	{OID: "1.3.6.1.2.1.33.2.1", Name: "upsTrapOnBattery", Severity: TrapSeverityCritical, Description: "UPS is running on battery"},
+	{OID: "1.3.6.1.2.1.33.2.2", Name: "upsTrapTestCompleted", Severity: TrapSeverityInfo, Description: "UPS self test completed"},
+	{OID: "1.3.6.1.2.1.33.2.3", Name: "upsTrapAlarmEntryAdded", Severity: TrapSeverityWarning, Description: "UPS alarm raised"},
+	{OID: "1.3.6.1.2.1.33.2.4", Name: "upsTrapAlarmEntryRemoved", Severity: TrapSeverityInfo, Description: "UPS alarm cleared"},
+
+	{OID: "1.3.6.1.4.1.9.9.13.3.0.1", Name: "ciscoEnvMonShutdownNotification", Severity: TrapSeverityCritical, Description: "environmental shutdown imminent"},
+	{OID: "1.3.6.1.4.1.9.9.13.3.0.2", Name: "ciscoEnvMonVoltageNotification", Severity: TrapSeverityWarning, Description: "voltage out of range"},
+	{OID: "1.3.6.1.4.1.9.9.13.3.0.3", Name: "ciscoEnvMonTemperatureNotification", Severity: TrapSeverityWarning, Description: "temperature out of range"},
+	{OID: "1.3.6.1.4.1.9.9.13.3.0.4", Name: "ciscoEnvMonFanNotification", Severity: TrapSeverityWarning, Description: "fan failure"},
+	{OID: "1.3.6.1.4.1.9.9.13.3.0.5", Name: "ciscoEnvMonRedundantSupplyNotification", Severity: TrapSeverityCritical, Description: "redundant power supply failed"},
+	{OID: "1.3.6.1.4.1.9.9.41.2.0.1", Name: "clogMessageGenerated", Severity: TrapSeverityInfo, Description: "syslog message"},
+	{OID: "1.3.6.1.4.1.9.9.43.2.0.1", Name: "ciscoConfigManEvent", Severity: TrapSeverityInfo, Description: "configuration changed"},
+}
+
+// trapVarbindNames gives readable names to varbinds commonly carried by notifications, keyed by column OID
+var trapVarbindNames = map[string]string{
+	oidSysUpTime:               "sysUpTime",
+	oidSnmpTrapOID:             "snmpTrapOID",
+	oidSnmpTrapAddress:         "snmpTrapAddress",
+	"1.3.6.1.2.1.2.2.1.1":      "ifIndex",
+	"1.3.6.1.2.1.2.2.1.2":      "ifDescr",
+	"1.3.6.1.2.1.2.2.1.3":      "ifType",
+	"1.3.6.1.2.1.2.2.1.7":      "ifAdminStatus",
+	"1.3.6.1.2.1.2.2.1.8":      "ifOperStatus",
+	"1.3.6.1.2.1.31.1.1.1.1":   "ifName",
+	"1.3.6.1.2.1.31.1.1.1.18":  "ifAlias",
+	"1.3.6.1.2.1.15.3.1.7":     "bgpPeerRemoteAddr",
+	"1.3.6.1.2.1.15.3.1.14":    "bgpPeerLastError",
+	"1.3.6.1.2.1.15.3.1.2":     "bgpPeerState",
+	"1.3.6.1.4.1.9.9.41.1.2.3": "clogHistMsgText",
+}
+
+// TrapUser is an SNMPv3 USM user allowed to send traps. Passphrases are secret references.
+type TrapUser struct {
+	UserName             string `json:"userName"`
+	AuthProtocol         string `json:"authProtocol"`
+	AuthPassphraseSecret string `json:"authPassphraseSecret"`
+	PrivProtocol         string `json:"privProtocol"`
+	PrivPassphraseSecret string `json:"privPassphraseSecret"`
+}
+
+// TrapVarbind is one decoded variable binding of a notification
+type TrapVarbind struct {
+	OID   string `json:"oid"`
+	Name  string `json:"name,omitempty"`
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+// Trap is a decoded notification
+type Trap struct {
+	Source     string
+	Version    string
+	Community  string
+	UserName   string
+	OID        string
+	Definition TrapDefinition
+	Varbinds   []TrapVarbind
+	Received   time.Time
+}
+
+// Summary renders the notification and its interesting varbinds on one line
+func (t Trap) Summary() string {
+	var parts []string
+	for _, vb := range t.Varbinds {
+		if vb.OID == oidSysUpTime || vb.OID == oidSnmpTrapOID {
+			continue
+		}
+		name := vb.Name
+		if name == "" {
+			name = vb.OID
+		}
+		parts = append(parts, name+"="+vb.Value)
+	}
+
+	summary := t.Definition.Name
+	if t.Definition.Description != "" {
+		summary += " (" + t.Definition.Description + ")"
+	}
+	if len(parts) > 0 {
+		summary += ": " + strings.Join(parts, ", ")
+	}
+	return summary
+}
+
+// TrapReceiver listens for SNMP v1/v2c/v3 notifications, correlates them with monitored services by source
+// address and feeds warning and critical ones into the engine's alert pipeline
+type TrapReceiver struct {
+	engine   *MonitoringEngine
+	address  string
+	listener *gosnmp.TrapListener
+	nm       *NetworkManager
+
+	mu          sync.RWMutex
+	definitions map[string]TrapDefinition
+	communities map[string]bool
+	relays      map[string]bool               // IP addresses of trusted trap forwarders
+	sources     map[string]ServiceMonitorData // IP address to service
+	users       []TrapUser
+}
+
+func NewTrapReceiver(engine *MonitoringEngine, address string) *TrapReceiver {
+	r := &TrapReceiver{
+		engine:      engine,
+		address:     address,
+		nm:          &NetworkManager{},
+		definitions: make(map[string]TrapDefinition),
+		communities: make(map[string]bool),
+		relays:      make(map[string]bool),
+		sources:     make(map[string]ServiceMonitorData),
+	}
+	for _, d := range wellKnownTraps {
+		r.definitions[d.OID] = d
+	}
+	for _, c := range strings.Split(constants.SNMPTrapCommunities, ",") {
+		if c = strings.TrimSpace(c); c != "" {
+			r.communities[c] = true
+		}
+	}
+	for _, relay := range strings.Split(constants.SNMPTrapRelays, ",") {
+		if ip := net.ParseIP(strings.TrimSpace(relay)); ip != nil {
+			r.relays[ip.String()] = true
+		}
+	}
+	return r
+}
+
+// LoadDefinitions adds trap definitions from a JSON file
+func (r *TrapReceiver) LoadDefinitions(path string) error {
+	var definitions []TrapDefinition
+	if err := readJSONFile(path, &definitions); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, d := range definitions {
+		oid := strings.TrimPrefix(d.OID, ".")
+		if oid == "" || d.Name == "" {
+			return fmt.Errorf("trap definition needs an oid and a name")
+		}
+		d.OID = oid
+		d.Severity = strings.ToLower(d.Severity)
+		r.definitions[oid] = d
+	}
+	return nil
+}
+
+// LoadUsers reads the USM users allowed to send SNMPv3 traps from a JSON file
+func (r *TrapReceiver) LoadUsers(path string) error {
+	var users []TrapUser
+	if err := readJSONFile(path, &users); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	r.users = users
+	r.mu.Unlock()
+	return nil
+}
+
+// UpdateSources rebuilds the source address index from the service inventory. Services are matched by
+// their Host and by the optional "trapSources" list, e.g. for devices sending from a loopback address.
+// Community strings and SNMPv3 credentials configured on network devices are accepted for their traps.
+func (r *TrapReceiver) UpdateSources(services []ServiceMonitorData) {
+	sources := SourceAddresses(services, "trapSources")
+
+	r.mu.Lock()
+	r.sources = sources
+	r.mu.Unlock()
+}
+
+// SourceAddresses maps the IP addresses of every service's Host and of its additional source
+// addresses under key to the service
+func SourceAddresses(services []ServiceMonitorData, key string) map[string]ServiceMonitorData {
+	sources := make(map[string]ServiceMonitorData)
+
+	for _, service := range services {
+		addresses := append([]string{service.Host}, utils.ConfigStringSlice(service.Configuration, key)...)
+		for _, address := range addresses {
+			if address == "" {
+				continue
+			}
+			if ip := net.ParseIP(address); ip != nil {
+				sources[ip.String()] = service
+				continue
+			}
+			ips, err := net.LookupIP(address)
+			if err != nil {
+				slog.Warn("Could not resolve source address", "service", service.Name, "host", address, "error", err)
+				continue
+			}
+			for _, ip := range ips {
+				sources[ip.String()] = service
+			}
+		}
+	}
+
+	return sources
+}
+
+// Start listens until Stop is called, it returns once the socket is closed or could not be opened
+func (r *TrapReceiver) Start(services []ServiceMonitorData) error {
+	r.UpdateSources(services)
+
+	// Version3 enables USM authentication against the security table, v1 and v2c packets are still
+	// decoded by their own version
+	params := &gosnmp.GoSNMP{
+		Transport: "udp",
+		Version:   gosnmp.Version3,
+		Timeout:   30 * time.Second,
+		MaxOids:   gosnmp.MaxOids,
+		Logger:    gosnmp.NewLogger(log.New(io.Discard, "", 0)),
+	}
+
+	table, err := r.securityTable(services)
+	if err != nil {
+		return err
+	}
+	params.TrapSecurityParametersTable = table
+
+	r.listener = gosnmp.NewTrapListener()
+	r.listener.Params = params
+	r.listener.OnNewTrap = r.handleTrap
+
+	slog.Info("Starting SNMP trap receiver", "address", r.address)
+	if err := r.listener.Listen(r.address); err != nil {
+		return fmt.Errorf("trap listener error: %v", err)
+	}
+	return nil
+}
+
+func (r *TrapReceiver) Stop() {
+	if r.listener != nil {
+		log.Println("Stopping Trap Listener...")
+		r.listener.Close()
+	}
+}
+
+// securityTable holds every USM user traps are accepted from, keyed by user name
+func (r *TrapReceiver) securityTable(services []ServiceMonitorData) (*gosnmp.SnmpV3SecurityParametersTable, error) {
+	r.mu.RLock()
+	users := append([]TrapUser(nil), r.users...)
+	r.mu.RUnlock()
+
+	table := gosnmp.NewSnmpV3SecurityParametersTable(gosnmp.NewLogger(log.New(io.Discard, "", 0)))
+
+	for _, user := range users {
+		usm, err := user.securityParameters()
+		if err != nil {
+			return nil, fmt.Errorf("trap user %s: %v", user.UserName, err)
+		}
+		if err := table.Add(user.UserName, usm); err != nil {
+			return nil, fmt.Errorf("trap user %s: %v", user.UserName, err)
+		}
+	}
+
+	for _, service := range services {
+		if service.Device != ServiceMonitorSNMP {
+			continue
+		}
+		usm, ok, err := deviceSecurityParameters(service.Configuration)
+		if err != nil {
+			slog.Warn("Ignoring SNMPv3 trap credentials", "service", service.Name, "error", err)
+			continue
+		}
+		if ok {
+			if err := table.Add(usm.UserName, usm); err != nil {
+				slog.Warn("Ignoring SNMPv3 trap credentials", "service", service.Name, "error", err)
+			}
+		}
+	}
+
+	return table, nil
+}
+
+func (u TrapUser) securityParameters() (*gosnmp.UsmSecurityParameters, error) {
+	auth, err := ParseAuthProtocol(u.AuthProtocol)
+	if err != nil {
+		return nil, err
+	}
+	priv, err := ParsePrivProtocol(u.PrivProtocol)
+	if err != nil {
+		return nil, err
+	}
+
+	usm := &gosnmp.UsmSecurityParameters{UserName: u.UserName, AuthenticationProtocol: auth, PrivacyProtocol: priv}
+	if u.AuthPassphraseSecret != "" {
+		if usm.AuthenticationPassphrase, err = secrets.Resolve(u.AuthPassphraseSecret); err != nil {
+			return nil, err
+		}
+	}
+	if u.PrivPassphraseSecret != "" {
+		if usm.PrivacyPassphrase, err = secrets.Resolve(u.PrivPassphraseSecret); err != nil {
+			return nil, err
+		}
+	}
+	return usm, nil
+}
+
+// deviceSecurityParameters builds trap credentials from a network device's SNMPv3 polling settings
+func deviceSecurityParameters(cfg map[string]any) (*gosnmp.UsmSecurityParameters, bool, error) {
+	config, err := ParseSNMPDeviceConfig(cfg)
+	if err != nil {
+		return nil, false, err
+	}
+	if config.SNMPVersion != SNMPVersion3 {
+		return nil, false, nil
+	}
+
+	nm := config.NetworkManager("")
+	return &gosnmp.UsmSecurityParameters{
+		UserName:                 nm.AuthUser,
+		AuthenticationProtocol:   nm.AuthProtocol,
+		AuthenticationPassphrase: nm.AuthPassword,
+		PrivacyProtocol:          nm.PrivProtocol,
+		PrivacyPassphrase:        nm.PrivPassword,
+	}, true, nil
+}
+
+func (r *TrapReceiver) handleTrap(packet *gosnmp.SnmpPacket, addr *net.UDPAddr) {
+	trap := r.Decode(packet, addr)
+
+	service, ok := r.correlate(trap, packet)
+	if !ok {
+		slog.Warn("SNMP trap from unmonitored source", "source", trap.Source, "trap", trap.Definition.Name, "details", trap.Summary())
+		return
+	}
+
+	if !r.authorized(trap, service) {
+		slog.Warn("SNMP trap with unknown community ignored", "source", trap.Source, "service", service.Name)
+		return
+	}
+
+	slog.Info("SNMP trap received", "service", service.Name, "source", trap.Source, "trap", trap.Definition.Name, "severity", trap.Definition.Severity, "details", trap.Summary())
+
+	if trap.Definition.Severity == TrapSeverityInfo || service.IsAcknowledged {
+		return
+	}
+
+	r.raise(service, trap)
+}
+
+// Decode converts a packet into a Trap, naming the notification and every varbind
+func (r *TrapReceiver) Decode(packet *gosnmp.SnmpPacket, addr *net.UDPAddr) Trap {
+	trap := Trap{Source: addr.IP.String(), Received: time.Now(), Community: packet.Community}
+
+	switch packet.Version {
+	case gosnmp.Version1:
+		trap.Version = "v1"
+		trap.OID = v1TrapOID(packet)
+	case gosnmp.Version3:
+		trap.Version = "v3"
+		if usm, ok := packet.SecurityParameters.(*gosnmp.UsmSecurityParameters); ok {
+			trap.UserName = usm.UserName
+		}
+	default:
+		trap.Version = "v2c"
+	}
+
+	for _, pdu := range packet.Variables {
+		oid := strings.TrimPrefix(pdu.Name, ".")
+		_, value := r.nm.GetFormattedSNMPValue([]gosnmp.SnmpPDU{pdu})
+		if pdu.Type == gosnmp.ObjectIdentifier {
+			value = strings.TrimPrefix(value, ".")
+		}
+
+		if oid == oidSnmpTrapOID {
+			trap.OID = value
+		}
+		trap.Varbinds = append(trap.Varbinds, TrapVarbind{OID: oid, Name: varbindName(oid), Type: pdu.Type.String(), Value: value})
+	}
+
+	r.mu.RLock()
+	definition, ok := r.definitions[trap.OID]
+	r.mu.RUnlock()
+	if !ok {
+		// Unknown notifications are reported so they can be classified with SNMP_TRAP_DEFINITIONS
+		definition = TrapDefinition{OID: trap.OID, Name: trap.OID, Severity: TrapSeverityWarning}
+	}
+	trap.Definition = definition
+
+	return trap
+}
+
+// v1TrapOID translates a v1 generic/specific trap into its SNMPv2 notification OID (RFC 3584 section 3.1)
+func v1TrapOID(packet *gosnmp.SnmpPacket) string {
+	if packet.GenericTrap >= 0 && packet.GenericTrap < 6 {
+		return fmt.Sprintf("%s.%d", oidGenericTraps, packet.GenericTrap+1)
+	}
+	return fmt.Sprintf("%s.0.%d", strings.TrimPrefix(packet.Enterprise, "."), packet.SpecificTrap)
+}
+
+func varbindName(oid string) string {
+	if name, ok := trapVarbindNames[oid]; ok {
+		return name
+	}
+	// Table columns carry the row index after the column OID
+	for column := oid; strings.Contains(column, "."); {
+		column = column[:strings.LastIndex(column, ".")]
+		if name, ok := trapVarbindNames[column]; ok {
+			return name + oid[len(column):]
+		}
+	}
+	return ""
+}
+
+// correlate finds the service a trap is about by its packet source. Only traps from a forwarder in
+// SNMP_TRAP_RELAYS are correlated on the originator named in the snmpTrapAddress varbind or the v1
+// agent-addr field, anyone else could claim to be any device.
+func (r *TrapReceiver) correlate(trap Trap, packet *gosnmp.SnmpPacket) (ServiceMonitorData, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	candidates := []string{trap.Source}
+	if r.relays[trap.Source] {
+		if packet.Version == gosnmp.Version1 && packet.AgentAddress != "" {
+			candidates = append([]string{packet.AgentAddress}, candidates...)
+		}
+		for _, vb := range trap.Varbinds {
+			if vb.OID == oidSnmpTrapAddress {
+				candidates = append([]string{vb.Value}, candidates...)
+			}
+		}
+	}
+
+	for _, candidate := range candidates {
+		if ip := net.ParseIP(candidate); ip != nil {
+			if service, ok := r.sources[ip.String()]; ok {
+				return service, true
+			}
+		}
+	}
+	return ServiceMonitorData{}, false
+}
+
+// authorized checks v1/v2c communities, without a configured community they are rejected. SNMPv3
+// traps were already authenticated by the listener.
+func (r *TrapReceiver) authorized(trap Trap, service ServiceMonitorData) bool {
+	if trap.Version == "v3" {
+		return true
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	device := utils.ConfigString(service.Configuration, "communityString", "")
+	return r.communities[trap.Community] || (device != "" && trap.Community == device)
+}
+
+// raise sends the trap through the alert pipeline, repeated traps for the same object are throttled
+func (r *TrapReceiver) raise(service ServiceMonitorData, trap Trap) {
+	identifier := service.SystemMonitorId.String() + "|" + service.Name + "|trap|" + trap.Definition.Name + "|" + trap.fingerprint()
+
+	if lastAlert, ok := r.engine.AlertCache.Load(identifier); ok {
+		if lastAlertTime, valid := lastAlert.(time.Time); valid && time.Since(lastAlertTime) < constants.AlertThrottleTime {
+			return
+		}
+	}
+	r.engine.AlertCache.Store(identifier, time.Now())
+
+	message := fmt.Sprintf("%s: SNMP trap %s from %s", service.Name, trap.Summary(), trap.Source)
+
+	alert := internal.ServiceAlertEvent{
+		SystemMonitorId: service.SystemMonitorId,
+		ServiceName:     service.Name,
+		Message:         message,
+		Device:          string(service.Device),
+		Severity:        trap.Definition.Severity,
+		Timestamp:       trap.Received,
+		AgentRepository: service.AgentRepository,
+		AgentAPI:        service.AgentAPIBaseURL,
+		ObjectRef:       "Trap/" + trap.Definition.Name,
+	}
+
+	// A trap storm must not block the listener, the alert handler drains the channel
+	select {
+	case r.engine.Alerts <- alert:
+	default:
+		slog.Error("Alert channel full, dropping SNMP trap alert", "service", service.Name, "trap", trap.Definition.Name)
+		return
+	}
+
+	notifier.SendNotification(notifier.NotiferEvent{
+		Title:      fmt.Sprintf("%s: %s", service.Name, trap.Definition.Name),
+		Identifier: identifier,
+		Message:    message,
+		Timestamp:  trap.Received.Format(time.RFC3339),
+	})
+}
+
+// fingerprint identifies the object a trap is about by its varbind values, so a linkDown on one
+// interface does not throttle a linkDown on another
+func (t Trap) fingerprint() string {
+	var values []string
+	for _, vb := range t.Varbinds {
+		if vb.OID == oidSysUpTime || vb.OID == oidSnmpTrapOID {
+			continue
+		}
+		values = append(values, vb.OID+"="+vb.Value)
+	}
+	sort.Strings(values)
+
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(strings.Join(values, "|")))
+	return fmt.Sprintf("%x", h.Sum64())
+}
+
+func readJSONFile(path string, v any) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("invalid JSON in %s: %v", path, err)
+	}
+	return nil
+}
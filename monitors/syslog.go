@@ -0,0 +1,683 @@
+package monitors
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"log"
+	"log/slog"
+	"net"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ZEGIFTED/MS.GoMonitor/internal"
+	"github.com/ZEGIFTED/MS.GoMonitor/internal/repository"
+	"github.com/ZEGIFTED/MS.GoMonitor/notifier"
+	"github.com/ZEGIFTED/MS.GoMonitor/pkg/constants"
+	mstypes "github.com/ZEGIFTED/MS.GoMonitor/types"
+)
+
+// What a matching syslog rule does with the message. Every message is kept in the recent list of its
+// device, ignore only stops it from matching later rules.
+const (
+	SyslogActionAlert    = "alert"
+	SyslogActionAnnotate = "annotate"
+	SyslogActionIgnore   = "ignore"
+)
+
+const (
+	syslogRecentLimit    = 200              // Messages kept per device
+	syslogUnknownSources = 256              // Unmonitored senders with kept messages, the least recent one is evicted
+	syslogMaxMessageSize = 64 * 1024        // Larger frames are dropped, RFC 5425 requires at least 8 KiB
+	syslogMaxLengthBytes = 10               // Digits of an octet counting frame length
+	syslogIdleTimeout    = 10 * time.Minute // Stream connections without traffic are closed
+	syslogFlushInterval  = 5 * time.Second  // Timeline events are written in batches
+	syslogEventBuffer    = 256
+)
+
+// SyslogRule selects messages by facility, severity, application and text. Empty criteria match
+// everything; the first matching rule decides.
+type SyslogRule struct {
+	Name          string   `json:"name"`
+	Facilities    []string `json:"facilities,omitempty"` // Keywords such as local7 or codes
+	Severity      string   `json:"severity,omitempty"`   // Least severe level that matches, warning also matches err
+	AppName       string   `json:"appName,omitempty"`    // Case-insensitive
+	Pattern       string   `json:"pattern,omitempty"`    // Regular expression matched against "TAG: MSG"
+	Services      []string `json:"services,omitempty"`   // Service names or SystemMonitorIds
+	Action        string   `json:"action"`
+	AlertSeverity string   `json:"alertSeverity,omitempty"` // critical or warning, derived from the message when empty
+
+	facilities map[int]bool
+	severity   int
+	pattern    *regexp.Regexp
+}
+
+// defaultSyslogRules apply after the rules from SYSLOG_RULES
+var defaultSyslogRules = []SyslogRule{
+	{Name: "critical", Severity: "crit", Action: SyslogActionAlert, AlertSeverity: TrapSeverityCritical},
+	{Name: "interfaceDown", Severity: "notice", Pattern: `(?i)%LINK-\d-UPDOWN: .*changed state to down|%LINEPROTO-\d-UPDOWN: .*changed state to down|\blink (is )?down\b`, Action: SyslogActionAlert, AlertSeverity: TrapSeverityWarning},
+	{Name: "configurationChange", Pattern: `(?i)%SYS-\d-CONFIG_I|UI_COMMIT\b|configuration (was )?changed|config(uration)? committed`, Action: SyslogActionAnnotate},
+	{Name: "error", Severity: "err", Action: SyslogActionAnnotate},
+}
+
+func (rule *SyslogRule) compile() error {
+	if rule.Name == "" {
+		return fmt.Errorf("syslog rule needs a name")
+	}
+
+	rule.Action = strings.ToLower(rule.Action)
+	switch rule.Action {
+	case SyslogActionAlert, SyslogActionAnnotate, SyslogActionIgnore:
+	default:
+		return fmt.Errorf("syslog rule %s: unknown action %q", rule.Name, rule.Action)
+	}
+
+	rule.AlertSeverity = strings.ToLower(rule.AlertSeverity)
+	if rule.AlertSeverity != "" && rule.AlertSeverity != TrapSeverityCritical && rule.AlertSeverity != TrapSeverityWarning {
+		return fmt.Errorf("syslog rule %s: alertSeverity must be critical or warning", rule.Name)
+	}
+
+	rule.severity = len(syslogSeverities) - 1
+	if rule.Severity != "" {
+		severity, err := parseSyslogSeverity(rule.Severity)
+		if err != nil {
+			return fmt.Errorf("syslog rule %s: %v", rule.Name, err)
+		}
+		rule.severity = severity
+	}
+
+	rule.facilities = nil
+	for _, facility := range rule.Facilities {
+		code, err := parseSyslogFacility(facility)
+		if err != nil {
+			return fmt.Errorf("syslog rule %s: %v", rule.Name, err)
+		}
+		if rule.facilities == nil {
+			rule.facilities = make(map[int]bool)
+		}
+		rule.facilities[code] = true
+	}
+
+	rule.pattern = nil
+	if rule.Pattern != "" {
+		pattern, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return fmt.Errorf("syslog rule %s: invalid pattern: %v", rule.Name, err)
+		}
+		rule.pattern = pattern
+	}
+	return nil
+}
+
+func (rule *SyslogRule) matches(msg SyslogMessage, service ServiceMonitorData, known bool) bool {
+	if msg.SeverityCode() > rule.severity {
+		return false
+	}
+	if rule.facilities != nil && !rule.facilities[msg.FacilityCode()] {
+		return false
+	}
+	if rule.AppName != "" && !strings.EqualFold(rule.AppName, msg.AppName) {
+		return false
+	}
+	if len(rule.Services) > 0 {
+		if !known {
+			return false
+		}
+		found := false
+		for _, s := range rule.Services {
+			if strings.EqualFold(s, service.Name) || s == service.SystemMonitorId.String() {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return rule.pattern == nil || rule.pattern.MatchString(msg.Summary())
+}
+
+// SyslogReceiver accepts RFC 3164 and RFC 5424 messages over UDP, TCP and TLS (RFC 6587 and RFC 5425
+// framing), correlates them with monitored services by source address or hostname and applies the rules
+type SyslogReceiver struct {
+	engine     *MonitoringEngine
+	UDPAddress string // Empty disables the transport
+	TCPAddress string
+	TLSAddress string
+	TLSConfig  *tls.Config
+
+	mu      sync.RWMutex
+	rules   []SyslogRule
+	sources map[string]ServiceMonitorData // IP address to service
+	names   map[string]ServiceMonitorData // Lowercase host or service name to service
+	recent  map[string][]SyslogMessage    // SystemMonitorId, or source address of unknown senders, to messages
+	unknown map[string]time.Time          // Source addresses of unknown senders in recent to their last message
+
+	packets   net.PacketConn
+	listeners []net.Listener
+	conns     map[net.Conn]bool
+	events    chan mstypes.ServiceTimelineEvent
+	wg        sync.WaitGroup
+}
+
+func NewSyslogReceiver(engine *MonitoringEngine, udpAddress, tcpAddress, tlsAddress string) *SyslogReceiver {
+	r := &SyslogReceiver{
+		engine:     engine,
+		UDPAddress: udpAddress,
+		TCPAddress: tcpAddress,
+		TLSAddress: tlsAddress,
+		sources:    make(map[string]ServiceMonitorData),
+		names:      make(map[string]ServiceMonitorData),
+		recent:     make(map[string][]SyslogMessage),
+		unknown:    make(map[string]time.Time),
+		conns:      make(map[net.Conn]bool),
+	}
+	for _, rule := range defaultSyslogRules {
+		if err := rule.compile(); err != nil {
+			panic(err)
+		}
+		r.rules = append(r.rules, rule)
+	}
+	return r
+}
+
+// LoadRules reads rules from a JSON file, they are evaluated before the built-in ones
+func (r *SyslogReceiver) LoadRules(path string) error {
+	var rules []SyslogRule
+	if err := readJSONFile(path, &rules); err != nil {
+		return err
+	}
+	for i := range rules {
+		if err := rules[i].compile(); err != nil {
+			return err
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.rules = append(rules, r.rules[len(r.rules)-len(defaultSyslogRules):]...)
+	return nil
+}
+
+// LoadCertificate enables the TLS transport with a PEM certificate and key
+func (r *SyslogReceiver) LoadCertificate(certFile, keyFile string) error {
+	certificate, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return err
+	}
+	r.TLSConfig = &tls.Config{Certificates: []tls.Certificate{certificate}, MinVersion: tls.VersionTLS12}
+	return nil
+}
+
+// UpdateSources rebuilds the source index from the service inventory. Services are matched by their
+// Host and the optional "syslogSources" list, then by the hostname in the message.
+func (r *SyslogReceiver) UpdateSources(services []ServiceMonitorData) {
+	sources := SourceAddresses(services, "syslogSources")
+
+	names := make(map[string]ServiceMonitorData)
+	for _, service := range services {
+		names[normalizeDeviceName(service.Name)] = service
+		if service.Host != "" && net.ParseIP(service.Host) == nil {
+			names[normalizeDeviceName(service.Host)] = service
+		}
+	}
+
+	r.mu.Lock()
+	r.sources, r.names = sources, names
+	r.mu.Unlock()
+}
+
+// Start opens the configured transports and serves them in the background until Stop is called
+func (r *SyslogReceiver) Start(services []ServiceMonitorData) error {
+	r.UpdateSources(services)
+
+	if r.TLSAddress != "" && r.TLSConfig == nil {
+		return fmt.Errorf("syslog TLS listener needs a certificate")
+	}
+
+	if r.UDPAddress != "" {
+		packets, err := net.ListenPacket("udp", r.UDPAddress)
+		if err != nil {
+			return fmt.Errorf("syslog UDP listener error: %v", err)
+		}
+		r.packets = packets
+		r.wg.Add(1)
+		go r.serveUDP()
+	}
+
+	for _, transport := range []struct{ name, address string }{{"tcp", r.TCPAddress}, {"tls", r.TLSAddress}} {
+		if transport.address == "" {
+			continue
+		}
+
+		listener, err := net.Listen("tcp", transport.address)
+		if err != nil {
+			r.Stop()
+			return fmt.Errorf("syslog %s listener error: %v", strings.ToUpper(transport.name), err)
+		}
+		if transport.name == "tls" {
+			listener = tls.NewListener(listener, r.TLSConfig)
+		}
+
+		r.mu.Lock()
+		r.listeners = append(r.listeners, listener)
+		r.mu.Unlock()
+		r.wg.Add(1)
+		go r.serveStream(listener)
+	}
+
+	if r.engine.Db != nil {
+		r.events = make(chan mstypes.ServiceTimelineEvent, syslogEventBuffer)
+		go r.writeEvents(r.events)
+	}
+
+	slog.Info("Starting syslog receiver", "udp", r.UDPAddress, "tcp", r.TCPAddress, "tls", r.TLSAddress)
+	return nil
+}
+
+func (r *SyslogReceiver) Stop() {
+	log.Println("Stopping Syslog Receiver...")
+
+	r.mu.Lock()
+	if r.packets != nil {
+		r.packets.Close()
+	}
+	for _, listener := range r.listeners {
+		listener.Close()
+	}
+	for conn := range r.conns {
+		conn.Close()
+	}
+	r.mu.Unlock()
+
+	r.wg.Wait()
+	if r.events != nil {
+		close(r.events)
+		r.events = nil
+	}
+}
+
+func (r *SyslogReceiver) serveUDP() {
+	defer r.wg.Done()
+
+	buffer := make([]byte, syslogMaxMessageSize)
+	for {
+		n, addr, err := r.packets.ReadFrom(buffer)
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				slog.Error("Syslog UDP listener stopped", "error", err)
+			}
+			return
+		}
+		r.receive(buffer[:n], addr)
+	}
+}
+
+func (r *SyslogReceiver) serveStream(listener net.Listener) {
+	defer r.wg.Done()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				slog.Error("Syslog stream listener stopped", "error", err)
+			}
+			return
+		}
+
+		r.mu.Lock()
+		r.conns[conn] = true
+		r.mu.Unlock()
+
+		r.wg.Add(1)
+		go func() {
+			defer func() {
+				r.mu.Lock()
+				delete(r.conns, conn)
+				r.mu.Unlock()
+				conn.Close()
+				r.wg.Done()
+			}()
+
+			reader := bufio.NewReader(conn)
+			for {
+				_ = conn.SetReadDeadline(time.Now().Add(syslogIdleTimeout))
+				frame, err := readSyslogFrame(reader)
+				if err != nil {
+					if !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+						slog.Warn("Syslog connection closed", "source", conn.RemoteAddr().String(), "error", err)
+					}
+					return
+				}
+				r.receive(frame, conn.RemoteAddr())
+			}
+		}()
+	}
+}
+
+// readSyslogFrame reads one message using octet counting ("LEN SP MSG", RFC 6587 section 3.4.1) when
+// the frame starts with a digit and newline delimited framing otherwise
+func readSyslogFrame(reader *bufio.Reader) ([]byte, error) {
+	first, err := reader.Peek(1)
+	if err != nil {
+		return nil, err
+	}
+
+	if first[0] >= '0' && first[0] <= '9' {
+		var prefix []byte
+		for {
+			b, err := reader.ReadByte()
+			if err != nil {
+				return nil, err
+			}
+			if b == ' ' {
+				break
+			}
+			if b < '0' || b > '9' || len(prefix) == syslogMaxLengthBytes {
+				return nil, fmt.Errorf("invalid frame length %q", append(prefix, b))
+			}
+			prefix = append(prefix, b)
+		}
+		length, err := strconv.Atoi(string(prefix))
+		if err != nil || length <= 0 || length > syslogMaxMessageSize {
+			return nil, fmt.Errorf("invalid frame length %q", prefix)
+		}
+		frame := make([]byte, length)
+		_, err = io.ReadFull(reader, frame)
+		return frame, err
+	}
+
+	var frame []byte
+	for {
+		chunk, err := reader.ReadSlice('\n')
+		frame = append(frame, chunk...)
+		if len(frame) > syslogMaxMessageSize {
+			return nil, fmt.Errorf("message exceeds %d bytes", syslogMaxMessageSize)
+		}
+		if errors.Is(err, bufio.ErrBufferFull) {
+			continue
+		}
+		if len(strings.TrimSpace(string(frame))) == 0 {
+			if err != nil {
+				return nil, err
+			}
+			// Blank lines between messages
+			frame = frame[:0]
+			continue
+		}
+		return frame, nil
+	}
+}
+
+func (r *SyslogReceiver) receive(data []byte, addr net.Addr) {
+	source := addr.String()
+	if host, _, err := net.SplitHostPort(source); err == nil {
+		source = host
+	}
+
+	msg, err := ParseSyslog(data, source, time.Now())
+	if err != nil {
+		slog.Warn("Invalid syslog message", "source", source, "error", err)
+		return
+	}
+	r.Handle(msg)
+}
+
+// Handle correlates a parsed message with its service, keeps it and applies the first matching rule
+func (r *SyslogReceiver) Handle(msg SyslogMessage) {
+	service, known := r.correlate(msg)
+
+	r.mu.RLock()
+	var rule *SyslogRule
+	for i := range r.rules {
+		if r.rules[i].matches(msg, service, known) {
+			rule = &r.rules[i]
+			break
+		}
+	}
+	r.mu.RUnlock()
+
+	if rule != nil {
+		msg.Rule = rule.Name
+	}
+
+	key := msg.Source
+	if known {
+		key = service.SystemMonitorId.String()
+	}
+	r.remember(key, msg, known)
+
+	if rule == nil || rule.Action == SyslogActionIgnore {
+		return
+	}
+	if !known {
+		slog.Warn("Syslog message from unmonitored source", "source", msg.Source, "hostname", msg.Hostname, "rule", rule.Name, "message", msg.Summary())
+		return
+	}
+
+	slog.Info("Syslog message matched", "service", service.Name, "rule", rule.Name, "severity", msg.Severity, "message", msg.Summary())
+
+	severity := rule.AlertSeverity
+	if severity == "" {
+		severity = TrapSeverityWarning
+		if msg.SeverityCode() <= 2 {
+			severity = TrapSeverityCritical
+		}
+	}
+
+	r.annotate(service, rule, msg, severity)
+	if rule.Action == SyslogActionAlert && !service.IsAcknowledged && !r.engine.suppressedByParent(service) {
+		r.raise(service, rule, msg, severity)
+	}
+}
+
+// correlate finds the service by the hostname in the message, so logs forwarded by a relay are
+// attributed to the device that wrote them, and otherwise by source address
+func (r *SyslogReceiver) correlate(msg SyslogMessage) (ServiceMonitorData, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if msg.Hostname != "" {
+		if ip := net.ParseIP(msg.Hostname); ip != nil {
+			if service, ok := r.sources[ip.String()]; ok {
+				return service, true
+			}
+		} else if service, ok := r.names[normalizeDeviceName(msg.Hostname)]; ok {
+			return service, true
+		}
+	}
+	if ip := net.ParseIP(msg.Source); ip != nil {
+		if service, ok := r.sources[ip.String()]; ok {
+			return service, true
+		}
+	}
+	return ServiceMonitorData{}, false
+}
+
+// remember keeps the message in the recent list of key. Anyone can send to the receiver, so only the
+// syslogUnknownSources most recently heard unmonitored senders are kept.
+func (r *SyslogReceiver) remember(key string, msg SyslogMessage, known bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !known {
+		if _, ok := r.unknown[key]; !ok && len(r.unknown) >= syslogUnknownSources {
+			oldest := ""
+			for source, last := range r.unknown {
+				if oldest == "" || last.Before(r.unknown[oldest]) {
+					oldest = source
+				}
+			}
+			delete(r.unknown, oldest)
+			delete(r.recent, oldest)
+		}
+		r.unknown[key] = msg.Received
+	}
+
+	messages := append(r.recent[key], msg)
+	if len(messages) > syslogRecentLimit {
+		messages = append([]SyslogMessage(nil), messages[len(messages)-syslogRecentLimit:]...)
+	}
+	r.recent[key] = messages
+}
+
+// Recent returns up to limit of the latest messages of a SystemMonitorId or source address, oldest first
+func (r *SyslogReceiver) Recent(key string, limit int) []SyslogMessage {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	messages := r.recent[key]
+	if limit > 0 && len(messages) > limit {
+		messages = messages[len(messages)-limit:]
+	}
+	return append([]SyslogMessage{}, messages...)
+}
+
+// annotate records the message on the service timeline, dropping it when the writer falls behind
+func (r *SyslogReceiver) annotate(service ServiceMonitorData, rule *SyslogRule, msg SyslogMessage, severity string) {
+	if r.events == nil {
+		return
+	}
+
+	event := mstypes.ServiceTimelineEvent{
+		SystemMonitorId: service.SystemMonitorId.String(),
+		Source:          "syslog",
+		Severity:        severity,
+		Title:           rule.Name,
+		Message:         msg.Summary(),
+		OccurredAt:      msg.Timestamp,
+	}
+	if rule.Action == SyslogActionAnnotate {
+		event.Severity = TrapSeverityInfo
+	}
+
+	select {
+	case r.events <- event:
+	default:
+		slog.Warn("Timeline event buffer full, dropping syslog annotation", "service", service.Name, "rule", rule.Name)
+	}
+}
+
+func (r *SyslogReceiver) writeEvents(events <-chan mstypes.ServiceTimelineEvent) {
+	ticker := time.NewTicker(syslogFlushInterval)
+	defer ticker.Stop()
+
+	var batch []mstypes.ServiceTimelineEvent
+	flush := func() {
+		if err := repository.SyncServiceTimelineEvents(r.engine.Db, batch); err != nil {
+			log.Printf("Error saving syslog timeline events: %v", err)
+		}
+		batch = batch[:0]
+	}
+
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				flush()
+				return
+			}
+			batch = append(batch, event)
+			if len(batch) >= syslogEventBuffer {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+// raise sends the message through the alert pipeline, repeats of the same message are throttled
+func (r *SyslogReceiver) raise(service ServiceMonitorData, rule *SyslogRule, msg SyslogMessage, severity string) {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(msg.AppName + "|" + msg.Message))
+	identifier := fmt.Sprintf("%s|%s|syslog|%s|%x", service.SystemMonitorId.String(), service.Name, rule.Name, h.Sum64())
+
+	if lastAlert, ok := r.engine.AlertCache.Load(identifier); ok {
+		if lastAlertTime, valid := lastAlert.(time.Time); valid && time.Since(lastAlertTime) < constants.AlertThrottleTime {
+			return
+		}
+	}
+	r.engine.AlertCache.Store(identifier, time.Now())
+
+	message := fmt.Sprintf("%s: syslog %s from %s: %s", service.Name, rule.Name, msg.Source, msg.Summary())
+
+	alert := internal.ServiceAlertEvent{
+		SystemMonitorId: service.SystemMonitorId,
+		ServiceName:     service.Name,
+		Message:         message,
+		Device:          string(service.Device),
+		Severity:        severity,
+		Timestamp:       msg.Received,
+		AgentRepository: service.AgentRepository,
+		AgentAPI:        service.AgentAPIBaseURL,
+		ObjectRef:       "Syslog/" + rule.Name,
+	}
+
+	// A log storm must not block the listeners, the alert handler drains the channel
+	select {
+	case r.engine.Alerts <- alert:
+	default:
+		slog.Error("Alert channel full, dropping syslog alert", "service", service.Name, "rule", rule.Name)
+		return
+	}
+
+	notifier.SendNotification(notifier.NotiferEvent{
+		Title:      fmt.Sprintf("%s: %s", service.Name, rule.Name),
+		Identifier: identifier,
+		Message:    message,
+		Timestamp:  msg.Received.Format(time.RFC3339),
+	})
+}
+
+// ServeHTTP handles GET /api/v1/syslog/{systemMonitorId or source address}?limit=N with the recent
+// messages of one device, and GET /api/v1/syslog/ with the number of messages kept per device
+func (r *SyslogReceiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	key := strings.Trim(strings.TrimPrefix(req.URL.Path, "/api/v1/syslog"), "/")
+	if key == "" {
+		type device struct {
+			Key      string    `json:"key"`
+			Messages int       `json:"messages"`
+			Last     time.Time `json:"last"`
+		}
+
+		r.mu.RLock()
+		devices := make([]device, 0, len(r.recent))
+		for key, messages := range r.recent {
+			devices = append(devices, device{Key: key, Messages: len(messages), Last: messages[len(messages)-1].Received})
+		}
+		r.mu.RUnlock()
+
+		sort.Slice(devices, func(i, j int) bool { return devices[i].Key < devices[j].Key })
+		_ = json.NewEncoder(w).Encode(devices)
+		return
+	}
+
+	limit := syslogRecentLimit
+	if v := req.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	_ = json.NewEncoder(w).Encode(r.Recent(key, limit))
+}
@@ -0,0 +1,245 @@
+package monitors
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Syslog severity and facility keywords by code (RFC 5424 section 6.2.1), a lower severity is more severe
+var (
+	syslogSeverities = []string{"emerg", "alert", "crit", "err", "warning", "notice", "info", "debug"}
+	syslogFacilities = []string{
+		"kern", "user", "mail", "daemon", "auth", "syslog", "lpr", "news", "uucp", "cron", "authpriv", "ftp",
+		"ntp", "security", "console", "solaris-cron", "local0", "local1", "local2", "local3", "local4", "local5",
+		"local6", "local7",
+	}
+
+	// Spellings used by other tools for the same severities
+	syslogSeverityAliases = map[string]int{"emergency": 0, "panic": 0, "critical": 2, "error": 3, "warn": 4, "informational": 6}
+)
+
+// rfc3164Tag is the TAG of a BSD syslog message, an optional PID in brackets and the colon ending it
+var rfc3164Tag = regexp.MustCompile(`^([^\s:\[\]]{1,48})(?:\[([^\]]*)\])?: ?`)
+
+// ciscoSequence is the message counter Cisco devices put in front of the timestamp
+var ciscoSequence = regexp.MustCompile(`^\d+: `)
+
+// SyslogMessage is a received syslog message in either format
+type SyslogMessage struct {
+	Source         string    `json:"source"`
+	Format         string    `json:"format"` // rfc3164 or rfc5424
+	Priority       int       `json:"priority"`
+	Facility       string    `json:"facility"`
+	Severity       string    `json:"severity"`
+	Timestamp      time.Time `json:"timestamp"`
+	Hostname       string    `json:"hostname,omitempty"`
+	AppName        string    `json:"appName,omitempty"`
+	ProcID         string    `json:"procId,omitempty"`
+	MsgID          string    `json:"msgId,omitempty"`
+	StructuredData string    `json:"structuredData,omitempty"`
+	Message        string    `json:"message"`
+	Received       time.Time `json:"received"`
+	Rule           string    `json:"rule,omitempty"` // Name of the rule the message matched
+}
+
+func (m SyslogMessage) SeverityCode() int { return m.Priority & 7 }
+func (m SyslogMessage) FacilityCode() int { return m.Priority >> 3 }
+
+// Summary renders the message on one line the way syslog daemons write it to a file
+func (m SyslogMessage) Summary() string {
+	tag := m.AppName
+	if tag != "" && m.ProcID != "" {
+		tag += "[" + m.ProcID + "]"
+	}
+	if tag == "" {
+		return m.Message
+	}
+	return tag + ": " + m.Message
+}
+
+// ParseSyslog decodes an RFC 5424 or RFC 3164 message. Only the PRI part is required, senders are
+// lenient with the rest of RFC 3164 and everything after PRI that cannot be parsed is the message.
+func ParseSyslog(data []byte, source string, received time.Time) (SyslogMessage, error) {
+	msg := SyslogMessage{Source: source, Received: received}
+
+	line := strings.TrimRight(string(data), "\r\n\x00")
+	end := strings.IndexByte(line, '>')
+	if !strings.HasPrefix(line, "<") || end < 2 || end > 4 {
+		return msg, fmt.Errorf("missing PRI part")
+	}
+	priority, err := strconv.Atoi(line[1:end])
+	if err != nil || priority < 0 || priority > 191 {
+		return msg, fmt.Errorf("invalid PRI %q", line[1:end])
+	}
+	msg.Priority = priority
+	msg.Facility = syslogFacilities[msg.FacilityCode()]
+	msg.Severity = syslogSeverities[msg.SeverityCode()]
+
+	rest := line[end+1:]
+	if strings.HasPrefix(rest, "1 ") {
+		msg.Format = "rfc5424"
+		if err := parseRFC5424(&msg, rest[2:]); err != nil {
+			return msg, err
+		}
+	} else {
+		msg.Format = "rfc3164"
+		parseRFC3164(&msg, rest, received)
+	}
+
+	if msg.Timestamp.IsZero() {
+		msg.Timestamp = received
+	}
+	return msg, nil
+}
+
+// parseRFC5424 reads TIMESTAMP HOSTNAME APP-NAME PROCID MSGID STRUCTURED-DATA [MSG]
+func parseRFC5424(msg *SyslogMessage, rest string) error {
+	fields := strings.SplitN(rest, " ", 6)
+	if len(fields) < 6 {
+		return fmt.Errorf("truncated RFC 5424 header")
+	}
+
+	nilValue := func(s string) string {
+		if s == "-" {
+			return ""
+		}
+		return s
+	}
+
+	if fields[0] != "-" {
+		timestamp, err := time.Parse(time.RFC3339Nano, fields[0])
+		if err != nil {
+			return fmt.Errorf("invalid timestamp %q", fields[0])
+		}
+		msg.Timestamp = timestamp
+	}
+	msg.Hostname = nilValue(fields[1])
+	msg.AppName = nilValue(fields[2])
+	msg.ProcID = nilValue(fields[3])
+	msg.MsgID = nilValue(fields[4])
+
+	data, text, err := splitStructuredData(fields[5])
+	if err != nil {
+		return err
+	}
+	msg.StructuredData = data
+	msg.Message = strings.TrimPrefix(text, "\ufeff")
+	return nil
+}
+
+// splitStructuredData separates the SD-ELEMENTs from the message. Parameter values are quoted and may
+// contain escaped quotes and brackets.
+func splitStructuredData(rest string) (string, string, error) {
+	if strings.HasPrefix(rest, "-") {
+		return "", strings.TrimPrefix(strings.TrimPrefix(rest, "-"), " "), nil
+	}
+	if !strings.HasPrefix(rest, "[") {
+		return "", "", fmt.Errorf("invalid structured data")
+	}
+
+	inValue, escaped := false, false
+	for i := 0; i < len(rest); i++ {
+		c := rest[i]
+		switch {
+		case escaped:
+			escaped = false
+		case c == '\\' && inValue:
+			escaped = true
+		case c == '"':
+			inValue = !inValue
+		case c == ']' && !inValue:
+			if i+1 < len(rest) && rest[i+1] == '[' {
+				continue
+			}
+			return rest[:i+1], strings.TrimPrefix(rest[i+1:], " "), nil
+		}
+	}
+	return "", "", fmt.Errorf("unterminated structured data")
+}
+
+// parseRFC3164 reads the optional TIMESTAMP HOSTNAME and TAG of a BSD syslog message
+func parseRFC3164(msg *SyslogMessage, rest string, received time.Time) {
+	rest = ciscoSequence.ReplaceAllString(rest, "")
+
+	// An asterisk or dot marks a Cisco clock that is not synchronised
+	if timestamp, remainder, ok := parseRFC3164Timestamp(strings.TrimLeft(rest, "*."), received); ok {
+		msg.Timestamp = timestamp
+		rest = remainder
+
+		// HOSTNAME follows the timestamp, unless the sender went straight to the tag
+		if host, remainder, found := strings.Cut(rest, " "); found && !rfc3164Tag.MatchString(rest) {
+			msg.Hostname = host
+			rest = remainder
+		}
+	}
+
+	if match := rfc3164Tag.FindStringSubmatch(rest); match != nil {
+		msg.AppName, msg.ProcID = match[1], match[2]
+		rest = rest[len(match[0]):]
+	}
+	msg.Message = strings.TrimSpace(rest)
+}
+
+// parseRFC3164Timestamp reads "Mmm dd hh:mm:ss" with optional milliseconds and year, or an RFC 3339
+// timestamp as written by rsyslog in its high precision mode. The year defaults to the one of received.
+func parseRFC3164Timestamp(rest string, received time.Time) (time.Time, string, bool) {
+	if token, remainder, _ := strings.Cut(rest, " "); len(token) > 19 {
+		if timestamp, err := time.Parse(time.RFC3339Nano, token); err == nil {
+			return timestamp, remainder, true
+		}
+	}
+
+	for _, layout := range []string{"Jan _2 2006 15:04:05", time.StampMilli, time.Stamp} {
+		if len(rest) < len(layout) {
+			continue
+		}
+		timestamp, err := time.ParseInLocation(layout, rest[:len(layout)], received.Location())
+		if err != nil {
+			continue
+		}
+
+		if timestamp.Year() == 0 {
+			timestamp = timestamp.AddDate(received.Year(), 0, 0)
+			// A message from late December received in January
+			if timestamp.After(received.Add(24 * time.Hour)) {
+				timestamp = timestamp.AddDate(-1, 0, 0)
+			}
+		}
+		return timestamp, strings.TrimLeft(strings.TrimPrefix(rest[len(layout):], ":"), " "), true
+	}
+	return time.Time{}, rest, false
+}
+
+// parseSyslogSeverity accepts a severity keyword, a common alias or the numeric code
+func parseSyslogSeverity(value string) (int, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	for code, name := range syslogSeverities {
+		if name == value {
+			return code, nil
+		}
+	}
+	if code, ok := syslogSeverityAliases[value]; ok {
+		return code, nil
+	}
+	if code, err := strconv.Atoi(value); err == nil && code >= 0 && code < len(syslogSeverities) {
+		return code, nil
+	}
+	return 0, fmt.Errorf("unknown syslog severity %q", value)
+}
+
+// parseSyslogFacility accepts a facility keyword or the numeric code
+func parseSyslogFacility(value string) (int, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	for code, name := range syslogFacilities {
+		if name == value {
+			return code, nil
+		}
+	}
+	if code, err := strconv.Atoi(value); err == nil && code >= 0 && code < len(syslogFacilities) {
+		return code, nil
+	}
+	return 0, fmt.Errorf("unknown syslog facility %q", value)
+}
@@ -0,0 +1,202 @@
+package monitors
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseSyslog(t *testing.T) {
+	received := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		data string
+		want SyslogMessage
+	}{
+		{
+			name: "RFC 5424 without structured data",
+			data: "<34>1 2003-10-11T22:14:15.003Z mymachine.example.com su - ID47 - 'su root' failed for lonvick on /dev/pts/8",
+			want: SyslogMessage{
+				Format: "rfc5424", Priority: 34, Facility: "auth", Severity: "crit",
+				Timestamp: time.Date(2003, 10, 11, 22, 14, 15, 3e6, time.UTC),
+				Hostname:  "mymachine.example.com", AppName: "su", MsgID: "ID47",
+				Message: "'su root' failed for lonvick on /dev/pts/8",
+			},
+		},
+		{
+			name: "RFC 5424 with structured data and BOM",
+			data: "<165>1 2003-10-11T22:14:15.003Z mymachine.example.com evntslog 42 ID47 [exampleSDID@32473 iut=\"3\" eventSource=\"Application\"] \ufeffAn application event\r\n",
+			want: SyslogMessage{
+				Format: "rfc5424", Priority: 165, Facility: "local4", Severity: "notice",
+				Timestamp: time.Date(2003, 10, 11, 22, 14, 15, 3e6, time.UTC),
+				Hostname:  "mymachine.example.com", AppName: "evntslog", ProcID: "42", MsgID: "ID47",
+				StructuredData: `[exampleSDID@32473 iut="3" eventSource="Application"]`,
+				Message:        "An application event",
+			},
+		},
+		{
+			name: "RFC 5424 with escaped brackets in several SD elements",
+			data: `<14>1 - host app - - [a@1 x="a\]b"][b@1 y="\"1\""] text`,
+			want: SyslogMessage{
+				Format: "rfc5424", Priority: 14, Facility: "user", Severity: "info",
+				Timestamp: received, Hostname: "host", AppName: "app",
+				StructuredData: `[a@1 x="a\]b"][b@1 y="\"1\""]`,
+				Message:        "text",
+			},
+		},
+		{
+			name: "RFC 3164 with hostname and tag",
+			data: "<13>May  1 11:59:00 web01 sshd[1234]: Accepted publickey for deploy",
+			want: SyslogMessage{
+				Format: "rfc3164", Priority: 13, Facility: "user", Severity: "notice",
+				Timestamp: time.Date(2024, 5, 1, 11, 59, 0, 0, time.UTC),
+				Hostname:  "web01", AppName: "sshd", ProcID: "1234",
+				Message: "Accepted publickey for deploy",
+			},
+		},
+		{
+			name: "RFC 3164 from a later month belongs to the previous year",
+			data: "<34>Oct 11 22:14:15 mymachine su: 'su root' failed",
+			want: SyslogMessage{
+				Format: "rfc3164", Priority: 34, Facility: "auth", Severity: "crit",
+				Timestamp: time.Date(2023, 10, 11, 22, 14, 15, 0, time.UTC),
+				Hostname:  "mymachine", AppName: "su",
+				Message: "'su root' failed",
+			},
+		},
+		{
+			name: "Cisco sequence number and unsynchronised clock",
+			data: "<189>52: *May  1 11:58:00.123: %LINK-3-UPDOWN: Interface Gi0/1, changed state to down",
+			want: SyslogMessage{
+				Format: "rfc3164", Priority: 189, Facility: "local7", Severity: "notice",
+				Timestamp: time.Date(2024, 5, 1, 11, 58, 0, 123e6, time.UTC),
+				AppName:   "%LINK-3-UPDOWN",
+				Message:   "Interface Gi0/1, changed state to down",
+			},
+		},
+		{
+			name: "RFC 3164 with RFC 3339 timestamp",
+			data: "<30>2024-05-01T11:00:00.5+02:00 db01 postgres[77]: checkpoint complete",
+			want: SyslogMessage{
+				Format: "rfc3164", Priority: 30, Facility: "daemon", Severity: "info",
+				Timestamp: time.Date(2024, 5, 1, 9, 0, 0, 5e8, time.UTC),
+				Hostname:  "db01", AppName: "postgres", ProcID: "77",
+				Message: "checkpoint complete",
+			},
+		},
+		{
+			name: "only PRI and text",
+			data: "<14>hello world",
+			want: SyslogMessage{
+				Format: "rfc3164", Priority: 14, Facility: "user", Severity: "info",
+				Timestamp: received, Message: "hello world",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseSyslog([]byte(tt.data), "192.0.2.1", received)
+			if err != nil {
+				t.Fatalf("ParseSyslog: %v", err)
+			}
+
+			tt.want.Source, tt.want.Received = "192.0.2.1", received
+			if !got.Timestamp.Equal(tt.want.Timestamp) {
+				t.Errorf("timestamp = %v, want %v", got.Timestamp, tt.want.Timestamp)
+			}
+			got.Timestamp = tt.want.Timestamp
+			if got != tt.want {
+				t.Errorf("got  %+v\nwant %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSyslogErrors(t *testing.T) {
+	received := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		data    string
+		wantErr string
+	}{
+		{"no PRI", "hello", "missing PRI"},
+		{"empty PRI", "<>hello", "missing PRI"},
+		{"PRI out of range", "<192>hello", "invalid PRI"},
+		{"PRI not numeric", "<1a>hello", "invalid PRI"},
+		{"truncated RFC 5424 header", "<34>1 2003-10-11T22:14:15Z host", "truncated RFC 5424 header"},
+		{"invalid RFC 5424 timestamp", "<34>1 yesterday host app - - - text", "invalid timestamp"},
+		{"invalid structured data", "<34>1 - host app - - text", "invalid structured data"},
+		{"unterminated structured data", `<34>1 - host app - - [a@1 x="]`, "unterminated structured data"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseSyslog([]byte(tt.data), "192.0.2.1", received)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("err = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseRFC3164TimestampYearRollover(t *testing.T) {
+	received := time.Date(2024, 1, 1, 0, 30, 0, 0, time.UTC)
+
+	got, _, ok := parseRFC3164Timestamp("Dec 31 23:59:00 host app: text", received)
+	if !ok {
+		t.Fatalf("timestamp not recognised")
+	}
+	if want := time.Date(2023, 12, 31, 23, 59, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("timestamp = %v, want %v", got, want)
+	}
+}
+
+func TestReadSyslogFrame(t *testing.T) {
+	tests := []struct {
+		name    string
+		stream  string
+		want    []string
+		wantErr string
+	}{
+		{"octet counting", "5 hello6 world!", []string{"hello", "world!"}, ""},
+		{"newline delimited skips blank lines", "\n<14>foo\n\r\n<14>bar\n", []string{"<14>foo\n", "<14>bar\n"}, ""},
+		{"zero length", "0 ", nil, "invalid frame length"},
+		{"length with a letter", "12x hello", nil, "invalid frame length"},
+		{"length too long", "12345678901 x", nil, "invalid frame length"},
+		{"length above the message limit", "99999999 x", nil, "invalid frame length"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader := bufio.NewReader(strings.NewReader(tt.stream))
+
+			var got []string
+			for {
+				frame, err := readSyslogFrame(reader)
+				if errors.Is(err, io.EOF) {
+					break
+				}
+				if err != nil {
+					if tt.wantErr == "" || !strings.Contains(err.Error(), tt.wantErr) {
+						t.Fatalf("err = %v, want %q", err, tt.wantErr)
+					}
+					return
+				}
+				got = append(got, string(frame))
+			}
+
+			if tt.wantErr != "" {
+				t.Fatalf("no error, want %q", tt.wantErr)
+			}
+			if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+				t.Errorf("frames = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,519 @@
+package monitors
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"log/slog"
+	"net"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ZEGIFTED/MS.GoMonitor/internal/repository"
+	"github.com/ZEGIFTED/MS.GoMonitor/notifier"
+)
+
+const (
+	DefaultTopologySchedule = "*/30 * * * *"
+
+	// topologyConcurrency bounds the devices walked at the same time, forwarding tables can be large
+	topologyConcurrency = 8
+
+	// Dependency source of the parents derived from the topology
+	topologyDependencySource = "topology"
+)
+
+// TopologyNode is a device in the topology graph. Managed nodes are services of the inventory and are
+// identified by their SystemMonitorId, other nodes are neighbours only known from LLDP or CDP.
+type TopologyNode struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Address  string `json:"address,omitempty"`
+	Device   string `json:"device,omitempty"`
+	Platform string `json:"platform,omitempty"`
+	Managed  bool   `json:"managed"`
+}
+
+// TopologyLink connects two nodes. Protocol is lldp or cdp for links reported by the devices, fdb when
+// the target's MAC address was learned on the source's port and arp for a layer 3 adjacency only.
+type TopologyLink struct {
+	Source     string `json:"source"`
+	SourcePort string `json:"sourcePort,omitempty"`
+	Target     string `json:"target"`
+	TargetPort string `json:"targetPort,omitempty"`
+	Protocol   string `json:"protocol"`
+}
+
+type Topology struct {
+	Nodes     []TopologyNode    `json:"nodes"`
+	Links     []TopologyLink    `json:"links"`
+	Root      string            `json:"root,omitempty"`
+	Parents   map[string]string `json:"parents"` // SystemMonitorId to the SystemMonitorId of its parent
+	UpdatedAt time.Time         `json:"updatedAt"`
+}
+
+// DeviceObservation is what was read from one polled device
+type DeviceObservation struct {
+	Service ServiceMonitorData
+	SysName string
+	DeviceNeighbors
+}
+
+// BuildTopology joins the observations into one graph. addresses maps the IP addresses of the inventory
+// to the SystemMonitorIds of the services on them. Parents are derived by walking the graph from root, a SystemMonitorId or service
+// name; without one the device with the most links is the root.
+func BuildTopology(observations []DeviceObservation, services []ServiceMonitorData, addresses map[string][]string, root string) Topology {
+	byID := make(map[string]ServiceMonitorData, len(services))
+	names := make(map[string]string)
+	for _, service := range services {
+		id := service.SystemMonitorId.String()
+		byID[id] = service
+		names[normalizeDeviceName(service.Name)] = id
+	}
+	for _, observation := range observations {
+		if observation.SysName != "" {
+			names[normalizeDeviceName(observation.SysName)] = observation.Service.SystemMonitorId.String()
+		}
+	}
+
+	observed := make(map[string]bool, len(observations))
+	for _, observation := range observations {
+		observed[observation.Service.SystemMonitorId.String()] = true
+	}
+
+	// Several services may share an address, a neighbour is the polled device among them if there is one
+	deviceAt := func(address string) (string, bool) {
+		ids := addresses[address]
+		for _, id := range ids {
+			if observed[id] {
+				return id, true
+			}
+		}
+		if len(ids) == 0 {
+			return "", false
+		}
+		return ids[0], true
+	}
+
+	nodes := make(map[string]*TopologyNode)
+	managedNode := func(id string) {
+		if _, ok := nodes[id]; ok {
+			return
+		}
+		service := byID[id]
+		nodes[id] = &TopologyNode{ID: id, Name: service.Name, Address: service.Host, Device: string(service.Device), Managed: true}
+	}
+
+	links := make(map[string]*TopologyLink)
+	linked := make(map[string]bool)
+	addLink := func(source, sourcePort, target, targetPort, protocol string) {
+		if source == target {
+			return
+		}
+		key := min(source, target) + "|" + max(source, target)
+		if link, ok := links[key]; ok {
+			// The other end reports the same link, it knows its own port
+			if link.Source == target && link.SourcePort == "" {
+				link.SourcePort = targetPort
+			}
+			if link.Target == source && link.TargetPort == "" {
+				link.TargetPort = sourcePort
+			}
+			return
+		}
+		links[key] = &TopologyLink{Source: source, SourcePort: sourcePort, Target: target, TargetPort: targetPort, Protocol: protocol}
+		linked[source], linked[target] = true, true
+	}
+
+	// Ports with an LLDP or CDP neighbour are uplinks, addresses learned on them are further away
+	uplinks := make(map[string]map[string]bool)
+
+	for _, observation := range observations {
+		device := observation.Service.SystemMonitorId.String()
+		managedNode(device)
+		uplinks[device] = make(map[string]bool)
+
+		for _, neighbor := range observation.Neighbors {
+			id, managed := "", false
+			if neighbor.Address != "" {
+				id, managed = deviceAt(neighbor.Address)
+			}
+			if !managed && neighbor.SysName != "" {
+				id, managed = names[normalizeDeviceName(neighbor.SysName)]
+			}
+
+			if managed {
+				managedNode(id)
+			} else {
+				id = neighbor.Protocol + ":" + neighbor.ChassisID
+				if neighbor.ChassisID == "" {
+					id = neighbor.Protocol + ":" + normalizeDeviceName(neighbor.SysName)
+				}
+				if _, ok := nodes[id]; !ok {
+					name := neighbor.SysName
+					if name == "" {
+						name = neighbor.ChassisID
+					}
+					nodes[id] = &TopologyNode{ID: id, Name: name, Address: neighbor.Address, Platform: neighbor.Platform}
+				}
+			}
+			if nodes[id].Platform == "" {
+				nodes[id].Platform = neighbor.Platform
+			}
+
+			uplinks[device][neighbor.LocalPort] = true
+			addLink(device, neighbor.LocalPort, id, neighbor.PortID, neighbor.Protocol)
+		}
+	}
+
+	// Services without LLDP or CDP are placed behind the access port their MAC address was learned on:
+	// of all ports that are not uplinks, the one with the fewest addresses is the closest
+	arp := make(map[string]string)
+	portSizes := make(map[string]map[string]int)
+	for _, observation := range observations {
+		for _, entry := range observation.ARP {
+			arp[entry.IP] = entry.MAC
+		}
+		device := observation.Service.SystemMonitorId.String()
+		portSizes[device] = make(map[string]int)
+		for _, entry := range observation.FDB {
+			portSizes[device][entry.Port]++
+		}
+	}
+
+	serviceAddresses := make([]string, 0, len(addresses))
+	for address := range addresses {
+		serviceAddresses = append(serviceAddresses, address)
+	}
+	sort.Strings(serviceAddresses)
+
+	for _, address := range serviceAddresses {
+		ids := addresses[address]
+		for _, id := range ids {
+			if linked[id] {
+				continue
+			}
+
+			var best, bestPort, via, viaPort string
+			bestSize := 0
+			mac, known := arp[address]
+			for _, observation := range observations {
+				device := observation.Service.SystemMonitorId.String()
+				if slices.Contains(ids, device) {
+					continue
+				}
+				for _, entry := range observation.FDB {
+					if !known || entry.MAC != mac || uplinks[device][entry.Port] {
+						continue
+					}
+					size := portSizes[device][entry.Port]
+					if best == "" || size < bestSize || (size == bestSize && device < best) {
+						best, bestPort, bestSize = device, entry.Port, size
+					}
+				}
+				for _, entry := range observation.ARP {
+					if entry.IP == address && (via == "" || device < via) {
+						via, viaPort = device, entry.Port
+					}
+				}
+			}
+
+			switch {
+			case best != "":
+				managedNode(id)
+				addLink(best, bestPort, id, "", "fdb")
+			case via != "":
+				managedNode(id)
+				addLink(via, viaPort, id, "", "arp")
+			}
+		}
+	}
+
+	topology := Topology{Parents: make(map[string]string), UpdatedAt: time.Now()}
+	for _, node := range nodes {
+		topology.Nodes = append(topology.Nodes, *node)
+	}
+	sort.Slice(topology.Nodes, func(i, j int) bool { return topology.Nodes[i].ID < topology.Nodes[j].ID })
+	for _, link := range links {
+		topology.Links = append(topology.Links, *link)
+	}
+	sort.Slice(topology.Links, func(i, j int) bool {
+		if topology.Links[i].Source != topology.Links[j].Source {
+			return topology.Links[i].Source < topology.Links[j].Source
+		}
+		return topology.Links[i].Target < topology.Links[j].Target
+	})
+
+	topology.Root = topologyRoot(topology, root)
+	if topology.Root != "" {
+		topology.Parents = topologyParents(topology, nodes)
+	}
+	return topology
+}
+
+// topologyRoot finds the configured root, or the managed node with the most links
+func topologyRoot(topology Topology, root string) string {
+	degree := make(map[string]int)
+	for _, link := range topology.Links {
+		degree[link.Source]++
+		degree[link.Target]++
+	}
+
+	best := ""
+	for _, node := range topology.Nodes {
+		if root != "" && (node.ID == root || strings.EqualFold(node.Name, root)) {
+			return node.ID
+		}
+		if node.Managed && degree[node.ID] > 0 && (best == "" || degree[node.ID] > degree[best]) {
+			best = node.ID
+		}
+	}
+	if root != "" {
+		slog.Warn("Topology root not found, using the busiest device", "root", root)
+	}
+	return best
+}
+
+// topologyParents walks the graph breadth first from the root. The parent of a managed node is the
+// closest managed node on its path to the root; unmanaged neighbours in between cannot be checked.
+func topologyParents(topology Topology, nodes map[string]*TopologyNode) map[string]string {
+	adjacent := make(map[string][]string)
+	for _, link := range topology.Links {
+		adjacent[link.Source] = append(adjacent[link.Source], link.Target)
+		adjacent[link.Target] = append(adjacent[link.Target], link.Source)
+	}
+
+	previous := map[string]string{topology.Root: ""}
+	queue := []string{topology.Root}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for _, next := range adjacent[current] {
+			if _, seen := previous[next]; seen {
+				continue
+			}
+			previous[next] = current
+			queue = append(queue, next)
+		}
+	}
+
+	parents := make(map[string]string)
+	for id, parent := range previous {
+		if id == topology.Root || !nodes[id].Managed {
+			continue
+		}
+		for parent != "" && !nodes[parent].Managed {
+			parent = previous[parent]
+		}
+		if parent != "" {
+			parents[id] = parent
+		}
+	}
+	return parents
+}
+
+// normalizeDeviceName reduces a host name to its lowercase first label. CDP device IDs may carry the
+// serial number in parentheses.
+func normalizeDeviceName(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if i := strings.Index(name, "("); i > 0 {
+		name = name[:i]
+	}
+	if net.ParseIP(name) == nil {
+		if i := strings.Index(name, "."); i > 0 {
+			name = name[:i]
+		}
+	}
+	return name
+}
+
+// TopologyCollector polls the network devices of the inventory for their neighbours and keeps the
+// resulting topology. The parents it derives are handed to the engine's dependency tracker.
+type TopologyCollector struct {
+	engine   *MonitoringEngine
+	Root     string // SystemMonitorId or name of the core device, the busiest device when empty
+	Schedule string
+
+	mu   sync.Mutex // Held for the duration of a run
+	last Topology
+	lmu  sync.RWMutex
+}
+
+func NewTopologyCollector(engine *MonitoringEngine, root, schedule string) *TopologyCollector {
+	if schedule == "" {
+		schedule = DefaultTopologySchedule
+	}
+	return &TopologyCollector{engine: engine, Root: root, Schedule: schedule}
+}
+
+// Last returns the topology of the latest run
+func (c *TopologyCollector) Last() Topology {
+	c.lmu.RLock()
+	defer c.lmu.RUnlock()
+	return c.last
+}
+
+// Run walks every network device monitored with network_snmp, rebuilds the topology and publishes it to
+// the management clients
+func (c *TopologyCollector) Run(ctx context.Context) (Topology, error) {
+	if !c.mu.TryLock() {
+		return Topology{}, fmt.Errorf("topology collection is already running")
+	}
+	defer c.mu.Unlock()
+
+	c.engine.MU.RLock()
+	services := append([]ServiceMonitorData(nil), c.engine.Services...)
+	c.engine.MU.RUnlock()
+
+	var devices []ServiceMonitorData
+	for _, service := range services {
+		if service.Device == ServiceMonitorSNMP && service.Host != "" && slices.Contains(service.Plugins, "network_snmp") {
+			devices = append(devices, service)
+		}
+	}
+
+	var (
+		wg           sync.WaitGroup
+		mu           sync.Mutex
+		observations []DeviceObservation
+		failed       = make(map[string]bool)
+	)
+	slots := make(chan struct{}, topologyConcurrency)
+	for _, device := range devices {
+		if ctx.Err() != nil {
+			break
+		}
+		slots <- struct{}{}
+		wg.Add(1)
+		go func(device ServiceMonitorData) {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+
+			observation, err := observeDevice(device)
+			if err != nil {
+				slog.Warn("Topology collection failed", "device", device.Name, "error", err)
+			}
+			mu.Lock()
+			observations = append(observations, observation)
+			if err != nil {
+				failed[device.SystemMonitorId.String()] = true
+			}
+			mu.Unlock()
+		}(device)
+	}
+	wg.Wait()
+	if ctx.Err() != nil {
+		return Topology{}, ctx.Err()
+	}
+
+	topology := BuildTopology(observations, services, serviceAddresses(services), c.Root)
+
+	previous := make(map[string][]string)
+	if c.engine.Dependencies != nil {
+		previous = c.engine.Dependencies.TopologyParents()
+	} else {
+		for child, parent := range c.Last().Parents {
+			previous[child] = []string{parent}
+		}
+	}
+
+	parents := make(map[string][]string, len(topology.Parents))
+	for child, parent := range topology.Parents {
+		parents[child] = []string{parent}
+	}
+
+	// The links of a device that could not be walked are missing from this run, it and the services
+	// placed behind it keep their previous parents
+	for child, childParents := range previous {
+		if _, ok := parents[child]; ok || len(childParents) == 0 {
+			continue
+		}
+		if failed[child] || slices.ContainsFunc(childParents, func(parent string) bool { return failed[parent] }) {
+			parents[child] = childParents
+			topology.Parents[child] = childParents[0]
+		}
+	}
+
+	c.lmu.Lock()
+	c.last = topology
+	c.lmu.Unlock()
+	if c.engine.Dependencies != nil {
+		c.engine.Dependencies.SetTopologyParents(parents)
+	}
+	if c.engine.Db != nil {
+		if err := repository.ReplaceServiceDependencies(c.engine.Db, topologyDependencySource, parents); err != nil {
+			log.Printf("Error saving topology dependencies: %v", err)
+		}
+	}
+
+	slog.Info("Network topology updated", "devices", len(devices), "nodes", len(topology.Nodes), "links", len(topology.Links), "parents", len(topology.Parents))
+	notifier.PublishManagementState("topology", topology)
+	return topology, nil
+}
+
+// LoadDependencies restores the parents derived by earlier runs, so suppression works before the
+// first run of this process completes
+func (c *TopologyCollector) LoadDependencies() error {
+	if c.engine.Db == nil || c.engine.Dependencies == nil {
+		return nil
+	}
+	parents, err := repository.FetchServiceDependencies(c.engine.Db, topologyDependencySource)
+	if err != nil {
+		return err
+	}
+	c.engine.Dependencies.SetTopologyParents(parents)
+	return nil
+}
+
+func observeDevice(device ServiceMonitorData) (DeviceObservation, error) {
+	observation := DeviceObservation{Service: device}
+
+	config, err := ParseSNMPDeviceConfig(device.Configuration)
+	if err != nil {
+		return observation, err
+	}
+	if config.SNMPVersion != SNMPVersion3 && config.CommunityString == "" {
+		config.CommunityString = "public"
+	}
+
+	nm := config.NetworkManager(device.Host)
+	snmp := nm.SNMPClient(config.SNMPVersion).SNMP
+	if err := snmp.Connect(); err != nil {
+		return observation, err
+	}
+	defer snmp.Conn.Close()
+
+	if packet, err := snmp.Get([]string{oidSysName}); err == nil && len(packet.Variables) > 0 {
+		observation.SysName = pduString(packet.Variables[0])
+	}
+
+	observation.DeviceNeighbors, err = nm.CollectNeighbors(snmp)
+	return observation, err
+}
+
+// serviceAddresses maps the IP addresses of the inventory to the SystemMonitorIds of the services on them
+func serviceAddresses(services []ServiceMonitorData) map[string][]string {
+	addresses := make(map[string][]string)
+	for _, service := range services {
+		if service.Host == "" {
+			continue
+		}
+		if ip := net.ParseIP(service.Host); ip != nil {
+			addresses[ip.String()] = append(addresses[ip.String()], service.SystemMonitorId.String())
+			continue
+		}
+		ips, err := net.LookupIP(service.Host)
+		if err != nil {
+			continue
+		}
+		for _, ip := range ips {
+			addresses[ip.String()] = append(addresses[ip.String()], service.SystemMonitorId.String())
+		}
+	}
+	return addresses
+}
@@ -22,6 +22,7 @@ const (
 	ServiceMonitorWebModules ServiceType = "Web Modules"
 	ServiceMonitorSNMP       ServiceType = "Network"
 	ServiceMonitorServer     ServiceType = "Server"
+	ServiceMonitorDatabase   ServiceType = "Database"
 	DockerEngine             Engines     = "Docker"
 )
 
@@ -62,14 +63,34 @@ type MonitoringResult struct {
 	ServicePluginID   string               `json:"service_plugin_id"`
 	HealthReport      constants.StatusInfo `json:"HealthReport,omitempty"`
 	Details           map[string]any
-	LastCheckTime     time.Time `json:"last_checked"`
-	LastServiceUpTime time.Time `json:"last_service_up_time"`
-	FailureCount      int       `json:"failure_count"`
+	LastCheckTime     time.Time     `json:"last_checked"`
+	LastServiceUpTime time.Time     `json:"last_service_up_time"`
+	FailureCount      int           `json:"failure_count"`
+	ObjectAlerts      []ObjectAlert `json:"object_alerts,omitempty"`
 	// Data            *string   `json:"data,omitempty"`
 	// ExecutionTime   *int64    `json:"execution_time,omitempty"`
 	// ErrorDetails    *string   `json:"error_details,omitempty"`
 }
 
+// ObjectAlert reports an unhealthy object inside a monitored service, e.g. a pod of a cluster.
+// Each object is alerted on separately so recipients see which object failed.
+type ObjectAlert struct {
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+	Reason    string `json:"reason"`
+	Message   string `json:"message"`
+	Severity  string `json:"severity"` // critical or warning
+}
+
+// Reference identifies the object as Kind/namespace/name
+func (o ObjectAlert) Reference() string {
+	if o.Namespace == "" {
+		return o.Kind + "/" + o.Name
+	}
+	return o.Kind + "/" + o.Namespace + "/" + o.Name
+}
+
 type MonitoringBatch struct {
 	MainResult    ServiceMonitorStatus
 	PluginResults []MonitoringResult `json:"pluginMonitoringResult"`
@@ -103,6 +124,12 @@ type ServiceMonitorPlugin interface {
 	Cleanup() error
 }
 
+// PrecheckExempt is implemented by plugins that reach their service without a TCP connection, e.g. over
+// ICMP or UDP. The engine's TCP precheck is skipped for services using such a plugin.
+type PrecheckExempt interface {
+	SkipsTCPPrecheck() bool
+}
+
 type MonitoringEngine struct {
 	Db             *sql.DB              // Database connection
 	Services       []ServiceMonitorData // List of services to monitor
@@ -120,4 +147,9 @@ type MonitoringEngine struct {
 	Cancel              context.CancelFunc
 	AlertCache          sync.Map
 	Alerts              chan internal.ServiceAlertEvent // Buffered channel for processing alerts
+	Heartbeats          *HeartbeatRegistry              // Push-based services, nil disables heartbeat monitoring
+	Traps               *TrapReceiver                   // SNMP trap receiver, nil disables trap handling
+	Syslog              *SyslogReceiver                 // Syslog receiver, nil disables syslog handling
+	Dependencies        *DependencyTracker              // Parents of services, nil disables alert suppression
+	Topology            *TopologyCollector              // LLDP/CDP, FDB and ARP collection, nil disables topology mapping
 }
@@ -11,6 +11,7 @@ import (
 	"time"
 
 	"github.com/ZEGIFTED/MS.GoMonitor/pkg/utils"
+	mstypes "github.com/ZEGIFTED/MS.GoMonitor/types"
 	"github.com/gorilla/websocket"
 	"github.com/lib/pq" // PostgreSQL driver
 )
@@ -39,7 +40,11 @@ type DashboardHub struct {
 	deviceGroups      map[string]DeviceGroup
 	serviceMonitors   map[string]ServiceMonitorData
 	lastDashboardData []byte
+	managementState   map[string][]byte // Latest message per type published with PublishManagementState
 	mu                sync.Mutex
+
+	facility   map[string]mstypes.FacilityStatus // SystemMonitorId to the latest sensor readings
+	facilityMu sync.RWMutex
 }
 
 var DashHub = DashboardHub{
@@ -50,6 +55,8 @@ var DashHub = DashboardHub{
 
 	deviceGroups:    make(map[string]DeviceGroup),
 	serviceMonitors: make(map[string]ServiceMonitorData),
+	managementState: make(map[string][]byte),
+	facility:        make(map[string]mstypes.FacilityStatus),
 }
 
 // Run starts the Hub to manage client connections
@@ -150,6 +157,16 @@ func (h *DashboardHub) sendInitialManagementData(client *DashboardClient) {
 	default:
 		log.Println("Failed to send initial devices data - channel blocked")
 	}
+
+	// Send the latest published state, e.g. the network topology
+	for messageType, data := range h.managementState {
+		select {
+		case client.send <- data:
+			log.Printf("Sent initial %s data to management client", messageType)
+		default:
+			log.Printf("Failed to send initial %s data - channel blocked", messageType)
+		}
+	}
 }
 
 // Broadcast data to all clients
@@ -312,6 +329,7 @@ FROM servicereport('ALL', NULL, TRUE, NULL);`
 		}
 
 		service.Plugins = plugins
+		service.Facility = facilityStatus(service.SystemMonitorId)
 		// service.StatusInfo = constants.GetStatusInfo(service.LiveCheckFlag, "")
 
 		services = append(services, service)
@@ -324,6 +342,23 @@ FROM servicereport('ALL', NULL, TRUE, NULL);`
 	return services, nil
 }
 
+// UpdateFacility keeps the latest sensor readings of a device for the facility view of the dashboard
+func UpdateFacility(systemMonitorId string, status mstypes.FacilityStatus) {
+	DashHub.facilityMu.Lock()
+	DashHub.facility[systemMonitorId] = status
+	DashHub.facilityMu.Unlock()
+}
+
+func facilityStatus(systemMonitorId string) *mstypes.FacilityStatus {
+	DashHub.facilityMu.RLock()
+	defer DashHub.facilityMu.RUnlock()
+
+	if status, ok := DashHub.facility[systemMonitorId]; ok {
+		return &status
+	}
+	return nil
+}
+
 // Helper function to convert 16-byte binary UUID to string representation
 func convertBinaryUUIDToString(b []byte) (string, error) {
 	if len(b) != 16 {
@@ -434,6 +469,40 @@ func broadcastGroupUpdate(action string, data interface{}) {
 	}
 }
 
+// BroadcastManagementEvent sends an event produced outside the management protocol, e.g. a network
+// discovery report, to the management clients
+func BroadcastManagementEvent(messageType string, data any) {
+	jsonData, err := json.Marshal(map[string]any{
+		"type": messageType,
+		"data": data,
+	})
+	if err != nil {
+		log.Printf("Error marshaling %s event: %v", messageType, err)
+		return
+	}
+
+	DashHub.broadcast <- BroadcastMessage{Target: "management", Data: jsonData}
+}
+
+// PublishManagementState broadcasts like BroadcastManagementEvent and keeps the message, so management
+// clients connecting later receive it with their initial data
+func PublishManagementState(messageType string, data any) {
+	jsonData, err := json.Marshal(map[string]any{
+		"type": messageType,
+		"data": data,
+	})
+	if err != nil {
+		log.Printf("Error marshaling %s state: %v", messageType, err)
+		return
+	}
+
+	DashHub.mu.Lock()
+	DashHub.managementState[messageType] = jsonData
+	DashHub.mu.Unlock()
+
+	DashHub.broadcast <- BroadcastMessage{Target: "management", Data: jsonData}
+}
+
 // broadcastDeviceUpdate
 func broadcastDeviceUpdate(device ServiceMonitorData) {
 	message := map[string]any{
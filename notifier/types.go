@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/ZEGIFTED/MS.GoMonitor/pkg/constants"
+	mstypes "github.com/ZEGIFTED/MS.GoMonitor/types"
 )
 
 type NotiferEvent struct {
@@ -44,13 +45,14 @@ type ServiceMonitorData struct {
 	// AcknowledgedDateTime sql.NullTime
 	// SnoozeUntil          sql.NullTime `json:"SnoozeUntil"`
 	// CreatedAt            sql.NullTime
-	StatusInfo         constants.StatusInfo `json:"HealthStatusInfo,omitempty"`
-	IsAcknowledged     bool                 `json:"IsServiceIssueAcknowledged"`
-	Device             string               `json:"Device"`
-	CurrentHealthCheck string               `json:"LiveCheckFlag"`
-	CheckInterval      string               `json:"checkInterval"` // Cron-style interval
-	Plugins            []string             `json:"Plugins"`       // This will hold the parsed plugins
-	Metadata           MonitorMetaData      `json:"Metadata"`
+	StatusInfo         constants.StatusInfo    `json:"HealthStatusInfo,omitempty"`
+	IsAcknowledged     bool                    `json:"IsServiceIssueAcknowledged"`
+	Device             string                  `json:"Device"`
+	CurrentHealthCheck string                  `json:"LiveCheckFlag"`
+	CheckInterval      string                  `json:"checkInterval"` // Cron-style interval
+	Plugins            []string                `json:"Plugins"`       // This will hold the parsed plugins
+	Metadata           MonitorMetaData         `json:"Metadata"`
+	Facility           *mstypes.FacilityStatus `json:"Facility,omitempty"` // Power and environment readings of UPS, PDU and sensor devices
 	// AgentAPIBaseURL   string                 `json:"agent_api"`
 	// AgentRepository internal.AgentRepository
 }
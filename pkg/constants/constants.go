@@ -74,6 +74,28 @@ var (
 	SMTPUser        = GetEnvWithDefault("MAILUSER", "")
 	SMTPPass        = GetEnvWithDefault("MAILPASS", "$_")
 	STMP_ADMIN_MAIL = GetEnvWithDefault("STMPADMIN", "")
+
+	SNMPTrapEnabled         = GetEnvWithDefault("SNMP_TRAP_ENABLED", "false") == "true"
+	SNMPTrapAddress         = GetEnvWithDefault("SNMP_TRAP_ADDRESS", "0.0.0.0:162")
+	SNMPTrapCommunities     = GetEnvWithDefault("SNMP_TRAP_COMMUNITIES", "") // Comma separated, v1/v2c traps also match the device's communityString
+	SNMPTrapRelays          = GetEnvWithDefault("SNMP_TRAP_RELAYS", "")      // Comma separated forwarder addresses, their traps are correlated on the originator they name
+	SNMPTrapUsersFile       = GetEnvWithDefault("SNMP_TRAP_USERS", "")       // JSON list of SNMPv3 USM users allowed to send traps
+	SNMPTrapDefinitionsFile = GetEnvWithDefault("SNMP_TRAP_DEFINITIONS", "") // JSON list of extra trap OID to severity mappings
+	SNMPProfilesDir         = GetEnvWithDefault("SNMP_PROFILES_DIR", "")     // Extra or overriding device profiles, one JSON file each
+	SNMPMIBDirs             = GetEnvWithDefault("SNMP_MIB_DIRS", "")         // Comma separated directories of MIB files for symbolic OIDs
+	DiscoveryConfigFile     = GetEnvWithDefault("DISCOVERY_CONFIG", "")      // JSON ranges, ports and SNMP credentials of the network discovery sweep
+	SyslogEnabled           = GetEnvWithDefault("SYSLOG_ENABLED", "false") == "true"
+	SyslogUDPAddress        = GetEnvWithDefault("SYSLOG_UDP_ADDRESS", "0.0.0.0:514")
+	SyslogTCPAddress        = GetEnvWithDefault("SYSLOG_TCP_ADDRESS", "") // Empty disables the transport, e.g. 0.0.0.0:514
+	SyslogTLSAddress        = GetEnvWithDefault("SYSLOG_TLS_ADDRESS", "") // Empty disables the transport, e.g. 0.0.0.0:6514
+	SyslogTLSCertFile       = GetEnvWithDefault("SYSLOG_TLS_CERT", "")
+	SyslogTLSKeyFile        = GetEnvWithDefault("SYSLOG_TLS_KEY", "")
+	SyslogRulesFile         = GetEnvWithDefault("SYSLOG_RULES", "") // JSON list of match rules evaluated before the built-in ones
+	FlowEnabled             = GetEnvWithDefault("FLOW_ENABLED", "false") == "true"
+	FlowAddresses           = GetEnvWithDefault("FLOW_ADDRESSES", "0.0.0.0:2055,0.0.0.0:4739") // Comma separated UDP addresses for NetFlow and IPFIX exports
+	TopologyEnabled         = GetEnvWithDefault("TOPOLOGY_ENABLED", "false") == "true"
+	TopologySchedule        = GetEnvWithDefault("TOPOLOGY_SCHEDULE", "*/30 * * * *")
+	TopologyRoot            = GetEnvWithDefault("TOPOLOGY_ROOT", "") // Service name or SystemMonitorId of the core device, the busiest device when empty
 )
 
 const (
@@ -159,6 +181,21 @@ func GetStatusInfo(code int, addedMessage string) StatusInfo {
 	return StatusMap[UnknownStatus]
 }
 
+// HealthColorStatus maps the green/yellow/red health color reported by clustered services
+// (Elasticsearch cluster health, Kafka and Redis summaries) onto a status flag
+func HealthColorStatus(color string) int {
+	switch strings.ToLower(strings.TrimSpace(color)) {
+	case "green":
+		return Healthy
+	case "yellow":
+		return Escalation
+	case "red":
+		return Degraded
+	}
+
+	return UnknownStatus
+}
+
 const (
 	UnknownStatus = iota
 	Healthy
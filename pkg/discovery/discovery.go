@@ -0,0 +1,546 @@
+package discovery
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"log/slog"
+	"math/big"
+	"net"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ZEGIFTED/MS.GoMonitor/internal/network"
+	"github.com/ZEGIFTED/MS.GoMonitor/internal/repository"
+	"github.com/ZEGIFTED/MS.GoMonitor/monitors"
+	"github.com/ZEGIFTED/MS.GoMonitor/notifier"
+	"github.com/ZEGIFTED/MS.GoMonitor/pkg/utils"
+	mstypes "github.com/ZEGIFTED/MS.GoMonitor/types"
+	"github.com/gosnmp/gosnmp"
+)
+
+const (
+	DefaultSchedule = "0 */6 * * *"
+
+	// maxSweepHosts bounds a run, a /16 takes long enough already
+	maxSweepHosts = 1 << 16
+
+	oidSysDescr    = ".1.3.6.1.2.1.1.1.0"
+	oidSysObjectID = ".1.3.6.1.2.1.1.2.0"
+	oidSysName     = ".1.3.6.1.2.1.1.5.0"
+)
+
+// defaultTCPPorts are services that identify common device roles, see suggest
+var defaultTCPPorts = []int{22, 25, 80, 389, 443, 1433, 3306, 3389, 5432, 6379, 8080, 8443, 9092, 9200}
+
+// Credential is an SNMP credential tried against every host, the first one accepted identifies the device
+type Credential struct {
+	Name   string
+	Config monitors.SNMPDeviceConfig
+}
+
+type Config struct {
+	Ranges         []*net.IPNet
+	Exclude        []*net.IPNet
+	TCPPorts       []int
+	Credentials    []Credential
+	ICMP           bool
+	PingPrivileged bool
+	Timeout        time.Duration // Per probe
+	Concurrency    int           // Hosts probed at the same time
+	Schedule       string
+}
+
+// LoadConfig reads a discovery configuration from a JSON file
+func LoadConfig(path string) (Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, err
+	}
+
+	var cfg map[string]any
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return Config{}, fmt.Errorf("invalid discovery configuration %s: %v", path, err)
+	}
+	return ParseConfig(cfg)
+}
+
+// ParseConfig validates a discovery configuration. SNMP credentials use the same keys as the
+// Configuration of a network device plus an optional name.
+func ParseConfig(cfg map[string]any) (Config, error) {
+	config := Config{
+		TCPPorts:       defaultTCPPorts,
+		ICMP:           utils.ConfigBool(cfg, "icmp", true),
+		PingPrivileged: utils.ConfigBool(cfg, "pingPrivileged", false),
+		Timeout:        utils.ConfigDuration(cfg, "timeout", time.Second),
+		Concurrency:    max(utils.ConfigInt(cfg, "concurrency", 64), 1),
+		Schedule:       utils.ConfigString(cfg, "schedule", DefaultSchedule),
+	}
+
+	var err error
+	if config.Ranges, err = parseNetworks(utils.ConfigStringSlice(cfg, "ranges")); err != nil {
+		return config, err
+	}
+	if len(config.Ranges) == 0 {
+		return config, fmt.Errorf("discovery needs at least one range")
+	}
+	if config.Exclude, err = parseNetworks(utils.ConfigStringSlice(cfg, "exclude")); err != nil {
+		return config, err
+	}
+	if !utils.IsValidCron(config.Schedule) {
+		return config, fmt.Errorf("invalid discovery schedule %q", config.Schedule)
+	}
+
+	if ports, ok := cfg["tcpPorts"]; ok {
+		if config.TCPPorts, err = parsePorts(ports); err != nil {
+			return config, err
+		}
+	}
+
+	for i, credential := range utils.ConfigMapSlice(cfg, "snmpCredentials") {
+		parsed, err := monitors.ParseSNMPDeviceConfig(credential)
+		if err != nil {
+			return config, fmt.Errorf("SNMP credential %d: %v", i+1, err)
+		}
+		if parsed.SNMPVersion != monitors.SNMPVersion3 && parsed.CommunityString == "" {
+			parsed.CommunityString = "public"
+		}
+
+		name := utils.ConfigString(credential, "name", "")
+		if name == "" {
+			name = fmt.Sprintf("%s-%d", parsed.SNMPVersion, i+1)
+		}
+		config.Credentials = append(config.Credentials, Credential{Name: name, Config: parsed})
+	}
+
+	if _, err := config.hosts(); err != nil {
+		return config, err
+	}
+	return config, nil
+}
+
+// parsePorts reads a list of port numbers, given as JSON numbers or strings
+func parsePorts(value any) ([]int, error) {
+	items, ok := value.([]any)
+	if !ok {
+		return nil, fmt.Errorf("tcpPorts must be a list")
+	}
+
+	ports := make([]int, 0, len(items))
+	for _, item := range items {
+		port := -1
+		switch v := item.(type) {
+		case float64:
+			if v == float64(int(v)) {
+				port = int(v)
+			}
+		case string:
+			if n, err := strconv.Atoi(strings.TrimSpace(v)); err == nil {
+				port = n
+			}
+		}
+		if port <= 0 || port > 65535 {
+			return nil, fmt.Errorf("invalid TCP port %v", item)
+		}
+		ports = append(ports, port)
+	}
+	return ports, nil
+}
+
+func parseNetworks(values []string) ([]*net.IPNet, error) {
+	var networks []*net.IPNet
+	for _, value := range values {
+		// A single address is a range of one
+		if ip := net.ParseIP(value); ip != nil {
+			bits := 8 * len(ip.To16())
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 32
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, network, err := net.ParseCIDR(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid range %q: %v", value, err)
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+// target is one address of a sweep with the range it belongs to
+type target struct {
+	ip      net.IP
+	network string
+}
+
+// hosts expands the ranges into the addresses to probe. The network and broadcast addresses of IPv4
+// ranges are skipped, except for /31 and /32.
+func (c Config) hosts() ([]target, error) {
+	var targets []target
+	seen := make(map[string]bool)
+
+	for _, network := range c.Ranges {
+		ones, bits := network.Mask.Size()
+		if bits-ones > 16 {
+			return nil, fmt.Errorf("range %s is larger than %d addresses", network, maxSweepHosts)
+		}
+
+		size := 1 << (bits - ones)
+		base := new(big.Int).SetBytes(network.IP.Mask(network.Mask))
+		for i := 0; i < size; i++ {
+			if bits == 32 && size > 2 && (i == 0 || i == size-1) {
+				continue
+			}
+
+			ip := make(net.IP, bits/8)
+			new(big.Int).Add(base, big.NewInt(int64(i))).FillBytes(ip)
+			if seen[ip.String()] || c.excluded(ip) {
+				continue
+			}
+			seen[ip.String()] = true
+
+			targets = append(targets, target{ip: ip, network: network.String()})
+			if len(targets) > maxSweepHosts {
+				return nil, fmt.Errorf("discovery ranges hold more than %d addresses", maxSweepHosts)
+			}
+		}
+	}
+	return targets, nil
+}
+
+func (c Config) excluded(ip net.IP) bool {
+	for _, network := range c.Exclude {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c Config) inRanges(address string) bool {
+	ip := net.ParseIP(address)
+	if ip == nil || c.excluded(ip) {
+		return false
+	}
+	for _, network := range c.Ranges {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Report is the outcome of one discovery run
+type Report struct {
+	StartedAt   time.Time                `json:"startedAt"`
+	FinishedAt  time.Time                `json:"finishedAt"`
+	Ranges      []string                 `json:"ranges"`
+	Scanned     int                      `json:"scanned"`
+	Alive       int                      `json:"alive"`
+	Candidates  []mstypes.DiscoveredHost `json:"candidates"`  // Live hosts not in the inventory yet
+	New         []mstypes.DiscoveredHost `json:"new"`         // Hosts that were not alive in the previous run
+	Disappeared []mstypes.DiscoveredHost `json:"disappeared"` // Hosts alive in the previous run that no longer answer
+}
+
+// Discoverer sweeps the configured ranges and remembers what it found between runs
+type Discoverer struct {
+	engine *monitors.MonitoringEngine
+	config Config
+
+	mu      sync.Mutex // Held for the duration of a run
+	hosts   map[string]mstypes.DiscoveredHost
+	fetched bool
+}
+
+func New(engine *monitors.MonitoringEngine, config Config) *Discoverer {
+	return &Discoverer{engine: engine, config: config, hosts: make(map[string]mstypes.DiscoveredHost)}
+}
+
+// Run sweeps the ranges once, stores the results and sends the report to the management clients.
+// A run that starts while the previous one is still going is skipped.
+func (d *Discoverer) Run(ctx context.Context) (Report, error) {
+	if !d.mu.TryLock() {
+		return Report{}, fmt.Errorf("discovery is already running")
+	}
+	defer d.mu.Unlock()
+
+	report := Report{StartedAt: time.Now()}
+	for _, network := range d.config.Ranges {
+		report.Ranges = append(report.Ranges, network.String())
+	}
+
+	targets, err := d.config.hosts()
+	if err != nil {
+		return report, err
+	}
+	report.Scanned = len(targets)
+
+	if !d.fetched && d.engine.Db != nil {
+		previous, err := repository.FetchDiscoveredHosts(d.engine.Db)
+		if err != nil {
+			log.Printf("Could not load previous discovery results: %v", err)
+		} else {
+			for _, host := range previous {
+				d.hosts[host.Address] = host
+			}
+			d.fetched = true
+		}
+	}
+
+	found := d.sweep(ctx, targets)
+	if ctx.Err() != nil {
+		// A partial sweep would report every host it did not reach as disappeared
+		return report, ctx.Err()
+	}
+
+	inventory := d.inventory()
+	var changed []mstypes.DiscoveredHost
+
+	for _, host := range found {
+		previous, seen := d.hosts[host.Address]
+		if seen {
+			host.FirstSeen = previous.FirstSeen
+		} else {
+			host.FirstSeen = report.StartedAt
+		}
+		host.LastSeen = report.StartedAt
+		host.Present = true
+		host.SystemMonitorId = inventory[host.Address]
+
+		if !seen || !previous.Present {
+			report.New = append(report.New, host)
+		}
+		if host.SystemMonitorId == "" {
+			report.Candidates = append(report.Candidates, host)
+		}
+
+		d.hosts[host.Address] = host
+		changed = append(changed, host)
+	}
+	report.Alive = len(found)
+
+	for address, host := range d.hosts {
+		if !host.Present || host.LastSeen.Equal(report.StartedAt) || !d.config.inRanges(address) {
+			continue
+		}
+		host.Present = false
+		d.hosts[address] = host
+		report.Disappeared = append(report.Disappeared, host)
+		changed = append(changed, host)
+	}
+	sort.Slice(report.Disappeared, func(i, j int) bool {
+		return report.Disappeared[i].Address < report.Disappeared[j].Address
+	})
+	report.FinishedAt = time.Now()
+
+	if d.engine.Db != nil {
+		if err := repository.SyncDiscoveredHosts(d.engine.Db, changed); err != nil {
+			log.Printf("Error saving discovery results: %v", err)
+		}
+	}
+
+	slog.Info("Network discovery finished", "scanned", report.Scanned, "alive", report.Alive,
+		"candidates", len(report.Candidates), "new", len(report.New), "disappeared", len(report.Disappeared),
+		"duration", report.FinishedAt.Sub(report.StartedAt).Round(time.Second))
+
+	notifier.BroadcastManagementEvent("discoveryReport", report)
+	return report, nil
+}
+
+// sweep probes the targets with at most Concurrency hosts at a time and returns the live ones in
+// address order
+func (d *Discoverer) sweep(ctx context.Context, targets []target) []mstypes.DiscoveredHost {
+	var (
+		wg    sync.WaitGroup
+		mu    sync.Mutex
+		found []mstypes.DiscoveredHost
+	)
+	slots := make(chan struct{}, d.config.Concurrency)
+
+	for _, t := range targets {
+		if ctx.Err() != nil {
+			break
+		}
+
+		slots <- struct{}{}
+		wg.Add(1)
+		go func(t target) {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+
+			host, alive := d.probe(ctx, t)
+			if !alive {
+				return
+			}
+			mu.Lock()
+			found = append(found, host)
+			mu.Unlock()
+		}(t)
+	}
+	wg.Wait()
+
+	sort.Slice(found, func(i, j int) bool {
+		return compareIP(net.ParseIP(found[i].Address), net.ParseIP(found[j].Address)) < 0
+	})
+	return found
+}
+
+// probe checks one address with ICMP, TCP and SNMP. SNMP is tried even when the host does not answer
+// pings or connections, network devices often only expose their agent.
+func (d *Discoverer) probe(ctx context.Context, t target) (mstypes.DiscoveredHost, bool) {
+	host := mstypes.DiscoveredHost{Address: t.ip.String(), Range: t.network}
+
+	if d.config.ICMP {
+		pinger := network.NewPinger()
+		pinger.Count = 1
+		pinger.Timeout = d.config.Timeout
+		pinger.Privileged = d.config.PingPrivileged
+		if result, err := pinger.Ping(ctx, host.Address); err == nil && result.Received > 0 {
+			host.Methods = append(host.Methods, "icmp")
+			host.RTTMs = float64(result.AvgRTT.Microseconds()) / 1000
+		}
+	}
+
+	if ports := d.openPorts(ctx, host.Address); len(ports) > 0 {
+		host.Methods = append(host.Methods, "tcp")
+		host.OpenPorts = ports
+	}
+
+	for _, credential := range d.config.Credentials {
+		if d.identify(&host, credential) {
+			host.Methods = append(host.Methods, "snmp")
+			break
+		}
+	}
+
+	if len(host.Methods) == 0 {
+		return host, false
+	}
+
+	lookupCtx, cancel := context.WithTimeout(ctx, d.config.Timeout)
+	if names, err := net.DefaultResolver.LookupAddr(lookupCtx, host.Address); err == nil && len(names) > 0 {
+		host.Hostname = trimDot(names[0])
+	}
+	cancel()
+
+	suggest(&host)
+	return host, true
+}
+
+func (d *Discoverer) openPorts(ctx context.Context, address string) []int {
+	var (
+		wg    sync.WaitGroup
+		mu    sync.Mutex
+		ports []int
+	)
+	dialer := net.Dialer{Timeout: d.config.Timeout}
+
+	for _, port := range d.config.TCPPorts {
+		wg.Add(1)
+		go func(port int) {
+			defer wg.Done()
+			conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(address, strconv.Itoa(port)))
+			if err != nil {
+				return
+			}
+			conn.Close()
+
+			mu.Lock()
+			ports = append(ports, port)
+			mu.Unlock()
+		}(port)
+	}
+	wg.Wait()
+
+	slices.Sort(ports)
+	return ports
+}
+
+// identify reads the system group with credential, it reports false when the agent did not answer
+func (d *Discoverer) identify(host *mstypes.DiscoveredHost, credential Credential) bool {
+	client := credential.Config.NetworkManager(host.Address).SNMPClient(credential.Config.SNMPVersion).SNMP
+	client.Timeout = d.config.Timeout
+	client.Retries = 0
+
+	if err := client.Connect(); err != nil {
+		return false
+	}
+	defer client.Conn.Close()
+
+	packet, err := client.Get([]string{oidSysDescr, oidSysObjectID, oidSysName})
+	if err != nil || packet.Error != gosnmp.NoError {
+		return false
+	}
+
+	for _, variable := range packet.Variables {
+		switch variable.Name {
+		case oidSysDescr:
+			if value, ok := variable.Value.([]byte); ok {
+				host.SysDescr = string(value)
+			}
+		case oidSysObjectID:
+			if value, ok := variable.Value.(string); ok {
+				host.SysObjectID = trimDot(value)
+			}
+		case oidSysName:
+			if value, ok := variable.Value.([]byte); ok {
+				host.SysName = string(value)
+			}
+		}
+	}
+	if host.SysDescr == "" && host.SysObjectID == "" {
+		return false
+	}
+
+	host.Credential = credential.Name
+	host.SuggestedConfiguration = map[string]any{
+		"snmpVersion": credential.Config.SNMPVersion,
+		"snmpPort":    credential.Config.Port,
+	}
+	if credential.Config.SNMPVersion == monitors.SNMPVersion3 {
+		host.SuggestedConfiguration["authUsernameV3"] = credential.Config.AuthUsernameV3
+		host.SuggestedConfiguration["securityLevel"] = credential.Config.SecurityLevel
+	}
+	return true
+}
+
+// inventory maps the addresses of the monitored services to their SystemMonitorId
+func (d *Discoverer) inventory() map[string]string {
+	d.engine.MU.RLock()
+	services := append([]monitors.ServiceMonitorData(nil), d.engine.Services...)
+	d.engine.MU.RUnlock()
+
+	addresses := make(map[string]string)
+	for _, service := range services {
+		if service.Host == "" {
+			continue
+		}
+		if ip := net.ParseIP(service.Host); ip != nil {
+			addresses[ip.String()] = service.SystemMonitorId.String()
+			continue
+		}
+		ips, err := net.LookupIP(service.Host)
+		if err != nil {
+			continue
+		}
+		for _, ip := range ips {
+			addresses[ip.String()] = service.SystemMonitorId.String()
+		}
+	}
+	return addresses
+}
+
+// compareIP orders addresses numerically, IPv4 addresses sort before IPv6 ones
+func compareIP(a, b net.IP) int {
+	return slices.Compare(a.To16(), b.To16())
+}
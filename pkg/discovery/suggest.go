@@ -0,0 +1,103 @@
+package discovery
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/ZEGIFTED/MS.GoMonitor/monitors"
+	"github.com/ZEGIFTED/MS.GoMonitor/pkg/snmpprofiles"
+	mstypes "github.com/ZEGIFTED/MS.GoMonitor/types"
+)
+
+// portPlugins are the plugins proposed for a host with the port open
+var portPlugins = map[int][]string{
+	25:   {"mail_check"},
+	80:   {"http_monitor"},
+	389:  {"ldap_check"},
+	443:  {"http_monitor", "ssl_check"},
+	1433: {"mssql_check"},
+	5432: {"postgres_check"},
+	6379: {"redis_check"},
+	8080: {"http_monitor"},
+	8443: {"http_monitor", "ssl_check"},
+	9092: {"kafka_check"},
+	9200: {"elastic_check"},
+}
+
+var databasePorts = []int{1433, 3306, 5432, 6379, 9200}
+var webPorts = []int{80, 443, 8080, 8443}
+
+// serverProfiles are SNMP profiles of general purpose hosts rather than network equipment
+var serverProfiles = []string{"linux_netsnmp"}
+
+// suggest fills in the inventory entry proposed for host. SNMP agents are network equipment unless
+// they describe a general purpose operating system; hosts without SNMP are classified by their ports.
+func suggest(host *mstypes.DiscoveredHost) {
+	host.SuggestedName = host.Address
+	switch {
+	case host.SysName != "":
+		host.SuggestedName = host.SysName
+	case host.Hostname != "":
+		host.SuggestedName = strings.Split(host.Hostname, ".")[0]
+	}
+
+	var plugins []string
+	if host.SysObjectID != "" || host.SysDescr != "" {
+		if profile, ok := snmpprofiles.Default.Match(host.SysObjectID); ok {
+			host.Profile = profile.Name
+		}
+
+		host.SuggestedDevice = string(monitors.ServiceMonitorSNMP)
+		if slices.Contains(serverProfiles, host.Profile) || isServerOS(host.SysDescr) {
+			host.SuggestedDevice = string(monitors.ServiceMonitorServer)
+		}
+		plugins = append(plugins, "network_snmp")
+		if host.Profile != "" && host.Profile != "generic" && host.SuggestedConfiguration != nil {
+			host.SuggestedConfiguration["snmpProfile"] = host.Profile
+		}
+	} else {
+		host.SuggestedDevice = string(monitors.ServiceMonitorServer)
+		switch {
+		case hasAny(host.OpenPorts, databasePorts):
+			host.SuggestedDevice = string(monitors.ServiceMonitorDatabase)
+		case hasAny(host.OpenPorts, webPorts):
+			host.SuggestedDevice = string(monitors.ServiceMonitorWebModules)
+		}
+	}
+
+	if slices.Contains(host.Methods, "icmp") {
+		plugins = append(plugins, "ping")
+	}
+	// Network equipment often serves a management UI, that is not a service worth its own checks
+	if host.SuggestedDevice != string(monitors.ServiceMonitorSNMP) {
+		for _, port := range host.OpenPorts {
+			plugins = append(plugins, portPlugins[port]...)
+		}
+	}
+
+	slices.Sort(plugins)
+	host.SuggestedPlugins = slices.Compact(plugins)
+}
+
+// isServerOS recognises the sysDescr of net-snmp on Linux and BSD and of the Windows SNMP service
+func isServerOS(sysDescr string) bool {
+	for _, prefix := range []string{"Linux ", "FreeBSD ", "OpenBSD ", "SunOS ", "Darwin "} {
+		if strings.HasPrefix(sysDescr, prefix) {
+			return true
+		}
+	}
+	return strings.Contains(sysDescr, "Software: Windows")
+}
+
+func hasAny(ports, wanted []int) bool {
+	for _, port := range ports {
+		if slices.Contains(wanted, port) {
+			return true
+		}
+	}
+	return false
+}
+
+func trimDot(value string) string {
+	return strings.TrimSuffix(strings.TrimPrefix(value, "."), ".")
+}
@@ -0,0 +1,359 @@
+package flows
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Aggregation dimensions
+const (
+	DimensionTalker       = "talker"       // Source address
+	DimensionProtocol     = "protocol"     // IP protocol and service port, e.g. TCP/443
+	DimensionConversation = "conversation" // Address pair in either direction
+)
+
+var dimensions = []string{DimensionTalker, DimensionProtocol, DimensionConversation}
+
+const (
+	bucketSize = time.Minute
+
+	// maxKeysPerDimension bounds the memory of one interface and minute, a scan must not exhaust it.
+	// Further keys are counted as "other".
+	maxKeysPerDimension = 5000
+	otherKey            = "other"
+
+	// maxInterfacesPerBucket bounds the interfaces of one minute, flows of further interfaces are only
+	// counted for the exporter as a whole
+	maxInterfacesPerBucket = 10000
+)
+
+var protocolNames = map[uint8]string{1: "ICMP", 2: "IGMP", 6: "TCP", 17: "UDP", 47: "GRE", 50: "ESP", 51: "AH", 58: "ICMPv6", 89: "OSPF", 112: "VRRP", 132: "SCTP"}
+
+// Interface selects the traffic of one exporter interface. IfIndex 0 with an empty Direction is all
+// traffic of the exporter.
+type Interface struct {
+	Exporter  string `json:"exporter"` // SystemMonitorId of the exporting device
+	IfIndex   uint32 `json:"ifIndex"`
+	Direction string `json:"direction,omitempty"` // in for flows entering the interface, out for leaving
+}
+
+type counter struct {
+	Bytes   uint64
+	Packets uint64
+}
+
+type interfaceStats struct {
+	total counter
+	keys  map[string]map[string]*counter // Dimension to key to counter
+}
+
+type bucket struct {
+	start      time.Time
+	interfaces map[Interface]*interfaceStats
+	stored     bool
+}
+
+// Entry is one key of a dimension with its share of the traffic
+type Entry struct {
+	Key        string  `json:"key"`
+	Bytes      uint64  `json:"bytes"`
+	Packets    uint64  `json:"packets"`
+	BitsPerSec float64 `json:"bitsPerSecond"`
+	Percent    float64 `json:"percent"`
+}
+
+// Summary is the traffic of an interface over a window with the top entries of every dimension
+type Summary struct {
+	Interface
+	Window           string    `json:"window"`
+	From             time.Time `json:"from"`
+	To               time.Time `json:"to"`
+	Bytes            uint64    `json:"bytes"`
+	Packets          uint64    `json:"packets"`
+	BitsPerSec       float64   `json:"bitsPerSecond"`
+	TopTalkers       []Entry   `json:"topTalkers"`
+	TopProtocols     []Entry   `json:"topProtocols"`
+	TopConversations []Entry   `json:"topConversations"`
+}
+
+// Describe renders the top talkers on one line, e.g. for an alert message
+func (s Summary) Describe(limit int) string {
+	var parts []string
+	for i, entry := range s.TopTalkers {
+		if i == limit {
+			break
+		}
+		parts = append(parts, fmt.Sprintf("%s %.1f%%", entry.Key, entry.Percent))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Aggregator sums flows per interface into one minute buckets and answers for any window up to its
+// retention. Flows are placed by the time they were received, long flows exported by an active timeout
+// are attributed to the minute of their export.
+type Aggregator struct {
+	mu        sync.Mutex
+	retention time.Duration
+	buckets   []*bucket // Oldest first
+}
+
+func NewAggregator(retention time.Duration) *Aggregator {
+	return &Aggregator{retention: retention}
+}
+
+// Add counts a flow for the exporter as a whole and for its input and output interface
+func (a *Aggregator) Add(exporter string, flow Flow, received time.Time) {
+	keys := map[string]string{
+		DimensionTalker:       flow.SrcAddr.String(),
+		DimensionProtocol:     protocolKey(flow),
+		DimensionConversation: conversationKey(flow),
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	b := a.bucket(received)
+	b.add(Interface{Exporter: exporter}, keys, flow)
+	if flow.InputIf != 0 {
+		b.add(Interface{Exporter: exporter, IfIndex: flow.InputIf, Direction: "in"}, keys, flow)
+	}
+	if flow.OutputIf != 0 {
+		b.add(Interface{Exporter: exporter, IfIndex: flow.OutputIf, Direction: "out"}, keys, flow)
+	}
+}
+
+// bucket returns the bucket of received, creating it and dropping expired ones
+func (a *Aggregator) bucket(received time.Time) *bucket {
+	start := received.Truncate(bucketSize)
+	for i := len(a.buckets) - 1; i >= 0; i-- {
+		if a.buckets[i].start.Equal(start) {
+			return a.buckets[i]
+		}
+		if a.buckets[i].start.Before(start) {
+			break
+		}
+	}
+
+	b := &bucket{start: start, interfaces: make(map[Interface]*interfaceStats)}
+	a.buckets = append(a.buckets, b)
+	sort.Slice(a.buckets, func(i, j int) bool { return a.buckets[i].start.Before(a.buckets[j].start) })
+
+	cutoff := start.Add(-a.retention)
+	for len(a.buckets) > 0 && a.buckets[0].start.Before(cutoff) {
+		a.buckets = a.buckets[1:]
+	}
+	return b
+}
+
+func (b *bucket) add(iface Interface, keys map[string]string, flow Flow) {
+	stats, ok := b.interfaces[iface]
+	if !ok {
+		if iface.IfIndex != 0 && len(b.interfaces) >= maxInterfacesPerBucket {
+			return
+		}
+		stats = &interfaceStats{keys: make(map[string]map[string]*counter, len(dimensions))}
+		for _, dimension := range dimensions {
+			stats.keys[dimension] = make(map[string]*counter)
+		}
+		b.interfaces[iface] = stats
+	}
+
+	stats.total.Bytes += flow.Bytes
+	stats.total.Packets += flow.Packets
+	for dimension, key := range keys {
+		counters := stats.keys[dimension]
+		c, ok := counters[key]
+		if !ok {
+			if len(counters) >= maxKeysPerDimension {
+				key = otherKey
+				c = counters[key]
+			}
+			if c == nil {
+				c = &counter{}
+				counters[key] = c
+			}
+		}
+		c.Bytes += flow.Bytes
+		c.Packets += flow.Packets
+	}
+}
+
+// Summary sums the buckets of the window ending now and returns the top limit entries per dimension
+func (a *Aggregator) Summary(iface Interface, window time.Duration, limit int, now time.Time) (Summary, bool) {
+	summary := Summary{Interface: iface, Window: window.String(), To: now}
+	totals := make(map[string]map[string]*counter, len(dimensions))
+	for _, dimension := range dimensions {
+		totals[dimension] = make(map[string]*counter)
+	}
+
+	from := now.Add(-window)
+	found := false
+
+	a.mu.Lock()
+	for _, b := range a.buckets {
+		// Buckets are counted whole, a window of five minutes covers the current and four full minutes
+		if !b.start.Add(bucketSize).After(from) || b.start.After(now) {
+			continue
+		}
+		stats, ok := b.interfaces[iface]
+		if !ok {
+			continue
+		}
+		if !found || b.start.Before(summary.From) {
+			summary.From = b.start
+		}
+		found = true
+
+		summary.Bytes += stats.total.Bytes
+		summary.Packets += stats.total.Packets
+		for dimension, counters := range stats.keys {
+			for key, c := range counters {
+				total, ok := totals[dimension][key]
+				if !ok {
+					total = &counter{}
+					totals[dimension][key] = total
+				}
+				total.Bytes += c.Bytes
+				total.Packets += c.Packets
+			}
+		}
+	}
+	a.mu.Unlock()
+
+	if !found {
+		return summary, false
+	}
+
+	seconds := now.Sub(summary.From).Seconds()
+	if seconds < 1 {
+		seconds = 1
+	}
+	summary.BitsPerSec = float64(summary.Bytes) * 8 / seconds
+
+	top := func(dimension string) []Entry {
+		return topEntries(totals[dimension], summary.Bytes, seconds, limit)
+	}
+	summary.TopTalkers = top(DimensionTalker)
+	summary.TopProtocols = top(DimensionProtocol)
+	summary.TopConversations = top(DimensionConversation)
+	return summary, true
+}
+
+// Interfaces lists everything seen within the window
+func (a *Aggregator) Interfaces(window time.Duration, now time.Time) []Interface {
+	seen := make(map[Interface]bool)
+
+	a.mu.Lock()
+	for _, b := range a.buckets {
+		if b.start.Add(bucketSize).After(now.Add(-window)) {
+			for iface := range b.interfaces {
+				seen[iface] = true
+			}
+		}
+	}
+	a.mu.Unlock()
+
+	interfaces := make([]Interface, 0, len(seen))
+	for iface := range seen {
+		interfaces = append(interfaces, iface)
+	}
+	sort.Slice(interfaces, func(i, j int) bool {
+		if interfaces[i].Exporter != interfaces[j].Exporter {
+			return interfaces[i].Exporter < interfaces[j].Exporter
+		}
+		if interfaces[i].IfIndex != interfaces[j].IfIndex {
+			return interfaces[i].IfIndex < interfaces[j].IfIndex
+		}
+		return interfaces[i].Direction < interfaces[j].Direction
+	})
+	return interfaces
+}
+
+// Completed returns the top limit entries of every minute that ended before now and was not returned
+// before, for storage
+func (a *Aggregator) Completed(limit int, now time.Time) []Summary {
+	var summaries []Summary
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for _, b := range a.buckets {
+		if b.stored || b.start.Add(bucketSize).After(now) {
+			continue
+		}
+		b.stored = true
+
+		seconds := bucketSize.Seconds()
+		for iface, stats := range b.interfaces {
+			summaries = append(summaries, Summary{
+				Interface:        iface,
+				Window:           bucketSize.String(),
+				From:             b.start,
+				To:               b.start.Add(bucketSize),
+				Bytes:            stats.total.Bytes,
+				Packets:          stats.total.Packets,
+				BitsPerSec:       float64(stats.total.Bytes) * 8 / seconds,
+				TopTalkers:       topEntries(stats.keys[DimensionTalker], stats.total.Bytes, seconds, limit),
+				TopProtocols:     topEntries(stats.keys[DimensionProtocol], stats.total.Bytes, seconds, limit),
+				TopConversations: topEntries(stats.keys[DimensionConversation], stats.total.Bytes, seconds, limit),
+			})
+		}
+	}
+	return summaries
+}
+
+func topEntries(counters map[string]*counter, total uint64, seconds float64, limit int) []Entry {
+	entries := make([]Entry, 0, len(counters))
+	for key, c := range counters {
+		entry := Entry{Key: key, Bytes: c.Bytes, Packets: c.Packets, BitsPerSec: float64(c.Bytes) * 8 / seconds}
+		if total > 0 {
+			entry.Percent = float64(c.Bytes) / float64(total) * 100
+		}
+		entries = append(entries, entry)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Bytes != entries[j].Bytes {
+			return entries[i].Bytes > entries[j].Bytes
+		}
+		return entries[i].Key < entries[j].Key
+	})
+	if limit > 0 && len(entries) > limit {
+		entries = entries[:limit]
+	}
+	return entries
+}
+
+func protocolName(protocol uint8) string {
+	if name, ok := protocolNames[protocol]; ok {
+		return name
+	}
+	return strconv.Itoa(int(protocol))
+}
+
+// protocolKey names the service of a flow by the lower of its ports, the client side usually picks an
+// ephemeral port above the service's
+func protocolKey(flow Flow) string {
+	name := protocolName(flow.Protocol)
+	if flow.Protocol != 6 && flow.Protocol != 17 && flow.Protocol != 132 {
+		return name
+	}
+	port := flow.DstPort
+	if flow.SrcPort != 0 && (port == 0 || flow.SrcPort < port) {
+		port = flow.SrcPort
+	}
+	return name + "/" + strconv.Itoa(int(port))
+}
+
+// conversationKey orders the addresses, so both directions of a conversation add up
+func conversationKey(flow Flow) string {
+	a, b := flow.SrcAddr.String(), flow.DstAddr.String()
+	if b < a {
+		a, b = b, a
+	}
+	return a + " <-> " + b
+}
@@ -0,0 +1,322 @@
+package flows
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ZEGIFTED/MS.GoMonitor/internal/repository"
+	"github.com/ZEGIFTED/MS.GoMonitor/monitors"
+	mstypes "github.com/ZEGIFTED/MS.GoMonitor/types"
+)
+
+const (
+	// Retention is the longest window summaries can be asked for
+	Retention = time.Hour
+
+	// AlertWindow is the window of the top talkers attached to interface utilization alerts
+	AlertWindow = 5 * time.Minute
+
+	defaultWindow = 5 * time.Minute
+	defaultLimit  = 10
+	storedLimit   = 20 // Entries per dimension stored for every minute
+	maxPacketSize = 65535
+)
+
+// active is the running collector, utilization alerts look up their top talkers in it
+var active atomic.Pointer[Collector]
+
+// Collector receives NetFlow v5/v9 and IPFIX exports from monitored devices and keeps rolling per
+// interface aggregates. Completed minutes are stored.
+type Collector struct {
+	engine    *monitors.MonitoringEngine
+	Addresses []string
+
+	decoder    *Decoder
+	aggregator *Aggregator
+
+	mu        sync.RWMutex
+	exporters map[string]monitors.ServiceMonitorData // IP address to service
+	services  map[string]monitors.ServiceMonitorData // SystemMonitorId to service
+
+	conns []net.PacketConn
+	wg    sync.WaitGroup
+	done  chan struct{}
+
+	received, dropped, unmonitored atomic.Uint64
+}
+
+func New(engine *monitors.MonitoringEngine, addresses []string) *Collector {
+	return &Collector{
+		engine:     engine,
+		Addresses:  addresses,
+		decoder:    NewDecoder(),
+		aggregator: NewAggregator(Retention),
+		exporters:  make(map[string]monitors.ServiceMonitorData),
+		services:   make(map[string]monitors.ServiceMonitorData),
+	}
+}
+
+// UpdateExporters rebuilds the exporter index from the service inventory. Services are matched by their
+// Host and the optional "flowSources" list, for devices exporting from another address.
+func (c *Collector) UpdateExporters(services []monitors.ServiceMonitorData) {
+	byID := make(map[string]monitors.ServiceMonitorData, len(services))
+	for _, service := range services {
+		byID[service.SystemMonitorId.String()] = service
+	}
+	exporters := monitors.SourceAddresses(services, "flowSources")
+
+	c.mu.Lock()
+	c.exporters, c.services = exporters, byID
+	c.mu.Unlock()
+}
+
+// Start listens on every address until Stop is called
+func (c *Collector) Start() error {
+	c.engine.MU.RLock()
+	services := append([]monitors.ServiceMonitorData(nil), c.engine.Services...)
+	c.engine.MU.RUnlock()
+	c.UpdateExporters(services)
+
+	c.done = make(chan struct{})
+	for _, address := range c.Addresses {
+		conn, err := net.ListenPacket("udp", address)
+		if err != nil {
+			c.Stop()
+			return fmt.Errorf("flow listener error: %v", err)
+		}
+		c.conns = append(c.conns, conn)
+		c.wg.Add(1)
+		go c.serve(conn)
+	}
+
+	c.wg.Add(1)
+	go c.store()
+
+	active.Store(c)
+	slog.Info("Starting flow collector", "addresses", strings.Join(c.Addresses, ","))
+	return nil
+}
+
+func (c *Collector) Stop() {
+	active.CompareAndSwap(c, nil)
+	for _, conn := range c.conns {
+		conn.Close()
+	}
+	if c.done != nil {
+		close(c.done)
+	}
+	c.wg.Wait()
+	c.conns, c.done = nil, nil
+}
+
+func (c *Collector) serve(conn net.PacketConn) {
+	defer c.wg.Done()
+
+	buffer := make([]byte, maxPacketSize)
+	for {
+		n, addr, err := conn.ReadFrom(buffer)
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				slog.Error("Flow listener stopped", "error", err)
+			}
+			return
+		}
+
+		source := addr.String()
+		if host, _, err := net.SplitHostPort(source); err == nil {
+			source = host
+		}
+
+		// Anyone can send to the collector, only monitored devices get templates and aggregates
+		exporter, monitored := c.exporterKey(source)
+		if !monitored {
+			c.unmonitored.Add(1)
+			continue
+		}
+
+		now := time.Now()
+		flows, err := c.decoder.Decode(buffer[:n], source, now)
+		if err != nil {
+			c.dropped.Add(1)
+			slog.Debug("Invalid flow export", "exporter", source, "error", err)
+		}
+
+		for _, flow := range flows {
+			c.aggregator.Add(exporter, flow, now)
+		}
+		c.received.Add(uint64(len(flows)))
+	}
+}
+
+// exporterKey is the SystemMonitorId of a monitored exporter
+func (c *Collector) exporterKey(address string) (string, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if service, ok := c.exporters[address]; ok {
+		return service.SystemMonitorId.String(), true
+	}
+	return address, false
+}
+
+// store writes every completed minute, the top entries of each dimension and the interface total
+func (c *Collector) store() {
+	defer c.wg.Done()
+
+	ticker := time.NewTicker(bucketSize)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+		}
+
+		now := time.Now()
+		c.decoder.Expire(now)
+
+		summaries := c.aggregator.Completed(storedLimit, now)
+		slog.Info("Flow aggregates completed", "interfaces", len(summaries), "flows", c.received.Swap(0),
+			"invalid_packets", c.dropped.Swap(0), "unmonitored_packets", c.unmonitored.Swap(0))
+		if c.engine.Db == nil || len(summaries) == 0 {
+			continue
+		}
+
+		if err := repository.SyncFlowAggregates(c.engine.Db, c.aggregates(summaries)); err != nil {
+			log.Printf("Error saving flow aggregates: %v", err)
+		}
+	}
+}
+
+func (c *Collector) aggregates(summaries []Summary) []mstypes.FlowAggregate {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var aggregates []mstypes.FlowAggregate
+	for _, summary := range summaries {
+		base := mstypes.FlowAggregate{
+			Exporter:    summary.Exporter,
+			IfIndex:     int64(summary.IfIndex),
+			Direction:   summary.Direction,
+			WindowStart: summary.From,
+			WindowEnd:   summary.To,
+		}
+		if service, ok := c.services[summary.Exporter]; ok {
+			base.SystemMonitorId, base.Exporter = summary.Exporter, service.Host
+		}
+
+		total := base
+		total.Dimension, total.Bytes, total.Packets = "total", int64(summary.Bytes), int64(summary.Packets)
+		aggregates = append(aggregates, total)
+
+		for dimension, entries := range map[string][]Entry{
+			DimensionTalker:       summary.TopTalkers,
+			DimensionProtocol:     summary.TopProtocols,
+			DimensionConversation: summary.TopConversations,
+		} {
+			for _, entry := range entries {
+				aggregate := base
+				aggregate.Dimension, aggregate.Key = dimension, entry.Key
+				aggregate.Bytes, aggregate.Packets = int64(entry.Bytes), int64(entry.Packets)
+				aggregates = append(aggregates, aggregate)
+			}
+		}
+	}
+	return aggregates
+}
+
+// Summary returns the traffic of an interface of a SystemMonitorId or exporter address over window
+func (c *Collector) Summary(exporter string, ifIndex uint32, direction string, window time.Duration, limit int) (Summary, bool) {
+	if net.ParseIP(exporter) != nil {
+		exporter, _ = c.exporterKey(exporter)
+	}
+	return c.aggregator.Summary(Interface{Exporter: exporter, IfIndex: ifIndex, Direction: direction}, window, limit, time.Now())
+}
+
+// InterfaceSummary is the traffic through an interface of a monitored device over window, as seen by
+// the running collector. ifIndex is the SNMP interface index, direction in or out.
+func InterfaceSummary(systemMonitorId, ifIndex, direction string, window time.Duration, limit int) (Summary, bool) {
+	c := active.Load()
+	if c == nil {
+		return Summary{}, false
+	}
+	index, err := strconv.ParseUint(ifIndex, 10, 32)
+	if err != nil {
+		return Summary{}, false
+	}
+	return c.Summary(systemMonitorId, uint32(index), direction, window, limit)
+}
+
+// ServeHTTP handles GET /api/v1/flows/ with every interface seen in the window and
+// GET /api/v1/flows/{systemMonitorId or exporter address}?interface=&direction=&window=&limit= with the
+// top talkers, protocols and conversations. Without an interface the whole exporter is summarised.
+func (c *Collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	window := defaultWindow
+	if v := query.Get("window"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 || d > Retention {
+			http.Error(w, fmt.Sprintf("window must be a duration up to %s", Retention), http.StatusBadRequest)
+			return
+		}
+		window = d
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	exporter := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/v1/flows"), "/")
+	if exporter == "" {
+		_ = json.NewEncoder(w).Encode(c.aggregator.Interfaces(window, time.Now()))
+		return
+	}
+
+	limit := defaultLimit
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	var ifIndex uint64
+	direction := ""
+	if v := query.Get("interface"); v != "" {
+		var err error
+		if ifIndex, err = strconv.ParseUint(v, 10, 32); err != nil {
+			http.Error(w, "invalid interface", http.StatusBadRequest)
+			return
+		}
+		direction = query.Get("direction")
+		if direction == "" {
+			direction = "in"
+		}
+		if direction != "in" && direction != "out" {
+			http.Error(w, "direction must be in or out", http.StatusBadRequest)
+			return
+		}
+	}
+
+	summary, ok := c.Summary(exporter, uint32(ifIndex), direction, window, limit)
+	if !ok {
+		http.Error(w, "no flows in window", http.StatusNotFound)
+		return
+	}
+	_ = json.NewEncoder(w).Encode(summary)
+}
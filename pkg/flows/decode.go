@@ -0,0 +1,534 @@
+package flows
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// NetFlow v9 and IPFIX information elements read from data records, IPFIX shares the v9 numbering for
+// the elements below 128
+const (
+	fieldOctetDelta         = 1
+	fieldPacketDelta        = 2
+	fieldProtocol           = 4
+	fieldSrcPort            = 7
+	fieldSrcIPv4            = 8
+	fieldInputInterface     = 10
+	fieldDstPort            = 11
+	fieldDstIPv4            = 12
+	fieldOutputInterface    = 14
+	fieldLastSwitched       = 21
+	fieldFirstSwitched      = 22
+	fieldPostOctetDelta     = 23
+	fieldPostPacketDelta    = 24
+	fieldSrcIPv6            = 27
+	fieldDstIPv6            = 28
+	fieldSamplingInterval   = 34
+	fieldFlowStartSeconds   = 150
+	fieldFlowEndSeconds     = 151
+	fieldFlowStartMillis    = 152
+	fieldFlowEndMillis      = 153
+	fieldSamplingPacketRate = 305
+
+	ipfixVariableLength = 65535
+	ipfixEnterpriseBit  = 0x8000
+)
+
+const (
+	netflowV5RecordSize = 48
+	netflowV5HeaderSize = 24
+	netflowV9HeaderSize = 20
+	ipfixHeaderSize     = 16
+
+	// Templates and sampling rates not refreshed for this long are forgotten, exporters resend them
+	// periodically
+	templateLifetime = 30 * time.Minute
+
+	// maxTemplatesPerExporter bounds the templates and sampling rates of one exporter, the least recently
+	// refreshed one is dropped for a new one
+	maxTemplatesPerExporter = 512
+)
+
+// Flow is one flow record, byte and packet counts are already multiplied by the sampling rate
+type Flow struct {
+	Exporter string
+	SrcAddr  net.IP
+	DstAddr  net.IP
+	SrcPort  uint16
+	DstPort  uint16
+	Protocol uint8
+	InputIf  uint32
+	OutputIf uint32
+	Bytes    uint64
+	Packets  uint64
+	Start    time.Time
+	End      time.Time
+}
+
+type templateField struct {
+	id       uint16
+	length   uint16
+	external bool // Option scope or enterprise specific element, not read
+}
+
+type template struct {
+	fields  []templateField
+	options bool
+	updated time.Time
+}
+
+type samplingRate struct {
+	rate    uint64
+	updated time.Time
+}
+
+// exporterState is what an exporter announced, keyed by observation domain and template ID
+type exporterState struct {
+	templates map[string]*template
+	sampling  map[string]samplingRate // Sampling rate announced in option records, per domain
+}
+
+// Decoder turns NetFlow v5, v9 and IPFIX packets into flows. v9 and IPFIX data records can only be
+// decoded after their template was received, templates are kept per exporter and observation domain.
+type Decoder struct {
+	mu        sync.Mutex
+	exporters map[string]*exporterState
+}
+
+func NewDecoder() *Decoder {
+	return &Decoder{exporters: make(map[string]*exporterState)}
+}
+
+// Expire forgets the templates and sampling rates exporters stopped refreshing, e.g. after a
+// reconfiguration. It is called periodically by the collector.
+func (d *Decoder) Expire(now time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for exporter, state := range d.exporters {
+		for key, t := range state.templates {
+			if now.Sub(t.updated) > templateLifetime {
+				delete(state.templates, key)
+			}
+		}
+		for domain, sampling := range state.sampling {
+			if now.Sub(sampling.updated) > templateLifetime {
+				delete(state.sampling, domain)
+			}
+		}
+		if len(state.templates) == 0 && len(state.sampling) == 0 {
+			delete(d.exporters, exporter)
+		}
+	}
+}
+
+// state returns the state of exporter, d.mu must be held
+func (d *Decoder) state(exporter string) *exporterState {
+	state, ok := d.exporters[exporter]
+	if !ok {
+		state = &exporterState{templates: make(map[string]*template), sampling: make(map[string]samplingRate)}
+		d.exporters[exporter] = state
+	}
+	return state
+}
+
+// Decode parses one export packet received from exporter
+func (d *Decoder) Decode(packet []byte, exporter string, received time.Time) ([]Flow, error) {
+	if len(packet) < 2 {
+		return nil, fmt.Errorf("packet too short")
+	}
+
+	switch version := binary.BigEndian.Uint16(packet); version {
+	case 5:
+		return decodeV5(packet, exporter)
+	case 9:
+		return d.decodeV9(packet, exporter, received)
+	case 10:
+		return d.decodeIPFIX(packet, exporter, received)
+	default:
+		return nil, fmt.Errorf("unsupported export version %d", version)
+	}
+}
+
+func decodeV5(packet []byte, exporter string) ([]Flow, error) {
+	if len(packet) < netflowV5HeaderSize {
+		return nil, fmt.Errorf("truncated NetFlow v5 header")
+	}
+
+	count := int(binary.BigEndian.Uint16(packet[2:]))
+	uptime := binary.BigEndian.Uint32(packet[4:])
+	exported := time.Unix(int64(binary.BigEndian.Uint32(packet[8:])), int64(binary.BigEndian.Uint32(packet[12:])))
+	// The two top bits select the sampling mode, the rest is the interval
+	sampling := uint64(binary.BigEndian.Uint16(packet[22:]) & 0x3fff)
+	if sampling == 0 {
+		sampling = 1
+	}
+
+	if len(packet) < netflowV5HeaderSize+count*netflowV5RecordSize {
+		return nil, fmt.Errorf("truncated NetFlow v5 packet: %d records announced", count)
+	}
+
+	flows := make([]Flow, 0, count)
+	for i := 0; i < count; i++ {
+		record := packet[netflowV5HeaderSize+i*netflowV5RecordSize:]
+		flows = append(flows, Flow{
+			Exporter: exporter,
+			SrcAddr:  net.IP(append([]byte(nil), record[0:4]...)),
+			DstAddr:  net.IP(append([]byte(nil), record[4:8]...)),
+			InputIf:  uint32(binary.BigEndian.Uint16(record[12:])),
+			OutputIf: uint32(binary.BigEndian.Uint16(record[14:])),
+			Packets:  uint64(binary.BigEndian.Uint32(record[16:])) * sampling,
+			Bytes:    uint64(binary.BigEndian.Uint32(record[20:])) * sampling,
+			Start:    uptimeTime(exported, uptime, binary.BigEndian.Uint32(record[24:])),
+			End:      uptimeTime(exported, uptime, binary.BigEndian.Uint32(record[28:])),
+			SrcPort:  binary.BigEndian.Uint16(record[32:]),
+			DstPort:  binary.BigEndian.Uint16(record[34:]),
+			Protocol: record[38],
+		})
+	}
+	return flows, nil
+}
+
+// uptimeTime converts a sysUptime in milliseconds to wall clock time using the export header
+func uptimeTime(exported time.Time, uptime, at uint32) time.Time {
+	return exported.Add(-time.Duration(uptime-at) * time.Millisecond)
+}
+
+func (d *Decoder) decodeV9(packet []byte, exporter string, received time.Time) ([]Flow, error) {
+	if len(packet) < netflowV9HeaderSize {
+		return nil, fmt.Errorf("truncated NetFlow v9 header")
+	}
+
+	header := recordHeader{
+		uptime:   binary.BigEndian.Uint32(packet[4:]),
+		exported: time.Unix(int64(binary.BigEndian.Uint32(packet[8:])), 0),
+		domain:   fmt.Sprintf("9|%d", binary.BigEndian.Uint32(packet[16:])),
+		exporter: exporter,
+		received: received,
+	}
+
+	var flows []Flow
+	for rest := packet[netflowV9HeaderSize:]; len(rest) >= 4; {
+		id, length := binary.BigEndian.Uint16(rest), int(binary.BigEndian.Uint16(rest[2:]))
+		if length < 4 || length > len(rest) {
+			return flows, fmt.Errorf("invalid flowset length %d", length)
+		}
+		body := rest[4:length]
+		rest = rest[length:]
+
+		switch {
+		case id == 0:
+			d.v9Templates(body, header)
+		case id == 1:
+			d.v9OptionTemplates(body, header)
+		case id >= 256:
+			flows = append(flows, d.dataRecords(body, id, header)...)
+		}
+	}
+	return flows, nil
+}
+
+func (d *Decoder) v9Templates(body []byte, header recordHeader) {
+	for len(body) >= 4 {
+		id, count := binary.BigEndian.Uint16(body), int(binary.BigEndian.Uint16(body[2:]))
+		body = body[4:]
+		// Template IDs start at 256, anything lower is padding
+		if id < 256 || len(body) < count*4 {
+			return
+		}
+
+		fields := make([]templateField, count)
+		for i := range fields {
+			fields[i] = templateField{id: binary.BigEndian.Uint16(body[i*4:]), length: binary.BigEndian.Uint16(body[i*4+2:])}
+		}
+		body = body[count*4:]
+		d.storeTemplate(header, id, &template{fields: fields, updated: header.received})
+	}
+}
+
+func (d *Decoder) v9OptionTemplates(body []byte, header recordHeader) {
+	for len(body) >= 6 {
+		id := binary.BigEndian.Uint16(body)
+		scopeLength, optionLength := int(binary.BigEndian.Uint16(body[2:])), int(binary.BigEndian.Uint16(body[4:]))
+		body = body[6:]
+		if len(body) < scopeLength+optionLength {
+			return
+		}
+
+		var fields []templateField
+		for i := 0; i+4 <= scopeLength+optionLength; i += 4 {
+			field := templateField{id: binary.BigEndian.Uint16(body[i:]), length: binary.BigEndian.Uint16(body[i+2:])}
+			// Scope field types have their own numbering, none of them is read
+			field.external = i < scopeLength
+			fields = append(fields, field)
+		}
+		body = body[scopeLength+optionLength:]
+		d.storeTemplate(header, id, &template{fields: fields, options: true, updated: header.received})
+	}
+}
+
+func (d *Decoder) decodeIPFIX(packet []byte, exporter string, received time.Time) ([]Flow, error) {
+	if len(packet) < ipfixHeaderSize {
+		return nil, fmt.Errorf("truncated IPFIX header")
+	}
+	if length := int(binary.BigEndian.Uint16(packet[2:])); length < len(packet) {
+		packet = packet[:length]
+	}
+
+	header := recordHeader{
+		exported: time.Unix(int64(binary.BigEndian.Uint32(packet[4:])), 0),
+		domain:   fmt.Sprintf("10|%d", binary.BigEndian.Uint32(packet[12:])),
+		exporter: exporter,
+		received: received,
+	}
+
+	var flows []Flow
+	for rest := packet[ipfixHeaderSize:]; len(rest) >= 4; {
+		id, length := binary.BigEndian.Uint16(rest), int(binary.BigEndian.Uint16(rest[2:]))
+		if length < 4 || length > len(rest) {
+			return flows, fmt.Errorf("invalid set length %d", length)
+		}
+		body := rest[4:length]
+		rest = rest[length:]
+
+		switch {
+		case id == 2:
+			d.ipfixTemplates(body, header, false)
+		case id == 3:
+			d.ipfixTemplates(body, header, true)
+		case id >= 256:
+			flows = append(flows, d.dataRecords(body, id, header)...)
+		}
+	}
+	return flows, nil
+}
+
+func (d *Decoder) ipfixTemplates(body []byte, header recordHeader, options bool) {
+	for len(body) >= 4 {
+		id, count := binary.BigEndian.Uint16(body), int(binary.BigEndian.Uint16(body[2:]))
+		body = body[4:]
+
+		// A template with no fields withdraws it (RFC 7011 section 8.1)
+		if count == 0 {
+			d.mu.Lock()
+			delete(d.state(header.exporter).templates, fmt.Sprintf("%s|%d", header.domain, id))
+			d.mu.Unlock()
+			continue
+		}
+
+		scopeCount := 0
+		if options {
+			if len(body) < 2 {
+				return
+			}
+			scopeCount = int(binary.BigEndian.Uint16(body))
+			body = body[2:]
+		}
+
+		fields := make([]templateField, 0, count)
+		for i := 0; i < count; i++ {
+			if len(body) < 4 {
+				return
+			}
+			field := templateField{id: binary.BigEndian.Uint16(body), length: binary.BigEndian.Uint16(body[2:])}
+			body = body[4:]
+			if field.id&ipfixEnterpriseBit != 0 {
+				if len(body) < 4 {
+					return
+				}
+				field.id &^= ipfixEnterpriseBit
+				field.external = true
+				body = body[4:]
+			}
+			if i < scopeCount {
+				field.external = true
+			}
+			fields = append(fields, field)
+		}
+		d.storeTemplate(header, id, &template{fields: fields, options: options, updated: header.received})
+	}
+}
+
+func (d *Decoder) storeTemplate(header recordHeader, id uint16, t *template) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	state := d.state(header.exporter)
+	key := fmt.Sprintf("%s|%d", header.domain, id)
+	if _, ok := state.templates[key]; !ok && len(state.templates) >= maxTemplatesPerExporter {
+		oldest := ""
+		for existing, existingTemplate := range state.templates {
+			if oldest == "" || existingTemplate.updated.Before(state.templates[oldest].updated) {
+				oldest = existing
+			}
+		}
+		delete(state.templates, oldest)
+	}
+	state.templates[key] = t
+}
+
+func (d *Decoder) storeSampling(header recordHeader, rate uint64) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	state := d.state(header.exporter)
+	if _, ok := state.sampling[header.domain]; !ok && len(state.sampling) >= maxTemplatesPerExporter {
+		oldest := ""
+		for domain, sampling := range state.sampling {
+			if oldest == "" || sampling.updated.Before(state.sampling[oldest].updated) {
+				oldest = domain
+			}
+		}
+		delete(state.sampling, oldest)
+	}
+	state.sampling[header.domain] = samplingRate{rate: rate, updated: header.received}
+}
+
+type recordHeader struct {
+	exporter string
+	domain   string // Version and observation domain
+	uptime   uint32
+	exported time.Time
+	received time.Time
+}
+
+// dataRecords decodes the records of a data set with the template it references. Option records only
+// update the sampling rate of the exporter.
+func (d *Decoder) dataRecords(body []byte, id uint16, header recordHeader) []Flow {
+	d.mu.Lock()
+	var t *template
+	var sampling uint64
+	state, ok := d.exporters[header.exporter]
+	if ok {
+		t, ok = state.templates[fmt.Sprintf("%s|%d", header.domain, id)]
+		sampling = state.sampling[header.domain].rate
+	}
+	d.mu.Unlock()
+	if !ok {
+		return nil
+	}
+
+	var flows []Flow
+	for len(body) > 0 {
+		values, consumed, ok := readRecord(body, t.fields)
+		if !ok {
+			break // Padding or a truncated record
+		}
+		body = body[consumed:]
+
+		if t.options {
+			rate := values[fieldSamplingPacketRate].uint()
+			if rate == 0 {
+				rate = values[fieldSamplingInterval].uint()
+			}
+			if rate > 0 {
+				d.storeSampling(header, rate)
+				sampling = rate
+			}
+			continue
+		}
+
+		rate := values[fieldSamplingInterval].uint()
+		if rate == 0 {
+			rate = sampling
+		}
+		if rate == 0 {
+			rate = 1
+		}
+		flows = append(flows, header.flow(values, rate))
+	}
+	return flows
+}
+
+type fieldValue []byte
+
+// uint reads an unsigned integer of any length up to 8 bytes, exporters may use reduced-size encoding
+func (v fieldValue) uint() uint64 {
+	var n uint64
+	for _, b := range v {
+		n = n<<8 | uint64(b)
+	}
+	return n
+}
+
+func (v fieldValue) ip() net.IP {
+	if len(v) != net.IPv4len && len(v) != net.IPv6len {
+		return nil
+	}
+	return net.IP(append([]byte(nil), v...))
+}
+
+func readRecord(body []byte, fields []templateField) (map[uint16]fieldValue, int, bool) {
+	values := make(map[uint16]fieldValue, len(fields))
+	offset := 0
+	for _, field := range fields {
+		length := int(field.length)
+		if field.length == ipfixVariableLength {
+			if offset >= len(body) {
+				return nil, 0, false
+			}
+			length = int(body[offset])
+			offset++
+			if length == 255 {
+				if offset+2 > len(body) {
+					return nil, 0, false
+				}
+				length = int(binary.BigEndian.Uint16(body[offset:]))
+				offset += 2
+			}
+		}
+		if length == 0 && field.length != ipfixVariableLength {
+			return nil, 0, false
+		}
+		if offset+length > len(body) {
+			return nil, 0, false
+		}
+		if !field.external {
+			values[field.id] = body[offset : offset+length]
+		}
+		offset += length
+	}
+	return values, offset, offset > 0
+}
+
+func (h recordHeader) flow(values map[uint16]fieldValue, sampling uint64) Flow {
+	flow := Flow{
+		Exporter: h.exporter,
+		SrcAddr:  values[fieldSrcIPv4].ip(),
+		DstAddr:  values[fieldDstIPv4].ip(),
+		SrcPort:  uint16(values[fieldSrcPort].uint()),
+		DstPort:  uint16(values[fieldDstPort].uint()),
+		Protocol: uint8(values[fieldProtocol].uint()),
+		InputIf:  uint32(values[fieldInputInterface].uint()),
+		OutputIf: uint32(values[fieldOutputInterface].uint()),
+		Bytes:    values[fieldOctetDelta].uint(),
+		Packets:  values[fieldPacketDelta].uint(),
+	}
+	if flow.SrcAddr == nil {
+		flow.SrcAddr, flow.DstAddr = values[fieldSrcIPv6].ip(), values[fieldDstIPv6].ip()
+	}
+	// Egress-only exporters report the counters after the output features
+	if flow.Bytes == 0 {
+		flow.Bytes, flow.Packets = values[fieldPostOctetDelta].uint(), values[fieldPostPacketDelta].uint()
+	}
+	flow.Bytes *= sampling
+	flow.Packets *= sampling
+
+	switch {
+	case values[fieldFlowStartMillis] != nil:
+		flow.Start = time.UnixMilli(int64(values[fieldFlowStartMillis].uint()))
+		flow.End = time.UnixMilli(int64(values[fieldFlowEndMillis].uint()))
+	case values[fieldFlowStartSeconds] != nil:
+		flow.Start = time.Unix(int64(values[fieldFlowStartSeconds].uint()), 0)
+		flow.End = time.Unix(int64(values[fieldFlowEndSeconds].uint()), 0)
+	case values[fieldFirstSwitched] != nil && h.uptime > 0:
+		flow.Start = uptimeTime(h.exported, h.uptime, uint32(values[fieldFirstSwitched].uint()))
+		flow.End = uptimeTime(h.exported, h.uptime, uint32(values[fieldLastSwitched].uint()))
+	default:
+		flow.Start, flow.End = h.exported, h.exported
+	}
+	return flow
+}
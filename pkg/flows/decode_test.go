@@ -0,0 +1,261 @@
+package flows
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// exportSeconds is 2024-05-01T12:00:00Z, the export time of every test packet
+const exportSeconds = 1714564800
+
+func be16(v uint16) []byte { return binary.BigEndian.AppendUint16(nil, v) }
+func be32(v uint32) []byte { return binary.BigEndian.AppendUint32(nil, v) }
+func be64(v uint64) []byte { return binary.BigEndian.AppendUint64(nil, v) }
+func ip4(s string) []byte  { return net.ParseIP(s).To4() }
+func ip6(s string) []byte  { return net.ParseIP(s).To16() }
+
+func cat(parts ...[]byte) []byte {
+	var b []byte
+	for _, p := range parts {
+		b = append(b, p...)
+	}
+	return b
+}
+
+// field is a template field specifier
+func field(id, length uint16) []byte { return cat(be16(id), be16(length)) }
+
+// set is a v9 flowset or IPFIX set with its header
+func set(id uint16, parts ...[]byte) []byte {
+	body := cat(parts...)
+	return cat(be16(id), be16(uint16(4+len(body))), body)
+}
+
+func v5Packet(uptime uint32, sampling uint16, records ...[]byte) []byte {
+	return cat(be16(5), be16(uint16(len(records))), be32(uptime), be32(exportSeconds), be32(0), be32(1),
+		[]byte{0, 0}, be16(sampling), cat(records...))
+}
+
+func v5Record(src, dst string, sport, dport uint16, protocol uint8, in, out uint16, packets, octets, first, last uint32) []byte {
+	return cat(ip4(src), ip4(dst), make([]byte, 4), be16(in), be16(out), be32(packets), be32(octets), be32(first), be32(last),
+		be16(sport), be16(dport), []byte{0, 0, protocol, 0}, make([]byte, 8))
+}
+
+func v9Packet(uptime, domain uint32, sets ...[]byte) []byte {
+	return cat(be16(9), be16(uint16(len(sets))), be32(uptime), be32(exportSeconds), be32(1), be32(domain), cat(sets...))
+}
+
+func ipfixPacket(domain uint32, sets ...[]byte) []byte {
+	body := cat(sets...)
+	return cat(be16(10), be16(uint16(ipfixHeaderSize+len(body))), be32(exportSeconds), be32(1), be32(domain), body)
+}
+
+// describe renders the decoded fields of a flow for comparison
+func describe(f Flow) string {
+	return fmt.Sprintf("%s:%d>%s:%d/%d if%d>%d %dB %dp %s..%s", f.SrcAddr, f.SrcPort, f.DstAddr, f.DstPort, f.Protocol,
+		f.InputIf, f.OutputIf, f.Bytes, f.Packets, f.Start.UTC().Format(time.RFC3339Nano), f.End.UTC().Format(time.RFC3339Nano))
+}
+
+var (
+	v9Template = set(0, be16(256), be16(9),
+		field(fieldSrcIPv4, 4), field(fieldDstIPv4, 4), field(fieldSrcPort, 2), field(fieldDstPort, 2),
+		field(fieldProtocol, 1), field(fieldOctetDelta, 4), field(fieldPacketDelta, 4),
+		field(fieldFirstSwitched, 4), field(fieldLastSwitched, 4))
+	// 29 byte record and 3 bytes of padding
+	v9Data = set(256, ip4("10.0.0.1"), ip4("10.0.0.2"), be16(51000), be16(443), []byte{6}, be32(1500), be32(3),
+		be32(90000), be32(99000), []byte{0, 0, 0})
+	// System scope, samplingInterval and samplingAlgorithm
+	v9OptionTemplate = set(1, be16(257), be16(4), be16(8),
+		field(1, 4), field(fieldSamplingInterval, 4), field(35, 1), []byte{0, 0})
+	v9OptionData = set(257, be32(0), be32(100), []byte{2}, []byte{0, 0, 0})
+
+	ipfixTemplate = set(2, be16(300), be16(11),
+		field(fieldSrcIPv4, 4), field(fieldDstIPv4, 4), field(fieldSrcPort, 2), field(fieldDstPort, 2),
+		field(fieldProtocol, 1), field(fieldOctetDelta, 8), field(fieldPacketDelta, 8),
+		field(fieldFlowStartMillis, 8), field(fieldFlowEndMillis, 8),
+		field(ipfixEnterpriseBit|200, 4), be32(9), // Enterprise specific, skipped
+		field(82, ipfixVariableLength)) // interfaceName
+	ipfixData = set(300, ip4("192.0.2.10"), ip4("198.51.100.7"), be16(53), be16(40000), []byte{17},
+		be64(900), be64(6), be64(exportSeconds*1000-9750), be64(exportSeconds*1000-500),
+		be32(0xdeadbeef), []byte{4}, []byte("eth0"))
+	// observationDomainId scope and samplingPacketInterval
+	ipfixOptionTemplate = set(3, be16(301), be16(2), be16(1), field(149, 4), field(fieldSamplingPacketRate, 4))
+	ipfixOptionData     = set(301, be32(1), be32(10))
+	ipfixV6Template     = set(2, be16(302), be16(5),
+		field(fieldSrcIPv6, 16), field(fieldDstIPv6, 16), field(fieldProtocol, 1),
+		field(fieldOctetDelta, 4), field(fieldPacketDelta, 4))
+	ipfixV6Data = set(302, ip6("2001:db8::1"), ip6("2001:db8::2"), []byte{58}, be32(128), be32(2))
+)
+
+func TestDecoderDecode(t *testing.T) {
+	type step struct {
+		packet  []byte
+		want    []string
+		wantErr string
+	}
+
+	v9Flow := "10.0.0.1:51000>10.0.0.2:443/6 if0>0 1500B 3p 2024-05-01T11:59:50Z..2024-05-01T11:59:59Z"
+	ipfixFlow := "192.0.2.10:53>198.51.100.7:40000/17 if0>0 900B 6p 2024-05-01T11:59:50.25Z..2024-05-01T11:59:59.5Z"
+
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{
+			name: "v5 records with uptime based times",
+			steps: []step{{packet: v5Packet(100000, 0,
+				v5Record("10.1.1.1", "10.2.2.2", 1234, 80, 6, 3, 7, 10, 5000, 90000, 99000),
+				v5Record("10.1.1.2", "10.2.2.3", 5353, 53, 17, 3, 8, 1, 60, 99500, 99500)),
+				want: []string{
+					"10.1.1.1:1234>10.2.2.2:80/6 if3>7 5000B 10p 2024-05-01T11:59:50Z..2024-05-01T11:59:59Z",
+					"10.1.1.2:5353>10.2.2.3:53/17 if3>8 60B 1p 2024-05-01T11:59:59.5Z..2024-05-01T11:59:59.5Z",
+				},
+			}},
+		},
+		{
+			name: "v5 sampling interval without the mode bits",
+			steps: []step{{packet: v5Packet(100000, 0x4000|10,
+				v5Record("10.1.1.1", "10.2.2.2", 1234, 80, 6, 3, 7, 10, 5000, 90000, 99000)),
+				want: []string{"10.1.1.1:1234>10.2.2.2:80/6 if3>7 50000B 100p 2024-05-01T11:59:50Z..2024-05-01T11:59:59Z"},
+			}},
+		},
+		{
+			name:  "v5 with fewer records than announced",
+			steps: []step{{packet: v5Packet(100000, 0, v5Record("10.1.1.1", "10.2.2.2", 1, 2, 6, 0, 0, 1, 1, 0, 0))[:netflowV5HeaderSize+20], wantErr: "truncated NetFlow v5 packet"}},
+		},
+		{
+			name:  "v9 template and data in one packet",
+			steps: []step{{packet: v9Packet(100000, 1, v9Template, v9Data), want: []string{v9Flow}}},
+		},
+		{
+			name: "v9 data before its template is dropped",
+			steps: []step{
+				{packet: v9Packet(100000, 1, v9Data)},
+				{packet: v9Packet(100000, 1, v9Template)},
+				{packet: v9Packet(100000, 1, v9Data), want: []string{v9Flow}},
+			},
+		},
+		{
+			name: "v9 templates are kept per observation domain",
+			steps: []step{
+				{packet: v9Packet(100000, 1, v9Template)},
+				{packet: v9Packet(100000, 2, v9Data)},
+			},
+		},
+		{
+			name: "v9 sampling rate from an option record",
+			steps: []step{
+				{packet: v9Packet(100000, 1, v9OptionTemplate, v9OptionData, v9Template)},
+				{packet: v9Packet(100000, 1, v9Data),
+					want: []string{"10.0.0.1:51000>10.0.0.2:443/6 if0>0 150000B 300p 2024-05-01T11:59:50Z..2024-05-01T11:59:59Z"}},
+			},
+		},
+		{
+			name:  "v9 flowset longer than the packet",
+			steps: []step{{packet: v9Packet(100000, 1, cat(be16(256), be16(100), make([]byte, 8))), wantErr: "invalid flowset length 100"}},
+		},
+		{
+			name:  "IPFIX with enterprise and variable length fields",
+			steps: []step{{packet: ipfixPacket(1, ipfixTemplate, ipfixData), want: []string{ipfixFlow}}},
+		},
+		{
+			name: "IPFIX sampling rate from an option record",
+			steps: []step{{packet: ipfixPacket(1, ipfixOptionTemplate, ipfixOptionData, ipfixTemplate, ipfixData),
+				want: []string{"192.0.2.10:53>198.51.100.7:40000/17 if0>0 9000B 60p 2024-05-01T11:59:50.25Z..2024-05-01T11:59:59.5Z"}}},
+		},
+		{
+			name: "IPFIX template withdrawal",
+			steps: []step{
+				{packet: ipfixPacket(1, ipfixTemplate)},
+				{packet: ipfixPacket(1, set(2, be16(300), be16(0)))},
+				{packet: ipfixPacket(1, ipfixData)},
+			},
+		},
+		{
+			name: "IPFIX IPv6 flow without timestamps uses the export time",
+			steps: []step{{packet: ipfixPacket(1, ipfixV6Template, ipfixV6Data),
+				want: []string{"2001:db8::1:0>2001:db8::2:0/58 if0>0 128B 2p 2024-05-01T12:00:00Z..2024-05-01T12:00:00Z"}}},
+		},
+		{
+			name:  "IPFIX set longer than the message",
+			steps: []step{{packet: ipfixPacket(1, cat(be16(300), be16(64), make([]byte, 4))), wantErr: "invalid set length 64"}},
+		},
+		{
+			name:  "unsupported version",
+			steps: []step{{packet: cat(be16(7), make([]byte, 22)), wantErr: "unsupported export version 7"}},
+		},
+		{
+			name:  "packet too short",
+			steps: []step{{packet: []byte{0}, wantErr: "packet too short"}},
+		},
+	}
+
+	received := time.Unix(exportSeconds, 0)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decoder := NewDecoder()
+			for i, s := range tt.steps {
+				flows, err := decoder.Decode(s.packet, "192.0.2.1", received)
+				if s.wantErr != "" {
+					if err == nil || !strings.Contains(err.Error(), s.wantErr) {
+						t.Fatalf("packet %d: err = %v, want %q", i, err, s.wantErr)
+					}
+					continue
+				}
+				if err != nil {
+					t.Fatalf("packet %d: %v", i, err)
+				}
+
+				var got []string
+				for _, f := range flows {
+					if f.Exporter != "192.0.2.1" {
+						t.Errorf("packet %d: exporter = %q", i, f.Exporter)
+					}
+					got = append(got, describe(f))
+				}
+				if strings.Join(got, "\n") != strings.Join(s.want, "\n") {
+					t.Errorf("packet %d flows:\n%s\nwant:\n%s", i, strings.Join(got, "\n"), strings.Join(s.want, "\n"))
+				}
+			}
+		})
+	}
+}
+
+func TestDecoderExpire(t *testing.T) {
+	t0 := time.Unix(exportSeconds, 0)
+
+	tests := []struct {
+		name      string
+		after     time.Duration
+		wantFlows int
+	}{
+		{"template still fresh", templateLifetime - time.Minute, 1},
+		{"template not refreshed", templateLifetime + time.Minute, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decoder := NewDecoder()
+			if _, err := decoder.Decode(v9Packet(100000, 1, v9Template), "192.0.2.1", t0); err != nil {
+				t.Fatalf("Decode: %v", err)
+			}
+
+			decoder.Expire(t0.Add(tt.after))
+
+			flows, err := decoder.Decode(v9Packet(100000, 1, v9Data), "192.0.2.1", t0.Add(tt.after))
+			if err != nil {
+				t.Fatalf("Decode: %v", err)
+			}
+			if len(flows) != tt.wantFlows {
+				t.Errorf("%d flows, want %d", len(flows), tt.wantFlows)
+			}
+			if tt.wantFlows == 0 && len(decoder.exporters) != 0 {
+				t.Errorf("exporter state kept after all templates expired")
+			}
+		})
+	}
+}
@@ -96,7 +96,16 @@ func (cfgManager *NotificationManager) FormatSlackMessageToSend(event internal.S
 	//blocks = append(blocks, dividerBlock, contextBlock)
 
 	// **Blocks Assembly**
-	blocks := []slack.Block{headerBlock, sectionBlock, buttons, slack.NewDividerBlock(), contextBlock}
+	blocks := []slack.Block{headerBlock, sectionBlock}
+
+	// Network path towards the failing service
+	if len(event.NetworkPath) > 0 {
+		pathText := fmt.Sprintf("*Network Path:*\n```%s```", strings.Join(event.NetworkPath, "\n"))
+		blocks = append(blocks, slack.NewSectionBlock(slack.NewTextBlockObject("mrkdwn", pathText, false, false), nil, nil))
+		fallbackText += "\nNetwork Path:\n" + strings.Join(event.NetworkPath, "\n")
+	}
+
+	blocks = append(blocks, buttons, slack.NewDividerBlock(), contextBlock)
 
 	alertColor, exists := SeverityColors[strings.ToLower(alertLevel)]
 	if !exists {
@@ -169,6 +178,7 @@ func (cfgManager *NotificationManager) FormatEmailMessageToSend(event internal.S
 		},
 
 		ProcessTableData: event.ServiceStats,
+		NetworkPath:      event.NetworkPath,
 		// Items: []string{"loop test 1", "loop test 2"},
 		// "AlertLevel":  WarningAlertLevel,
 		Content:     event.Message,
@@ -0,0 +1,347 @@
+package plugins
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ZEGIFTED/MS.GoMonitor/monitors"
+	"github.com/ZEGIFTED/MS.GoMonitor/pkg/constants"
+	"github.com/ZEGIFTED/MS.GoMonitor/pkg/utils"
+	_ "github.com/lib/pq"               // PostgreSQL driver
+	_ "github.com/microsoft/go-mssqldb" // SQL Server driver
+)
+
+// DatabaseDialect holds the driver and the catalog queries of one database engine.
+// Every optional query returns a single row.
+type DatabaseDialect struct {
+	Name   string
+	Driver string
+	DSN    func(service monitors.ServiceMonitorData) string
+
+	HealthQuery      string
+	ConnectionsQuery string // Active connections, maximum allowed connections
+	LongRunningQuery string // Count and longest runtime in seconds of queries running longer than $1 seconds
+	BlockingQuery    string // Sessions waiting on a lock held by another session
+	ReplicationQuery string // Replication lag in seconds, 0 on a primary
+}
+
+var PostgresDialect = DatabaseDialect{
+	Name:        "postgres_check",
+	Driver:      "postgres",
+	DSN:         postgresDSN,
+	HealthQuery: "SELECT 1",
+	ConnectionsQuery: `SELECT count(*), current_setting('max_connections')::int FROM pg_stat_activity
+		WHERE backend_type = 'client backend'`,
+	LongRunningQuery: `SELECT count(*), COALESCE(EXTRACT(EPOCH FROM max(now() - query_start)), 0)::float8
+		FROM pg_stat_activity
+		WHERE backend_type = 'client backend' AND state <> 'idle' AND pid <> pg_backend_pid()
+		AND now() - query_start > make_interval(secs => $1)`,
+	BlockingQuery: `SELECT count(*) FROM pg_stat_activity WHERE cardinality(pg_blocking_pids(pid)) > 0`,
+	// A standby that replayed everything it received is caught up, the last replayed transaction only
+	// ages because the primary is idle
+	ReplicationQuery: `SELECT CASE
+		WHEN NOT pg_is_in_recovery() OR pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+		ELSE COALESCE(EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()), 0)::float8 END`,
+}
+
+var SQLServerDialect = DatabaseDialect{
+	Name:        "mssql_check",
+	Driver:      "sqlserver",
+	DSN:         sqlServerDSN,
+	HealthQuery: "SELECT 1",
+	ConnectionsQuery: `SELECT COUNT(*), @@MAX_CONNECTIONS FROM sys.dm_exec_sessions
+		WHERE is_user_process = 1`,
+	LongRunningQuery: `SELECT COUNT(*), COALESCE(MAX(total_elapsed_time), 0) / 1000.0 FROM sys.dm_exec_requests
+		WHERE session_id <> @@SPID AND session_id > 50 AND total_elapsed_time > @p1 * 1000`,
+	BlockingQuery:    `SELECT COUNT(*) FROM sys.dm_exec_requests WHERE blocking_session_id <> 0`,
+	ReplicationQuery: `SELECT COALESCE(MAX(secondary_lag_seconds), 0) FROM sys.dm_hadr_database_replica_states`,
+}
+
+// DatabaseThresholds holds the limits applied to the collected database metrics
+type DatabaseThresholds struct {
+	ConnectLatencyWarn         time.Duration
+	QueryLatencyWarn           time.Duration
+	ConnectionUsageWarnPercent float64
+	ConnectionUsageCritPercent float64
+	LongRunningSeconds         float64
+	LongRunningWarnCount       int
+	BlockingWarnCount          int
+	ReplicationLagWarnSeconds  float64
+	ReplicationLagCritSeconds  float64
+}
+
+type DatabaseMonitorPlugin struct {
+	config  map[string]any
+	dialect DatabaseDialect
+}
+
+func NewPostgresMonitorPlugin() *DatabaseMonitorPlugin {
+	return &DatabaseMonitorPlugin{dialect: PostgresDialect}
+}
+
+func NewSQLServerMonitorPlugin() *DatabaseMonitorPlugin {
+	return &DatabaseMonitorPlugin{dialect: SQLServerDialect}
+}
+
+func (p *DatabaseMonitorPlugin) Initialize(config map[string]any) error {
+	p.config = config
+	return nil
+}
+
+// Check opens a dedicated connection to the monitored database (the db argument is the monitoring
+// store, not the target) so the connect latency is measured on every run.
+func (p *DatabaseMonitorPlugin) Check(ctx context.Context, _ *sql.DB, service monitors.ServiceMonitorData) (monitors.MonitoringResult, error) {
+	status := monitors.MonitoringResult{
+		SystemMonitorId: service.SystemMonitorId.String(),
+		ServicePluginID: p.Name(),
+		HealthReport:    constants.GetStatusInfo(constants.UnknownStatus, ""),
+		Details:         make(map[string]any),
+		LastCheckTime:   time.Now(),
+	}
+
+	cfg := service.Configuration
+	dsn := utils.ConfigString(cfg, "dsn", "")
+	if dsn == "" {
+		if service.Host == "" {
+			status.FailureCount++
+			status.HealthReport = constants.GetStatusInfo(constants.InvalidConfiguration, "")
+			return status, fmt.Errorf("host cannot be empty")
+		}
+		dsn = p.dialect.DSN(service)
+	}
+
+	timeout := utils.ConfigDuration(cfg, "timeout", 15*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	target, err := sql.Open(p.dialect.Driver, dsn)
+	if err != nil {
+		status.FailureCount++
+		status.HealthReport = constants.GetStatusInfo(constants.InvalidConfiguration, err.Error())
+		return status, fmt.Errorf("invalid %s DSN: %v", p.dialect.Driver, err)
+	}
+	defer target.Close()
+	target.SetMaxOpenConns(1)
+
+	start := time.Now()
+	conn, err := target.Conn(ctx)
+	if err == nil {
+		err = conn.PingContext(ctx)
+	}
+	if err != nil {
+		status.FailureCount++
+		status.HealthReport = constants.GetStatusInfo(constants.Degraded, "Connection failed "+err.Error())
+		return status, fmt.Errorf("database connection failed: %v", err)
+	}
+	defer conn.Close()
+	connectLatency := time.Since(start)
+
+	query := utils.ConfigString(cfg, "healthQuery", p.dialect.HealthQuery)
+	start = time.Now()
+	rows, err := conn.QueryContext(ctx, query)
+	if err == nil {
+		for rows.Next() {
+		}
+		err = rows.Err()
+		rows.Close()
+	}
+	if err != nil {
+		status.FailureCount++
+		status.HealthReport = constants.GetStatusInfo(constants.Degraded, "Health query failed "+err.Error())
+		return status, fmt.Errorf("health query failed: %v", err)
+	}
+	queryLatency := time.Since(start)
+
+	status.Details["connect_latency_ms"] = float64(connectLatency.Microseconds()) / 1000
+	status.Details["query_latency_ms"] = float64(queryLatency.Microseconds()) / 1000
+
+	thresholds := DatabaseThresholds{
+		ConnectLatencyWarn:         time.Duration(utils.ConfigFloat(cfg, "connectLatencyWarnMs", 0)) * time.Millisecond,
+		QueryLatencyWarn:           time.Duration(utils.ConfigFloat(cfg, "queryLatencyWarnMs", 0)) * time.Millisecond,
+		ConnectionUsageWarnPercent: utils.ConfigFloat(cfg, "connectionUsageWarnPercent", 80),
+		ConnectionUsageCritPercent: utils.ConfigFloat(cfg, "connectionUsageCriticalPercent", 95),
+		LongRunningSeconds:         utils.ConfigFloat(cfg, "longRunningQuerySeconds", 300),
+		LongRunningWarnCount:       utils.ConfigInt(cfg, "longRunningWarnCount", 1),
+		BlockingWarnCount:          utils.ConfigInt(cfg, "blockingWarnCount", 1),
+		ReplicationLagWarnSeconds:  utils.ConfigFloat(cfg, "replicationLagWarnSeconds", 30),
+		ReplicationLagCritSeconds:  utils.ConfigFloat(cfg, "replicationLagCriticalSeconds", 300),
+	}
+
+	var critical, warnings []string
+
+	if thresholds.ConnectLatencyWarn > 0 && connectLatency >= thresholds.ConnectLatencyWarn {
+		warnings = append(warnings, fmt.Sprintf("connect latency %v (warning %v)", connectLatency.Round(time.Millisecond), thresholds.ConnectLatencyWarn))
+	}
+	if thresholds.QueryLatencyWarn > 0 && queryLatency >= thresholds.QueryLatencyWarn {
+		warnings = append(warnings, fmt.Sprintf("query latency %v (warning %v)", queryLatency.Round(time.Millisecond), thresholds.QueryLatencyWarn))
+	}
+
+	// Optional collectors; a failing catalog query (missing permission, unsupported version) is reported
+	// in the details but does not fail the check.
+	if utils.ConfigBool(cfg, "collectConnections", false) {
+		var active, maxConnections int
+		if err := conn.QueryRowContext(ctx, p.dialect.ConnectionsQuery).Scan(&active, &maxConnections); err != nil {
+			status.Details["connections_error"] = err.Error()
+		} else {
+			usage := 0.0
+			if maxConnections > 0 {
+				usage = float64(active) / float64(maxConnections) * 100
+			}
+			status.Details["connections_active"] = active
+			status.Details["connections_max"] = maxConnections
+			status.Details["connections_usage_percent"] = usage
+
+			switch {
+			case thresholds.ConnectionUsageCritPercent > 0 && usage >= thresholds.ConnectionUsageCritPercent:
+				critical = append(critical, fmt.Sprintf("connection usage %.1f%% (critical %.1f%%)", usage, thresholds.ConnectionUsageCritPercent))
+			case thresholds.ConnectionUsageWarnPercent > 0 && usage >= thresholds.ConnectionUsageWarnPercent:
+				warnings = append(warnings, fmt.Sprintf("connection usage %.1f%% (warning %.1f%%)", usage, thresholds.ConnectionUsageWarnPercent))
+			}
+		}
+	}
+
+	if utils.ConfigBool(cfg, "collectLongRunning", false) {
+		var count int
+		var longest float64
+		if err := conn.QueryRowContext(ctx, p.dialect.LongRunningQuery, thresholds.LongRunningSeconds).Scan(&count, &longest); err != nil {
+			status.Details["long_running_error"] = err.Error()
+		} else {
+			status.Details["long_running_queries"] = count
+			status.Details["longest_query_seconds"] = longest
+
+			if thresholds.LongRunningWarnCount > 0 && count >= thresholds.LongRunningWarnCount {
+				warnings = append(warnings, fmt.Sprintf("%d queries running longer than %.0fs (longest %.0fs)", count, thresholds.LongRunningSeconds, longest))
+			}
+		}
+	}
+
+	if utils.ConfigBool(cfg, "collectBlocking", false) {
+		var blocked int
+		if err := conn.QueryRowContext(ctx, p.dialect.BlockingQuery).Scan(&blocked); err != nil {
+			status.Details["blocking_error"] = err.Error()
+		} else {
+			status.Details["blocked_sessions"] = blocked
+
+			if thresholds.BlockingWarnCount > 0 && blocked >= thresholds.BlockingWarnCount {
+				warnings = append(warnings, fmt.Sprintf("%d blocked sessions", blocked))
+			}
+		}
+	}
+
+	if utils.ConfigBool(cfg, "collectReplicationLag", false) {
+		var lag float64
+		if err := conn.QueryRowContext(ctx, p.dialect.ReplicationQuery).Scan(&lag); err != nil {
+			status.Details["replication_lag_error"] = err.Error()
+		} else {
+			status.Details["replication_lag_seconds"] = lag
+
+			switch {
+			case thresholds.ReplicationLagCritSeconds > 0 && lag >= thresholds.ReplicationLagCritSeconds:
+				critical = append(critical, fmt.Sprintf("replication lag %.0fs (critical %.0fs)", lag, thresholds.ReplicationLagCritSeconds))
+			case thresholds.ReplicationLagWarnSeconds > 0 && lag >= thresholds.ReplicationLagWarnSeconds:
+				warnings = append(warnings, fmt.Sprintf("replication lag %.0fs (warning %.0fs)", lag, thresholds.ReplicationLagWarnSeconds))
+			}
+		}
+	}
+
+	if len(critical) > 0 {
+		message := strings.Join(critical, "; ")
+		status.FailureCount++
+		status.HealthReport = constants.GetStatusInfo(constants.Degraded, message)
+		return status, fmt.Errorf("database check failed: %s", message)
+	}
+
+	if len(warnings) > 0 {
+		message := strings.Join(warnings, "; ")
+		status.FailureCount++
+		status.HealthReport = constants.GetStatusInfo(constants.Escalation, message)
+		return status, fmt.Errorf("database thresholds exceeded: %s", message)
+	}
+
+	status.FailureCount = 0
+	status.HealthReport = constants.GetStatusInfo(constants.Healthy, "")
+	status.LastServiceUpTime = time.Now()
+
+	return status, nil
+}
+
+func postgresDSN(service monitors.ServiceMonitorData) string {
+	cfg := service.Configuration
+
+	port := service.Port
+	if port == 0 {
+		port = 5432
+	}
+
+	dsn := url.URL{
+		Scheme: "postgres",
+		User:   url.UserPassword(utils.ConfigString(cfg, "dbUser", ""), utils.ConfigString(cfg, "dbPassword", "")),
+		Host:   net.JoinHostPort(service.Host, strconv.Itoa(port)),
+		Path:   "/" + utils.ConfigString(cfg, "dbName", "postgres"),
+	}
+
+	query := url.Values{}
+	query.Set("sslmode", utils.ConfigString(cfg, "dbSSLMode", "disable"))
+	query.Set("application_name", "ms-monitor")
+	dsn.RawQuery = query.Encode()
+
+	return dsn.String()
+}
+
+func sqlServerDSN(service monitors.ServiceMonitorData) string {
+	cfg := service.Configuration
+
+	port := service.Port
+	if port == 0 {
+		port = 1433
+	}
+
+	dsn := url.URL{
+		Scheme: "sqlserver",
+		User:   url.UserPassword(utils.ConfigString(cfg, "dbUser", ""), utils.ConfigString(cfg, "dbPassword", "")),
+		Host:   net.JoinHostPort(service.Host, strconv.Itoa(port)),
+	}
+
+	if instance := utils.ConfigString(cfg, "dbInstance", ""); instance != "" {
+		dsn.Path = "/" + instance
+	}
+
+	query := url.Values{}
+	query.Set("database", utils.ConfigString(cfg, "dbName", "master"))
+	query.Set("encrypt", utils.ConfigString(cfg, "dbEncrypt", "disable"))
+	query.Set("app name", "ms-monitor")
+	if utils.ConfigBool(cfg, "dbTrustServerCertificate", false) {
+		query.Set("TrustServerCertificate", "true")
+	}
+	dsn.RawQuery = query.Encode()
+
+	return dsn.String()
+}
+
+func (p *DatabaseMonitorPlugin) Name() string {
+	return p.dialect.Name
+}
+
+func (p *DatabaseMonitorPlugin) Description() string {
+	return fmt.Sprintf("%s health, latency, connection, long running query, blocking and replication monitor", p.dialect.Driver)
+}
+
+func (p *DatabaseMonitorPlugin) SupportedTypes() []monitors.ServiceType {
+	return []monitors.ServiceType{
+		monitors.ServiceMonitorDatabase,
+		monitors.ServiceMonitorServer,
+	}
+}
+
+func (p *DatabaseMonitorPlugin) Cleanup() error {
+	return nil
+}
+
+var PostgresPlugin monitors.ServiceMonitorPlugin = NewPostgresMonitorPlugin()
+var SQLServerPlugin monitors.ServiceMonitorPlugin = NewSQLServerMonitorPlugin()
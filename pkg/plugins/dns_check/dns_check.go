@@ -0,0 +1,535 @@
+package dnscheck
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"database/sql"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand"
+	"net"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ZEGIFTED/MS.GoMonitor/monitors"
+	"github.com/ZEGIFTED/MS.GoMonitor/pkg/constants"
+	"github.com/ZEGIFTED/MS.GoMonitor/pkg/utils"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+const (
+	TransportUDP = "udp"
+	TransportTCP = "tcp"
+	TransportTLS = "tls"
+
+	defaultDNSPort = "53"
+	defaultDoTPort = "853"
+	maxUDPSize     = 4096
+)
+
+var recordTypes = map[string]dnsmessage.Type{
+	"A":     dnsmessage.TypeA,
+	"AAAA":  dnsmessage.TypeAAAA,
+	"CNAME": dnsmessage.TypeCNAME,
+	"MX":    dnsmessage.TypeMX,
+	"TXT":   dnsmessage.TypeTXT,
+	"SRV":   dnsmessage.TypeSRV,
+}
+
+// DNSQuery describes a single lookup performed against every configured resolver
+type DNSQuery struct {
+	Name     string   `json:"name"`
+	Type     string   `json:"type"`
+	Expected []string `json:"expected"`
+}
+
+// DNSAnswer is the parsed response of a resolver for a DNS query
+type DNSAnswer struct {
+	Resolver string
+	RCode    dnsmessage.RCode
+	Records  []string
+	Latency  time.Duration
+}
+
+type DNSChecker struct {
+	mu          sync.Mutex
+	lastAnswers map[string][]string // keyed by SystemMonitorId|resolver|name|type
+}
+
+func NewDNSChecker() *DNSChecker {
+	return &DNSChecker{
+		lastAnswers: make(map[string][]string),
+	}
+}
+
+func (d *DNSChecker) Initialize(config map[string]any) error {
+	return nil
+}
+
+func (d *DNSChecker) Check(ctx context.Context, db *sql.DB, service monitors.ServiceMonitorData) (monitors.MonitoringResult, error) {
+	status := monitors.MonitoringResult{
+		SystemMonitorId: service.SystemMonitorId.String(),
+		ServicePluginID: d.Name(),
+		HealthReport:    constants.GetStatusInfo(constants.UnknownStatus, ""),
+		Details:         make(map[string]any),
+		LastCheckTime:   time.Now(),
+	}
+
+	cfg := service.Configuration
+	timeout := utils.ConfigDuration(cfg, "timeout", 5*time.Second)
+	transport := strings.ToLower(utils.ConfigString(cfg, "dnsTransport", TransportUDP))
+
+	queries, err := d.parseQueries(service)
+	if err != nil {
+		status.FailureCount++
+		status.HealthReport = constants.GetStatusInfo(constants.InvalidConfiguration, err.Error())
+		return status, err
+	}
+
+	resolvers := d.resolvers(service, transport)
+	if len(resolvers) == 0 {
+		status.FailureCount++
+		status.HealthReport = constants.GetStatusInfo(constants.InvalidConfiguration, "No DNS resolvers configured")
+		return status, fmt.Errorf("no DNS resolvers configured for %s", service.Name)
+	}
+
+	var tlsConfig *tls.Config
+	if transport == TransportTLS {
+		tlsConfig = &tls.Config{
+			ServerName:         utils.ConfigString(cfg, "dnsTLSServerName", ""),
+			InsecureSkipVerify: utils.ConfigBool(cfg, "dnsTLSSkipVerify", false),
+		}
+	}
+
+	latencyThreshold := time.Duration(utils.ConfigFloat(cfg, "latencyThresholdMs", 0)) * time.Millisecond
+	// Round robin and geo DNS answer differently on every query, drift is only alerted on when asked for
+	alertOnDrift := utils.ConfigBool(cfg, "alertOnDrift", false)
+
+	var failures, warnings []string
+	results := make([]map[string]any, 0, len(queries)*len(resolvers))
+
+	for _, q := range queries {
+		for _, resolver := range resolvers {
+			queryCtx, cancel := context.WithTimeout(ctx, timeout)
+			answer, err := Query(queryCtx, resolver, transport, tlsConfig, q.Name, recordTypes[q.Type])
+			cancel()
+
+			result := map[string]any{
+				"resolver": resolver,
+				"name":     q.Name,
+				"type":     q.Type,
+			}
+			results = append(results, result)
+
+			if err != nil {
+				result["error"] = err.Error()
+				failures = append(failures, fmt.Sprintf("%s %s via %s: %v", q.Type, q.Name, resolver, err))
+				continue
+			}
+
+			result["rcode"] = answer.RCode.String()
+			result["answers"] = answer.Records
+			result["latency_ms"] = float64(answer.Latency.Microseconds()) / 1000
+
+			if answer.RCode != dnsmessage.RCodeSuccess {
+				failures = append(failures, fmt.Sprintf("%s %s via %s returned %s", q.Type, q.Name, resolver, answer.RCode))
+				continue
+			}
+
+			if len(answer.Records) == 0 {
+				failures = append(failures, fmt.Sprintf("%s %s via %s returned no records", q.Type, q.Name, resolver))
+				continue
+			}
+
+			if len(q.Expected) > 0 {
+				if missing := missingAnswers(q.Expected, answer.Records); len(missing) > 0 {
+					warnings = append(warnings, fmt.Sprintf("%s %s via %s missing expected answers %v (got %v)", q.Type, q.Name, resolver, missing, answer.Records))
+				}
+			}
+
+			if latencyThreshold > 0 && answer.Latency > latencyThreshold {
+				warnings = append(warnings, fmt.Sprintf("%s via %s took %v (threshold %v)", q.Name, resolver, answer.Latency.Round(time.Millisecond), latencyThreshold))
+			}
+
+			if previous, drifted := d.recordAnswers(service.SystemMonitorId.String(), resolver, q, answer.Records); drifted {
+				result["previous_answers"] = previous
+				if alertOnDrift {
+					warnings = append(warnings, fmt.Sprintf("%s %s via %s changed from %v to %v", q.Type, q.Name, resolver, previous, answer.Records))
+				}
+			}
+		}
+	}
+
+	status.Details["queries"] = results
+
+	if len(failures) > 0 {
+		message := strings.Join(failures, "; ")
+		status.FailureCount++
+		status.HealthReport = constants.GetStatusInfo(constants.Degraded, message)
+		return status, fmt.Errorf("DNS resolution failed: %s", message)
+	}
+
+	if len(warnings) > 0 {
+		message := strings.Join(warnings, "; ")
+		status.FailureCount++
+		status.HealthReport = constants.GetStatusInfo(constants.Escalation, message)
+		return status, fmt.Errorf("DNS check failed: %s", message)
+	}
+
+	status.FailureCount = 0
+	status.HealthReport = constants.GetStatusInfo(constants.Healthy, "")
+	status.LastServiceUpTime = time.Now()
+
+	return status, nil
+}
+
+// parseQueries builds the lookups for a service from either the dnsQueries list
+// or the single dnsName/dnsRecordTypes/dnsExpected keys, defaulting to an A lookup of the host.
+func (d *DNSChecker) parseQueries(service monitors.ServiceMonitorData) ([]DNSQuery, error) {
+	var queries []DNSQuery
+
+	for _, m := range utils.ConfigMapSlice(service.Configuration, "dnsQueries") {
+		queries = append(queries, DNSQuery{
+			Name:     utils.ConfigString(m, "name", service.Host),
+			Type:     strings.ToUpper(utils.ConfigString(m, "type", "A")),
+			Expected: utils.ConfigStringSlice(m, "expected"),
+		})
+	}
+
+	if len(queries) == 0 {
+		name := utils.ConfigString(service.Configuration, "dnsName", service.Host)
+		types := utils.ConfigStringSlice(service.Configuration, "dnsRecordTypes")
+		if len(types) == 0 {
+			types = []string{"A"}
+		}
+
+		expected := utils.ConfigStringSlice(service.Configuration, "dnsExpected")
+		for _, t := range types {
+			queries = append(queries, DNSQuery{Name: name, Type: strings.ToUpper(t), Expected: expected})
+		}
+	}
+
+	for _, q := range queries {
+		if q.Name == "" {
+			return nil, fmt.Errorf("DNS query name cannot be empty")
+		}
+		if _, ok := recordTypes[q.Type]; !ok {
+			return nil, fmt.Errorf("unsupported DNS record type %q", q.Type)
+		}
+	}
+
+	return queries, nil
+}
+
+// resolvers returns the configured resolvers as host:port pairs. When none are
+// configured a service registered on the DNS port is treated as the resolver itself,
+// otherwise the system resolvers are used.
+func (d *DNSChecker) resolvers(service monitors.ServiceMonitorData, transport string) []string {
+	port := defaultDNSPort
+	if transport == TransportTLS {
+		port = defaultDoTPort
+	}
+
+	configured := utils.ConfigStringSlice(service.Configuration, "dnsResolvers")
+	if len(configured) == 0 {
+		if service.Host != "" && (service.Port == 53 || service.Port == 853) {
+			return []string{net.JoinHostPort(service.Host, strconv.Itoa(service.Port))}
+		}
+		configured = systemResolvers()
+	}
+
+	resolvers := make([]string, 0, len(configured))
+	for _, r := range configured {
+		if _, _, err := net.SplitHostPort(r); err != nil {
+			r = net.JoinHostPort(strings.Trim(r, "[]"), port)
+		}
+		resolvers = append(resolvers, r)
+	}
+
+	return resolvers
+}
+
+// recordAnswers stores the latest answer set and reports whether it differs from the previous one
+func (d *DNSChecker) recordAnswers(systemMonitorId, resolver string, q DNSQuery, records []string) ([]string, bool) {
+	key := strings.Join([]string{systemMonitorId, resolver, strings.ToLower(q.Name), q.Type}, "|")
+
+	current := slices.Clone(records)
+	slices.Sort(current)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	previous, seen := d.lastAnswers[key]
+	d.lastAnswers[key] = current
+
+	return previous, seen && !slices.Equal(previous, current)
+}
+
+func missingAnswers(expected, records []string) []string {
+	var missing []string
+	for _, e := range expected {
+		found := false
+		for _, r := range records {
+			if strings.EqualFold(strings.TrimSuffix(e, "."), strings.TrimSuffix(r, ".")) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			missing = append(missing, e)
+		}
+	}
+	return missing
+}
+
+// Query sends a single question to resolver (host:port) over the given transport and parses the answers
+func Query(ctx context.Context, resolver, transport string, tlsConfig *tls.Config, name string, qtype dnsmessage.Type) (DNSAnswer, error) {
+	answer := DNSAnswer{Resolver: resolver}
+
+	fqdn := name
+	if !strings.HasSuffix(fqdn, ".") {
+		fqdn += "."
+	}
+
+	qname, err := dnsmessage.NewName(fqdn)
+	if err != nil {
+		return answer, fmt.Errorf("invalid DNS name %q: %v", name, err)
+	}
+
+	id := uint16(rand.Intn(1 << 16))
+	query := dnsmessage.Message{
+		Header:    dnsmessage.Header{ID: id, RecursionDesired: true},
+		Questions: []dnsmessage.Question{{Name: qname, Type: qtype, Class: dnsmessage.ClassINET}},
+	}
+
+	packed, err := query.Pack()
+	if err != nil {
+		return answer, fmt.Errorf("failed to build DNS query: %v", err)
+	}
+
+	start := time.Now()
+	raw, err := Exchange(ctx, resolver, transport, tlsConfig, packed)
+	if err != nil {
+		return answer, err
+	}
+
+	var parser dnsmessage.Parser
+	header, err := parser.Start(raw)
+	if err != nil {
+		return answer, fmt.Errorf("invalid DNS response: %v", err)
+	}
+
+	// Retry truncated UDP answers over TCP
+	if header.Truncated && transport == TransportUDP {
+		raw, err = Exchange(ctx, resolver, TransportTCP, nil, packed)
+		if err != nil {
+			return answer, err
+		}
+		if header, err = parser.Start(raw); err != nil {
+			return answer, fmt.Errorf("invalid DNS response: %v", err)
+		}
+	}
+	answer.Latency = time.Since(start)
+
+	if header.ID != id {
+		return answer, fmt.Errorf("DNS response ID mismatch")
+	}
+	answer.RCode = header.RCode
+
+	if err := parser.SkipAllQuestions(); err != nil {
+		return answer, fmt.Errorf("invalid DNS response: %v", err)
+	}
+
+	for {
+		rh, err := parser.AnswerHeader()
+		if err == dnsmessage.ErrSectionDone {
+			break
+		}
+		if err != nil {
+			return answer, fmt.Errorf("invalid DNS answer: %v", err)
+		}
+
+		record, err := parseRecord(&parser, rh, qtype)
+		if err != nil {
+			return answer, err
+		}
+		if record != "" {
+			answer.Records = append(answer.Records, record)
+		}
+	}
+
+	return answer, nil
+}
+
+// parseRecord formats an answer record. Records that are not of the queried type
+// (e.g. the CNAME chain returned for an A lookup) are skipped.
+func parseRecord(parser *dnsmessage.Parser, rh dnsmessage.ResourceHeader, qtype dnsmessage.Type) (string, error) {
+	if rh.Type != qtype {
+		return "", parser.SkipAnswer()
+	}
+
+	switch rh.Type {
+	case dnsmessage.TypeA:
+		r, err := parser.AResource()
+		if err != nil {
+			return "", err
+		}
+		return net.IP(r.A[:]).String(), nil
+	case dnsmessage.TypeAAAA:
+		r, err := parser.AAAAResource()
+		if err != nil {
+			return "", err
+		}
+		return net.IP(r.AAAA[:]).String(), nil
+	case dnsmessage.TypeCNAME:
+		r, err := parser.CNAMEResource()
+		if err != nil {
+			return "", err
+		}
+		return r.CNAME.String(), nil
+	case dnsmessage.TypeMX:
+		r, err := parser.MXResource()
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%d %s", r.Pref, r.MX.String()), nil
+	case dnsmessage.TypeTXT:
+		r, err := parser.TXTResource()
+		if err != nil {
+			return "", err
+		}
+		return strings.Join(r.TXT, ""), nil
+	case dnsmessage.TypeSRV:
+		r, err := parser.SRVResource()
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%d %d %d %s", r.Priority, r.Weight, r.Port, r.Target.String()), nil
+	default:
+		return "", parser.SkipAnswer()
+	}
+}
+
+// Exchange sends a packed DNS message to resolver and returns the raw response.
+// TCP and TLS (DoT) use the two byte length prefix framing from RFC 1035 / RFC 7858.
+func Exchange(ctx context.Context, resolver, transport string, tlsConfig *tls.Config, msg []byte) ([]byte, error) {
+	dialer := &net.Dialer{}
+	var conn net.Conn
+	var err error
+
+	switch transport {
+	case TransportUDP, "":
+		conn, err = dialer.DialContext(ctx, "udp", resolver)
+	case TransportTCP:
+		conn, err = dialer.DialContext(ctx, "tcp", resolver)
+	case TransportTLS:
+		cfg := &tls.Config{}
+		if tlsConfig != nil {
+			cfg = tlsConfig.Clone()
+		}
+		if cfg.ServerName == "" {
+			cfg.ServerName, _, _ = net.SplitHostPort(resolver)
+		}
+		tlsDialer := &tls.Dialer{NetDialer: dialer, Config: cfg}
+		conn, err = tlsDialer.DialContext(ctx, "tcp", resolver)
+	default:
+		return nil, fmt.Errorf("unsupported DNS transport %q", transport)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to resolver %s: %v", resolver, err)
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	if transport == TransportUDP || transport == "" {
+		if _, err := conn.Write(msg); err != nil {
+			return nil, fmt.Errorf("failed to send DNS query: %v", err)
+		}
+
+		buf := make([]byte, maxUDPSize)
+		n, err := conn.Read(buf)
+		if err != nil {
+			return nil, fmt.Errorf("no response from resolver %s: %v", resolver, err)
+		}
+		return buf[:n], nil
+	}
+
+	framed := make([]byte, 2+len(msg))
+	binary.BigEndian.PutUint16(framed, uint16(len(msg)))
+	copy(framed[2:], msg)
+
+	if _, err := conn.Write(framed); err != nil {
+		return nil, fmt.Errorf("failed to send DNS query: %v", err)
+	}
+
+	var length [2]byte
+	if _, err := io.ReadFull(conn, length[:]); err != nil {
+		return nil, fmt.Errorf("no response from resolver %s: %v", resolver, err)
+	}
+
+	buf := make([]byte, binary.BigEndian.Uint16(length[:]))
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		return nil, fmt.Errorf("truncated response from resolver %s: %v", resolver, err)
+	}
+
+	return buf, nil
+}
+
+// systemResolvers reads the nameservers from /etc/resolv.conf
+func systemResolvers() []string {
+	file, err := os.Open("/etc/resolv.conf")
+	if err != nil {
+		slog.Warn("Unable to read system resolvers, using localhost", "Error", err.Error())
+		return []string{"127.0.0.1"}
+	}
+	defer file.Close()
+
+	var servers []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == "nameserver" {
+			servers = append(servers, fields[1])
+		}
+	}
+
+	if len(servers) == 0 {
+		slog.Warn("No nameservers found in /etc/resolv.conf, using localhost")
+		servers = []string{"127.0.0.1"}
+	}
+
+	return servers
+}
+
+func (d *DNSChecker) Name() string {
+	return "dns_check"
+}
+
+func (d *DNSChecker) Description() string {
+	return "Checks DNS resolution, expected answers and resolver latency"
+}
+
+func (d *DNSChecker) SupportedTypes() []monitors.ServiceType {
+	return []monitors.ServiceType{
+		monitors.ServiceMonitorWebModules,
+		monitors.ServiceMonitorServer,
+		monitors.ServiceMonitorSNMP,
+	}
+}
+
+func (d *DNSChecker) Cleanup() error {
+	return nil
+}
+
+var Plugin monitors.ServiceMonitorPlugin = NewDNSChecker()
@@ -2,15 +2,50 @@ package plugins
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/ZEGIFTED/MS.GoMonitor/monitors"
 	"github.com/ZEGIFTED/MS.GoMonitor/pkg/constants"
+	"github.com/ZEGIFTED/MS.GoMonitor/pkg/utils"
+	mstypes "github.com/ZEGIFTED/MS.GoMonitor/types"
 )
 
+const defaultDockerHost = "unix:///var/run/docker.sock"
+
+// Stats requests wait for two CPU samples, they are sent in parallel with a deadline of their own
+const (
+	defaultStatsConcurrency = 8
+	defaultStatsTimeout     = 10 * time.Second
+)
+
+// ContainerExpectation is a per-container rule from the service Configuration ("containers").
+// Name may be a glob such as "web-*".
+type ContainerExpectation struct {
+	Name             string
+	MustBeRunning    bool
+	MaxRestarts      int
+	MaxCPUPercent    float64
+	MaxMemoryPercent float64
+}
+
 type DockerPlugin struct {
 	config map[string]any
+
+	mu       sync.Mutex
+	restarts map[string]int // SystemMonitorId|container -> restart count at the previous check
 }
 
 func (d *DockerPlugin) Initialize(config map[string]interface{}) error {
@@ -18,8 +53,8 @@ func (d *DockerPlugin) Initialize(config map[string]interface{}) error {
 	return nil
 }
 
-func _DockerPlugin() *DockerPlugin {
-	return &DockerPlugin{}
+func NewDockerPlugin() *DockerPlugin {
+	return &DockerPlugin{restarts: make(map[string]int)}
 }
 
 func (p *DockerPlugin) Name() string {
@@ -27,11 +62,11 @@ func (p *DockerPlugin) Name() string {
 }
 
 func (p *DockerPlugin) Description() string {
-	return "Docker Engine"
+	return "Docker Engine container state, health, restarts, OOM kills and resource usage"
 }
 
 func (p *DockerPlugin) SupportedTypes() []monitors.ServiceType {
-	return []monitors.ServiceType{monitors.ServiceMonitorServer}
+	return []monitors.ServiceType{monitors.ServiceMonitorServer, monitors.ServiceMonitorAgent}
 }
 
 func (hc *DockerPlugin) Cleanup() error {
@@ -43,13 +78,429 @@ func (d *DockerPlugin) Check(ctx context.Context, db *sql.DB, service monitors.S
 		SystemMonitorId: service.SystemMonitorId.String(),
 		ServicePluginID: d.Name(),
 		HealthReport:    constants.GetStatusInfo(constants.UnknownStatus, ""),
+		Details:         make(map[string]any),
 		LastCheckTime:   time.Now(),
 	}
 
-	status.FailureCount = 0
-	status.HealthReport = constants.GetStatusInfo(constants.Healthy, "")
+	cfg := service.Configuration
+	timeout := utils.ConfigDuration(cfg, "timeout", 30*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	containers, source, err := d.collect(ctx, service, timeout)
+	if err != nil {
+		status.FailureCount++
+		status.HealthReport = constants.GetStatusInfo(constants.Degraded, err.Error())
+		return status, fmt.Errorf("docker container query failed: %v", err)
+	}
+
+	status.Details["source"] = source
+	status.Details["containers"] = containers
+
+	running := 0
+	for _, c := range containers {
+		if c.State == "running" {
+			running++
+		}
+	}
+	status.Details["containers_total"] = len(containers)
+	status.Details["containers_running"] = running
+
+	// A stats sample that timed out says nothing about the container's health
+	var statsErrors []string
+	for _, c := range containers {
+		if c.StatsError != "" {
+			statsErrors = append(statsErrors, c.Name+": "+c.StatsError)
+		}
+	}
+	if len(statsErrors) > 0 {
+		status.Details["stats_errors"] = statsErrors
+	}
+
+	issues := d.evaluate(service.SystemMonitorId.String(), containers, containerExpectations(cfg))
+	status.ObjectAlerts = issues
+
+	if len(issues) == 0 {
+		status.FailureCount = 0
+		status.HealthReport = constants.GetStatusInfo(constants.Healthy, "")
+		status.LastServiceUpTime = time.Now()
+		return status, nil
+	}
+
+	level := constants.Escalation
+	summary := make([]string, 0, len(issues))
+	for _, issue := range issues {
+		if issue.Severity == "critical" {
+			level = constants.Degraded
+		}
+		summary = append(summary, issue.Name+" "+issue.Reason)
+	}
+
+	message := strings.Join(summary, "; ")
+	status.FailureCount++
+	status.HealthReport = constants.GetStatusInfo(level, message)
+	return status, fmt.Errorf("%d unhealthy containers: %s", len(issues), message)
+}
+
+// collect reads the containers from the agent when the service is an agent managed Docker host
+// without an explicit "dockerHost", otherwise from the Engine API.
+func (d *DockerPlugin) collect(ctx context.Context, service monitors.ServiceMonitorData, timeout time.Duration) ([]mstypes.ContainerStats, string, error) {
+	cfg := service.Configuration
+	host := utils.ConfigString(cfg, "dockerHost", "")
+
+	useAgent := utils.ConfigString(cfg, "dockerSource", "") == "agent" ||
+		(host == "" && service.Engine == monitors.DockerEngine && service.AgentAPIBaseURL != "")
+
+	if useAgent {
+		client, endpoint, err := service.AgentRepository.ValidateAgentURL(service.AgentAPIBaseURL, "/api/v1/agent/container")
+		if err != nil {
+			return nil, "agent", err
+		}
+		response, err := service.AgentRepository.GetAgentContainerStats(client, endpoint)
+		return response.Containers, "agent", err
+	}
+
+	if host == "" {
+		host = defaultDockerHost
+	}
+
+	engine, err := newDockerEngineClient(host, cfg, timeout)
+	if err != nil {
+		return nil, host, err
+	}
+	// The transport is built per check from the TLS files, its connections must not outlive it
+	defer engine.http.CloseIdleConnections()
+
+	containers, err := engine.Containers(ctx, utils.ConfigBool(cfg, "collectStats", true))
+	return containers, host, err
+}
+
+// evaluate applies the expectations and the built-in rules (unhealthy health check, new OOM kills)
+func (d *DockerPlugin) evaluate(systemMonitorId string, containers []mstypes.ContainerStats, expectations []ContainerExpectation) []monitors.ObjectAlert {
+	var issues []monitors.ObjectAlert
+	report := func(c mstypes.ContainerStats, reason, message, severity string) {
+		issues = append(issues, monitors.ObjectAlert{Kind: "Container", Name: c.Name, Reason: reason, Message: message, Severity: severity})
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for _, c := range containers {
+		key := systemMonitorId + "|" + c.Name
+		previousRestarts, seen := d.restarts[key]
+		d.restarts[key] = c.RestartCount
+
+		if c.Health == "unhealthy" {
+			report(c, "Unhealthy", "container health check is failing", "critical")
+		}
+		if c.OOMKilled && (!seen || c.RestartCount != previousRestarts) {
+			report(c, "OOMKilled", fmt.Sprintf("killed by the kernel OOM killer (limit %s)", formatBytes(c.MemoryLimitBytes)), "warning")
+		}
+
+		for _, e := range expectations {
+			if matched, _ := path.Match(e.Name, c.Name); !matched {
+				continue
+			}
+
+			if e.MustBeRunning && c.State != "running" {
+				report(c, "NotRunning", fmt.Sprintf("container is %s (exit code %d)", c.State, c.ExitCode), "critical")
+			}
+			if e.MaxRestarts > 0 && c.RestartCount > e.MaxRestarts {
+				report(c, "Restarts", fmt.Sprintf("restarted %d times (max %d)", c.RestartCount, e.MaxRestarts), "warning")
+			}
+			if e.MaxCPUPercent > 0 && c.CPUPercent > e.MaxCPUPercent {
+				report(c, "HighCPU", fmt.Sprintf("cpu %.1f%% (max %.1f%%)", c.CPUPercent, e.MaxCPUPercent), "warning")
+			}
+			if e.MaxMemoryPercent > 0 && c.MemoryPercent > e.MaxMemoryPercent {
+				report(c, "HighMemory", fmt.Sprintf("memory %.1f%% of %s (max %.1f%%)", c.MemoryPercent, formatBytes(c.MemoryLimitBytes), e.MaxMemoryPercent), "warning")
+			}
+		}
+	}
+
+	// Expected containers that do not exist at all
+	for _, e := range expectations {
+		if !e.MustBeRunning {
+			continue
+		}
+
+		found := false
+		for _, c := range containers {
+			if matched, _ := path.Match(e.Name, c.Name); matched {
+				found = true
+				break
+			}
+		}
+		if !found {
+			issues = append(issues, monitors.ObjectAlert{Kind: "Container", Name: e.Name, Reason: "Missing", Message: "no matching container exists", Severity: "critical"})
+		}
+	}
+
+	return issues
+}
+
+func containerExpectations(cfg map[string]any) []ContainerExpectation {
+	var expectations []ContainerExpectation
+	for _, entry := range utils.ConfigMapSlice(cfg, "containers") {
+		name := utils.ConfigString(entry, "name", "")
+		if name == "" {
+			continue
+		}
+		expectations = append(expectations, ContainerExpectation{
+			Name:             strings.TrimPrefix(name, "/"),
+			MustBeRunning:    utils.ConfigBool(entry, "mustBeRunning", true),
+			MaxRestarts:      utils.ConfigInt(entry, "maxRestarts", 0),
+			MaxCPUPercent:    utils.ConfigFloat(entry, "maxCpuPercent", 0),
+			MaxMemoryPercent: utils.ConfigFloat(entry, "maxMemoryPercent", 0),
+		})
+	}
+	return expectations
+}
+
+func formatBytes(b float64) string {
+	if b <= 0 {
+		return "unlimited"
+	}
+	return fmt.Sprintf("%.0fMiB", b/(1<<20))
+}
+
+// dockerEngineClient talks to the Engine API over a Unix socket or TCP with optional TLS
+type dockerEngineClient struct {
+	baseURL string
+	http    *http.Client
+
+	statsConcurrency int
+	statsTimeout     time.Duration
+}
+
+func newDockerEngineClient(host string, cfg map[string]any, timeout time.Duration) (*dockerEngineClient, error) {
+	u, err := url.Parse(host)
+	if err != nil {
+		return nil, fmt.Errorf("invalid docker host %q: %v", host, err)
+	}
+
+	transport := &http.Transport{}
+	baseURL := ""
 
-	return status, nil
+	switch u.Scheme {
+	case "unix":
+		socket := u.Path
+		transport.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", socket)
+		}
+		baseURL = "http://docker"
+	case "tcp", "http", "https":
+		scheme := "http"
+		if u.Scheme == "https" || utils.ConfigBool(cfg, "dockerTLS", false) {
+			scheme = "https"
+			tlsConfig, err := dockerTLSConfig(cfg)
+			if err != nil {
+				return nil, err
+			}
+			transport.TLSClientConfig = tlsConfig
+		}
+		baseURL = scheme + "://" + u.Host
+	default:
+		return nil, fmt.Errorf("unsupported docker host scheme %q", u.Scheme)
+	}
+
+	return &dockerEngineClient{
+		baseURL:          baseURL,
+		http:             &http.Client{Timeout: timeout, Transport: transport},
+		statsConcurrency: max(utils.ConfigInt(cfg, "statsConcurrency", defaultStatsConcurrency), 1),
+		statsTimeout:     utils.ConfigDuration(cfg, "statsTimeout", defaultStatsTimeout),
+	}, nil
+}
+
+// dockerTLSConfig loads the client certificate and CA used by a TLS protected daemon (dockerd --tlsverify)
+func dockerTLSConfig(cfg map[string]any) (*tls.Config, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: !utils.ConfigBool(cfg, "dockerTLSVerify", true)}
+
+	if caFile := utils.ConfigString(cfg, "dockerTLSCA", ""); caFile != "" {
+		ca, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read docker CA: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("invalid docker CA certificate")
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	certFile, keyFile := utils.ConfigString(cfg, "dockerTLSCert", ""), utils.ConfigString(cfg, "dockerTLSKey", "")
+	if certFile != "" && keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load docker client certificate: %v", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
+
+func (c *dockerEngineClient) get(ctx context.Context, path string, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+path, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("GET %s: %d %s", path, resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+type dockerContainerSummary struct {
+	ID    string   `json:"Id"`
+	Names []string `json:"Names"`
+	Image string   `json:"Image"`
+	State string   `json:"State"`
+}
+
+type dockerContainerInspect struct {
+	RestartCount int `json:"RestartCount"`
+	State        struct {
+		Status    string    `json:"Status"`
+		OOMKilled bool      `json:"OOMKilled"`
+		ExitCode  int       `json:"ExitCode"`
+		StartedAt time.Time `json:"StartedAt"`
+		Health    *struct {
+			Status string `json:"Status"`
+		} `json:"Health"`
+	} `json:"State"`
+}
+
+type dockerContainerStats struct {
+	CPUStats    dockerCPUStats `json:"cpu_stats"`
+	PreCPUStats dockerCPUStats `json:"precpu_stats"`
+	MemoryStats struct {
+		Usage float64            `json:"usage"`
+		Limit float64            `json:"limit"`
+		Stats map[string]float64 `json:"stats"`
+	} `json:"memory_stats"`
+}
+
+type dockerCPUStats struct {
+	CPUUsage struct {
+		TotalUsage float64 `json:"total_usage"`
+	} `json:"cpu_usage"`
+	SystemUsage float64 `json:"system_cpu_usage"`
+	OnlineCPUs  float64 `json:"online_cpus"`
+}
+
+// Containers lists every container with its inspected state and, for running ones, a stats sample
+func (c *dockerEngineClient) Containers(ctx context.Context, withStats bool) ([]mstypes.ContainerStats, error) {
+	var summaries []dockerContainerSummary
+	if err := c.get(ctx, "/containers/json?all=1", &summaries); err != nil {
+		return nil, err
+	}
+
+	containers := make([]mstypes.ContainerStats, 0, len(summaries))
+	for _, s := range summaries {
+		container := mstypes.ContainerStats{ID: s.ID, Image: s.Image, State: s.State}
+		if len(s.Names) > 0 {
+			container.Name = strings.TrimPrefix(s.Names[0], "/")
+		}
+
+		var inspect dockerContainerInspect
+		if err := c.get(ctx, "/containers/"+s.ID+"/json", &inspect); err != nil {
+			return nil, err
+		}
+		container.State = inspect.State.Status
+		container.RestartCount = inspect.RestartCount
+		container.OOMKilled = inspect.State.OOMKilled
+		container.ExitCode = inspect.State.ExitCode
+		container.StartedAt = inspect.State.StartedAt
+		if inspect.State.Health != nil {
+			container.Health = inspect.State.Health.Status
+		}
+
+		containers = append(containers, container)
+	}
+
+	if withStats {
+		c.collectStats(ctx, containers)
+	}
+
+	return containers, nil
+}
+
+// collectStats samples the running containers with a bounded number of requests in flight. A failed
+// sample is recorded in StatsError.
+func (c *dockerEngineClient) collectStats(ctx context.Context, containers []mstypes.ContainerStats) {
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < c.statsConcurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range indexes {
+				c.containerStats(ctx, &containers[index])
+			}
+		}()
+	}
+
+	for i := range containers {
+		if containers[i].State == "running" {
+			indexes <- i
+		}
+	}
+	close(indexes)
+	wg.Wait()
+}
+
+func (c *dockerEngineClient) containerStats(ctx context.Context, container *mstypes.ContainerStats) {
+	ctx, cancel := context.WithTimeout(ctx, c.statsTimeout)
+	defer cancel()
+
+	var stats dockerContainerStats
+	if err := c.get(ctx, "/containers/"+container.ID+"/stats?stream=false", &stats); err != nil {
+		container.StatsError = err.Error()
+		return
+	}
+
+	container.CPUPercent = stats.cpuPercent()
+	container.MemoryUsageBytes = stats.memoryUsage()
+	container.MemoryLimitBytes = stats.MemoryStats.Limit
+	if stats.MemoryStats.Limit > 0 {
+		container.MemoryPercent = container.MemoryUsageBytes / stats.MemoryStats.Limit * 100
+	}
+}
+
+// cpuPercent follows the docker stats CLI calculation
+func (s dockerContainerStats) cpuPercent() float64 {
+	cpuDelta := s.CPUStats.CPUUsage.TotalUsage - s.PreCPUStats.CPUUsage.TotalUsage
+	systemDelta := s.CPUStats.SystemUsage - s.PreCPUStats.SystemUsage
+	if cpuDelta <= 0 || systemDelta <= 0 {
+		return 0
+	}
+
+	cpus := s.CPUStats.OnlineCPUs
+	if cpus == 0 {
+		cpus = 1
+	}
+	return cpuDelta / systemDelta * cpus * 100
+}
+
+// memoryUsage excludes the page cache like the docker stats CLI (inactive_file on cgroup v2, cache on v1)
+func (s dockerContainerStats) memoryUsage() float64 {
+	usage := s.MemoryStats.Usage
+	if v, ok := s.MemoryStats.Stats["inactive_file"]; ok && v < usage {
+		return usage - v
+	}
+	if v, ok := s.MemoryStats.Stats["cache"]; ok && v < usage {
+		return usage - v
+	}
+	return usage
 }
 
-var Docker monitors.ServiceMonitorPlugin = _DockerPlugin()
+var Docker monitors.ServiceMonitorPlugin = NewDockerPlugin()
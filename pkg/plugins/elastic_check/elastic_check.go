@@ -0,0 +1,247 @@
+package elasticcheck
+
+import (
+	"context"
+	"crypto/tls"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ZEGIFTED/MS.GoMonitor/monitors"
+	"github.com/ZEGIFTED/MS.GoMonitor/pkg/constants"
+	"github.com/ZEGIFTED/MS.GoMonitor/pkg/utils"
+)
+
+// ClusterHealth is the subset of GET _cluster/health used by the checker
+type ClusterHealth struct {
+	ClusterName             string  `json:"cluster_name"`
+	Status                  string  `json:"status"`
+	TimedOut                bool    `json:"timed_out"`
+	NumberOfNodes           int     `json:"number_of_nodes"`
+	NumberOfDataNodes       int     `json:"number_of_data_nodes"`
+	ActiveShards            int     `json:"active_shards"`
+	RelocatingShards        int     `json:"relocating_shards"`
+	InitializingShards      int     `json:"initializing_shards"`
+	UnassignedShards        int     `json:"unassigned_shards"`
+	NumberOfPendingTasks    int     `json:"number_of_pending_tasks"`
+	ActiveShardsPercent     float64 `json:"active_shards_percent_as_number"`
+	TaskMaxWaitingInQueueMs int64   `json:"task_max_waiting_in_queue_millis"`
+}
+
+// nodesJVMStats is the subset of GET _nodes/stats/jvm used by the checker
+type nodesJVMStats struct {
+	Nodes map[string]struct {
+		Name string `json:"name"`
+		JVM  struct {
+			Mem struct {
+				HeapUsedPercent float64 `json:"heap_used_percent"`
+				HeapUsedBytes   int64   `json:"heap_used_in_bytes"`
+				HeapMaxBytes    int64   `json:"heap_max_in_bytes"`
+			} `json:"mem"`
+		} `json:"jvm"`
+	} `json:"nodes"`
+}
+
+type ElasticChecker struct {
+	config map[string]any
+
+	mu         sync.Mutex
+	transports map[bool]*http.Transport // Keyed by elasticSkipVerify, kept so connections are reused between checks
+}
+
+func NewElasticChecker() *ElasticChecker {
+	return &ElasticChecker{transports: make(map[bool]*http.Transport)}
+}
+
+func (e *ElasticChecker) transport(skipVerify bool) *http.Transport {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	transport, ok := e.transports[skipVerify]
+	if !ok {
+		transport = &http.Transport{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: skipVerify},
+			IdleConnTimeout: 90 * time.Second,
+		}
+		e.transports[skipVerify] = transport
+	}
+	return transport
+}
+
+func (e *ElasticChecker) Initialize(config map[string]any) error {
+	e.config = config
+	return nil
+}
+
+func (e *ElasticChecker) Check(ctx context.Context, db *sql.DB, service monitors.ServiceMonitorData) (monitors.MonitoringResult, error) {
+	status := monitors.MonitoringResult{
+		SystemMonitorId: service.SystemMonitorId.String(),
+		ServicePluginID: e.Name(),
+		HealthReport:    constants.GetStatusInfo(constants.UnknownStatus, ""),
+		Details:         make(map[string]any),
+		LastCheckTime:   time.Now(),
+	}
+
+	cfg := service.Configuration
+	baseURL := strings.TrimRight(utils.ConfigString(cfg, "elasticURL", ""), "/")
+	if baseURL == "" {
+		if service.Host == "" {
+			status.FailureCount++
+			status.HealthReport = constants.GetStatusInfo(constants.InvalidConfiguration, "")
+			return status, fmt.Errorf("host cannot be empty")
+		}
+
+		port := service.Port
+		if port == 0 {
+			port = 9200
+		}
+		scheme := "http"
+		if utils.ConfigBool(cfg, "elasticTLS", false) {
+			scheme = "https"
+		}
+		baseURL = scheme + "://" + net.JoinHostPort(service.Host, strconv.Itoa(port))
+	}
+
+	client := &http.Client{
+		Timeout:   utils.ConfigDuration(cfg, "timeout", 15*time.Second),
+		Transport: e.transport(utils.ConfigBool(cfg, "elasticSkipVerify", false)),
+	}
+
+	var health ClusterHealth
+	start := time.Now()
+	if err := e.get(ctx, client, cfg, baseURL+"/_cluster/health", &health); err != nil {
+		status.FailureCount++
+		status.HealthReport = constants.GetStatusInfo(constants.Degraded, err.Error())
+		return status, fmt.Errorf("cluster health request failed: %v", err)
+	}
+	status.Details["response_time_ms"] = float64(time.Since(start).Microseconds()) / 1000
+
+	status.Details["cluster_name"] = health.ClusterName
+	status.Details["health"] = health.Status
+	status.Details["number_of_nodes"] = health.NumberOfNodes
+	status.Details["number_of_data_nodes"] = health.NumberOfDataNodes
+	status.Details["active_shards"] = health.ActiveShards
+	status.Details["relocating_shards"] = health.RelocatingShards
+	status.Details["initializing_shards"] = health.InitializingShards
+	status.Details["unassigned_shards"] = health.UnassignedShards
+	status.Details["pending_tasks"] = health.NumberOfPendingTasks
+	status.Details["active_shards_percent"] = health.ActiveShardsPercent
+
+	// The cluster color is the baseline, thresholds can only raise it
+	level := constants.HealthColorStatus(health.Status)
+	var problems []string
+	if level != constants.Healthy {
+		problems = append(problems, fmt.Sprintf("cluster %s is %s with %d unassigned shards", health.ClusterName, health.Status, health.UnassignedShards))
+	}
+
+	raise := func(to int, message string) {
+		if to > level {
+			level = to
+		}
+		problems = append(problems, message)
+	}
+
+	if expected := utils.ConfigInt(cfg, "expectedNodes", 0); expected > 0 && health.NumberOfNodes < expected {
+		raise(constants.Degraded, fmt.Sprintf("%d of %d nodes in the cluster", health.NumberOfNodes, expected))
+	}
+	if warn := utils.ConfigInt(cfg, "unassignedShardsWarn", 0); warn > 0 && health.UnassignedShards >= warn {
+		raise(constants.Escalation, fmt.Sprintf("%d unassigned shards (warning %d)", health.UnassignedShards, warn))
+	}
+	if warn := utils.ConfigInt(cfg, "pendingTasksWarn", 0); warn > 0 && health.NumberOfPendingTasks >= warn {
+		raise(constants.Escalation, fmt.Sprintf("%d pending cluster tasks (warning %d)", health.NumberOfPendingTasks, warn))
+	}
+
+	if utils.ConfigBool(cfg, "collectJvmHeap", true) {
+		var stats nodesJVMStats
+		if err := e.get(ctx, client, cfg, baseURL+"/_nodes/stats/jvm", &stats); err != nil {
+			status.Details["jvm_error"] = err.Error()
+		} else {
+			heapWarn := utils.ConfigFloat(cfg, "heapWarnPercent", 85)
+			heapCritical := utils.ConfigFloat(cfg, "heapCriticalPercent", 95)
+
+			heap := make(map[string]float64, len(stats.Nodes))
+			for _, node := range stats.Nodes {
+				used := node.JVM.Mem.HeapUsedPercent
+				heap[node.Name] = used
+
+				switch {
+				case heapCritical > 0 && used >= heapCritical:
+					raise(constants.Degraded, fmt.Sprintf("node %s heap %.0f%% (critical %.0f%%)", node.Name, used, heapCritical))
+				case heapWarn > 0 && used >= heapWarn:
+					raise(constants.Escalation, fmt.Sprintf("node %s heap %.0f%% (warning %.0f%%)", node.Name, used, heapWarn))
+				}
+			}
+			status.Details["jvm_heap_used_percent"] = heap
+		}
+	}
+
+	if level != constants.Healthy {
+		message := strings.Join(problems, "; ")
+		status.FailureCount++
+		status.HealthReport = constants.GetStatusInfo(level, message)
+		return status, fmt.Errorf("elasticsearch check failed: %s", message)
+	}
+
+	status.FailureCount = 0
+	status.HealthReport = constants.GetStatusInfo(constants.Healthy, "")
+	status.LastServiceUpTime = time.Now()
+
+	return status, nil
+}
+
+func (e *ElasticChecker) get(ctx context.Context, client *http.Client, cfg map[string]any, url string, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	if apiKey := utils.ConfigString(cfg, "elasticApiKey", ""); apiKey != "" {
+		req.Header.Set("Authorization", "ApiKey "+apiKey)
+	} else if username := utils.ConfigString(cfg, "elasticUsername", ""); username != "" {
+		req.SetBasicAuth(username, utils.ConfigString(cfg, "elasticPassword", ""))
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// _cluster/health answers 408 when wait_for_* times out but the body is still the health report
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusRequestTimeout {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("unexpected status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+func (e *ElasticChecker) Name() string {
+	return "elastic_check"
+}
+
+func (e *ElasticChecker) Description() string {
+	return "Elasticsearch cluster health, shard allocation and JVM heap monitor"
+}
+
+func (e *ElasticChecker) SupportedTypes() []monitors.ServiceType {
+	return []monitors.ServiceType{
+		monitors.ServiceMonitorDatabase,
+		monitors.ServiceMonitorServer,
+		monitors.ServiceMonitorWebModules,
+	}
+}
+
+func (e *ElasticChecker) Cleanup() error {
+	return nil
+}
+
+var Plugin monitors.ServiceMonitorPlugin = NewElasticChecker()
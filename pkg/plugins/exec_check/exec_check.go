@@ -0,0 +1,400 @@
+package execcheck
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/ZEGIFTED/MS.GoMonitor/monitors"
+	"github.com/ZEGIFTED/MS.GoMonitor/pkg/constants"
+	"github.com/ZEGIFTED/MS.GoMonitor/pkg/utils"
+)
+
+// Nagios plugin return codes
+const (
+	StateOK       = 0
+	StateWarning  = 1
+	StateCritical = 2
+	StateUnknown  = 3
+)
+
+const defaultPath = "/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"
+
+// PerfData is one performance data item: 'label'=value[UOM];[warn];[crit];[min];[max]
+type PerfData struct {
+	Label string   `json:"label"`
+	Value float64  `json:"value"`
+	UOM   string   `json:"uom,omitempty"`
+	Warn  string   `json:"warn,omitempty"`
+	Crit  string   `json:"crit,omitempty"`
+	Min   *float64 `json:"min,omitempty"`
+	Max   *float64 `json:"max,omitempty"`
+}
+
+type ExecPlugin struct {
+	config map[string]any
+}
+
+func NewExecPlugin() *ExecPlugin {
+	return &ExecPlugin{}
+}
+
+func (e *ExecPlugin) Initialize(config map[string]any) error {
+	e.config = config
+	return nil
+}
+
+func (e *ExecPlugin) Check(ctx context.Context, db *sql.DB, service monitors.ServiceMonitorData) (monitors.MonitoringResult, error) {
+	status := monitors.MonitoringResult{
+		SystemMonitorId: service.SystemMonitorId.String(),
+		ServicePluginID: e.Name(),
+		HealthReport:    constants.GetStatusInfo(constants.UnknownStatus, ""),
+		Details:         make(map[string]any),
+		LastCheckTime:   time.Now(),
+	}
+
+	cfg := service.Configuration
+	argv, err := commandLine(cfg)
+	if err != nil {
+		status.FailureCount++
+		status.HealthReport = constants.GetStatusInfo(constants.InvalidConfiguration, err.Error())
+		return status, err
+	}
+
+	timeout := utils.ConfigDuration(cfg, "timeout", 60*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	maxOutput := utils.ConfigInt(cfg, "maxOutputBytes", 4096)
+	output := &limitedBuffer{limit: maxOutput}
+
+	cmd := exec.CommandContext(ctx, argv[0], argv[1:]...)
+	cmd.Dir = utils.ConfigString(cfg, "workingDir", "")
+	cmd.Env = environment(cfg)
+	cmd.Stdout = output
+	cmd.Stderr = output
+	cmd.WaitDelay = 2 * time.Second // Do not wait forever on children that keep the pipes open
+
+	start := time.Now()
+	err = cmd.Run()
+	elapsed := time.Since(start)
+
+	exitCode := StateOK
+	var exitErr *exec.ExitError
+	switch {
+	case ctx.Err() == context.DeadlineExceeded:
+		exitCode = StateUnknown
+		output.WriteString(fmt.Sprintf("\ncommand timed out after %v", timeout))
+	case errors.As(err, &exitErr):
+		exitCode = exitErr.ExitCode()
+	case err != nil:
+		status.FailureCount++
+		status.HealthReport = constants.GetStatusInfo(constants.InvalidConfiguration, err.Error())
+		return status, fmt.Errorf("failed to run %s: %v", argv[0], err)
+	}
+
+	text, perfdata := ParseOutput(output.String())
+
+	status.Details["command"] = strings.Join(argv, " ")
+	status.Details["exit_code"] = exitCode
+	status.Details["output"] = text
+	status.Details["output_truncated"] = output.truncated
+	status.Details["execution_time_ms"] = float64(elapsed.Microseconds()) / 1000
+	if len(perfdata) > 0 {
+		metrics := make(map[string]float64, len(perfdata))
+		for _, p := range perfdata {
+			metrics[p.Label] = p.Value
+		}
+		status.Details["perfdata"] = perfdata
+		status.Details["metrics"] = metrics
+	}
+
+	summary := firstLine(text)
+
+	switch exitCode {
+	case StateOK:
+		status.FailureCount = 0
+		status.HealthReport = constants.GetStatusInfo(constants.Healthy, summary)
+		status.LastServiceUpTime = time.Now()
+		return status, nil
+	case StateWarning:
+		status.HealthReport = constants.GetStatusInfo(constants.Escalation, summary)
+	case StateCritical:
+		status.HealthReport = constants.GetStatusInfo(constants.Degraded, summary)
+	default:
+		status.HealthReport = constants.GetStatusInfo(constants.UnknownStatus, summary)
+	}
+
+	status.FailureCount++
+	return status, fmt.Errorf("command exited with %d: %s", exitCode, summary)
+}
+
+// commandLine builds argv from "command" and "args". Without "useShell" the command string is split
+// into words honouring quotes but no shell features (pipes, globbing, variables) are interpreted.
+func commandLine(cfg map[string]any) ([]string, error) {
+	command := strings.TrimSpace(utils.ConfigString(cfg, "command", ""))
+	if command == "" {
+		command = strings.TrimSpace(utils.ConfigString(cfg, "MonitorCommand", ""))
+	}
+	if command == "" {
+		return nil, fmt.Errorf("command cannot be empty")
+	}
+
+	args := utils.ConfigStringSlice(cfg, "args")
+
+	if utils.ConfigBool(cfg, "useShell", false) {
+		shell := utils.ConfigString(cfg, "shell", "/bin/sh")
+		return append([]string{shell, "-c", command, "--"}, args...), nil
+	}
+
+	argv, err := splitWords(command)
+	if err != nil {
+		return nil, err
+	}
+	return append(argv, args...), nil
+}
+
+// splitWords splits a command on whitespace, single and double quotes group words and backslash escapes
+func splitWords(s string) ([]string, error) {
+	var words []string
+	var current strings.Builder
+	inWord := false
+	var quote rune
+	escaped := false
+
+	for _, r := range s {
+		switch {
+		case escaped:
+			current.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped = true
+			inWord = true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				current.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inWord = true
+		case unicode.IsSpace(r):
+			if inWord {
+				words = append(words, current.String())
+				current.Reset()
+				inWord = false
+			}
+		default:
+			current.WriteRune(r)
+			inWord = true
+		}
+	}
+
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated quote in command")
+	}
+	if inWord {
+		words = append(words, current.String())
+	}
+	return words, nil
+}
+
+// environment returns a minimal environment like the Nagios daemon provides, extended with EnvironmentVars.
+// The engine's own environment (database credentials, tokens) is only passed on when explicitly requested.
+func environment(cfg map[string]any) []string {
+	env := map[string]string{"PATH": defaultPath, "LANG": "C"}
+	if utils.ConfigBool(cfg, "inheritEnvironment", false) {
+		for _, kv := range os.Environ() {
+			if k, v, ok := strings.Cut(kv, "="); ok {
+				env[k] = v
+			}
+		}
+	}
+
+	// EnvironmentVars is stored as a JSON object, either embedded or as a string
+	vars := utils.ConfigMap(cfg, "EnvironmentVars")
+	if raw, ok := cfg["EnvironmentVars"].(string); ok && vars == nil {
+		_ = json.Unmarshal([]byte(raw), &vars)
+	}
+	for k, v := range vars {
+		env[k] = fmt.Sprint(v)
+	}
+
+	list := make([]string, 0, len(env))
+	for k, v := range env {
+		list = append(list, k+"="+v)
+	}
+	return list
+}
+
+// ParseOutput separates the human readable output from the performance data.
+// Nagios allows perfdata after "|" on the first line and after a "|" in the long output.
+func ParseOutput(output string) (string, []PerfData) {
+	lines := strings.Split(strings.TrimRight(output, "\n"), "\n")
+
+	var text []string
+	var perf []string
+
+	first, firstPerf, _ := strings.Cut(lines[0], "|")
+	text = append(text, strings.TrimSpace(first))
+	perf = append(perf, firstPerf)
+
+	inPerf := false
+	for _, line := range lines[1:] {
+		if inPerf {
+			perf = append(perf, line)
+			continue
+		}
+		if before, after, found := strings.Cut(line, "|"); found {
+			text = append(text, before)
+			perf = append(perf, after)
+			inPerf = true
+			continue
+		}
+		text = append(text, line)
+	}
+
+	return strings.TrimSpace(strings.Join(text, "\n")), ParsePerfData(strings.Join(perf, " "))
+}
+
+// ParsePerfData parses space separated perfdata items, malformed items are skipped
+func ParsePerfData(s string) []PerfData {
+	var items []PerfData
+
+	for _, token := range splitPerfTokens(s) {
+		label, rest, ok := strings.Cut(token, "=")
+		if !ok {
+			continue
+		}
+		label = strings.Trim(label, "'")
+
+		fields := strings.Split(rest, ";")
+		value, uom := splitUOM(fields[0])
+		v, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			continue
+		}
+
+		item := PerfData{Label: label, Value: v, UOM: uom}
+		if len(fields) > 1 {
+			item.Warn = fields[1]
+		}
+		if len(fields) > 2 {
+			item.Crit = fields[2]
+		}
+		if len(fields) > 3 {
+			if f, err := strconv.ParseFloat(fields[3], 64); err == nil {
+				item.Min = &f
+			}
+		}
+		if len(fields) > 4 {
+			if f, err := strconv.ParseFloat(fields[4], 64); err == nil {
+				item.Max = &f
+			}
+		}
+		items = append(items, item)
+	}
+
+	return items
+}
+
+// splitPerfTokens splits on whitespace outside single quoted labels
+func splitPerfTokens(s string) []string {
+	var tokens []string
+	var current strings.Builder
+	quoted := false
+
+	for _, r := range s {
+		switch {
+		case r == '\'':
+			quoted = !quoted
+			current.WriteRune(r)
+		case unicode.IsSpace(r) && !quoted:
+			if current.Len() > 0 {
+				tokens = append(tokens, current.String())
+				current.Reset()
+			}
+		default:
+			current.WriteRune(r)
+		}
+	}
+	if current.Len() > 0 {
+		tokens = append(tokens, current.String())
+	}
+	return tokens
+}
+
+func splitUOM(s string) (string, string) {
+	i := strings.LastIndexFunc(s, func(r rune) bool {
+		return unicode.IsDigit(r) || r == '.'
+	})
+	return s[:i+1], s[i+1:]
+}
+
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(s, "\n")
+	return line
+}
+
+// limitedBuffer keeps the first limit bytes written and drops the rest
+type limitedBuffer struct {
+	buf       []byte
+	limit     int
+	truncated bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	remaining := b.limit - len(b.buf)
+	if remaining <= 0 {
+		b.truncated = b.truncated || len(p) > 0
+		return len(p), nil
+	}
+	if len(p) > remaining {
+		b.buf = append(b.buf, p[:remaining]...)
+		b.truncated = true
+		return len(p), nil
+	}
+	b.buf = append(b.buf, p...)
+	return len(p), nil
+}
+
+func (b *limitedBuffer) WriteString(s string) (int, error) {
+	return b.Write([]byte(s))
+}
+
+func (b *limitedBuffer) String() string {
+	return string(b.buf)
+}
+
+func (e *ExecPlugin) Name() string {
+	return "exec"
+}
+
+func (e *ExecPlugin) Description() string {
+	return "Runs a command following the Nagios plugin conventions (exit code and perfdata)"
+}
+
+func (e *ExecPlugin) SupportedTypes() []monitors.ServiceType {
+	return []monitors.ServiceType{
+		monitors.ServiceMonitorServer,
+		monitors.ServiceMonitorAgent,
+		monitors.ServiceMonitorDatabase,
+		monitors.ServiceMonitorWebModules,
+		monitors.ServiceMonitorSNMP,
+	}
+}
+
+func (e *ExecPlugin) Cleanup() error {
+	return nil
+}
+
+var Plugin monitors.ServiceMonitorPlugin = NewExecPlugin()
@@ -0,0 +1,221 @@
+package grpchealth
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"database/sql"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/ZEGIFTED/MS.GoMonitor/monitors"
+	"github.com/ZEGIFTED/MS.GoMonitor/pkg/constants"
+	"github.com/ZEGIFTED/MS.GoMonitor/pkg/utils"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+type GRPCHealthChecker struct {
+	config map[string]any
+}
+
+func NewGRPCHealthChecker() *GRPCHealthChecker {
+	return &GRPCHealthChecker{}
+}
+
+func (g *GRPCHealthChecker) Initialize(config map[string]any) error {
+	g.config = config
+	return nil
+}
+
+func (g *GRPCHealthChecker) Check(ctx context.Context, db *sql.DB, service monitors.ServiceMonitorData) (monitors.MonitoringResult, error) {
+	result := monitors.MonitoringResult{
+		SystemMonitorId: service.SystemMonitorId.String(),
+		ServicePluginID: g.Name(),
+		HealthReport:    constants.GetStatusInfo(constants.UnknownStatus, ""),
+		Details:         make(map[string]any),
+		LastCheckTime:   time.Now(),
+	}
+
+	cfg := service.Configuration
+	if service.Host == "" {
+		result.FailureCount++
+		result.HealthReport = constants.GetStatusInfo(constants.InvalidConfiguration, "")
+		return result, fmt.Errorf("host cannot be empty")
+	}
+
+	port := service.Port
+	if port == 0 {
+		port = 50051
+	}
+	target := net.JoinHostPort(service.Host, strconv.Itoa(port))
+
+	creds, err := transportCredentials(cfg, service.Host)
+	if err != nil {
+		result.FailureCount++
+		result.HealthReport = constants.GetStatusInfo(constants.InvalidConfiguration, err.Error())
+		return result, err
+	}
+
+	conn, err := grpc.NewClient(target, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		result.FailureCount++
+		result.HealthReport = constants.GetStatusInfo(constants.InvalidConfiguration, err.Error())
+		return result, fmt.Errorf("invalid gRPC target %s: %v", target, err)
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(ctx, utils.ConfigDuration(cfg, "timeout", 10*time.Second))
+	defer cancel()
+
+	// Auth tokens and routing headers are sent as request metadata
+	md := metadata.MD{}
+	for k, v := range utils.ConfigMap(cfg, "grpcMetadata") {
+		md.Append(k, fmt.Sprint(v))
+	}
+	if len(md) > 0 {
+		ctx = metadata.NewOutgoingContext(ctx, md)
+	}
+
+	serviceName := utils.ConfigString(cfg, "grpcService", "")
+	request := &healthpb.HealthCheckRequest{Service: serviceName}
+	client := healthpb.NewHealthClient(conn)
+	useWatch := utils.ConfigBool(cfg, "useWatch", false)
+
+	var serving healthpb.HealthCheckResponse_ServingStatus
+	start := time.Now()
+	if useWatch {
+		serving, err = watchOnce(ctx, client, request)
+	} else {
+		var resp *healthpb.HealthCheckResponse
+		resp, err = client.Check(ctx, request)
+		if resp != nil {
+			serving = resp.GetStatus()
+		}
+	}
+	latency := time.Since(start)
+
+	result.Details["target"] = target
+	result.Details["grpc_service"] = serviceName
+	result.Details["method"] = map[bool]string{true: "Watch", false: "Check"}[useWatch]
+	result.Details["rpc_latency_ms"] = float64(latency.Microseconds()) / 1000
+
+	if err != nil {
+		result.FailureCount++
+		code := status.Code(err)
+		result.Details["grpc_code"] = code.String()
+
+		switch code {
+		case codes.NotFound:
+			// The server does not know the configured service name
+			result.HealthReport = constants.GetStatusInfo(constants.InvalidConfiguration, status.Convert(err).Message())
+		case codes.Unimplemented:
+			result.HealthReport = constants.GetStatusInfo(constants.InvalidConfiguration, "server does not implement grpc.health.v1.Health")
+		case codes.Unauthenticated, codes.PermissionDenied:
+			result.HealthReport = constants.GetStatusInfo(constants.Escalation, status.Convert(err).Message())
+		default:
+			result.HealthReport = constants.GetStatusInfo(constants.Degraded, status.Convert(err).Message())
+		}
+		return result, fmt.Errorf("health %s on %s failed: %v", result.Details["method"], target, err)
+	}
+
+	result.Details["serving_status"] = serving.String()
+
+	switch serving {
+	case healthpb.HealthCheckResponse_SERVING:
+		result.FailureCount = 0
+		result.HealthReport = constants.GetStatusInfo(constants.Healthy, "")
+		result.LastServiceUpTime = time.Now()
+		return result, nil
+	case healthpb.HealthCheckResponse_NOT_SERVING:
+		result.HealthReport = constants.GetStatusInfo(constants.Degraded, "")
+	case healthpb.HealthCheckResponse_SERVICE_UNKNOWN:
+		result.HealthReport = constants.GetStatusInfo(constants.InvalidConfiguration, "")
+	default:
+		result.HealthReport = constants.GetStatusInfo(constants.UnknownStatus, "")
+	}
+
+	result.FailureCount++
+	return result, fmt.Errorf("service %q on %s reports %s", serviceName, target, serving)
+}
+
+// watchOnce opens a Watch stream and returns the first status the server sends, which is its current state
+func watchOnce(ctx context.Context, client healthpb.HealthClient, request *healthpb.HealthCheckRequest) (healthpb.HealthCheckResponse_ServingStatus, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	stream, err := client.Watch(ctx, request)
+	if err != nil {
+		return healthpb.HealthCheckResponse_UNKNOWN, err
+	}
+
+	resp, err := stream.Recv()
+	if err != nil {
+		return healthpb.HealthCheckResponse_UNKNOWN, err
+	}
+	return resp.GetStatus(), nil
+}
+
+func transportCredentials(cfg map[string]any, host string) (credentials.TransportCredentials, error) {
+	if !utils.ConfigBool(cfg, "grpcTLS", false) {
+		return insecure.NewCredentials(), nil
+	}
+
+	tlsConfig := &tls.Config{
+		ServerName:         utils.ConfigString(cfg, "grpcServerName", host),
+		InsecureSkipVerify: utils.ConfigBool(cfg, "grpcSkipVerify", false),
+	}
+
+	if caFile := utils.ConfigString(cfg, "grpcCAFile", ""); caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", caFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	certFile := utils.ConfigString(cfg, "grpcCertFile", "")
+	keyFile := utils.ConfigString(cfg, "grpcKeyFile", "")
+	if certFile != "" && keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %v", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return credentials.NewTLS(tlsConfig), nil
+}
+
+func (g *GRPCHealthChecker) Name() string {
+	return "grpc_health"
+}
+
+func (g *GRPCHealthChecker) Description() string {
+	return "Checks services implementing the gRPC health checking protocol"
+}
+
+func (g *GRPCHealthChecker) SupportedTypes() []monitors.ServiceType {
+	return []monitors.ServiceType{
+		monitors.ServiceMonitorWebModules,
+		monitors.ServiceMonitorServer,
+	}
+}
+
+func (g *GRPCHealthChecker) Cleanup() error {
+	return nil
+}
+
+var Plugin monitors.ServiceMonitorPlugin = NewGRPCHealthChecker()
@@ -0,0 +1,364 @@
+package kafkacheck
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ZEGIFTED/MS.GoMonitor/monitors"
+	"github.com/ZEGIFTED/MS.GoMonitor/pkg/constants"
+	"github.com/ZEGIFTED/MS.GoMonitor/pkg/utils"
+)
+
+// GroupLag is the consumer lag of a group summed over its partitions
+type GroupLag struct {
+	Group           string `json:"group"`
+	TotalLag        int64  `json:"total_lag"`
+	MaxPartitionLag int64  `json:"max_partition_lag"`
+	Partitions      int    `json:"partitions"`
+}
+
+type KafkaChecker struct {
+	config map[string]any
+}
+
+func NewKafkaChecker() *KafkaChecker {
+	return &KafkaChecker{}
+}
+
+func (k *KafkaChecker) Initialize(config map[string]any) error {
+	k.config = config
+	return nil
+}
+
+func (k *KafkaChecker) Check(ctx context.Context, db *sql.DB, service monitors.ServiceMonitorData) (monitors.MonitoringResult, error) {
+	status := monitors.MonitoringResult{
+		SystemMonitorId: service.SystemMonitorId.String(),
+		ServicePluginID: k.Name(),
+		HealthReport:    constants.GetStatusInfo(constants.UnknownStatus, ""),
+		Details:         make(map[string]any),
+		LastCheckTime:   time.Now(),
+	}
+
+	cfg := service.Configuration
+	bootstrap := utils.ConfigStringSlice(cfg, "brokers")
+	if len(bootstrap) == 0 && service.Host != "" {
+		port := service.Port
+		if port == 0 {
+			port = 9092
+		}
+		bootstrap = []string{net.JoinHostPort(service.Host, strconv.Itoa(port))}
+	}
+
+	if len(bootstrap) == 0 {
+		status.FailureCount++
+		status.HealthReport = constants.GetStatusInfo(constants.InvalidConfiguration, "")
+		return status, fmt.Errorf("host cannot be empty")
+	}
+
+	timeout := utils.ConfigDuration(cfg, "timeout", 15*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	opts := DialOptions{
+		Timeout:      timeout,
+		TLS:          utils.ConfigBool(cfg, "kafkaTLS", false),
+		SkipVerify:   utils.ConfigBool(cfg, "kafkaSkipVerify", false),
+		SASLUsername: utils.ConfigString(cfg, "saslUsername", ""),
+		SASLPassword: utils.ConfigString(cfg, "saslPassword", ""),
+	}
+
+	pool := &brokerPool{ctx: ctx, opts: opts, conns: make(map[string]*brokerConn)}
+	defer pool.Close()
+
+	start := time.Now()
+	md, conn, err := pool.bootstrap(bootstrap)
+	if err != nil {
+		status.FailureCount++
+		status.HealthReport = constants.GetStatusInfo(constants.Degraded, err.Error())
+		return status, fmt.Errorf("kafka metadata request failed: %v", err)
+	}
+	status.Details["metadata_latency_ms"] = float64(time.Since(start).Microseconds()) / 1000
+
+	var partitions, underReplicated, offline int
+	var offlinePartitions, underReplicatedPartitions []string
+	for _, topic := range md.Topics {
+		for _, p := range topic.Partitions {
+			partitions++
+			name := fmt.Sprintf("%s/%d", topic.Name, p.ID)
+
+			if p.Leader < 0 || p.ErrorCode == 5 {
+				offline++
+				offlinePartitions = append(offlinePartitions, name)
+				continue
+			}
+			if len(p.ISR) < len(p.Replicas) {
+				underReplicated++
+				underReplicatedPartitions = append(underReplicatedPartitions, name)
+			}
+		}
+	}
+
+	status.Details["brokers"] = len(md.Brokers)
+	status.Details["controller_id"] = md.ControllerID
+	status.Details["topics"] = len(md.Topics)
+	status.Details["partitions"] = partitions
+	status.Details["under_replicated_partitions"] = underReplicated
+	status.Details["offline_partitions"] = offline
+
+	var red, yellow []string
+
+	if md.ControllerID < 0 {
+		red = append(red, "no active controller")
+	}
+	if minBrokers := utils.ConfigInt(cfg, "minBrokers", 0); minBrokers > 0 && len(md.Brokers) < minBrokers {
+		red = append(red, fmt.Sprintf("%d of %d brokers available", len(md.Brokers), minBrokers))
+	}
+	if offline > 0 {
+		red = append(red, fmt.Sprintf("%d offline partitions (%s)", offline, truncateList(offlinePartitions, 5)))
+	}
+	if warn := utils.ConfigInt(cfg, "underReplicatedWarn", 1); warn > 0 && underReplicated >= warn {
+		yellow = append(yellow, fmt.Sprintf("%d under-replicated partitions (%s)", underReplicated, truncateList(underReplicatedPartitions, 5)))
+	}
+
+	if groups := utils.ConfigStringSlice(cfg, "consumerGroups"); len(groups) > 0 {
+		topics := utils.ConfigStringSlice(cfg, "consumerTopics")
+		lagWarn := int64(utils.ConfigInt(cfg, "consumerLagWarn", 1000))
+		lagCritical := int64(utils.ConfigInt(cfg, "consumerLagCritical", 0))
+
+		lags := make([]GroupLag, 0, len(groups))
+		for _, group := range groups {
+			lag, err := pool.groupLag(conn, md, group, topics)
+			if err != nil {
+				yellow = append(yellow, fmt.Sprintf("consumer group %s: %v", group, err))
+				continue
+			}
+			lags = append(lags, lag)
+
+			switch {
+			case lagCritical > 0 && lag.TotalLag >= lagCritical:
+				red = append(red, fmt.Sprintf("consumer group %s lag %d (critical %d)", group, lag.TotalLag, lagCritical))
+			case lagWarn > 0 && lag.TotalLag >= lagWarn:
+				yellow = append(yellow, fmt.Sprintf("consumer group %s lag %d (warning %d)", group, lag.TotalLag, lagWarn))
+			}
+		}
+		status.Details["consumer_lag"] = lags
+	}
+
+	color := "green"
+	problems := yellow
+	switch {
+	case len(red) > 0:
+		color = "red"
+		problems = append(red, yellow...)
+	case len(yellow) > 0:
+		color = "yellow"
+	}
+	status.Details["health"] = color
+
+	if level := constants.HealthColorStatus(color); level != constants.Healthy {
+		message := strings.Join(problems, "; ")
+		status.FailureCount++
+		status.HealthReport = constants.GetStatusInfo(level, message)
+		return status, fmt.Errorf("kafka health %s: %s", color, message)
+	}
+
+	status.FailureCount = 0
+	status.HealthReport = constants.GetStatusInfo(constants.Healthy, "")
+	status.LastServiceUpTime = time.Now()
+
+	return status, nil
+}
+
+// brokerPool keeps one connection per broker for the duration of a check
+type brokerPool struct {
+	ctx     context.Context
+	opts    DialOptions
+	conns   map[string]*brokerConn
+	offsets map[string]map[int32]int64 // Log end offsets, fetched once per check
+}
+
+func (p *brokerPool) get(address string) (*brokerConn, error) {
+	if conn, ok := p.conns[address]; ok {
+		return conn, nil
+	}
+
+	conn, err := dialBroker(p.ctx, address, p.opts)
+	if err != nil {
+		return nil, err
+	}
+	p.conns[address] = conn
+	return conn, nil
+}
+
+// bootstrap returns the cluster metadata from the first bootstrap broker that answers
+func (p *brokerPool) bootstrap(addresses []string) (Metadata, *brokerConn, error) {
+	var errs []string
+	for _, address := range addresses {
+		conn, err := p.get(address)
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+
+		md, err := conn.Metadata()
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", address, err))
+			continue
+		}
+		return md, conn, nil
+	}
+
+	return Metadata{}, nil, fmt.Errorf("no bootstrap broker answered: %s", strings.Join(errs, "; "))
+}
+
+// groupLag compares the committed offsets of group with the log end offsets of the consumed partitions
+func (p *brokerPool) groupLag(conn *brokerConn, md Metadata, group string, topics []string) (GroupLag, error) {
+	lag := GroupLag{Group: group}
+
+	coordinator, err := conn.FindCoordinator(group)
+	if err != nil {
+		return lag, fmt.Errorf("coordinator lookup failed: %v", err)
+	}
+
+	coordinatorConn, err := p.get(coordinator.Address())
+	if err != nil {
+		return lag, err
+	}
+
+	partitions := make(map[string][]int32)
+	for _, topic := range md.Topics {
+		if topic.Internal || (len(topics) > 0 && !slices.Contains(topics, topic.Name)) {
+			continue
+		}
+		for _, partition := range topic.Partitions {
+			partitions[topic.Name] = append(partitions[topic.Name], partition.ID)
+		}
+	}
+
+	committed, err := coordinatorConn.OffsetFetch(group, partitions)
+	if err != nil {
+		return lag, fmt.Errorf("offset fetch failed: %v", err)
+	}
+
+	if err := p.loadEndOffsets(md); err != nil {
+		return lag, err
+	}
+
+	for topic, offsets := range committed {
+		for partition, offset := range offsets {
+			if offset < 0 {
+				continue // Nothing committed, the group does not consume this partition
+			}
+
+			end, ok := p.offsets[topic][partition]
+			if !ok {
+				continue
+			}
+
+			partitionLag := max(end-offset, 0)
+			lag.TotalLag += partitionLag
+			lag.MaxPartitionLag = max(lag.MaxPartitionLag, partitionLag)
+			lag.Partitions++
+		}
+	}
+
+	return lag, nil
+}
+
+// loadEndOffsets asks every partition leader for the latest offsets of the partitions it leads
+func (p *brokerPool) loadEndOffsets(md Metadata) error {
+	if p.offsets != nil {
+		return nil
+	}
+
+	brokers := make(map[int32]Broker, len(md.Brokers))
+	for _, b := range md.Brokers {
+		brokers[b.ID] = b
+	}
+
+	byLeader := make(map[int32]map[string][]int32)
+	for _, topic := range md.Topics {
+		if topic.Internal {
+			continue
+		}
+		for _, partition := range topic.Partitions {
+			if partition.Leader < 0 {
+				continue
+			}
+			if byLeader[partition.Leader] == nil {
+				byLeader[partition.Leader] = make(map[string][]int32)
+			}
+			byLeader[partition.Leader][topic.Name] = append(byLeader[partition.Leader][topic.Name], partition.ID)
+		}
+	}
+
+	offsets := make(map[string]map[int32]int64)
+	for leader, partitions := range byLeader {
+		broker, ok := brokers[leader]
+		if !ok {
+			continue
+		}
+
+		conn, err := p.get(broker.Address())
+		if err != nil {
+			return err
+		}
+
+		latest, err := conn.LatestOffsets(partitions)
+		if err != nil {
+			return fmt.Errorf("list offsets on broker %d failed: %v", leader, err)
+		}
+
+		for topic, partitionOffsets := range latest {
+			if offsets[topic] == nil {
+				offsets[topic] = make(map[int32]int64)
+			}
+			for partition, offset := range partitionOffsets {
+				offsets[topic][partition] = offset
+			}
+		}
+	}
+
+	p.offsets = offsets
+	return nil
+}
+
+func (p *brokerPool) Close() {
+	for _, conn := range p.conns {
+		conn.Close()
+	}
+}
+
+func truncateList(items []string, limit int) string {
+	if len(items) <= limit {
+		return strings.Join(items, ", ")
+	}
+	return fmt.Sprintf("%s and %d more", strings.Join(items[:limit], ", "), len(items)-limit)
+}
+
+func (k *KafkaChecker) Name() string {
+	return "kafka_check"
+}
+
+func (k *KafkaChecker) Description() string {
+	return "Kafka broker metadata, partition replication and consumer group lag monitor"
+}
+
+func (k *KafkaChecker) SupportedTypes() []monitors.ServiceType {
+	return []monitors.ServiceType{
+		monitors.ServiceMonitorServer,
+		monitors.ServiceMonitorDatabase,
+	}
+}
+
+func (k *KafkaChecker) Cleanup() error {
+	return nil
+}
+
+var Plugin monitors.ServiceMonitorPlugin = NewKafkaChecker()
@@ -0,0 +1,441 @@
+package kafkacheck
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"time"
+)
+
+// Kafka API keys and the versions spoken by the checker. The versions are old enough to be
+// supported by every broker since 0.10 and avoid the flexible (tagged field) encodings.
+const (
+	apiListOffsets      int16 = 2
+	apiMetadata         int16 = 3
+	apiOffsetFetch      int16 = 9
+	apiFindCoordinator  int16 = 10
+	apiSaslHandshake    int16 = 17
+	apiSaslAuthenticate int16 = 36
+)
+
+const clientID = "ms-monitor"
+
+// Broker is a node of the cluster as returned by Metadata
+type Broker struct {
+	ID   int32
+	Host string
+	Port int32
+}
+
+func (b Broker) Address() string {
+	return net.JoinHostPort(b.Host, fmt.Sprint(b.Port))
+}
+
+// PartitionMetadata is the leadership and replica state of a partition
+type PartitionMetadata struct {
+	ErrorCode int16
+	ID        int32
+	Leader    int32
+	Replicas  []int32
+	ISR       []int32
+}
+
+type TopicMetadata struct {
+	ErrorCode  int16
+	Name       string
+	Internal   bool
+	Partitions []PartitionMetadata
+}
+
+type Metadata struct {
+	Brokers      []Broker
+	ControllerID int32
+	Topics       []TopicMetadata
+}
+
+// DialOptions control how broker connections are established
+type DialOptions struct {
+	Timeout      time.Duration
+	TLS          bool
+	SkipVerify   bool
+	SASLUsername string // SASL/PLAIN credentials, empty disables authentication
+	SASLPassword string
+}
+
+// brokerConn is a connection to a single broker exchanging one request at a time
+type brokerConn struct {
+	conn          net.Conn
+	correlationID int32
+}
+
+func dialBroker(ctx context.Context, address string, opts DialOptions) (*brokerConn, error) {
+	dialer := &net.Dialer{Timeout: opts.Timeout}
+
+	var conn net.Conn
+	var err error
+	if opts.TLS {
+		host, _, _ := net.SplitHostPort(address)
+		tlsDialer := &tls.Dialer{NetDialer: dialer, Config: &tls.Config{ServerName: host, InsecureSkipVerify: opts.SkipVerify}}
+		conn, err = tlsDialer.DialContext(ctx, "tcp", address)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", address)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("connection to broker %s failed: %v", address, err)
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	bc := &brokerConn{conn: conn}
+	if opts.SASLUsername != "" {
+		if err := bc.authenticatePlain(opts.SASLUsername, opts.SASLPassword); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("SASL authentication with %s failed: %v", address, err)
+		}
+	}
+
+	return bc, nil
+}
+
+func (b *brokerConn) Close() error {
+	return b.conn.Close()
+}
+
+// roundTrip frames a request, sends it and returns the response body after the correlation id
+func (b *brokerConn) roundTrip(apiKey, apiVersion int16, body []byte) (*decoder, error) {
+	b.correlationID++
+
+	header := &encoder{}
+	header.int16(apiKey)
+	header.int16(apiVersion)
+	header.int32(b.correlationID)
+	header.string(clientID)
+
+	frame := &encoder{}
+	frame.int32(int32(len(header.buf) + len(body)))
+	frame.buf = append(frame.buf, header.buf...)
+	frame.buf = append(frame.buf, body...)
+
+	if _, err := b.conn.Write(frame.buf); err != nil {
+		return nil, err
+	}
+
+	sizeBuf := make([]byte, 4)
+	if _, err := io.ReadFull(b.conn, sizeBuf); err != nil {
+		return nil, err
+	}
+
+	size := int32(binary.BigEndian.Uint32(sizeBuf))
+	if size < 4 || size > 64<<20 {
+		return nil, fmt.Errorf("invalid response size %d", size)
+	}
+
+	response := make([]byte, size)
+	if _, err := io.ReadFull(b.conn, response); err != nil {
+		return nil, err
+	}
+
+	d := &decoder{buf: response}
+	if id := d.int32(); id != b.correlationID {
+		return nil, fmt.Errorf("correlation id mismatch: got %d, want %d", id, b.correlationID)
+	}
+
+	return d, nil
+}
+
+func (b *brokerConn) authenticatePlain(username, password string) error {
+	req := &encoder{}
+	req.string("PLAIN")
+	d, err := b.roundTrip(apiSaslHandshake, 1, req.buf)
+	if err != nil {
+		return err
+	}
+	if code := d.int16(); code != 0 {
+		return kafkaError(code)
+	}
+
+	req = &encoder{}
+	req.bytes([]byte("\x00" + username + "\x00" + password))
+	d, err = b.roundTrip(apiSaslAuthenticate, 0, req.buf)
+	if err != nil {
+		return err
+	}
+	code := d.int16()
+	message := d.nullableString()
+	if code != 0 {
+		return fmt.Errorf("%v: %s", kafkaError(code), message)
+	}
+
+	return d.err
+}
+
+// Metadata requests the brokers and every topic of the cluster (Metadata v1)
+func (b *brokerConn) Metadata() (Metadata, error) {
+	req := &encoder{}
+	req.int32(-1) // null array, all topics
+
+	d, err := b.roundTrip(apiMetadata, 1, req.buf)
+	if err != nil {
+		return Metadata{}, err
+	}
+
+	var md Metadata
+	for i, n := 0, d.arrayLen(12); i < n; i++ {
+		broker := Broker{ID: d.int32(), Host: d.string(), Port: d.int32()}
+		d.nullableString() // rack
+		md.Brokers = append(md.Brokers, broker)
+	}
+
+	md.ControllerID = d.int32()
+
+	for i, n := 0, d.arrayLen(9); i < n; i++ {
+		topic := TopicMetadata{ErrorCode: d.int16(), Name: d.string(), Internal: d.bool()}
+		for j, m := 0, d.arrayLen(18); j < m; j++ {
+			topic.Partitions = append(topic.Partitions, PartitionMetadata{
+				ErrorCode: d.int16(),
+				ID:        d.int32(),
+				Leader:    d.int32(),
+				Replicas:  d.int32Array(),
+				ISR:       d.int32Array(),
+			})
+		}
+		md.Topics = append(md.Topics, topic)
+	}
+
+	return md, d.err
+}
+
+// FindCoordinator returns the broker coordinating a consumer group (FindCoordinator v0)
+func (b *brokerConn) FindCoordinator(group string) (Broker, error) {
+	req := &encoder{}
+	req.string(group)
+
+	d, err := b.roundTrip(apiFindCoordinator, 0, req.buf)
+	if err != nil {
+		return Broker{}, err
+	}
+
+	code := d.int16()
+	coordinator := Broker{ID: d.int32(), Host: d.string(), Port: d.int32()}
+	if code != 0 {
+		return Broker{}, kafkaError(code)
+	}
+
+	return coordinator, d.err
+}
+
+// OffsetFetch returns the committed offsets of a group (OffsetFetch v1), -1 marks partitions without a commit
+func (b *brokerConn) OffsetFetch(group string, partitions map[string][]int32) (map[string]map[int32]int64, error) {
+	req := &encoder{}
+	req.string(group)
+	req.int32(int32(len(partitions)))
+	for topic, ids := range partitions {
+		req.string(topic)
+		req.int32Array(ids)
+	}
+
+	d, err := b.roundTrip(apiOffsetFetch, 1, req.buf)
+	if err != nil {
+		return nil, err
+	}
+
+	offsets := make(map[string]map[int32]int64)
+	for i, n := 0, d.arrayLen(6); i < n; i++ {
+		topic := d.string()
+		offsets[topic] = make(map[int32]int64)
+		for j, m := 0, d.arrayLen(16); j < m; j++ {
+			partition := d.int32()
+			offset := d.int64()
+			d.nullableString() // metadata
+			if code := d.int16(); code != 0 {
+				return nil, fmt.Errorf("%s/%d: %v", topic, partition, kafkaError(code))
+			}
+			offsets[topic][partition] = offset
+		}
+	}
+
+	return offsets, d.err
+}
+
+// LatestOffsets returns the log end offsets of partitions led by this broker (ListOffsets v1)
+func (b *brokerConn) LatestOffsets(partitions map[string][]int32) (map[string]map[int32]int64, error) {
+	req := &encoder{}
+	req.int32(-1) // replica id, consumer
+	req.int32(int32(len(partitions)))
+	for topic, ids := range partitions {
+		req.string(topic)
+		req.int32(int32(len(ids)))
+		for _, id := range ids {
+			req.int32(id)
+			req.int64(-1) // latest
+		}
+	}
+
+	d, err := b.roundTrip(apiListOffsets, 1, req.buf)
+	if err != nil {
+		return nil, err
+	}
+
+	offsets := make(map[string]map[int32]int64)
+	for i, n := 0, d.arrayLen(6); i < n; i++ {
+		topic := d.string()
+		offsets[topic] = make(map[int32]int64)
+		for j, m := 0, d.arrayLen(22); j < m; j++ {
+			partition := d.int32()
+			code := d.int16()
+			d.int64() // timestamp
+			offset := d.int64()
+			if code != 0 {
+				return nil, fmt.Errorf("%s/%d: %v", topic, partition, kafkaError(code))
+			}
+			offsets[topic][partition] = offset
+		}
+	}
+
+	return offsets, d.err
+}
+
+// kafkaErrors names the error codes a health check is likely to meet
+var kafkaErrors = map[int16]string{
+	3:  "UNKNOWN_TOPIC_OR_PARTITION",
+	5:  "LEADER_NOT_AVAILABLE",
+	6:  "NOT_LEADER_FOR_PARTITION",
+	7:  "REQUEST_TIMED_OUT",
+	14: "COORDINATOR_LOAD_IN_PROGRESS",
+	15: "COORDINATOR_NOT_AVAILABLE",
+	16: "NOT_COORDINATOR",
+	29: "TOPIC_AUTHORIZATION_FAILED",
+	30: "GROUP_AUTHORIZATION_FAILED",
+	31: "CLUSTER_AUTHORIZATION_FAILED",
+	33: "UNSUPPORTED_SASL_MECHANISM",
+	35: "UNSUPPORTED_VERSION",
+	58: "SASL_AUTHENTICATION_FAILED",
+}
+
+func kafkaError(code int16) error {
+	if name, ok := kafkaErrors[code]; ok {
+		return fmt.Errorf("kafka error %d %s", code, name)
+	}
+	return fmt.Errorf("kafka error %d", code)
+}
+
+type encoder struct {
+	buf []byte
+}
+
+func (e *encoder) int16(v int16) {
+	e.buf = binary.BigEndian.AppendUint16(e.buf, uint16(v))
+}
+
+func (e *encoder) int32(v int32) {
+	e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(v))
+}
+
+func (e *encoder) int64(v int64) {
+	e.buf = binary.BigEndian.AppendUint64(e.buf, uint64(v))
+}
+
+func (e *encoder) string(s string) {
+	e.int16(int16(len(s)))
+	e.buf = append(e.buf, s...)
+}
+
+func (e *encoder) bytes(b []byte) {
+	e.int32(int32(len(b)))
+	e.buf = append(e.buf, b...)
+}
+
+func (e *encoder) int32Array(values []int32) {
+	e.int32(int32(len(values)))
+	for _, v := range values {
+		e.int32(v)
+	}
+}
+
+// decoder reads big endian primitives, the first short read is kept in err and later reads return zero values
+type decoder struct {
+	buf []byte
+	off int
+	err error
+}
+
+func (d *decoder) take(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if n < 0 || d.off+n > len(d.buf) {
+		d.err = io.ErrUnexpectedEOF
+		return nil
+	}
+	b := d.buf[d.off : d.off+n]
+	d.off += n
+	return b
+}
+
+func (d *decoder) int16() int16 {
+	if b := d.take(2); b != nil {
+		return int16(binary.BigEndian.Uint16(b))
+	}
+	return 0
+}
+
+func (d *decoder) int32() int32 {
+	if b := d.take(4); b != nil {
+		return int32(binary.BigEndian.Uint32(b))
+	}
+	return 0
+}
+
+func (d *decoder) int64() int64 {
+	if b := d.take(8); b != nil {
+		return int64(binary.BigEndian.Uint64(b))
+	}
+	return 0
+}
+
+func (d *decoder) bool() bool {
+	if b := d.take(1); b != nil {
+		return b[0] != 0
+	}
+	return false
+}
+
+func (d *decoder) string() string {
+	n := d.int16()
+	if n < 0 {
+		return ""
+	}
+	return string(d.take(int(n)))
+}
+
+func (d *decoder) nullableString() string {
+	return d.string()
+}
+
+// arrayLen reads an array length. Elements take at least minSize bytes, a length the rest of the
+// response cannot hold is an error rather than an allocation.
+func (d *decoder) arrayLen(minSize int) int {
+	n := int(d.int32())
+	if n < 0 || d.err != nil {
+		return 0
+	}
+	if n > (len(d.buf)-d.off)/minSize {
+		d.err = fmt.Errorf("array of %d elements exceeds the %d bytes left in the response", n, len(d.buf)-d.off)
+		return 0
+	}
+	return n
+}
+
+func (d *decoder) int32Array() []int32 {
+	n := d.arrayLen(4)
+	values := make([]int32, 0, n)
+	for i := 0; i < n && d.err == nil; i++ {
+		values = append(values, d.int32())
+	}
+	return values
+}
@@ -0,0 +1,259 @@
+package kubecheck
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+const serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+// RESTConfig holds what is needed to reach an API server. It is built from a kubeconfig,
+// the in-cluster service account or directly from the service Configuration.
+type RESTConfig struct {
+	Server    string
+	Token     string
+	Username  string
+	Password  string
+	CAData    []byte
+	CertData  []byte
+	KeyData   []byte
+	Insecure  bool
+	Namespace string // Default namespace of the kubeconfig context
+}
+
+// InClusterConfig uses the service account mounted into the pod the engine runs in
+func InClusterConfig() (RESTConfig, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return RESTConfig{}, fmt.Errorf("not running inside a cluster, KUBERNETES_SERVICE_HOST is not set")
+	}
+
+	token, err := os.ReadFile(filepath.Join(serviceAccountDir, "token"))
+	if err != nil {
+		return RESTConfig{}, fmt.Errorf("failed to read service account token: %v", err)
+	}
+
+	ca, err := os.ReadFile(filepath.Join(serviceAccountDir, "ca.crt"))
+	if err != nil {
+		return RESTConfig{}, fmt.Errorf("failed to read service account CA: %v", err)
+	}
+
+	return RESTConfig{
+		Server: "https://" + net.JoinHostPort(host, port),
+		Token:  strings.TrimSpace(string(token)),
+		CAData: ca,
+	}, nil
+}
+
+type kubeconfig struct {
+	CurrentContext string `yaml:"current-context"`
+	Clusters       []struct {
+		Name    string `yaml:"name"`
+		Cluster struct {
+			Server                   string `yaml:"server"`
+			CertificateAuthority     string `yaml:"certificate-authority"`
+			CertificateAuthorityData string `yaml:"certificate-authority-data"`
+			InsecureSkipTLSVerify    bool   `yaml:"insecure-skip-tls-verify"`
+		} `yaml:"cluster"`
+	} `yaml:"clusters"`
+	Users []struct {
+		Name string `yaml:"name"`
+		User struct {
+			Token                 string `yaml:"token"`
+			TokenFile             string `yaml:"tokenFile"`
+			Username              string `yaml:"username"`
+			Password              string `yaml:"password"`
+			ClientCertificate     string `yaml:"client-certificate"`
+			ClientCertificateData string `yaml:"client-certificate-data"`
+			ClientKey             string `yaml:"client-key"`
+			ClientKeyData         string `yaml:"client-key-data"`
+		} `yaml:"user"`
+	} `yaml:"users"`
+	Contexts []struct {
+		Name    string `yaml:"name"`
+		Context struct {
+			Cluster   string `yaml:"cluster"`
+			User      string `yaml:"user"`
+			Namespace string `yaml:"namespace"`
+		} `yaml:"context"`
+	} `yaml:"contexts"`
+}
+
+// LoadKubeconfig reads a kubeconfig file and resolves contextName (the current context when empty).
+// Exec and auth-provider plugins are not supported, the user needs a token or a client certificate.
+func LoadKubeconfig(path, contextName string) (RESTConfig, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return RESTConfig{}, fmt.Errorf("failed to read kubeconfig: %v", err)
+	}
+
+	var kc kubeconfig
+	if err := yaml.Unmarshal(raw, &kc); err != nil {
+		return RESTConfig{}, fmt.Errorf("failed to parse kubeconfig: %v", err)
+	}
+
+	if contextName == "" {
+		contextName = kc.CurrentContext
+	}
+
+	var cfg RESTConfig
+	var clusterName, userName string
+	found := false
+	for _, c := range kc.Contexts {
+		if c.Name == contextName {
+			clusterName, userName, cfg.Namespace = c.Context.Cluster, c.Context.User, c.Context.Namespace
+			found = true
+			break
+		}
+	}
+	if !found {
+		return RESTConfig{}, fmt.Errorf("context %q not found in kubeconfig", contextName)
+	}
+
+	// Relative file references are resolved against the kubeconfig location
+	base := filepath.Dir(path)
+	readData := func(inline, file string) ([]byte, error) {
+		if inline != "" {
+			return base64.StdEncoding.DecodeString(inline)
+		}
+		if file == "" {
+			return nil, nil
+		}
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(base, file)
+		}
+		return os.ReadFile(file)
+	}
+
+	for _, c := range kc.Clusters {
+		if c.Name != clusterName {
+			continue
+		}
+		cfg.Server = c.Cluster.Server
+		cfg.Insecure = c.Cluster.InsecureSkipTLSVerify
+		if cfg.CAData, err = readData(c.Cluster.CertificateAuthorityData, c.Cluster.CertificateAuthority); err != nil {
+			return RESTConfig{}, fmt.Errorf("failed to load cluster CA: %v", err)
+		}
+	}
+	if cfg.Server == "" {
+		return RESTConfig{}, fmt.Errorf("cluster %q not found in kubeconfig", clusterName)
+	}
+
+	for _, u := range kc.Users {
+		if u.Name != userName {
+			continue
+		}
+		cfg.Token, cfg.Username, cfg.Password = u.User.Token, u.User.Username, u.User.Password
+		if cfg.Token == "" && u.User.TokenFile != "" {
+			token, err := readData("", u.User.TokenFile)
+			if err != nil {
+				return RESTConfig{}, fmt.Errorf("failed to read token file: %v", err)
+			}
+			cfg.Token = strings.TrimSpace(string(token))
+		}
+		if cfg.CertData, err = readData(u.User.ClientCertificateData, u.User.ClientCertificate); err != nil {
+			return RESTConfig{}, fmt.Errorf("failed to load client certificate: %v", err)
+		}
+		if cfg.KeyData, err = readData(u.User.ClientKeyData, u.User.ClientKey); err != nil {
+			return RESTConfig{}, fmt.Errorf("failed to load client key: %v", err)
+		}
+	}
+
+	return cfg, nil
+}
+
+// Client performs read only requests against the Kubernetes API
+type Client struct {
+	server string
+	config RESTConfig
+	http   *http.Client
+}
+
+// NewClient returns a client using transport, which is shared by the checks of a cluster so its
+// connections are reused
+func NewClient(cfg RESTConfig, transport http.RoundTripper, timeout time.Duration) *Client {
+	return &Client{
+		server: strings.TrimRight(cfg.Server, "/"),
+		config: cfg,
+		http:   &http.Client{Timeout: timeout, Transport: transport},
+	}
+}
+
+// NewTransport returns a transport trusting the cluster CA and presenting the client certificate of cfg
+func NewTransport(cfg RESTConfig) (*http.Transport, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: cfg.Insecure}
+
+	if len(cfg.CAData) > 0 {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(cfg.CAData) {
+			return nil, fmt.Errorf("invalid cluster CA certificate")
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if len(cfg.CertData) > 0 && len(cfg.KeyData) > 0 {
+		cert, err := tls.X509KeyPair(cfg.CertData, cfg.KeyData)
+		if err != nil {
+			return nil, fmt.Errorf("invalid client certificate: %v", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return &http.Transport{TLSClientConfig: tlsConfig, IdleConnTimeout: 90 * time.Second}, nil
+}
+
+// Get decodes the JSON object at path (e.g. /api/v1/nodes) into out
+func (c *Client) Get(ctx context.Context, path string, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.server+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	switch {
+	case c.config.Token != "":
+		req.Header.Set("Authorization", "Bearer "+c.config.Token)
+	case c.config.Username != "":
+		req.SetBasicAuth(c.config.Username, c.config.Password)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var status struct {
+			Message string `json:"message"`
+		}
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		if json.Unmarshal(body, &status) == nil && status.Message != "" {
+			return fmt.Errorf("GET %s: %d %s", path, resp.StatusCode, status.Message)
+		}
+		return fmt.Errorf("GET %s: unexpected status %d", path, resp.StatusCode)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// namespacedPath returns the cluster wide path of a resource or the one of a namespace
+func namespacedPath(group, namespace, resource string) string {
+	if namespace == "" {
+		return group + "/" + resource
+	}
+	return group + "/namespaces/" + namespace + "/" + resource
+}
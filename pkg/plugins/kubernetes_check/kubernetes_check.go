@@ -0,0 +1,460 @@
+package kubecheck
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ZEGIFTED/MS.GoMonitor/monitors"
+	"github.com/ZEGIFTED/MS.GoMonitor/pkg/constants"
+	"github.com/ZEGIFTED/MS.GoMonitor/pkg/utils"
+)
+
+const (
+	severityCritical = "critical"
+	severityWarning  = "warning"
+)
+
+// waitingReasons are the container waiting reasons that mean the pod cannot run
+var waitingReasons = map[string]bool{
+	"CrashLoopBackOff":           true,
+	"ImagePullBackOff":           true,
+	"ErrImagePull":               true,
+	"CreateContainerConfigError": true,
+	"CreateContainerError":       true,
+	"InvalidImageName":           true,
+}
+
+type objectMeta struct {
+	Name              string    `json:"name"`
+	Namespace         string    `json:"namespace"`
+	CreationTimestamp time.Time `json:"creationTimestamp"`
+}
+
+type nodeList struct {
+	Items []struct {
+		Metadata objectMeta `json:"metadata"`
+		Spec     struct {
+			Unschedulable bool `json:"unschedulable"`
+		} `json:"spec"`
+		Status struct {
+			Conditions []struct {
+				Type    string `json:"type"`
+				Status  string `json:"status"`
+				Reason  string `json:"reason"`
+				Message string `json:"message"`
+			} `json:"conditions"`
+		} `json:"status"`
+	} `json:"items"`
+}
+
+type containerState struct {
+	Waiting *struct {
+		Reason  string `json:"reason"`
+		Message string `json:"message"`
+	} `json:"waiting"`
+	Terminated *struct {
+		Reason   string `json:"reason"`
+		ExitCode int    `json:"exitCode"`
+	} `json:"terminated"`
+}
+
+type podList struct {
+	Items []struct {
+		Metadata objectMeta `json:"metadata"`
+		Status   struct {
+			Phase             string `json:"phase"`
+			Reason            string `json:"reason"`
+			ContainerStatuses []struct {
+				Name         string         `json:"name"`
+				RestartCount int            `json:"restartCount"`
+				State        containerState `json:"state"`
+				LastState    containerState `json:"lastState"`
+			} `json:"containerStatuses"`
+		} `json:"status"`
+	} `json:"items"`
+}
+
+type deploymentList struct {
+	Items []struct {
+		Metadata objectMeta `json:"metadata"`
+		Spec     struct {
+			Replicas *int `json:"replicas"`
+		} `json:"spec"`
+		Status struct {
+			Replicas            int `json:"replicas"`
+			AvailableReplicas   int `json:"availableReplicas"`
+			UnavailableReplicas int `json:"unavailableReplicas"`
+		} `json:"status"`
+	} `json:"items"`
+}
+
+type pvcList struct {
+	Items []struct {
+		Metadata objectMeta `json:"metadata"`
+		Spec     struct {
+			StorageClassName string `json:"storageClassName"`
+		} `json:"spec"`
+		Status struct {
+			Phase string `json:"phase"`
+		} `json:"status"`
+	} `json:"items"`
+}
+
+type KubernetesChecker struct {
+	config map[string]any
+
+	mu         sync.Mutex
+	transports map[string]*http.Transport // Keyed by API server and TLS settings
+	restarts   map[string]map[string]int  // SystemMonitorId to the restart count of every container at the last check
+}
+
+func NewKubernetesChecker() *KubernetesChecker {
+	return &KubernetesChecker{
+		transports: make(map[string]*http.Transport),
+		restarts:   make(map[string]map[string]int),
+	}
+}
+
+// transport returns the transport of the cluster behind cfg, creating it on first use
+func (k *KubernetesChecker) transport(cfg RESTConfig) (*http.Transport, error) {
+	hash := sha256.New()
+	for _, part := range [][]byte{[]byte(cfg.Server), cfg.CAData, cfg.CertData, cfg.KeyData, []byte(strconv.FormatBool(cfg.Insecure))} {
+		hash.Write([]byte(strconv.Itoa(len(part)) + ":"))
+		hash.Write(part)
+	}
+	key := hex.EncodeToString(hash.Sum(nil))
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if transport, ok := k.transports[key]; ok {
+		return transport, nil
+	}
+	transport, err := NewTransport(cfg)
+	if err != nil {
+		return nil, err
+	}
+	k.transports[key] = transport
+	return transport, nil
+}
+
+func (k *KubernetesChecker) Initialize(config map[string]any) error {
+	k.config = config
+	return nil
+}
+
+func (k *KubernetesChecker) Check(ctx context.Context, db *sql.DB, service monitors.ServiceMonitorData) (monitors.MonitoringResult, error) {
+	status := monitors.MonitoringResult{
+		SystemMonitorId: service.SystemMonitorId.String(),
+		ServicePluginID: k.Name(),
+		HealthReport:    constants.GetStatusInfo(constants.UnknownStatus, ""),
+		Details:         make(map[string]any),
+		LastCheckTime:   time.Now(),
+	}
+
+	cfg := service.Configuration
+	restConfig, err := restConfigFor(service)
+	if err != nil {
+		status.FailureCount++
+		status.HealthReport = constants.GetStatusInfo(constants.InvalidConfiguration, err.Error())
+		return status, err
+	}
+
+	timeout := utils.ConfigDuration(cfg, "timeout", 30*time.Second)
+	transport, err := k.transport(restConfig)
+	if err != nil {
+		status.FailureCount++
+		status.HealthReport = constants.GetStatusInfo(constants.InvalidConfiguration, err.Error())
+		return status, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	namespaces := utils.ConfigStringSlice(cfg, "namespaces")
+	if len(namespaces) == 0 {
+		namespaces = []string{""} // Cluster wide
+	}
+
+	k.mu.Lock()
+	previousRestarts := k.restarts[status.SystemMonitorId]
+	k.mu.Unlock()
+
+	inspector := &inspector{
+		client:        NewClient(restConfig, transport, timeout),
+		restartWarn:   utils.ConfigInt(cfg, "restartWarnCount", 5),
+		lastRestarts:  previousRestarts,
+		restarts:      make(map[string]int),
+		pendingGrace:  utils.ConfigDuration(cfg, "pendingGrace", 5*time.Minute),
+		ignoreCordons: utils.ConfigBool(cfg, "ignoreCordonedNodes", true),
+		details:       status.Details,
+	}
+
+	if err := inspector.nodes(ctx); err != nil {
+		status.FailureCount++
+		status.HealthReport = constants.GetStatusInfo(constants.Degraded, "API server request failed "+err.Error())
+		return status, fmt.Errorf("kubernetes API request failed: %v", err)
+	}
+
+	for _, namespace := range namespaces {
+		for _, collect := range []func(context.Context, string) error{inspector.pods, inspector.deployments, inspector.pvcs} {
+			if err := collect(ctx, namespace); err != nil {
+				status.FailureCount++
+				status.HealthReport = constants.GetStatusInfo(constants.Degraded, "API server request failed "+err.Error())
+				return status, fmt.Errorf("kubernetes API request failed: %v", err)
+			}
+		}
+	}
+
+	// Containers of deleted pods are forgotten with the next complete listing
+	k.mu.Lock()
+	k.restarts[status.SystemMonitorId] = inspector.restarts
+	k.mu.Unlock()
+
+	status.Details["issues"] = inspector.issues
+	status.ObjectAlerts = inspector.issues
+
+	if len(inspector.issues) == 0 {
+		status.FailureCount = 0
+		status.HealthReport = constants.GetStatusInfo(constants.Healthy, "")
+		status.LastServiceUpTime = time.Now()
+		return status, nil
+	}
+
+	level := constants.Escalation
+	summary := make([]string, 0, len(inspector.issues))
+	for _, issue := range inspector.issues {
+		if issue.Severity == severityCritical {
+			level = constants.Degraded
+		}
+		summary = append(summary, issue.Reference()+" "+issue.Reason)
+	}
+
+	message := strings.Join(summary, "; ")
+	status.FailureCount++
+	status.HealthReport = constants.GetStatusInfo(level, message)
+	return status, fmt.Errorf("%d unhealthy kubernetes objects: %s", len(inspector.issues), message)
+}
+
+// restConfigFor picks the API server credentials: explicit server and token, a kubeconfig or the in-cluster service account
+func restConfigFor(service monitors.ServiceMonitorData) (RESTConfig, error) {
+	cfg := service.Configuration
+
+	if server := utils.ConfigString(cfg, "apiServer", ""); server != "" {
+		return RESTConfig{
+			Server:   server,
+			Token:    utils.ConfigString(cfg, "bearerToken", ""),
+			CAData:   []byte(utils.ConfigString(cfg, "caData", "")),
+			Insecure: utils.ConfigBool(cfg, "skipVerify", false),
+		}, nil
+	}
+
+	if path := utils.ConfigString(cfg, "kubeconfig", ""); path != "" {
+		restConfig, err := LoadKubeconfig(path, utils.ConfigString(cfg, "kubeContext", ""))
+		if err != nil {
+			return RESTConfig{}, err
+		}
+		restConfig.Insecure = restConfig.Insecure || utils.ConfigBool(cfg, "skipVerify", false)
+		return restConfig, nil
+	}
+
+	return InClusterConfig()
+}
+
+// inspector lists the cluster objects and collects the unhealthy ones
+type inspector struct {
+	client        *Client
+	restartWarn   int
+	pendingGrace  time.Duration
+	ignoreCordons bool
+
+	// Restart counts are cumulative over the life of a pod, only containers that restarted since the
+	// previous check are reported
+	lastRestarts map[string]int
+	restarts     map[string]int
+
+	details map[string]any
+	issues  []monitors.ObjectAlert
+}
+
+func (in *inspector) report(kind string, meta objectMeta, reason, message, severity string) {
+	in.issues = append(in.issues, monitors.ObjectAlert{
+		Kind:      kind,
+		Namespace: meta.Namespace,
+		Name:      meta.Name,
+		Reason:    reason,
+		Message:   message,
+		Severity:  severity,
+	})
+}
+
+func (in *inspector) add(key string, n int) {
+	current, _ := in.details[key].(int)
+	in.details[key] = current + n
+}
+
+func (in *inspector) nodes(ctx context.Context) error {
+	var nodes nodeList
+	if err := in.client.Get(ctx, "/api/v1/nodes", &nodes); err != nil {
+		return err
+	}
+
+	ready := 0
+	for _, node := range nodes.Items {
+		nodeReady := false
+		for _, condition := range node.Status.Conditions {
+			switch condition.Type {
+			case "Ready":
+				nodeReady = condition.Status == "True"
+				if !nodeReady {
+					in.report("Node", node.Metadata, "NotReady", strings.TrimSpace(condition.Reason+" "+condition.Message), severityCritical)
+				}
+			case "MemoryPressure", "DiskPressure", "PIDPressure":
+				if condition.Status == "True" {
+					in.report("Node", node.Metadata, condition.Type, condition.Message, severityWarning)
+				}
+			}
+		}
+
+		if nodeReady {
+			ready++
+		}
+		if node.Spec.Unschedulable && !in.ignoreCordons {
+			in.report("Node", node.Metadata, "Cordoned", "node is marked unschedulable", severityWarning)
+		}
+	}
+
+	in.details["nodes"] = len(nodes.Items)
+	in.details["nodes_ready"] = ready
+	return nil
+}
+
+func (in *inspector) pods(ctx context.Context, namespace string) error {
+	var pods podList
+	if err := in.client.Get(ctx, namespacedPath("/api/v1", namespace, "pods"), &pods); err != nil {
+		return err
+	}
+
+	crashLooping := 0
+	for _, pod := range pods.Items {
+		if pod.Status.Phase == "Succeeded" || pod.Status.Phase == "Failed" {
+			continue
+		}
+
+		if pod.Status.Phase == "Pending" && time.Since(pod.Metadata.CreationTimestamp) > in.pendingGrace {
+			in.report("Pod", pod.Metadata, "Pending", fmt.Sprintf("pending for %v", time.Since(pod.Metadata.CreationTimestamp).Round(time.Second)), severityWarning)
+		}
+
+		for _, container := range pod.Status.ContainerStatuses {
+			key := pod.Metadata.Namespace + "/" + pod.Metadata.Name + "/" + container.Name
+			in.restarts[key] = container.RestartCount
+
+			lastExit := ""
+			if t := container.LastState.Terminated; t != nil {
+				lastExit = fmt.Sprintf(", last exit %s (code %d)", t.Reason, t.ExitCode)
+			}
+
+			if w := container.State.Waiting; w != nil && waitingReasons[w.Reason] {
+				if w.Reason == "CrashLoopBackOff" {
+					crashLooping++
+				}
+				in.report("Pod", pod.Metadata, w.Reason, fmt.Sprintf("container %s: %s%s", container.Name, w.Message, lastExit), severityCritical)
+				continue
+			}
+
+			previous, seen := in.lastRestarts[key]
+			if in.restartWarn > 0 && container.RestartCount >= in.restartWarn && seen && container.RestartCount > previous {
+				in.report("Pod", pod.Metadata, "Restarts", fmt.Sprintf("container %s restarted %d times since the last check, %d in total%s",
+					container.Name, container.RestartCount-previous, container.RestartCount, lastExit), severityWarning)
+			}
+		}
+	}
+
+	in.add("pods", len(pods.Items))
+	in.add("pods_crash_looping", crashLooping)
+	return nil
+}
+
+func (in *inspector) deployments(ctx context.Context, namespace string) error {
+	var deployments deploymentList
+	if err := in.client.Get(ctx, namespacedPath("/apis/apps/v1", namespace, "deployments"), &deployments); err != nil {
+		return err
+	}
+
+	unavailable := 0
+	for _, deployment := range deployments.Items {
+		desired := 1
+		if deployment.Spec.Replicas != nil {
+			desired = *deployment.Spec.Replicas
+		}
+		if desired == 0 || deployment.Status.AvailableReplicas >= desired {
+			continue
+		}
+
+		unavailable++
+		severity := severityWarning
+		if deployment.Status.AvailableReplicas == 0 {
+			severity = severityCritical
+		}
+		in.report("Deployment", deployment.Metadata, "ReplicasUnavailable",
+			fmt.Sprintf("%d of %d replicas available", deployment.Status.AvailableReplicas, desired), severity)
+	}
+
+	in.add("deployments", len(deployments.Items))
+	in.add("deployments_unavailable", unavailable)
+	return nil
+}
+
+func (in *inspector) pvcs(ctx context.Context, namespace string) error {
+	var claims pvcList
+	if err := in.client.Get(ctx, namespacedPath("/api/v1", namespace, "persistentvolumeclaims"), &claims); err != nil {
+		return err
+	}
+
+	pending := 0
+	for _, claim := range claims.Items {
+		if claim.Status.Phase == "Lost" {
+			in.report("PersistentVolumeClaim", claim.Metadata, "Lost", "bound volume no longer exists", severityCritical)
+			continue
+		}
+		if claim.Status.Phase != "Pending" || time.Since(claim.Metadata.CreationTimestamp) <= in.pendingGrace {
+			continue
+		}
+
+		pending++
+		in.report("PersistentVolumeClaim", claim.Metadata, "Pending",
+			fmt.Sprintf("unbound for %v (storage class %q)", time.Since(claim.Metadata.CreationTimestamp).Round(time.Second), claim.Spec.StorageClassName), severityWarning)
+	}
+
+	in.add("pvcs", len(claims.Items))
+	in.add("pvcs_pending", pending)
+	return nil
+}
+
+func (k *KubernetesChecker) Name() string {
+	return "kubernetes_check"
+}
+
+func (k *KubernetesChecker) Description() string {
+	return "Kubernetes node readiness, pod crash loop, deployment availability and PVC monitor"
+}
+
+func (k *KubernetesChecker) SupportedTypes() []monitors.ServiceType {
+	return []monitors.ServiceType{
+		monitors.ServiceMonitorServer,
+	}
+}
+
+func (k *KubernetesChecker) Cleanup() error {
+	return nil
+}
+
+var Plugin monitors.ServiceMonitorPlugin = NewKubernetesChecker()
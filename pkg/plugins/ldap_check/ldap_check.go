@@ -0,0 +1,290 @@
+package ldapcheck
+
+import (
+	"context"
+	"crypto/tls"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ZEGIFTED/MS.GoMonitor/monitors"
+	"github.com/ZEGIFTED/MS.GoMonitor/pkg/constants"
+	"github.com/ZEGIFTED/MS.GoMonitor/pkg/secrets"
+	"github.com/ZEGIFTED/MS.GoMonitor/pkg/utils"
+	"github.com/go-ldap/ldap/v3"
+)
+
+// Connection security
+const (
+	SecurityStartTLS = "starttls"
+	SecurityLDAPS    = "ldaps"
+	SecurityNone     = "none"
+)
+
+var searchScopes = map[string]int{
+	"base": ldap.ScopeBaseObject,
+	"one":  ldap.ScopeSingleLevel,
+	"sub":  ldap.ScopeWholeSubtree,
+}
+
+type LDAPChecker struct {
+	config map[string]any
+}
+
+func NewLDAPChecker() *LDAPChecker {
+	return &LDAPChecker{}
+}
+
+func (l *LDAPChecker) Initialize(config map[string]any) error {
+	l.config = config
+	return nil
+}
+
+func (l *LDAPChecker) Check(ctx context.Context, db *sql.DB, service monitors.ServiceMonitorData) (monitors.MonitoringResult, error) {
+	status := monitors.MonitoringResult{
+		SystemMonitorId: service.SystemMonitorId.String(),
+		ServicePluginID: l.Name(),
+		HealthReport:    constants.GetStatusInfo(constants.UnknownStatus, ""),
+		Details:         make(map[string]any),
+		LastCheckTime:   time.Now(),
+	}
+
+	cfg := service.Configuration
+	target, security, err := endpoint(service)
+	if err != nil {
+		status.FailureCount++
+		status.HealthReport = constants.GetStatusInfo(constants.InvalidConfiguration, err.Error())
+		return status, err
+	}
+	status.Details["url"] = target
+	status.Details["security"] = security
+
+	// The bind password only ever comes from the secrets store
+	bindDN := utils.ConfigString(cfg, "bindDN", "")
+	bindPassword, err := secrets.FromConfig(cfg, "bindPasswordSecret")
+	if err != nil {
+		status.FailureCount++
+		status.HealthReport = constants.GetStatusInfo(constants.InvalidConfiguration, err.Error())
+		return status, err
+	}
+	if bindDN != "" && bindPassword == "" {
+		status.FailureCount++
+		status.HealthReport = constants.GetStatusInfo(constants.InvalidConfiguration, "bindPasswordSecret is required with bindDN")
+		return status, fmt.Errorf("bindPasswordSecret is required with bindDN")
+	}
+
+	timeout := utils.ConfigDuration(cfg, "timeout", 10*time.Second)
+	host, _, _ := net.SplitHostPort(strings.TrimPrefix(strings.TrimPrefix(target, "ldaps://"), "ldap://"))
+	tlsConfig := &tls.Config{
+		ServerName:         utils.ConfigString(cfg, "ldapServerName", host),
+		InsecureSkipVerify: utils.ConfigBool(cfg, "ldapSkipVerify", false),
+	}
+
+	start := time.Now()
+	conn, err := ldap.DialURL(target,
+		ldap.DialWithDialer(&net.Dialer{Timeout: timeout}),
+		ldap.DialWithTLSConfig(tlsConfig))
+	if err != nil {
+		status.FailureCount++
+		status.HealthReport = constants.GetStatusInfo(constants.Degraded, err.Error())
+		return status, fmt.Errorf("LDAP connection to %s failed: %v", target, err)
+	}
+	defer conn.Close()
+	conn.SetTimeout(timeout)
+
+	if security == SecurityStartTLS {
+		if err := conn.StartTLS(tlsConfig); err != nil {
+			status.FailureCount++
+			status.HealthReport = constants.GetStatusInfo(constants.Degraded, err.Error())
+			return status, fmt.Errorf("StartTLS failed: %v", err)
+		}
+	}
+	if state, ok := conn.TLSConnectionState(); ok {
+		status.Details["tls_version"] = tls.VersionName(state.Version)
+	}
+	status.Details["connect_ms"] = milliseconds(time.Since(start))
+
+	start = time.Now()
+	if bindDN != "" {
+		err = conn.Bind(bindDN, bindPassword)
+	} else {
+		err = conn.UnauthenticatedBind("")
+	}
+	bindTime := time.Since(start)
+	status.Details["bind_ms"] = milliseconds(bindTime)
+	if err != nil {
+		status.FailureCount++
+		status.Details["result_code"] = resultCode(err)
+		status.HealthReport = constants.GetStatusInfo(constants.Degraded, err.Error())
+		return status, fmt.Errorf("bind as %q failed: %v", bindDN, err)
+	}
+
+	var problems []string
+	level := constants.Healthy
+
+	if base := utils.ConfigString(cfg, "searchBase", ""); base != "" {
+		count, searchTime, err := search(conn, cfg, base)
+		status.Details["search_ms"] = milliseconds(searchTime)
+		if err != nil {
+			status.FailureCount++
+			status.Details["result_code"] = resultCode(err)
+			status.HealthReport = constants.GetStatusInfo(constants.Degraded, err.Error())
+			return status, fmt.Errorf("search under %q failed: %v", base, err)
+		}
+		status.Details["result_count"] = count
+
+		if expected := utils.ConfigInt(cfg, "expectedCount", -1); expected >= 0 && count != expected {
+			level = constants.Degraded
+			problems = append(problems, fmt.Sprintf("search returned %d entries, expected %d", count, expected))
+		}
+		if minimum := utils.ConfigInt(cfg, "minResults", 0); count < minimum {
+			level = constants.Degraded
+			problems = append(problems, fmt.Sprintf("search returned %d entries, expected at least %d", count, minimum))
+		}
+		if warn := utils.ConfigFloat(cfg, "searchLatencyWarnMs", 0); warn > 0 && milliseconds(searchTime) > warn {
+			level = max(level, constants.Escalation)
+			problems = append(problems, fmt.Sprintf("search took %v", searchTime.Round(time.Millisecond)))
+		}
+	}
+
+	if warn := utils.ConfigFloat(cfg, "bindLatencyWarnMs", 0); warn > 0 && milliseconds(bindTime) > warn {
+		level = max(level, constants.Escalation)
+		problems = append(problems, fmt.Sprintf("bind took %v", bindTime.Round(time.Millisecond)))
+	}
+
+	if level != constants.Healthy {
+		status.FailureCount++
+		message := strings.Join(problems, "; ")
+		status.HealthReport = constants.GetStatusInfo(level, message)
+		return status, fmt.Errorf("%s", message)
+	}
+
+	status.FailureCount = 0
+	status.HealthReport = constants.GetStatusInfo(constants.Healthy, "")
+	status.LastServiceUpTime = time.Now()
+
+	return status, nil
+}
+
+func search(conn *ldap.Conn, cfg map[string]any, base string) (int, time.Duration, error) {
+	scopeName := utils.ConfigString(cfg, "searchScope", "sub")
+	scope, ok := searchScopes[scopeName]
+	if !ok {
+		return 0, 0, fmt.Errorf("unknown searchScope %q", scopeName)
+	}
+
+	attributes := utils.ConfigStringSlice(cfg, "searchAttributes")
+	if len(attributes) == 0 {
+		attributes = []string{"1.1"} // RFC 4511: no attributes, only the DNs
+	}
+
+	request := ldap.NewSearchRequest(
+		base, scope, ldap.NeverDerefAliases,
+		utils.ConfigInt(cfg, "searchSizeLimit", 1000), 0, false,
+		utils.ConfigString(cfg, "searchFilter", "(objectClass=*)"),
+		attributes, nil,
+	)
+
+	start := time.Now()
+	result, err := conn.Search(request)
+	elapsed := time.Since(start)
+
+	// Hitting the size limit still proves the directory answers, count what came back
+	if err != nil && !ldap.IsErrorWithCode(err, ldap.LDAPResultSizeLimitExceeded) {
+		return 0, elapsed, err
+	}
+	if result == nil {
+		return 0, elapsed, err
+	}
+	return len(result.Entries), elapsed, nil
+}
+
+// endpoint returns "ldapURL" or builds one from the service host, port and "ldapSecurity"
+func endpoint(service monitors.ServiceMonitorData) (string, string, error) {
+	cfg := service.Configuration
+	security := strings.ToLower(utils.ConfigString(cfg, "ldapSecurity", SecurityStartTLS))
+
+	if raw := utils.ConfigString(cfg, "ldapURL", ""); raw != "" {
+		u, err := url.Parse(raw)
+		if err != nil {
+			return "", "", fmt.Errorf("invalid ldapURL: %v", err)
+		}
+		switch u.Scheme {
+		case "ldaps":
+			security = SecurityLDAPS
+		case "ldap":
+			if security == SecurityLDAPS {
+				security = SecurityStartTLS
+			}
+		default:
+			return "", "", fmt.Errorf("unsupported LDAP scheme %q", u.Scheme)
+		}
+		if u.Port() == "" {
+			u.Host = net.JoinHostPort(u.Hostname(), defaultPort(security))
+		}
+		return u.Scheme + "://" + u.Host, security, nil
+	}
+
+	if service.Host == "" {
+		return "", "", fmt.Errorf("host cannot be empty")
+	}
+
+	scheme := "ldap"
+	switch security {
+	case SecurityLDAPS:
+		scheme = "ldaps"
+	case SecurityStartTLS, SecurityNone:
+	default:
+		return "", "", fmt.Errorf("unknown ldapSecurity %q", security)
+	}
+
+	port := defaultPort(security)
+	if service.Port != 0 {
+		port = strconv.Itoa(service.Port)
+	}
+	return scheme + "://" + net.JoinHostPort(service.Host, port), security, nil
+}
+
+func defaultPort(security string) string {
+	if security == SecurityLDAPS {
+		return "636"
+	}
+	return "389"
+}
+
+func resultCode(err error) uint16 {
+	var ldapErr *ldap.Error
+	if errors.As(err, &ldapErr) {
+		return ldapErr.ResultCode
+	}
+	return ldap.ErrorNetwork
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+func (l *LDAPChecker) Name() string {
+	return "ldap_check"
+}
+
+func (l *LDAPChecker) Description() string {
+	return "Binds to LDAP and Active Directory servers and runs a verification search"
+}
+
+func (l *LDAPChecker) SupportedTypes() []monitors.ServiceType {
+	return []monitors.ServiceType{
+		monitors.ServiceMonitorServer,
+	}
+}
+
+func (l *LDAPChecker) Cleanup() error {
+	return nil
+}
+
+var Plugin monitors.ServiceMonitorPlugin = NewLDAPChecker()
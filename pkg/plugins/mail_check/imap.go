@@ -0,0 +1,143 @@
+package mailcheck
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// imapClient speaks just enough IMAP4rev1 (RFC 3501) to find and remove a probe message
+type imapClient struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	seq    int
+}
+
+var internalDatePattern = regexp.MustCompile(`INTERNALDATE "([^"]+)"`)
+
+func dialIMAP(ctx context.Context, address string, useTLS bool, tlsConfig *tls.Config) (*imapClient, error) {
+	dialer := &net.Dialer{}
+	var conn net.Conn
+	var err error
+	if useTLS {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: tlsConfig}).DialContext(ctx, "tcp", address)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", address)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	c := &imapClient{conn: conn, reader: bufio.NewReader(conn)}
+	greeting, err := c.reader.ReadString('\n')
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to read IMAP greeting: %v", err)
+	}
+	if !strings.HasPrefix(greeting, "* OK") && !strings.HasPrefix(greeting, "* PREAUTH") {
+		conn.Close()
+		return nil, fmt.Errorf("unexpected IMAP greeting: %s", strings.TrimSpace(greeting))
+	}
+
+	return c, nil
+}
+
+// command sends a tagged command and returns the untagged response lines
+func (c *imapClient) command(format string, args ...any) ([]string, error) {
+	c.seq++
+	tag := fmt.Sprintf("m%d", c.seq)
+
+	if _, err := fmt.Fprintf(c.conn, "%s %s\r\n", tag, fmt.Sprintf(format, args...)); err != nil {
+		return nil, err
+	}
+
+	var untagged []string
+	for {
+		line, err := c.reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+
+		if rest, ok := strings.CutPrefix(line, tag+" "); ok {
+			if !strings.HasPrefix(rest, "OK") {
+				return untagged, fmt.Errorf("IMAP %s", rest)
+			}
+			return untagged, nil
+		}
+		untagged = append(untagged, line)
+	}
+}
+
+func (c *imapClient) Login(username, password string) error {
+	_, err := c.command("LOGIN %s %s", quote(username), quote(password))
+	return err
+}
+
+func (c *imapClient) Select(mailbox string) error {
+	_, err := c.command("SELECT %s", quote(mailbox))
+	return err
+}
+
+// SearchHeader returns the UIDs of messages whose header field contains value
+func (c *imapClient) SearchHeader(field, value string) ([]string, error) {
+	// NOOP lets the server report messages delivered since the mailbox was selected
+	if _, err := c.command("NOOP"); err != nil {
+		return nil, err
+	}
+
+	lines, err := c.command("UID SEARCH HEADER %s %s", quote(field), quote(value))
+	if err != nil {
+		return nil, err
+	}
+
+	var uids []string
+	for _, line := range lines {
+		if rest, ok := strings.CutPrefix(line, "* SEARCH"); ok {
+			uids = append(uids, strings.Fields(rest)...)
+		}
+	}
+	return uids, nil
+}
+
+// InternalDate returns the time the server delivered the message into the mailbox
+func (c *imapClient) InternalDate(uid string) (time.Time, error) {
+	lines, err := c.command("UID FETCH %s (INTERNALDATE)", uid)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	for _, line := range lines {
+		if m := internalDatePattern.FindStringSubmatch(line); m != nil {
+			return time.Parse("_2-Jan-2006 15:04:05 -0700", m[1])
+		}
+	}
+	return time.Time{}, fmt.Errorf("no INTERNALDATE returned for UID %s", uid)
+}
+
+func (c *imapClient) Delete(uids []string) error {
+	if _, err := c.command("UID STORE %s +FLAGS.SILENT (\\Deleted)", strings.Join(uids, ",")); err != nil {
+		return err
+	}
+	_, err := c.command("EXPUNGE")
+	return err
+}
+
+func (c *imapClient) Close() error {
+	_, _ = c.command("LOGOUT")
+	return c.conn.Close()
+}
+
+// quote returns s as an IMAP quoted string, line breaks cannot be quoted and are dropped
+func quote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\r", "", "\n", "").Replace(s) + `"`
+}
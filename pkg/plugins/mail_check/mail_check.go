@@ -0,0 +1,380 @@
+package mailcheck
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ZEGIFTED/MS.GoMonitor/monitors"
+	"github.com/ZEGIFTED/MS.GoMonitor/pkg/constants"
+	"github.com/ZEGIFTED/MS.GoMonitor/pkg/secrets"
+	"github.com/ZEGIFTED/MS.GoMonitor/pkg/utils"
+)
+
+// TokenHeader carries the probe token so the message can be found over IMAP
+const TokenHeader = "X-MS-Monitor-Probe"
+
+// SMTP connection security
+const (
+	SecurityNone     = "none"
+	SecurityStartTLS = "starttls"
+	SecurityTLS      = "tls"
+)
+
+type MailChecker struct {
+	config map[string]any
+}
+
+func NewMailChecker() *MailChecker {
+	return &MailChecker{}
+}
+
+func (m *MailChecker) Initialize(config map[string]any) error {
+	m.config = config
+	return nil
+}
+
+func (m *MailChecker) Check(ctx context.Context, db *sql.DB, service monitors.ServiceMonitorData) (monitors.MonitoringResult, error) {
+	status := monitors.MonitoringResult{
+		SystemMonitorId: service.SystemMonitorId.String(),
+		ServicePluginID: m.Name(),
+		HealthReport:    constants.GetStatusInfo(constants.UnknownStatus, ""),
+		Details:         make(map[string]any),
+		LastCheckTime:   time.Now(),
+	}
+
+	cfg := service.Configuration
+	host := utils.ConfigString(cfg, "smtpHost", service.Host)
+	if host == "" {
+		status.FailureCount++
+		status.HealthReport = constants.GetStatusInfo(constants.InvalidConfiguration, "")
+		return status, fmt.Errorf("host cannot be empty")
+	}
+
+	security := strings.ToLower(utils.ConfigString(cfg, "smtpSecurity", SecurityStartTLS))
+	defaultPort := 25
+	if security == SecurityTLS {
+		defaultPort = 465
+	}
+	port := utils.ConfigInt(cfg, "smtpPort", service.Port)
+	if port == 0 {
+		port = defaultPort
+	}
+	address := net.JoinHostPort(host, strconv.Itoa(port))
+	status.Details["smtp_address"] = address
+
+	roundTrip := utils.ConfigBool(cfg, "roundTrip", false)
+	timeout := utils.ConfigDuration(cfg, "timeout", 30*time.Second)
+	if roundTrip {
+		timeout += utils.ConfigDuration(cfg, "deliveryTimeout", 2*time.Minute)
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	tlsConfig := &tls.Config{ServerName: host, InsecureSkipVerify: utils.ConfigBool(cfg, "smtpSkipVerify", false)}
+
+	start := time.Now()
+	client, err := dialSMTP(ctx, address, host, security, tlsConfig)
+	if err != nil {
+		status.FailureCount++
+		status.HealthReport = constants.GetStatusInfo(constants.Degraded, err.Error())
+		return status, fmt.Errorf("SMTP connection to %s failed: %v", address, err)
+	}
+	defer client.Close()
+	status.Details["smtp_connect_ms"] = float64(time.Since(start).Microseconds()) / 1000
+
+	if err := client.Hello(utils.ConfigString(cfg, "heloName", "localhost")); err != nil {
+		status.FailureCount++
+		status.HealthReport = constants.GetStatusInfo(constants.Degraded, err.Error())
+		return status, fmt.Errorf("EHLO rejected: %v", err)
+	}
+
+	startTLS, _ := client.Extension("STARTTLS")
+	status.Details["starttls_offered"] = startTLS
+	if security == SecurityStartTLS {
+		if startTLS {
+			if err := client.StartTLS(tlsConfig); err != nil {
+				status.FailureCount++
+				status.HealthReport = constants.GetStatusInfo(constants.Degraded, err.Error())
+				return status, fmt.Errorf("STARTTLS failed: %v", err)
+			}
+		} else if utils.ConfigBool(cfg, "requireStartTLS", false) {
+			status.FailureCount++
+			status.HealthReport = constants.GetStatusInfo(constants.Escalation, "STARTTLS not offered")
+			return status, fmt.Errorf("%s does not offer STARTTLS", address)
+		}
+	}
+	if state, ok := client.TLSConnectionState(); ok {
+		status.Details["tls_version"] = tls.VersionName(state.Version)
+	}
+
+	if ok, mechanisms := client.Extension("AUTH"); ok {
+		status.Details["auth_mechanisms"] = strings.Fields(mechanisms)
+	}
+
+	if username := utils.ConfigString(cfg, "smtpUsername", ""); username != "" {
+		// Credentials are only sent in the clear when the operator explicitly chose no transport security
+		if _, ok := client.TLSConnectionState(); !ok && security != SecurityNone {
+			status.FailureCount++
+			status.HealthReport = constants.GetStatusInfo(constants.Escalation, "refusing to authenticate without TLS")
+			return status, fmt.Errorf("%s offers no TLS, refusing to send SMTP credentials in plaintext", address)
+		}
+		password, err := secrets.FromConfig(cfg, "smtpPassword")
+		if err != nil {
+			status.FailureCount++
+			status.HealthReport = constants.GetStatusInfo(constants.InvalidConfiguration, err.Error())
+			return status, err
+		}
+		auth := plainAuth{username: username, password: password}
+		if err := client.Auth(auth); err != nil {
+			status.FailureCount++
+			status.HealthReport = constants.GetStatusInfo(constants.Degraded, err.Error())
+			return status, fmt.Errorf("SMTP authentication failed: %v", err)
+		}
+		status.Details["authenticated"] = true
+	}
+
+	if roundTrip {
+		if err := m.roundTrip(ctx, client, cfg, status.Details); err != nil {
+			status.FailureCount++
+			status.HealthReport = constants.GetStatusInfo(constants.Degraded, err.Error())
+			return status, err
+		}
+	} else {
+		_ = client.Quit()
+	}
+
+	if warn := utils.ConfigFloat(cfg, "deliveryWarnSeconds", 0); warn > 0 {
+		if latency, ok := status.Details["delivery_latency_ms"].(float64); ok && latency/1000 > warn {
+			status.FailureCount++
+			status.HealthReport = constants.GetStatusInfo(constants.Escalation, fmt.Sprintf("delivery took %.1fs", latency/1000))
+			return status, fmt.Errorf("mail delivery took %.1fs (warning %vs)", latency/1000, warn)
+		}
+	}
+
+	status.FailureCount = 0
+	status.HealthReport = constants.GetStatusInfo(constants.Healthy, "")
+	status.LastServiceUpTime = time.Now()
+
+	return status, nil
+}
+
+// roundTrip sends a tagged message through the open SMTP session and polls IMAP until it arrives
+func (m *MailChecker) roundTrip(ctx context.Context, client *smtp.Client, cfg map[string]any, details map[string]any) error {
+	from := utils.ConfigString(cfg, "mailFrom", "")
+	to := utils.ConfigString(cfg, "mailTo", "")
+	if from == "" || to == "" {
+		return fmt.Errorf("mailFrom and mailTo are required for a round trip")
+	}
+
+	imapHost := utils.ConfigString(cfg, "imapHost", "")
+	if imapHost == "" {
+		return fmt.Errorf("imapHost is required for a round trip")
+	}
+	imapTLS := utils.ConfigBool(cfg, "imapTLS", true)
+	imapPort := utils.ConfigInt(cfg, "imapPort", 0)
+	if imapPort == 0 {
+		imapPort = 143
+		if imapTLS {
+			imapPort = 993
+		}
+	}
+
+	// Connect to IMAP first so a broken mailbox does not leave probe messages piling up
+	imap, err := dialIMAP(ctx, net.JoinHostPort(imapHost, strconv.Itoa(imapPort)), imapTLS,
+		&tls.Config{ServerName: imapHost, InsecureSkipVerify: utils.ConfigBool(cfg, "imapSkipVerify", false)})
+	if err != nil {
+		return fmt.Errorf("IMAP connection failed: %v", err)
+	}
+	defer imap.Close()
+
+	imapPassword, err := secrets.FromConfig(cfg, "imapPassword")
+	if err != nil {
+		return err
+	}
+	if err := imap.Login(utils.ConfigString(cfg, "imapUsername", to), imapPassword); err != nil {
+		return fmt.Errorf("IMAP login failed: %v", err)
+	}
+	if err := imap.Select(utils.ConfigString(cfg, "imapMailbox", "INBOX")); err != nil {
+		return fmt.Errorf("IMAP select failed: %v", err)
+	}
+
+	deleteProbe := utils.ConfigBool(cfg, "deleteProbe", true)
+	if deleteProbe {
+		// Probes that arrived after an earlier check gave up are still in the mailbox, an empty
+		// value matches every message carrying the token header
+		stale, err := imap.SearchHeader(TokenHeader, "")
+		if err == nil && len(stale) > 0 {
+			err = imap.Delete(stale)
+		}
+		if err != nil {
+			details["cleanup_error"] = err.Error()
+		} else {
+			details["stale_probes_deleted"] = len(stale)
+		}
+	}
+
+	token := probeToken()
+	details["probe_token"] = token
+
+	sent := time.Now()
+	if err := send(client, from, to, token, sent); err != nil {
+		return fmt.Errorf("failed to send probe message: %v", err)
+	}
+	details["smtp_send_ms"] = float64(time.Since(sent).Microseconds()) / 1000
+
+	poll := utils.ConfigDuration(cfg, "pollInterval", 5*time.Second)
+	ticker := time.NewTicker(poll)
+	defer ticker.Stop()
+
+	for {
+		uids, err := imap.SearchHeader(TokenHeader, token)
+		if err != nil {
+			return fmt.Errorf("IMAP search failed: %v", err)
+		}
+
+		if len(uids) > 0 {
+			found := time.Now()
+			latency := found.Sub(sent)
+			// INTERNALDATE is when the server stored the message, independent of the poll interval.
+			// It has one second resolution so it is only used when it narrows the estimate.
+			if delivered, err := imap.InternalDate(uids[0]); err == nil && delivered.After(sent) && delivered.Before(found) {
+				latency = delivered.Sub(sent)
+			}
+			details["delivery_latency_ms"] = float64(latency.Microseconds()) / 1000
+			details["detected_after_ms"] = float64(found.Sub(sent).Microseconds()) / 1000
+
+			if deleteProbe {
+				if err := imap.Delete(uids); err != nil {
+					details["cleanup_error"] = err.Error()
+				}
+			}
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("probe message not delivered to %s within %v", to, time.Since(sent).Round(time.Second))
+		case <-ticker.C:
+		}
+	}
+}
+
+func dialSMTP(ctx context.Context, address, host, security string, tlsConfig *tls.Config) (*smtp.Client, error) {
+	dialer := &net.Dialer{}
+	var conn net.Conn
+	var err error
+
+	switch security {
+	case SecurityTLS:
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: tlsConfig}).DialContext(ctx, "tcp", address)
+	case SecurityStartTLS, SecurityNone:
+		conn, err = dialer.DialContext(ctx, "tcp", address)
+	default:
+		return nil, fmt.Errorf("unknown smtpSecurity %q", security)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	// NewClient reads the 220 banner
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return client, nil
+}
+
+func send(client *smtp.Client, from, to, token string, sent time.Time) error {
+	if err := client.Mail(from); err != nil {
+		return err
+	}
+	if err := client.Rcpt(to); err != nil {
+		return err
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+
+	domain := from[strings.LastIndex(from, "@")+1:]
+	message := strings.Join([]string{
+		"From: " + from,
+		"To: " + to,
+		"Subject: " + constants.OrganizationName + " mail delivery probe " + token,
+		"Date: " + sent.Format(time.RFC1123Z),
+		"Message-ID: <" + token + "@" + domain + ">",
+		TokenHeader + ": " + token,
+		"Auto-Submitted: auto-generated",
+		"",
+		"This message was sent by the service monitor to verify mail delivery and can be deleted.",
+		"",
+	}, "\r\n")
+
+	if _, err := w.Write([]byte(message)); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+func probeToken() string {
+	b := make([]byte, 12)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// plainAuth is PLAIN authentication without net/smtp's refusal to authenticate over plaintext
+// to remote hosts. Check only allows that when smtpSecurity is none, relays inside the network
+// often accept it and the check must reflect it.
+type plainAuth struct {
+	username, password string
+}
+
+func (a plainAuth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	return "PLAIN", []byte("\x00" + a.username + "\x00" + a.password), nil
+}
+
+func (a plainAuth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if more {
+		return nil, fmt.Errorf("unexpected server challenge")
+	}
+	return nil, nil
+}
+
+func (m *MailChecker) Name() string {
+	return "mail_check"
+}
+
+func (m *MailChecker) Description() string {
+	return "Checks SMTP relays and optionally the full SMTP to IMAP delivery round trip"
+}
+
+func (m *MailChecker) SupportedTypes() []monitors.ServiceType {
+	return []monitors.ServiceType{
+		monitors.ServiceMonitorServer,
+		monitors.ServiceMonitorWebModules,
+	}
+}
+
+func (m *MailChecker) Cleanup() error {
+	return nil
+}
+
+var Plugin monitors.ServiceMonitorPlugin = NewMailChecker()
@@ -3,11 +3,13 @@ package plugins
 import (
 	"context"
 	"database/sql"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"log"
 	"log/slog"
 	"net"
+	"strconv"
 	"strings"
 
 	// "os"
@@ -15,15 +17,22 @@ import (
 
 	"github.com/ZEGIFTED/MS.GoMonitor/internal/repository"
 	"github.com/ZEGIFTED/MS.GoMonitor/monitors"
+	"github.com/ZEGIFTED/MS.GoMonitor/notifier"
 	"github.com/ZEGIFTED/MS.GoMonitor/pkg/constants"
+	"github.com/ZEGIFTED/MS.GoMonitor/pkg/flows"
+	"github.com/ZEGIFTED/MS.GoMonitor/pkg/snmpprofiles"
+	"github.com/ZEGIFTED/MS.GoMonitor/pkg/utils"
+	mstypes "github.com/ZEGIFTED/MS.GoMonitor/types"
+	"github.com/gosnmp/gosnmp"
 )
 
 type NetworkSNMPPlugin struct {
-	config map[string]any
+	config   map[string]any
+	counters *monitors.CounterStore
 }
 
 func NewNetworkSNMPPlugin() *NetworkSNMPPlugin {
-	return &NetworkSNMPPlugin{}
+	return &NetworkSNMPPlugin{counters: monitors.NewCounterStore()}
 }
 
 func (p *NetworkSNMPPlugin) Initialize(config map[string]interface{}) error {
@@ -36,6 +45,7 @@ func (s *NetworkSNMPPlugin) Check(ctx context.Context, db *sql.DB, netDevice mon
 		SystemMonitorId: netDevice.SystemMonitorId.String(),
 		ServicePluginID: s.Name(),
 		HealthReport:    constants.GetStatusInfo(constants.UnknownStatus, ""),
+		Details:         make(map[string]any),
 		LastCheckTime:   time.Now(),
 	}
 
@@ -45,29 +55,11 @@ func (s *NetworkSNMPPlugin) Check(ctx context.Context, db *sql.DB, netDevice mon
 		return status, fmt.Errorf("Host or Port cannot be empty")
 	}
 
-	var config monitors.SNMPDeviceConfig
-
-	if communityString, ok := netDevice.Configuration["communityString"]; ok {
-		config.CommunityString, _ = communityString.(string)
-	}
-
-	if authUsername, ok := netDevice.Configuration["authUsernameV3"]; ok {
-		config.AuthUsernameV3, _ = authUsername.(string)
-	}
-
-	if ap, ok := netDevice.Configuration["authPasswordV3"]; ok {
-		config.AuthPasswordV3, _ = ap.(string)
-	}
-
-	if pp, ok := netDevice.Configuration["privPassword"]; ok {
-		config.PrivPassword, _ = pp.(string)
-	}
-
-	if sv, ok := netDevice.Configuration["snmpVersion"]; ok {
-		config.SNMPVersion, _ = sv.(string)
-		config.SNMPVersion = strings.ToLower(strings.TrimSpace(config.SNMPVersion))
-	} else {
-		config.SNMPVersion = "v2" // default
+	config, err := monitors.ParseSNMPDeviceConfig(netDevice.Configuration)
+	if err != nil {
+		status.FailureCount++
+		status.HealthReport = constants.GetStatusInfo(constants.InvalidConfiguration, err.Error())
+		return status, err
 	}
 
 	// Handle SNMP metrics configuration
@@ -91,40 +83,24 @@ func (s *NetworkSNMPPlugin) Check(ctx context.Context, db *sql.DB, netDevice mon
 						Description: getString(metricMap, "description"),
 						MetricType:  getString(metricMap, "metricType"),
 						Name:        getString(metricMap, "name"),
+						IsCounter:   utils.ConfigBool(metricMap, "isCounter", false),
+						Scale:       utils.ConfigInt(metricMap, "scale", 0),
+					}
+					// The ifIndex may be stored as a number
+					if id, ok := metricMap["interfaceId"].(float64); ok {
+						mc.InterfaceID = strconv.Itoa(int(id))
+					} else {
+						mc.InterfaceID = getString(metricMap, "interfaceId")
 					}
-					// if scale, ok := metricMap["scale"].(float64); ok {
-					// 	config.Scale = scale
-					// }
 					if unit, ok := metricMap["unit"].(string); ok {
 						mc.Unit = unit
 					}
-					// metricConfiguration = append(metricConfiguration, mc)
-					config.SNMPMetrics = append(config.SNMPMetrics, mc)
+					metricConfiguration = append(metricConfiguration, mc)
 				}
 			}
 		}
 	}
 
-	// If no metrics configured, use some defaults
-	if len(metricConfiguration) == 0 {
-		slog.Info("Using Defalt Metric OID Configuration")
-		metricConfiguration = []monitors.SNMPMetricConfig{
-			{
-				OID:         "1.3.6.1.2.1.1.5.0",
-				Description: "System Name",
-				MetricType:  "string",
-				Name:        "sysName",
-			},
-			{
-				OID:         "1.3.6.1.2.1.1.1.0",
-				Description: "System Description",
-				MetricType:  "string",
-				Name:        "sysDescr",
-			},
-			// Add more default metrics as needed
-		}
-	}
-
 	// Define metrics to collect
 	// metrics = []struct {
 	// 	OID         string
@@ -173,31 +149,43 @@ func (s *NetworkSNMPPlugin) Check(ctx context.Context, db *sql.DB, netDevice mon
 	// 	}
 	// }
 
-	switch config.SNMPVersion {
-	case "v2", "v2c":
-		// For v2, we only need community string
-		if config.CommunityString == "" {
-			log.Println("Using default community string for SNMP v2")
-			config.CommunityString = "public"
-		}
-
-	case "v3":
-		// For v3, we need auth username and password
-		if config.AuthUsernameV3 == "" || config.AuthPasswordV3 == "" {
+	// SNMP tables walked in addition to the scalar metrics, left to the device profile when not configured
+	tables := utils.ConfigStringSlice(netDevice.Configuration, "snmpTables")
+	_, tablesConfigured := netDevice.Configuration["snmpTables"]
+	for _, table := range tables {
+		switch table {
+		case monitors.TableInterfaces, monitors.TableStorage, monitors.TableProcessors, monitors.TableUPS, monitors.TableSensors:
+		default:
 			status.FailureCount++
-			status.HealthReport = constants.GetStatusInfo(constants.InvalidConfiguration, "SNMP v3 requires authUsernameV3 and authPasswordV3")
-			return status, fmt.Errorf("SNMP v3 requires authUsernameV3 and authPasswordV3")
+			status.HealthReport = constants.GetStatusInfo(constants.InvalidConfiguration, "unknown SNMP table "+table)
+			return status, fmt.Errorf("unknown SNMP table %q", table)
 		}
 	}
 
-	networkManager := &monitors.NetworkManager{
-		Target:       netDevice.Host,
-		AuthUser:     config.AuthUsernameV3,
-		AuthPassword: config.AuthPasswordV3,
-		PrivPassword: config.PrivPassword,
-		Community:    config.CommunityString,
+	selector, err := monitors.NewInterfaceSelector(
+		utils.ConfigString(netDevice.Configuration, "interfaceInclude", ""),
+		utils.ConfigString(netDevice.Configuration, "interfaceExclude", ""))
+	if err != nil {
+		status.FailureCount++
+		status.HealthReport = constants.GetStatusInfo(constants.InvalidConfiguration, err.Error())
+		return status, err
 	}
 
+	sensorConfig, err := monitors.ParseSensorConfig(netDevice.Configuration)
+	if err != nil {
+		status.FailureCount++
+		status.HealthReport = constants.GetStatusInfo(constants.InvalidConfiguration, err.Error())
+		return status, err
+	}
+
+	if config.SNMPVersion != monitors.SNMPVersion3 && config.CommunityString == "" {
+		log.Println("Using default community string for SNMP", config.SNMPVersion)
+		config.CommunityString = "public"
+	}
+
+	networkManager := config.NetworkManager(netDevice.Host)
+	networkManager.Counters = s.counters
+
 	snmpClient := networkManager.SNMPClient(config.SNMPVersion)
 
 	snmpConnectErr := snmpClient.SNMP.Connect()
@@ -216,8 +204,50 @@ func (s *NetworkSNMPPlugin) Check(ctx context.Context, db *sql.DB, netDevice mon
 		}
 	}(snmpClient.SNMP.Conn)
 
+	// Devices without their own metrics get them from the profile matching their sysObjectID, devices
+	// with metrics but no tables walk the tables of the named or the generic profile
+	if len(metricConfiguration) == 0 || !tablesConfigured {
+		profileName := utils.ConfigString(netDevice.Configuration, "snmpProfile", "")
+		if profileName == "" && len(metricConfiguration) > 0 {
+			profileName = snmpprofiles.GenericProfile
+		}
+
+		profile, sysObjectID, err := snmpprofiles.Default.Select(snmpClient.SNMP, profileName)
+		if err != nil {
+			slog.WarnContext(ctx, "SNMP profile selection failed, using the generic profile", "Error", err.Error())
+			status.Details["snmp_profile_error"] = err.Error()
+			profile, err = snmpprofiles.Default.ByName(snmpprofiles.GenericProfile)
+		}
+		if err != nil && len(metricConfiguration) == 0 {
+			status.FailureCount++
+			status.HealthReport = constants.GetStatusInfo(constants.InvalidConfiguration, err.Error())
+			return status, err
+		}
+
+		if err == nil {
+			status.Details["snmp_profile"] = profile.Name
+			if sysObjectID != "" {
+				status.Details["sys_object_id"] = sysObjectID
+			}
+			if len(metricConfiguration) == 0 {
+				metricConfiguration = profile.Metrics
+			}
+			if !tablesConfigured {
+				tables = profile.Tables
+			}
+		}
+	}
+
+	metricConfiguration, err = snmpprofiles.Default.ResolveMetrics(metricConfiguration)
+	if err != nil {
+		status.FailureCount++
+		status.HealthReport = constants.GetStatusInfo(constants.InvalidConfiguration, err.Error())
+		return status, err
+	}
+
 	// Collect metrics
-	deviceMetrics, err := networkManager.CollectSNMPMetrics(snmpClient.SNMP, netDevice.SystemMonitorId.String(), netDevice.Host, config.SNMPMetrics)
+	config.SNMPMetrics = metricConfiguration
+	deviceMetrics, utilization, err := networkManager.CollectSNMPMetrics(snmpClient.SNMP, netDevice.SystemMonitorId.String(), netDevice.Host, config.SNMPMetrics)
 	if err != nil {
 		slog.ErrorContext(ctx, "Metric collection failed: ", "Error", err.Error())
 
@@ -226,6 +256,60 @@ func (s *NetworkSNMPPlugin) Check(ctx context.Context, db *sql.DB, netDevice mon
 		return status, err
 	}
 
+	// Engine discovery ran with the first request, boots increase with every restart of the agent
+	if usm, ok := snmpClient.SNMP.SecurityParameters.(*gosnmp.UsmSecurityParameters); ok && usm.AuthoritativeEngineID != "" {
+		status.Details["engine_id"] = hex.EncodeToString([]byte(usm.AuthoritativeEngineID))
+		status.Details["engine_boots"] = usm.AuthoritativeEngineBoots
+	}
+
+	var tableMetrics []mstypes.NetworkTableMetric
+	var readings []mstypes.SensorReading
+	tableErrors := make(map[string]string)
+	for _, table := range tables {
+		var rows []mstypes.NetworkTableMetric
+		var usage []monitors.InterfaceUtilization
+		var sensors []mstypes.SensorReading
+
+		switch table {
+		case monitors.TableInterfaces:
+			rows, usage, err = networkManager.CollectInterfaceMetrics(snmpClient.SNMP, netDevice.SystemMonitorId.String(), netDevice.Host, selector)
+		case monitors.TableStorage:
+			rows, err = networkManager.CollectStorageMetrics(snmpClient.SNMP, netDevice.SystemMonitorId.String(), netDevice.Host)
+		case monitors.TableProcessors:
+			rows, err = networkManager.CollectProcessorMetrics(snmpClient.SNMP, netDevice.SystemMonitorId.String(), netDevice.Host)
+		case monitors.TableUPS:
+			sensors, err = networkManager.GetUPSSensors(snmpClient.SNMP)
+		case monitors.TableSensors:
+			sensors, err = networkManager.GetEntitySensors(snmpClient.SNMP)
+		}
+		if err != nil {
+			slog.ErrorContext(ctx, "SNMP table collection failed", "Table", table, "Error", err.Error())
+
+			// Tables of the profile are best effort, not every device implements every MIB it lists. Partial
+			// readings, e.g. the UPS battery without the input lines, are kept.
+			if !tablesConfigured || len(sensors) > 0 {
+				tableErrors[table] = err.Error()
+				readings = append(readings, sensors...)
+				continue
+			}
+
+			status.FailureCount++
+			status.HealthReport = constants.GetStatusInfo(constants.Escalation, "SNMP "+table+" collection failed: "+err.Error())
+			return status, err
+		}
+
+		tableMetrics = append(tableMetrics, rows...)
+		utilization = append(utilization, usage...)
+		readings = append(readings, sensors...)
+	}
+	if len(tableErrors) > 0 {
+		status.Details["table_errors"] = tableErrors
+	}
+
+	// Sensor readings are stored in the configured units
+	readings, sensorAlerts := sensorConfig.Apply(readings)
+	tableMetrics = append(tableMetrics, monitors.SensorMetrics(netDevice.SystemMonitorId.String(), netDevice.Host, readings)...)
+
 	// for _, metric := range config.SNMPMetrics {
 	// 	result, err := snmpClient.snmp.Get([]string{metric.OID})
 	// 	if err != nil {
@@ -274,12 +358,111 @@ func (s *NetworkSNMPPlugin) Check(ctx context.Context, db *sql.DB, netDevice mon
 		return status, err
 	}
 
+	if err := repository.SyncNetworkTableMetrics(db, tableMetrics); err != nil {
+		slog.ErrorContext(ctx, "Error syncing SNMP table metrics to database:", "Error", err.Error())
+
+		status.FailureCount++
+		status.HealthReport = constants.GetStatusInfo(constants.Escalation, "Error saving metrics to database "+err.Error())
+		return status, err
+	}
+
+	if len(utilization) > 0 {
+		status.Details["interface_utilization"] = utilization
+	}
+
+	// Interfaces above the thresholds are alerted on one by one
+	warnPercent := utils.ConfigFloat(netDevice.Configuration, "utilizationWarnPercent", 80)
+	criticalPercent := utils.ConfigFloat(netDevice.Configuration, "utilizationCriticalPercent", 95)
+
+	level := constants.Healthy
+	var problems []string
+	var talkers []flows.Summary
+	for _, usage := range utilization {
+		severity := ""
+		switch {
+		case criticalPercent > 0 && usage.Percent >= criticalPercent:
+			severity = "critical"
+			level = constants.Degraded
+		case warnPercent > 0 && usage.Percent >= warnPercent:
+			severity = "warning"
+			level = max(level, constants.Escalation)
+		default:
+			continue
+		}
+
+		name := usage.InterfaceID
+		if usage.InterfaceName != "" {
+			name = usage.InterfaceName
+		}
+
+		message := fmt.Sprintf("%s utilization %.1f%% of %s", usage.Direction, usage.Percent, formatBitRate(usage.SpeedBps))
+		// Flow exports from the device tell who is using the bandwidth
+		if summary, ok := flows.InterfaceSummary(netDevice.SystemMonitorId.String(), usage.InterfaceID, usage.Direction, flows.AlertWindow, 3); ok && len(summary.TopTalkers) > 0 {
+			message += ", top talkers " + summary.Describe(3)
+			talkers = append(talkers, summary)
+		}
+		problems = append(problems, fmt.Sprintf("interface %s %s", name, message))
+		status.ObjectAlerts = append(status.ObjectAlerts, monitors.ObjectAlert{
+			Kind:     "Interface",
+			Name:     name,
+			Reason:   "HighUtilization",
+			Message:  message,
+			Severity: severity,
+		})
+	}
+
+	if len(talkers) > 0 {
+		status.Details["interface_top_talkers"] = talkers
+	}
+
+	if len(readings) > 0 {
+		facility := mstypes.FacilityStatus{Readings: readings, UpdatedAt: time.Now()}
+		for _, reading := range readings {
+			if reading.Type == monitors.SensorOnBattery && reading.Value == 1 {
+				facility.OnBattery = true
+			}
+		}
+		for _, alert := range sensorAlerts {
+			switch alert.Severity {
+			case "critical":
+				level = constants.Degraded
+			case "warning":
+				level = max(level, constants.Escalation)
+			}
+			if facility.Severity != "critical" {
+				facility.Severity = alert.Severity
+			}
+			problems = append(problems, fmt.Sprintf("sensor %s %s", alert.Name, alert.Message))
+		}
+		status.ObjectAlerts = append(status.ObjectAlerts, sensorAlerts...)
+		status.Details["sensors"] = readings
+		notifier.UpdateFacility(netDevice.SystemMonitorId.String(), facility)
+	}
+
+	if level != constants.Healthy {
+		status.FailureCount++
+		message := strings.Join(problems, "; ")
+		status.HealthReport = constants.GetStatusInfo(level, message)
+		return status, fmt.Errorf("%s", message)
+	}
+
 	status.FailureCount = 0
 	status.HealthReport = constants.GetStatusInfo(constants.Healthy, "")
 
 	return status, nil
 }
 
+// formatBitRate renders an interface speed such as 1 Gbps
+func formatBitRate(bps float64) string {
+	units := []string{"bps", "Kbps", "Mbps", "Gbps", "Tbps"}
+	i := 0
+	for bps >= 1000 && i < len(units)-1 {
+		bps /= 1000
+		i++
+	}
+	return strconv.FormatFloat(bps, 'f', -1, 64) + " " + units[i]
+}
+
 // Helper function to format timeticks into a human-readable duration
 
 // Helper function to safely get strings from map
@@ -301,7 +484,7 @@ func (p *NetworkSNMPPlugin) Description() string {
 }
 
 func (p *NetworkSNMPPlugin) SupportedTypes() []monitors.ServiceType {
-	return []monitors.ServiceType{monitors.ServiceMonitorWebModules, monitors.ServiceMonitorServer}
+	return []monitors.ServiceType{monitors.ServiceMonitorWebModules, monitors.ServiceMonitorServer, monitors.ServiceMonitorSNMP}
 }
 
 func (hc *NetworkSNMPPlugin) Cleanup() error {
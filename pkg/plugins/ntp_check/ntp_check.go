@@ -0,0 +1,284 @@
+package ntpcheck
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ZEGIFTED/MS.GoMonitor/monitors"
+	"github.com/ZEGIFTED/MS.GoMonitor/pkg/constants"
+	"github.com/ZEGIFTED/MS.GoMonitor/pkg/utils"
+	mstypes "github.com/ZEGIFTED/MS.GoMonitor/types"
+)
+
+// ServerResult is the outcome of querying one NTP server
+type ServerResult struct {
+	Server           string  `json:"server"`
+	Reachable        bool    `json:"reachable"`
+	Reach            string  `json:"reach"` // Answered samples out of sent, like the ntpq reach register
+	Stratum          int     `json:"stratum,omitempty"`
+	Leap             int     `json:"leap"`
+	ReferenceID      string  `json:"reference_id,omitempty"`
+	OffsetMs         float64 `json:"offset_ms"`
+	DelayMs          float64 `json:"delay_ms"`
+	RootDelayMs      float64 `json:"root_delay_ms"`
+	RootDispersionMs float64 `json:"root_dispersion_ms"`
+	Error            string  `json:"error,omitempty"`
+}
+
+type NTPChecker struct {
+	config map[string]any
+}
+
+func NewNTPChecker() *NTPChecker {
+	return &NTPChecker{}
+}
+
+func (n *NTPChecker) Initialize(config map[string]any) error {
+	n.config = config
+	return nil
+}
+
+func (n *NTPChecker) Check(ctx context.Context, db *sql.DB, service monitors.ServiceMonitorData) (monitors.MonitoringResult, error) {
+	status := monitors.MonitoringResult{
+		SystemMonitorId: service.SystemMonitorId.String(),
+		ServicePluginID: n.Name(),
+		HealthReport:    constants.GetStatusInfo(constants.UnknownStatus, ""),
+		Details:         make(map[string]any),
+		LastCheckTime:   time.Now(),
+	}
+
+	cfg := service.Configuration
+	servers := utils.ConfigStringSlice(cfg, "ntpServers")
+	if len(servers) == 0 && service.Host != "" {
+		servers = []string{service.Host}
+	}
+	checkAgent := utils.ConfigBool(cfg, "checkAgentClock", false)
+	if len(servers) == 0 && !checkAgent {
+		status.FailureCount++
+		status.HealthReport = constants.GetStatusInfo(constants.InvalidConfiguration, "")
+		return status, fmt.Errorf("host cannot be empty")
+	}
+
+	timeout := utils.ConfigDuration(cfg, "timeout", 5*time.Second)
+	samples := max(utils.ConfigInt(cfg, "ntpSamples", 3), 1)
+
+	warnOffset := utils.ConfigFloat(cfg, "offsetWarnMs", 100)
+	criticalOffset := utils.ConfigFloat(cfg, "offsetCriticalMs", 1000)
+	maxStratum := utils.ConfigInt(cfg, "maxStratum", 0)
+	dispersionWarn := utils.ConfigFloat(cfg, "rootDispersionWarnMs", 0)
+
+	level := constants.Healthy
+	var problems []string
+	raise := func(to int, message string) {
+		level = max(level, to)
+		problems = append(problems, message)
+	}
+
+	results := make([]ServerResult, 0, len(servers))
+	reachable := 0
+	for _, server := range servers {
+		result := queryServer(ctx, server, samples, timeout)
+		results = append(results, result)
+		if !result.Reachable {
+			continue
+		}
+		reachable++
+
+		offset := math.Abs(result.OffsetMs)
+		switch {
+		case result.Leap == LeapUnsynchronized:
+			raise(constants.Degraded, fmt.Sprintf("%s is not synchronised", server))
+		case criticalOffset > 0 && offset >= criticalOffset:
+			raise(constants.Degraded, fmt.Sprintf("%s offset %.1fms", server, result.OffsetMs))
+		case warnOffset > 0 && offset >= warnOffset:
+			raise(constants.Escalation, fmt.Sprintf("%s offset %.1fms", server, result.OffsetMs))
+		}
+		if maxStratum > 0 && result.Stratum > maxStratum {
+			raise(constants.Escalation, fmt.Sprintf("%s stratum %d above %d", server, result.Stratum, maxStratum))
+		}
+		if dispersionWarn > 0 && result.RootDispersionMs >= dispersionWarn {
+			raise(constants.Escalation, fmt.Sprintf("%s root dispersion %.1fms", server, result.RootDispersionMs))
+		}
+	}
+
+	if len(servers) > 0 {
+		status.Details["servers"] = results
+		status.Details["reachable_servers"] = reachable
+		if len(results) == 1 && results[0].Reachable {
+			status.Details["offset_ms"] = results[0].OffsetMs
+			status.Details["stratum"] = results[0].Stratum
+			status.Details["root_dispersion_ms"] = results[0].RootDispersionMs
+		}
+
+		switch {
+		case reachable == 0:
+			raise(constants.Degraded, "no NTP server reachable")
+		case reachable < len(servers):
+			raise(constants.Escalation, fmt.Sprintf("%d of %d NTP servers reachable", reachable, len(servers)))
+		}
+	}
+
+	if checkAgent {
+		skew, err := agentClockSkew(service, timeout)
+		if err != nil {
+			raise(constants.Escalation, "agent clock not checked: "+err.Error())
+		} else {
+			status.Details["agent_skew_seconds"] = skew.Seconds()
+
+			seconds := math.Abs(skew.Seconds())
+			if critical := utils.ConfigFloat(cfg, "agentSkewCriticalSeconds", 30); critical > 0 && seconds >= critical {
+				raise(constants.Degraded, fmt.Sprintf("agent clock skewed by %v", skew.Round(time.Second)))
+			} else if warn := utils.ConfigFloat(cfg, "agentSkewWarnSeconds", 5); warn > 0 && seconds >= warn {
+				raise(constants.Escalation, fmt.Sprintf("agent clock skewed by %v", skew.Round(time.Second)))
+			}
+		}
+	}
+
+	if level != constants.Healthy {
+		status.FailureCount++
+		message := strings.Join(problems, "; ")
+		status.HealthReport = constants.GetStatusInfo(level, message)
+		return status, fmt.Errorf("%s", message)
+	}
+
+	status.FailureCount = 0
+	status.HealthReport = constants.GetStatusInfo(constants.Healthy, "")
+	status.LastServiceUpTime = time.Now()
+
+	return status, nil
+}
+
+// queryServer sends samples requests and keeps the one with the lowest round trip delay, which has the
+// smallest error bound on its offset
+func queryServer(ctx context.Context, server string, samples int, timeout time.Duration) ServerResult {
+	address := server
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		address = net.JoinHostPort(server, "123")
+	}
+
+	result := ServerResult{Server: server}
+	var best *Response
+	answered := 0
+	var lastErr error
+
+	for i := 0; i < samples; i++ {
+		queryCtx, cancel := context.WithTimeout(ctx, timeout)
+		response, err := Query(queryCtx, address)
+		cancel()
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		answered++
+		if best == nil || response.Delay < best.Delay {
+			best = &response
+		}
+	}
+
+	result.Reach = fmt.Sprintf("%d/%d", answered, samples)
+	if best == nil {
+		result.Error = lastErr.Error()
+		return result
+	}
+
+	result.Reachable = true
+	result.Stratum = best.Stratum
+	result.Leap = best.Leap
+	result.ReferenceID = best.ReferenceID
+	result.OffsetMs = milliseconds(best.Offset)
+	result.DelayMs = milliseconds(best.Delay)
+	result.RootDelayMs = milliseconds(best.RootDelay)
+	result.RootDispersionMs = milliseconds(best.RootDispersion)
+	return result
+}
+
+// agentClockSkew estimates how far the agent's clock is from the engine's. The HTTP Date header
+// gives the agent's clock to the second; metric samples stamped in the future are a stronger
+// signal and override it.
+func agentClockSkew(service monitors.ServiceMonitorData, timeout time.Duration) (time.Duration, error) {
+	client, endpoint, err := service.AgentRepository.ValidateAgentURL(service.AgentAPIBaseURL, "/api/v1/agent/health")
+	if err != nil {
+		return 0, err
+	}
+	client.Timeout = timeout
+
+	sent := time.Now()
+	resp, err := client.Get(endpoint)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	received := time.Now()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("agent returned status %d", resp.StatusCode)
+	}
+
+	midpoint := sent.Add(received.Sub(sent) / 2)
+	var skew time.Duration
+	if date, err := http.ParseTime(resp.Header.Get("Date")); err == nil {
+		// Date is truncated to the second, compare against the midpoint truncated the same way
+		skew = date.Sub(midpoint.Truncate(time.Second))
+	}
+
+	var metrics mstypes.AgentMetricResponse
+	if err := json.Unmarshal(body, &metrics); err == nil {
+		var newest time.Time
+		for _, sample := range append(metrics.SystemInfo.CPU, metrics.SystemInfo.Memory...) {
+			if len(sample) == 0 {
+				continue
+			}
+			if t := utils.ConvertUnixToTime(sample[0]); t.After(newest) {
+				newest = t
+			}
+		}
+		if ahead := newest.Sub(received); ahead > skew {
+			skew = ahead
+		}
+	}
+
+	return skew, nil
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+// SkipsTCPPrecheck lets NTP servers, which only listen on UDP/123, reach the plugin
+func (n *NTPChecker) SkipsTCPPrecheck() bool {
+	return true
+}
+
+func (n *NTPChecker) Name() string {
+	return "ntp_check"
+}
+
+func (n *NTPChecker) Description() string {
+	return "Measures clock offset against NTP servers and agent clock skew"
+}
+
+func (n *NTPChecker) SupportedTypes() []monitors.ServiceType {
+	return []monitors.ServiceType{
+		monitors.ServiceMonitorServer,
+		monitors.ServiceMonitorAgent,
+		monitors.ServiceMonitorSNMP,
+	}
+}
+
+func (n *NTPChecker) Cleanup() error {
+	return nil
+}
+
+var Plugin monitors.ServiceMonitorPlugin = NewNTPChecker()
@@ -0,0 +1,155 @@
+package ntpcheck
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"time"
+)
+
+// ntpEpochOffset is the number of seconds between the NTP era 0 epoch (1900) and the Unix epoch
+const ntpEpochOffset = 2208988800
+
+// Leap indicator values, 3 means the server clock is not synchronised
+const (
+	LeapNone           = 0
+	LeapUnsynchronized = 3
+)
+
+// Response is the part of an NTP server reply the check reports, with the offset and round trip
+// delay calculated as in RFC 4330 section 5
+type Response struct {
+	Leap           int
+	Version        int
+	Stratum        int
+	Precision      time.Duration
+	RootDelay      time.Duration
+	RootDispersion time.Duration
+	ReferenceID    string
+	ReferenceTime  time.Time
+	Offset         time.Duration
+	Delay          time.Duration
+}
+
+// Query sends a single SNTPv4 client request to address (host:port)
+func Query(ctx context.Context, address string) (Response, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "udp", address)
+	if err != nil {
+		return Response{}, err
+	}
+	defer conn.Close()
+
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(5 * time.Second)
+	}
+	_ = conn.SetDeadline(deadline)
+
+	request := make([]byte, 48)
+	request[0] = 0<<6 | 4<<3 | 3 // LI 0, version 4, mode 3 (client)
+
+	// The transmit timestamp is echoed back as the originate timestamp and checked to reject stray replies
+	t1 := time.Now()
+	binary.BigEndian.PutUint64(request[40:], toNTPTime(t1))
+
+	if _, err := conn.Write(request); err != nil {
+		return Response{}, err
+	}
+
+	reply := make([]byte, 128)
+	for {
+		n, err := conn.Read(reply)
+		if err != nil {
+			return Response{}, err
+		}
+		t4 := time.Now()
+
+		if n < 48 {
+			continue
+		}
+		if binary.BigEndian.Uint64(reply[24:]) != binary.BigEndian.Uint64(request[40:]) {
+			continue
+		}
+
+		return parseResponse(reply[:n], t1, t4)
+	}
+}
+
+func parseResponse(b []byte, t1, t4 time.Time) (Response, error) {
+	mode := b[0] & 0x07
+	if mode != 4 && mode != 5 {
+		return Response{}, fmt.Errorf("unexpected NTP mode %d", mode)
+	}
+
+	r := Response{
+		Leap:           int(b[0] >> 6),
+		Version:        int(b[0]>>3) & 0x07,
+		Stratum:        int(b[1]),
+		Precision:      time.Duration(float64(time.Second) * pow2(int8(b[3]))),
+		RootDelay:      shortDuration(binary.BigEndian.Uint32(b[4:])),
+		RootDispersion: shortDuration(binary.BigEndian.Uint32(b[8:])),
+		ReferenceTime:  fromNTPTime(binary.BigEndian.Uint64(b[16:])),
+	}
+
+	// Kiss-o'-Death packets carry stratum 0 and a four character code in the reference ID
+	if r.Stratum == 0 {
+		return r, fmt.Errorf("kiss-o'-death %q from server", string(b[12:16]))
+	}
+
+	if r.Stratum == 1 {
+		r.ReferenceID = string(trimZero(b[12:16]))
+	} else {
+		r.ReferenceID = net.IP(b[12:16]).String()
+	}
+
+	t2 := fromNTPTime(binary.BigEndian.Uint64(b[32:]))
+	t3 := fromNTPTime(binary.BigEndian.Uint64(b[40:]))
+	if t3.IsZero() {
+		return r, fmt.Errorf("server sent no transmit timestamp")
+	}
+
+	r.Offset = (t2.Sub(t1) + t3.Sub(t4)) / 2
+	r.Delay = t4.Sub(t1) - t3.Sub(t2)
+
+	return r, nil
+}
+
+func toNTPTime(t time.Time) uint64 {
+	seconds := uint64(t.Unix() + ntpEpochOffset)
+	fraction := uint64(t.Nanosecond()) << 32 / uint64(time.Second)
+	return seconds<<32 | fraction
+}
+
+func fromNTPTime(v uint64) time.Time {
+	if v == 0 {
+		return time.Time{}
+	}
+	seconds := int64(v>>32) - ntpEpochOffset
+	nanos := (v & 0xffffffff) * uint64(time.Second) >> 32
+	return time.Unix(seconds, int64(nanos))
+}
+
+// shortDuration converts the 16.16 fixed point NTP short format
+func shortDuration(v uint32) time.Duration {
+	return time.Duration(uint64(v) * uint64(time.Second) >> 16)
+}
+
+func pow2(exp int8) float64 {
+	v := 1.0
+	for ; exp < 0; exp++ {
+		v /= 2
+	}
+	for ; exp > 0; exp-- {
+		v *= 2
+	}
+	return v
+}
+
+func trimZero(b []byte) []byte {
+	for len(b) > 0 && b[len(b)-1] == 0 {
+		b = b[:len(b)-1]
+	}
+	return b
+}
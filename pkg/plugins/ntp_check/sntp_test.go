@@ -0,0 +1,115 @@
+package ntpcheck
+
+import (
+	"encoding/binary"
+	"strings"
+	"testing"
+	"time"
+)
+
+// reply builds a server packet with receive time t2 and transmit time t3
+func reply(mode, stratum byte, t2, t3 time.Time) []byte {
+	b := make([]byte, 48)
+	b[0] = 0<<6 | 4<<3 | mode
+	b[1] = stratum
+	copy(b[12:16], "GPS")
+	if !t2.IsZero() {
+		binary.BigEndian.PutUint64(b[32:], toNTPTime(t2))
+	}
+	if !t3.IsZero() {
+		binary.BigEndian.PutUint64(b[40:], toNTPTime(t3))
+	}
+	return b
+}
+
+func TestParseResponseOffsetAndDelay(t *testing.T) {
+	t1 := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	ms := time.Millisecond
+
+	tests := []struct {
+		name       string
+		t2, t3, t4 time.Duration // relative to t1
+		wantOffset time.Duration
+		wantDelay  time.Duration
+	}{
+		{"in sync", 10 * ms, 11 * ms, 21 * ms, 0, 20 * ms},
+		{"server ahead", time.Second + 10*ms, time.Second + 10*ms, 20 * ms, time.Second, 20 * ms},
+		{"server behind", -500*ms + 5*ms, -500*ms + 6*ms, 11 * ms, -500 * ms, 10 * ms},
+		{"asymmetric path", 30 * ms, 30 * ms, 40 * ms, 10 * ms, 40 * ms},
+		{"slow server processing", 10 * ms, 210 * ms, 220 * ms, 0, 20 * ms},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := reply(4, 2, t1.Add(tt.t2), t1.Add(tt.t3))
+			r, err := parseResponse(b, t1, t1.Add(tt.t4))
+			if err != nil {
+				t.Fatalf("parseResponse: %v", err)
+			}
+			// NTP fractions have sub-nanosecond resolution, conversions may be off by a nanosecond
+			if d := r.Offset - tt.wantOffset; d < -time.Microsecond || d > time.Microsecond {
+				t.Errorf("offset = %v, want %v", r.Offset, tt.wantOffset)
+			}
+			if d := r.Delay - tt.wantDelay; d < -time.Microsecond || d > time.Microsecond {
+				t.Errorf("delay = %v, want %v", r.Delay, tt.wantDelay)
+			}
+		})
+	}
+}
+
+func TestParseResponseRejects(t *testing.T) {
+	t1 := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	t4 := t1.Add(20 * time.Millisecond)
+
+	tests := []struct {
+		name    string
+		packet  []byte
+		wantErr string
+	}{
+		{"client mode", reply(3, 2, t1, t1), "unexpected NTP mode"},
+		{"kiss-o'-death", reply(4, 0, t1, t1), "kiss-o'-death"},
+		{"no transmit timestamp", reply(4, 2, t1, time.Time{}), "no transmit timestamp"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseResponse(tt.packet, t1, t4)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("err = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNTPTimeRoundTrip(t *testing.T) {
+	tests := []time.Time{
+		time.Unix(0, 0),
+		time.Date(2024, 5, 1, 12, 0, 0, 123456789, time.UTC),
+		time.Date(2036, 2, 7, 6, 28, 15, 999999999, time.UTC),
+	}
+
+	for _, want := range tests {
+		got := fromNTPTime(toNTPTime(want))
+		if d := got.Sub(want); d < -time.Nanosecond || d > time.Nanosecond {
+			t.Errorf("round trip of %v = %v", want, got)
+		}
+	}
+}
+
+func TestShortDuration(t *testing.T) {
+	tests := []struct {
+		in   uint32
+		want time.Duration
+	}{
+		{0, 0},
+		{1 << 16, time.Second},
+		{1 << 15, 500 * time.Millisecond},
+		{0x0001_4000, 1250 * time.Millisecond},
+	}
+
+	for _, tt := range tests {
+		if got := shortDuration(tt.in); got != tt.want {
+			t.Errorf("shortDuration(%#x) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,242 @@
+package path_monitor
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ZEGIFTED/MS.GoMonitor/internal/network"
+	"github.com/ZEGIFTED/MS.GoMonitor/internal/repository"
+	"github.com/ZEGIFTED/MS.GoMonitor/monitors"
+	"github.com/ZEGIFTED/MS.GoMonitor/pkg/constants"
+	"github.com/ZEGIFTED/MS.GoMonitor/pkg/utils"
+	mstypes "github.com/ZEGIFTED/MS.GoMonitor/types"
+)
+
+// baselineWeight is the smoothing factor of the per hop latency baseline (EWMA)
+const baselineWeight = 0.3
+
+type PathMonitorPlugin struct {
+	config map[string]any
+
+	mu        sync.Mutex
+	baselines map[string]map[string]time.Duration // SystemMonitorId -> hop address -> baseline RTT
+}
+
+func NewPathMonitorPlugin() *PathMonitorPlugin {
+	return &PathMonitorPlugin{
+		baselines: make(map[string]map[string]time.Duration),
+	}
+}
+
+func (p *PathMonitorPlugin) Initialize(config map[string]any) error {
+	p.config = config
+	return nil
+}
+
+func (p *PathMonitorPlugin) Check(ctx context.Context, db *sql.DB, service monitors.ServiceMonitorData) (monitors.MonitoringResult, error) {
+	status := monitors.MonitoringResult{
+		SystemMonitorId: service.SystemMonitorId.String(),
+		ServicePluginID: p.Name(),
+		HealthReport:    constants.GetStatusInfo(constants.UnknownStatus, ""),
+		Details:         make(map[string]any),
+		LastCheckTime:   time.Now(),
+	}
+
+	cfg := service.Configuration
+	target := utils.ConfigString(cfg, "pathTarget", service.Host)
+	if target == "" {
+		status.FailureCount++
+		status.HealthReport = constants.GetStatusInfo(constants.InvalidConfiguration, "")
+		return status, fmt.Errorf("host cannot be empty")
+	}
+
+	tracer := network.NewTracer(target)
+	tracer.MaxHops = utils.ConfigInt(cfg, "pathMaxHops", tracer.MaxHops)
+	tracer.ProbesPerHop = min(max(utils.ConfigInt(cfg, "pathProbesPerHop", tracer.ProbesPerHop), 1), 15)
+	tracer.Timeout = utils.ConfigDuration(cfg, "pathProbeTimeout", tracer.Timeout)
+	tracer.ResolveNames = utils.ConfigBool(cfg, "pathResolveNames", true)
+
+	path, err := tracer.Traceroute(ctx)
+	if err != nil {
+		status.FailureCount++
+		status.HealthReport = constants.GetStatusInfo(constants.Escalation, "Traceroute failed "+err.Error())
+		return status, fmt.Errorf("traceroute to %s failed: %v", target, err)
+	}
+
+	systemMonitorId := service.SystemMonitorId.String()
+	previous, hasPrevious := p.previousPath(db, systemMonitorId, target)
+
+	network.StorePath(systemMonitorId, path)
+	p.persistPath(ctx, db, systemMonitorId, path)
+
+	status.Details["destination"] = path.Destination
+	status.Details["address"] = path.Address
+	status.Details["reached"] = path.Reached
+	status.Details["hops"] = path.Summary()
+	status.Details["fingerprint"] = path.Fingerprint()
+
+	var warnings []string
+
+	if hasPrevious && RoutesDiffer(previous, path.Route()) && utils.ConfigBool(cfg, "alertOnRouteChange", true) {
+		status.Details["previous_route"] = previous
+		warnings = append(warnings, fmt.Sprintf("route to %s changed from [%s] to [%s]", target, strings.Join(previous, " > "), strings.Join(path.Route(), " > ")))
+	}
+
+	spikeFactor := utils.ConfigFloat(cfg, "hopLatencySpikeFactor", 3)
+	spikeMin := time.Duration(utils.ConfigFloat(cfg, "hopLatencySpikeMinMs", 20)) * time.Millisecond
+	warnings = append(warnings, p.detectLatencySpikes(systemMonitorId, path, spikeFactor, spikeMin)...)
+
+	if !path.Reached && utils.ConfigBool(cfg, "requireDestinationReached", true) {
+		message := fmt.Sprintf("destination %s not reached within %d hops", target, len(path.Hops))
+		status.FailureCount++
+		status.HealthReport = constants.GetStatusInfo(constants.Degraded, message)
+		return status, fmt.Errorf("%s", message)
+	}
+
+	if len(warnings) > 0 {
+		message := strings.Join(warnings, "; ")
+		status.FailureCount++
+		status.HealthReport = constants.GetStatusInfo(constants.Escalation, message)
+		return status, fmt.Errorf("path check failed: %s", message)
+	}
+
+	status.FailureCount = 0
+	status.HealthReport = constants.GetStatusInfo(constants.Healthy, "")
+	status.LastServiceUpTime = time.Now()
+
+	return status, nil
+}
+
+// previousPath returns the last known route, falling back to the database after an engine restart
+func (p *PathMonitorPlugin) previousPath(db *sql.DB, systemMonitorId, target string) ([]string, bool) {
+	if last, ok := network.LastPath(systemMonitorId); ok && last.Destination == target {
+		return last.Route(), true
+	}
+
+	if db == nil {
+		return nil, false
+	}
+
+	record, found, err := repository.FetchLastNetworkPath(db, systemMonitorId, target)
+	if err != nil {
+		slog.Error("Error fetching previous network path", "Destination", target, "Error", err.Error())
+		return nil, false
+	}
+	if !found {
+		return nil, false
+	}
+
+	var hops []network.Hop
+	if err := json.Unmarshal([]byte(record.Hops), &hops); err != nil {
+		slog.Error("Error decoding stored network path", "Destination", target, "Error", err.Error())
+		return nil, false
+	}
+
+	return network.TraceResult{Hops: hops}.Route(), true
+}
+
+func (p *PathMonitorPlugin) persistPath(ctx context.Context, db *sql.DB, systemMonitorId string, path network.TraceResult) {
+	if db == nil {
+		return
+	}
+
+	hops, err := json.Marshal(path.Hops)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error encoding network path", "Destination", path.Destination, "Error", err.Error())
+		return
+	}
+
+	err = repository.SyncNetworkPath(db, mstypes.NetworkPathRecord{
+		SystemMonitorId: systemMonitorId,
+		Destination:     path.Destination,
+		Address:         path.Address,
+		Fingerprint:     path.Fingerprint(),
+		Hops:            string(hops),
+		Reached:         path.Reached,
+		CollectedAt:     path.TracedAt,
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Error syncing network path to database", "Destination", path.Destination, "Error", err.Error())
+	}
+}
+
+// detectLatencySpikes compares every hop against its smoothed baseline and updates the baseline
+func (p *PathMonitorPlugin) detectLatencySpikes(systemMonitorId string, path network.TraceResult, factor float64, minDelta time.Duration) []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	baseline, ok := p.baselines[systemMonitorId]
+	if !ok {
+		baseline = make(map[string]time.Duration)
+		p.baselines[systemMonitorId] = baseline
+	}
+
+	var spikes []string
+	for _, hop := range path.Hops {
+		rtt := hop.AvgRTT()
+		if hop.Address == "" || rtt == 0 {
+			continue
+		}
+
+		previous, seen := baseline[hop.Address]
+		if !seen {
+			baseline[hop.Address] = rtt
+			continue
+		}
+
+		if factor > 0 && float64(rtt) > float64(previous)*factor && rtt-previous > minDelta {
+			spikes = append(spikes, fmt.Sprintf("hop %d %s latency %v (baseline %v)", hop.TTL, hop.Address, rtt.Round(10*time.Microsecond), previous.Round(10*time.Microsecond)))
+		}
+
+		baseline[hop.Address] = time.Duration(baselineWeight*float64(rtt) + (1-baselineWeight)*float64(previous))
+	}
+
+	return spikes
+}
+
+// RoutesDiffer reports whether two routes take different hops. Unanswered hops ("*") match any address
+// so that routers rate limiting ICMP do not raise false route changes.
+func RoutesDiffer(previous, current []string) bool {
+	if len(previous) != len(current) {
+		return true
+	}
+
+	for i := range previous {
+		if previous[i] == "*" || current[i] == "*" {
+			continue
+		}
+		if previous[i] != current[i] {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (p *PathMonitorPlugin) Name() string {
+	return "path_monitor"
+}
+
+func (p *PathMonitorPlugin) Description() string {
+	return "Traces the network path to a service and detects route changes and hop latency spikes"
+}
+
+func (p *PathMonitorPlugin) SupportedTypes() []monitors.ServiceType {
+	return []monitors.ServiceType{
+		monitors.ServiceMonitorSNMP,
+		monitors.ServiceMonitorServer,
+		monitors.ServiceMonitorWebModules,
+	}
+}
+
+func (p *PathMonitorPlugin) Cleanup() error {
+	return nil
+}
+
+var Plugin monitors.ServiceMonitorPlugin = NewPathMonitorPlugin()
@@ -0,0 +1,203 @@
+package ping
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/ZEGIFTED/MS.GoMonitor/internal/network"
+	"github.com/ZEGIFTED/MS.GoMonitor/internal/repository"
+	"github.com/ZEGIFTED/MS.GoMonitor/monitors"
+	"github.com/ZEGIFTED/MS.GoMonitor/pkg/constants"
+	"github.com/ZEGIFTED/MS.GoMonitor/pkg/utils"
+	mstypes "github.com/ZEGIFTED/MS.GoMonitor/types"
+)
+
+// PingThresholds holds the loss and latency limits applied to each ping run
+type PingThresholds struct {
+	LossWarnPercent     float64
+	LossCriticalPercent float64
+	RTTWarn             time.Duration
+	RTTCritical         time.Duration
+	JitterWarn          time.Duration
+}
+
+type PingPlugin struct {
+	config map[string]any
+}
+
+func NewPingPlugin() *PingPlugin {
+	return &PingPlugin{}
+}
+
+func (p *PingPlugin) Initialize(config map[string]any) error {
+	p.config = config
+	return nil
+}
+
+func (p *PingPlugin) Check(ctx context.Context, db *sql.DB, service monitors.ServiceMonitorData) (monitors.MonitoringResult, error) {
+	status := monitors.MonitoringResult{
+		SystemMonitorId: service.SystemMonitorId.String(),
+		ServicePluginID: p.Name(),
+		HealthReport:    constants.GetStatusInfo(constants.UnknownStatus, ""),
+		Details:         make(map[string]any),
+		LastCheckTime:   time.Now(),
+	}
+
+	cfg := service.Configuration
+
+	targets := utils.ConfigStringSlice(cfg, "pingTargets")
+	if len(targets) == 0 && service.Host != "" {
+		targets = []string{service.Host}
+	}
+
+	if len(targets) == 0 {
+		status.FailureCount++
+		status.HealthReport = constants.GetStatusInfo(constants.InvalidConfiguration, "")
+		return status, fmt.Errorf("host cannot be empty")
+	}
+
+	pinger := network.NewPinger()
+	pinger.Count = max(utils.ConfigInt(cfg, "pingCount", pinger.Count), 1)
+	pinger.Interval = time.Duration(utils.ConfigFloat(cfg, "pingIntervalMs", float64(pinger.Interval.Milliseconds()))) * time.Millisecond
+	pinger.Timeout = utils.ConfigDuration(cfg, "pingTimeout", pinger.Timeout)
+	pinger.Size = utils.ConfigInt(cfg, "pingSize", pinger.Size)
+	pinger.Privileged = utils.ConfigBool(cfg, "pingPrivileged", false)
+
+	thresholds := PingThresholds{
+		LossWarnPercent:     utils.ConfigFloat(cfg, "lossWarnPercent", 20),
+		LossCriticalPercent: utils.ConfigFloat(cfg, "lossCriticalPercent", 60),
+		RTTWarn:             time.Duration(utils.ConfigFloat(cfg, "rttWarnMs", 0)) * time.Millisecond,
+		RTTCritical:         time.Duration(utils.ConfigFloat(cfg, "rttCriticalMs", 0)) * time.Millisecond,
+		JitterWarn:          time.Duration(utils.ConfigFloat(cfg, "jitterWarnMs", 0)) * time.Millisecond,
+	}
+
+	var critical, warnings []string
+	var metrics []mstypes.PingMetric
+
+	for _, target := range targets {
+		result, err := pinger.Ping(ctx, target)
+		if err != nil {
+			critical = append(critical, fmt.Sprintf("%s: %v", target, err))
+			continue
+		}
+
+		metric := toPingMetric(service.SystemMonitorId.String(), result)
+		metrics = append(metrics, metric)
+		status.Details[target] = map[string]any{
+			"address":     metric.Address,
+			"sent":        metric.Sent,
+			"received":    metric.Received,
+			"packet_loss": metric.PacketLoss,
+			"min_rtt_ms":  metric.MinRTT,
+			"avg_rtt_ms":  metric.AvgRTT,
+			"max_rtt_ms":  metric.MaxRTT,
+			"jitter_ms":   metric.Jitter,
+		}
+
+		level, message := thresholds.Evaluate(result)
+		switch level {
+		case constants.Degraded:
+			critical = append(critical, fmt.Sprintf("%s: %s", target, message))
+		case constants.Escalation:
+			warnings = append(warnings, fmt.Sprintf("%s: %s", target, message))
+		}
+	}
+
+	// Persisting the series must not mask the reachability result
+	if db != nil {
+		if err := repository.SyncPingMetrics(db, metrics); err != nil {
+			slog.ErrorContext(ctx, "Error syncing ping metrics to database", "Service", service.Name, "Error", err.Error())
+		}
+	}
+
+	if len(critical) > 0 {
+		message := strings.Join(critical, "; ")
+		status.FailureCount++
+		status.HealthReport = constants.GetStatusInfo(constants.Degraded, message)
+		return status, fmt.Errorf("ping failed: %s", message)
+	}
+
+	if len(warnings) > 0 {
+		message := strings.Join(warnings, "; ")
+		status.FailureCount++
+		status.HealthReport = constants.GetStatusInfo(constants.Escalation, message)
+		return status, fmt.Errorf("ping thresholds exceeded: %s", message)
+	}
+
+	status.FailureCount = 0
+	status.HealthReport = constants.GetStatusInfo(constants.Healthy, "")
+	status.LastServiceUpTime = time.Now()
+
+	return status, nil
+}
+
+// Evaluate maps a ping result onto a status flag and a description of the breached threshold
+func (t PingThresholds) Evaluate(result network.PingResult) (int, string) {
+	switch {
+	case result.Received == 0:
+		return constants.Degraded, fmt.Sprintf("unreachable, %d/%d echoes lost", result.Sent, result.Sent)
+	case t.LossCriticalPercent > 0 && result.PacketLoss >= t.LossCriticalPercent:
+		return constants.Degraded, fmt.Sprintf("packet loss %.1f%% (critical %.1f%%)", result.PacketLoss, t.LossCriticalPercent)
+	case t.RTTCritical > 0 && result.AvgRTT >= t.RTTCritical:
+		return constants.Degraded, fmt.Sprintf("avg rtt %v (critical %v)", result.AvgRTT.Round(time.Microsecond), t.RTTCritical)
+	case t.LossWarnPercent > 0 && result.PacketLoss >= t.LossWarnPercent:
+		return constants.Escalation, fmt.Sprintf("packet loss %.1f%% (warning %.1f%%)", result.PacketLoss, t.LossWarnPercent)
+	case t.RTTWarn > 0 && result.AvgRTT >= t.RTTWarn:
+		return constants.Escalation, fmt.Sprintf("avg rtt %v (warning %v)", result.AvgRTT.Round(time.Microsecond), t.RTTWarn)
+	case t.JitterWarn > 0 && result.Jitter >= t.JitterWarn:
+		return constants.Escalation, fmt.Sprintf("jitter %v (warning %v)", result.Jitter.Round(time.Microsecond), t.JitterWarn)
+	}
+
+	return constants.Healthy, ""
+}
+
+func toPingMetric(systemMonitorId string, result network.PingResult) mstypes.PingMetric {
+	ms := func(d time.Duration) float64 {
+		return float64(d.Microseconds()) / 1000
+	}
+
+	return mstypes.PingMetric{
+		SystemMonitorId: systemMonitorId,
+		Target:          result.Target,
+		Address:         result.Address,
+		Sent:            result.Sent,
+		Received:        result.Received,
+		PacketLoss:      result.PacketLoss,
+		MinRTT:          ms(result.MinRTT),
+		AvgRTT:          ms(result.AvgRTT),
+		MaxRTT:          ms(result.MaxRTT),
+		Jitter:          ms(result.Jitter),
+		CollectedAt:     time.Now(),
+	}
+}
+
+// SkipsTCPPrecheck lets ICMP-only devices without an open TCP port reach the plugin
+func (p *PingPlugin) SkipsTCPPrecheck() bool {
+	return true
+}
+
+func (p *PingPlugin) Name() string {
+	return "ping"
+}
+
+func (p *PingPlugin) Description() string {
+	return "ICMP reachability monitor with packet loss, latency and jitter"
+}
+
+func (p *PingPlugin) SupportedTypes() []monitors.ServiceType {
+	return []monitors.ServiceType{
+		monitors.ServiceMonitorSNMP,
+		monitors.ServiceMonitorServer,
+		monitors.ServiceMonitorWebModules,
+	}
+}
+
+func (p *PingPlugin) Cleanup() error {
+	return nil
+}
+
+var Plugin monitors.ServiceMonitorPlugin = NewPingPlugin()
@@ -0,0 +1,301 @@
+package redischeck
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"database/sql"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ZEGIFTED/MS.GoMonitor/monitors"
+	"github.com/ZEGIFTED/MS.GoMonitor/pkg/constants"
+	"github.com/ZEGIFTED/MS.GoMonitor/pkg/utils"
+)
+
+type RedisChecker struct {
+	config map[string]any
+}
+
+func NewRedisChecker() *RedisChecker {
+	return &RedisChecker{}
+}
+
+func (r *RedisChecker) Initialize(config map[string]any) error {
+	r.config = config
+	return nil
+}
+
+func (r *RedisChecker) Check(ctx context.Context, db *sql.DB, service monitors.ServiceMonitorData) (monitors.MonitoringResult, error) {
+	status := monitors.MonitoringResult{
+		SystemMonitorId: service.SystemMonitorId.String(),
+		ServicePluginID: r.Name(),
+		HealthReport:    constants.GetStatusInfo(constants.UnknownStatus, ""),
+		Details:         make(map[string]any),
+		LastCheckTime:   time.Now(),
+	}
+
+	if service.Host == "" {
+		status.FailureCount++
+		status.HealthReport = constants.GetStatusInfo(constants.InvalidConfiguration, "")
+		return status, fmt.Errorf("host cannot be empty")
+	}
+
+	cfg := service.Configuration
+	port := service.Port
+	if port == 0 {
+		port = 6379
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, utils.ConfigDuration(cfg, "timeout", 10*time.Second))
+	defer cancel()
+
+	client, err := dialRedis(ctx, service.Host, port, utils.ConfigBool(cfg, "redisTLS", false), utils.ConfigBool(cfg, "redisSkipVerify", false))
+	if err != nil {
+		status.FailureCount++
+		status.HealthReport = constants.GetStatusInfo(constants.Degraded, err.Error())
+		return status, err
+	}
+	defer client.Close()
+
+	if password := utils.ConfigString(cfg, "redisPassword", ""); password != "" {
+		args := []string{"AUTH", password}
+		if username := utils.ConfigString(cfg, "redisUsername", ""); username != "" {
+			args = []string{"AUTH", username, password}
+		}
+		if _, err := client.Do(args...); err != nil {
+			status.FailureCount++
+			status.HealthReport = constants.GetStatusInfo(constants.Degraded, "Authentication failed "+err.Error())
+			return status, fmt.Errorf("redis authentication failed: %v", err)
+		}
+	}
+
+	start := time.Now()
+	pong, err := client.Do("PING")
+	if err != nil || pong != "PONG" {
+		status.FailureCount++
+		status.HealthReport = constants.GetStatusInfo(constants.Degraded, "PING failed")
+		return status, fmt.Errorf("redis PING failed: %v", err)
+	}
+	status.Details["ping_latency_ms"] = float64(time.Since(start).Microseconds()) / 1000
+
+	raw, err := client.Do("INFO")
+	if err != nil {
+		status.FailureCount++
+		status.HealthReport = constants.GetStatusInfo(constants.Escalation, "INFO failed "+err.Error())
+		return status, fmt.Errorf("redis INFO failed: %v", err)
+	}
+	info := ParseInfo(raw)
+
+	color, problems := evaluate(cfg, info, status.Details)
+	status.Details["health"] = color
+
+	level := constants.HealthColorStatus(color)
+	if level != constants.Healthy {
+		message := strings.Join(problems, "; ")
+		status.FailureCount++
+		status.HealthReport = constants.GetStatusInfo(level, message)
+		return status, fmt.Errorf("redis health %s: %s", color, message)
+	}
+
+	status.FailureCount = 0
+	status.HealthReport = constants.GetStatusInfo(constants.Healthy, "")
+	status.LastServiceUpTime = time.Now()
+
+	return status, nil
+}
+
+// evaluate derives the replication, memory and keyspace metrics from INFO and summarises them as a health color
+func evaluate(cfg map[string]any, info map[string]string, details map[string]any) (string, []string) {
+	var red, yellow []string
+
+	number := func(key string) float64 {
+		v, _ := strconv.ParseFloat(info[key], 64)
+		return v
+	}
+
+	role := info["role"]
+	details["role"] = role
+	details["redis_version"] = info["redis_version"]
+	details["connected_clients"] = number("connected_clients")
+	details["blocked_clients"] = number("blocked_clients")
+	details["evicted_keys"] = number("evicted_keys")
+	details["rejected_connections"] = number("rejected_connections")
+
+	if expected := utils.ConfigString(cfg, "expectedRole", ""); expected != "" && !strings.EqualFold(expected, role) {
+		red = append(red, fmt.Sprintf("role is %s, expected %s", role, expected))
+	}
+
+	// Replication
+	switch role {
+	case "master":
+		replicas := number("connected_slaves")
+		details["connected_replicas"] = replicas
+		if minReplicas := utils.ConfigFloat(cfg, "minReplicas", 0); replicas < minReplicas {
+			yellow = append(yellow, fmt.Sprintf("%.0f connected replicas (expected %.0f)", replicas, minReplicas))
+		}
+	case "slave":
+		link := info["master_link_status"]
+		details["master_link_status"] = link
+		details["master_last_io_seconds_ago"] = number("master_last_io_seconds_ago")
+		if link != "up" {
+			red = append(red, "replication link to master is "+link)
+		} else if warn := utils.ConfigFloat(cfg, "replicationLagWarnSeconds", 30); warn > 0 && number("master_last_io_seconds_ago") >= warn {
+			yellow = append(yellow, fmt.Sprintf("no replication traffic for %.0fs", number("master_last_io_seconds_ago")))
+		}
+	}
+
+	// Memory
+	used, limit := number("used_memory"), number("maxmemory")
+	details["used_memory_bytes"] = used
+	details["maxmemory_bytes"] = limit
+	details["mem_fragmentation_ratio"] = number("mem_fragmentation_ratio")
+	if limit > 0 {
+		usage := used / limit * 100
+		details["memory_usage_percent"] = usage
+
+		switch {
+		case usage >= utils.ConfigFloat(cfg, "memoryCriticalPercent", 95):
+			red = append(red, fmt.Sprintf("memory usage %.1f%%", usage))
+		case usage >= utils.ConfigFloat(cfg, "memoryWarnPercent", 85):
+			yellow = append(yellow, fmt.Sprintf("memory usage %.1f%%", usage))
+		}
+	}
+
+	// Keyspace hit rate
+	hits, misses := number("keyspace_hits"), number("keyspace_misses")
+	details["keyspace_hits"] = hits
+	details["keyspace_misses"] = misses
+	if hits+misses > 0 {
+		hitRate := hits / (hits + misses) * 100
+		details["keyspace_hit_rate_percent"] = hitRate
+
+		if warn := utils.ConfigFloat(cfg, "hitRateWarnPercent", 0); warn > 0 && hitRate < warn {
+			yellow = append(yellow, fmt.Sprintf("keyspace hit rate %.1f%% (warning below %.1f%%)", hitRate, warn))
+		}
+	}
+
+	switch {
+	case len(red) > 0:
+		return "red", append(red, yellow...)
+	case len(yellow) > 0:
+		return "yellow", yellow
+	}
+	return "green", nil
+}
+
+// ParseInfo turns the INFO reply into a flat key/value map, section headers are dropped
+func ParseInfo(raw string) map[string]string {
+	info := make(map[string]string)
+	for _, line := range strings.Split(raw, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if key, value, ok := strings.Cut(line, ":"); ok {
+			info[key] = value
+		}
+	}
+	return info
+}
+
+// redisClient is a minimal RESP client, enough for the commands of a health check
+type redisClient struct {
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+func dialRedis(ctx context.Context, host string, port int, useTLS, skipVerify bool) (*redisClient, error) {
+	address := net.JoinHostPort(host, strconv.Itoa(port))
+
+	var conn net.Conn
+	var err error
+	if useTLS {
+		dialer := &tls.Dialer{Config: &tls.Config{ServerName: host, InsecureSkipVerify: skipVerify}}
+		conn, err = dialer.DialContext(ctx, "tcp", address)
+	} else {
+		conn, err = (&net.Dialer{}).DialContext(ctx, "tcp", address)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("connection to %s failed: %v", address, err)
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	return &redisClient{conn: conn, reader: bufio.NewReader(conn)}, nil
+}
+
+// Do sends a command and returns a simple, integer or bulk string reply
+func (c *redisClient) Do(args ...string) (string, error) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+
+	if _, err := c.conn.Write([]byte(b.String())); err != nil {
+		return "", err
+	}
+
+	line, err := c.reader.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	line = strings.TrimRight(line, "\r\n")
+	if line == "" {
+		return "", fmt.Errorf("empty reply")
+	}
+
+	switch line[0] {
+	case '+', ':':
+		return line[1:], nil
+	case '-':
+		return "", fmt.Errorf("%s", line[1:])
+	case '$':
+		size, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return "", fmt.Errorf("invalid bulk length %q", line)
+		}
+		if size < 0 {
+			return "", nil
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(c.reader, buf); err != nil {
+			return "", err
+		}
+		return string(buf[:size]), nil
+	}
+
+	return "", fmt.Errorf("unsupported reply %q", line)
+}
+
+func (c *redisClient) Close() error {
+	return c.conn.Close()
+}
+
+func (r *RedisChecker) Name() string {
+	return "redis_check"
+}
+
+func (r *RedisChecker) Description() string {
+	return "Redis availability, replication, memory and keyspace hit rate monitor"
+}
+
+func (r *RedisChecker) SupportedTypes() []monitors.ServiceType {
+	return []monitors.ServiceType{
+		monitors.ServiceMonitorDatabase,
+		monitors.ServiceMonitorServer,
+	}
+}
+
+func (r *RedisChecker) Cleanup() error {
+	return nil
+}
+
+var Plugin monitors.ServiceMonitorPlugin = NewRedisChecker()
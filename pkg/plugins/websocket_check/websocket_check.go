@@ -0,0 +1,232 @@
+package websocketcheck
+
+import (
+	"context"
+	"crypto/tls"
+	"database/sql"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/ZEGIFTED/MS.GoMonitor/monitors"
+	"github.com/ZEGIFTED/MS.GoMonitor/pkg/constants"
+	"github.com/ZEGIFTED/MS.GoMonitor/pkg/utils"
+	"github.com/gorilla/websocket"
+)
+
+// defaultMaxMessageSize bounds a message read from the endpoint, larger ones fail the check
+const defaultMaxMessageSize = 1 << 20
+
+type WebSocketChecker struct {
+	config map[string]any
+}
+
+func NewWebSocketChecker() *WebSocketChecker {
+	return &WebSocketChecker{}
+}
+
+func (w *WebSocketChecker) Initialize(config map[string]any) error {
+	w.config = config
+	return nil
+}
+
+func (w *WebSocketChecker) Check(ctx context.Context, db *sql.DB, service monitors.ServiceMonitorData) (monitors.MonitoringResult, error) {
+	status := monitors.MonitoringResult{
+		SystemMonitorId: service.SystemMonitorId.String(),
+		ServicePluginID: w.Name(),
+		HealthReport:    constants.GetStatusInfo(constants.UnknownStatus, ""),
+		Details:         make(map[string]any),
+		LastCheckTime:   time.Now(),
+	}
+
+	cfg := service.Configuration
+	target, err := endpoint(service)
+	if err != nil {
+		status.FailureCount++
+		status.HealthReport = constants.GetStatusInfo(constants.InvalidConfiguration, err.Error())
+		return status, err
+	}
+	status.Details["url"] = target
+
+	var expect *regexp.Regexp
+	if pattern := utils.ConfigString(cfg, "wsExpect", ""); pattern != "" {
+		if expect, err = regexp.Compile(pattern); err != nil {
+			status.FailureCount++
+			status.HealthReport = constants.GetStatusInfo(constants.InvalidConfiguration, err.Error())
+			return status, fmt.Errorf("invalid wsExpect pattern: %v", err)
+		}
+	}
+
+	timeout := utils.ConfigDuration(cfg, "timeout", 10*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	dialer := websocket.Dialer{
+		HandshakeTimeout: timeout,
+		Subprotocols:     utils.ConfigStringSlice(cfg, "wsSubprotocols"),
+		TLSClientConfig:  &tls.Config{InsecureSkipVerify: utils.ConfigBool(cfg, "wsSkipVerify", false)},
+		Proxy:            http.ProxyFromEnvironment,
+	}
+
+	header := http.Header{}
+	for k, v := range utils.ConfigMap(cfg, "wsHeaders") {
+		header.Set(k, fmt.Sprint(v))
+	}
+
+	start := time.Now()
+	conn, resp, err := dialer.DialContext(ctx, target, header)
+	handshake := time.Since(start)
+	if resp != nil {
+		status.Details["http_status"] = resp.StatusCode
+	}
+	if err != nil {
+		status.FailureCount++
+		status.HealthReport = constants.GetStatusInfo(constants.Degraded, err.Error())
+		if resp != nil {
+			return status, fmt.Errorf("websocket upgrade rejected with HTTP %d: %v", resp.StatusCode, err)
+		}
+		return status, fmt.Errorf("websocket handshake failed: %v", err)
+	}
+	defer conn.Close()
+	conn.SetReadLimit(int64(utils.ConfigInt(cfg, "wsMaxMessageBytes", defaultMaxMessageSize)))
+
+	status.Details["handshake_ms"] = float64(handshake.Microseconds()) / 1000
+	status.Details["subprotocol"] = conn.Subprotocol()
+
+	// First-message latency is measured from the completed handshake, including the send
+	exchangeStart := time.Now()
+	message := utils.ConfigString(cfg, "wsSendMessage", "")
+	if message != "" {
+		messageType := websocket.TextMessage
+		if utils.ConfigBool(cfg, "wsBinary", false) {
+			messageType = websocket.BinaryMessage
+		}
+		_ = conn.SetWriteDeadline(deadline(ctx))
+		if err := conn.WriteMessage(messageType, []byte(message)); err != nil {
+			status.FailureCount++
+			status.HealthReport = constants.GetStatusInfo(constants.Degraded, err.Error())
+			return status, fmt.Errorf("failed to send message: %v", err)
+		}
+	}
+
+	// Without a message to send or a reply to wait for, a successful upgrade is the check
+	if message != "" || expect != nil {
+		reply, elapsed, err := awaitReply(ctx, conn, expect, exchangeStart)
+		if err != nil {
+			status.FailureCount++
+			status.HealthReport = constants.GetStatusInfo(constants.Degraded, err.Error())
+			return status, err
+		}
+		status.Details["first_message_ms"] = float64(elapsed.Microseconds()) / 1000
+		status.Details["reply"] = truncate(reply, 256)
+	}
+
+	_ = conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
+
+	if warn := utils.ConfigFloat(cfg, "handshakeLatencyWarnMs", 0); warn > 0 && float64(handshake.Milliseconds()) > warn {
+		status.FailureCount++
+		status.HealthReport = constants.GetStatusInfo(constants.Escalation, fmt.Sprintf("handshake took %v", handshake.Round(time.Millisecond)))
+		return status, fmt.Errorf("websocket handshake took %v (warning %vms)", handshake.Round(time.Millisecond), warn)
+	}
+
+	status.FailureCount = 0
+	status.HealthReport = constants.GetStatusInfo(constants.Healthy, "")
+	status.LastServiceUpTime = time.Now()
+
+	return status, nil
+}
+
+// awaitReply reads messages until one matches expect (any message when expect is nil) or the deadline passes.
+// Streaming APIs often push heartbeats or snapshots first, so non-matching messages are skipped.
+func awaitReply(ctx context.Context, conn *websocket.Conn, expect *regexp.Regexp, start time.Time) (string, time.Duration, error) {
+	_ = conn.SetReadDeadline(deadline(ctx))
+
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			if expect != nil {
+				return "", 0, fmt.Errorf("no message matching %q received: %v", expect, err)
+			}
+			return "", 0, fmt.Errorf("no message received: %v", err)
+		}
+		if expect == nil || expect.Match(data) {
+			return string(data), time.Since(start), nil
+		}
+	}
+}
+
+// endpoint returns "wsURL" or builds ws(s)://host:port/wsPath from the service
+func endpoint(service monitors.ServiceMonitorData) (string, error) {
+	cfg := service.Configuration
+	if raw := utils.ConfigString(cfg, "wsURL", ""); raw != "" {
+		u, err := url.Parse(raw)
+		if err != nil {
+			return "", fmt.Errorf("invalid wsURL: %v", err)
+		}
+		switch u.Scheme {
+		case "ws", "wss":
+		case "http":
+			u.Scheme = "ws"
+		case "https":
+			u.Scheme = "wss"
+		default:
+			return "", fmt.Errorf("unsupported websocket scheme %q", u.Scheme)
+		}
+		return u.String(), nil
+	}
+
+	if service.Host == "" {
+		return "", fmt.Errorf("host cannot be empty")
+	}
+
+	scheme := "ws"
+	if utils.ConfigBool(cfg, "wsTLS", false) {
+		scheme = "wss"
+	}
+	host := service.Host
+	if service.Port != 0 {
+		host = net.JoinHostPort(service.Host, strconv.Itoa(service.Port))
+	}
+
+	u := url.URL{Scheme: scheme, Host: host, Path: utils.ConfigString(cfg, "wsPath", "/")}
+	return u.String(), nil
+}
+
+func deadline(ctx context.Context) time.Time {
+	if d, ok := ctx.Deadline(); ok {
+		return d
+	}
+	return time.Now().Add(10 * time.Second)
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "..."
+}
+
+func (w *WebSocketChecker) Name() string {
+	return "websocket_check"
+}
+
+func (w *WebSocketChecker) Description() string {
+	return "Verifies WebSocket upgrades and optional request/reply exchanges"
+}
+
+func (w *WebSocketChecker) SupportedTypes() []monitors.ServiceType {
+	return []monitors.ServiceType{
+		monitors.ServiceMonitorWebModules,
+		monitors.ServiceMonitorServer,
+	}
+}
+
+func (w *WebSocketChecker) Cleanup() error {
+	return nil
+}
+
+var Plugin monitors.ServiceMonitorPlugin = NewWebSocketChecker()
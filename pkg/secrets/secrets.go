@@ -0,0 +1,114 @@
+// Package secrets resolves credential references so passwords and tokens do not have to be stored in a
+// service's Configuration. A reference has the form "<provider>:<name>", for example
+// "env:LDAP_BIND_PASSWORD" or "file:ldap/bind-password".
+package secrets
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/ZEGIFTED/MS.GoMonitor/pkg/utils"
+)
+
+// Provider looks up a secret by name
+type Provider interface {
+	Lookup(name string) (string, error)
+}
+
+var (
+	mu        sync.RWMutex
+	providers = map[string]Provider{
+		"env":  envProvider{},
+		"file": fileProvider{},
+	}
+)
+
+// Register adds or replaces the provider for a reference scheme
+func Register(scheme string, provider Provider) {
+	mu.Lock()
+	defer mu.Unlock()
+	providers[scheme] = provider
+}
+
+// Resolve returns the secret a reference points to. Values without a known provider prefix are
+// rejected so a password pasted into Configuration is never silently used.
+func Resolve(ref string) (string, error) {
+	scheme, name, ok := strings.Cut(strings.TrimSpace(ref), ":")
+	if !ok || name == "" {
+		return "", fmt.Errorf("invalid secret reference, expected <provider>:<name>")
+	}
+
+	mu.RLock()
+	provider, ok := providers[scheme]
+	mu.RUnlock()
+	if !ok {
+		return "", fmt.Errorf("unknown secret provider %q", scheme)
+	}
+
+	value, err := provider.Lookup(name)
+	if err != nil {
+		return "", fmt.Errorf("secret %s: %v", ref, err)
+	}
+	return value, nil
+}
+
+// FromConfig resolves the reference stored under key in a service Configuration.
+// An absent key yields an empty secret.
+func FromConfig(config map[string]any, key string) (string, error) {
+	ref := utils.ConfigString(config, key, "")
+	if ref == "" {
+		return "", nil
+	}
+	return Resolve(ref)
+}
+
+// envProvider reads secrets from environment variables (including the .env file loaded at startup)
+type envProvider struct{}
+
+func (envProvider) Lookup(name string) (string, error) {
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		return "", fmt.Errorf("environment variable %s is not set", name)
+	}
+	return value, nil
+}
+
+// fileProvider reads secrets from files, as mounted by Docker and Kubernetes. Names are relative to
+// SECRETS_DIR (default /run/secrets) and may not escape it, also not through a symlink.
+type fileProvider struct{}
+
+func (fileProvider) Lookup(name string) (string, error) {
+	dir := os.Getenv("SECRETS_DIR")
+	if dir == "" {
+		dir = "/run/secrets"
+	}
+	dir = filepath.Clean(dir)
+
+	name = filepath.Clean(name)
+	if filepath.IsAbs(name) || !filepath.IsLocal(name) {
+		return "", fmt.Errorf("path must be relative to the secrets directory")
+	}
+	path := filepath.Join(dir, name)
+
+	// Kubernetes mounts secrets as symlinks into a hidden directory of the same volume
+	resolvedDir, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return "", err
+	}
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return "", err
+	}
+	if rel, err := filepath.Rel(resolvedDir, resolved); err != nil || !filepath.IsLocal(rel) {
+		return "", fmt.Errorf("path escapes the secrets directory")
+	}
+
+	data, err := os.ReadFile(resolved)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
@@ -0,0 +1,417 @@
+package snmpprofiles
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// baseOIDs are the SNMPv2-SMI and RFC1155-SMI roots every MIB builds on, so MIB files can be loaded
+// without the SMI modules themselves
+var baseOIDs = map[string]string{
+	"ccitt":           "0",
+	"iso":             "1",
+	"joint-iso-ccitt": "2",
+	"org":             "1.3",
+	"dod":             "1.3.6",
+	"internet":        "1.3.6.1",
+	"directory":       "1.3.6.1.1",
+	"mgmt":            "1.3.6.1.2",
+	"mib-2":           "1.3.6.1.2.1",
+	"transmission":    "1.3.6.1.2.1.10",
+	"experimental":    "1.3.6.1.3",
+	"private":         "1.3.6.1.4",
+	"enterprises":     "1.3.6.1.4.1",
+	"security":        "1.3.6.1.5",
+	"snmpV2":          "1.3.6.1.6",
+	"snmpDomains":     "1.3.6.1.6.1",
+	"snmpProxys":      "1.3.6.1.6.2",
+	"snmpModules":     "1.3.6.1.6.3",
+	"zeroDotZero":     "0.0",
+}
+
+// builtinSymbols lets profiles and configurations use the most common names without any MIB files
+var builtinSymbols = map[string]map[string]string{
+	"SNMPv2-MIB": {
+		"system":      "1.3.6.1.2.1.1",
+		"sysDescr":    "1.3.6.1.2.1.1.1",
+		"sysObjectID": "1.3.6.1.2.1.1.2",
+		"sysUpTime":   "1.3.6.1.2.1.1.3",
+		"sysContact":  "1.3.6.1.2.1.1.4",
+		"sysName":     "1.3.6.1.2.1.1.5",
+		"sysLocation": "1.3.6.1.2.1.1.6",
+		"snmpTrapOID": "1.3.6.1.6.3.1.1.4.1",
+	},
+	"IF-MIB": {
+		"ifNumber":        "1.3.6.1.2.1.2.1",
+		"ifIndex":         "1.3.6.1.2.1.2.2.1.1",
+		"ifDescr":         "1.3.6.1.2.1.2.2.1.2",
+		"ifType":          "1.3.6.1.2.1.2.2.1.3",
+		"ifMtu":           "1.3.6.1.2.1.2.2.1.4",
+		"ifSpeed":         "1.3.6.1.2.1.2.2.1.5",
+		"ifAdminStatus":   "1.3.6.1.2.1.2.2.1.7",
+		"ifOperStatus":    "1.3.6.1.2.1.2.2.1.8",
+		"ifInOctets":      "1.3.6.1.2.1.2.2.1.10",
+		"ifInUcastPkts":   "1.3.6.1.2.1.2.2.1.11",
+		"ifInDiscards":    "1.3.6.1.2.1.2.2.1.13",
+		"ifInErrors":      "1.3.6.1.2.1.2.2.1.14",
+		"ifOutOctets":     "1.3.6.1.2.1.2.2.1.16",
+		"ifOutUcastPkts":  "1.3.6.1.2.1.2.2.1.17",
+		"ifOutDiscards":   "1.3.6.1.2.1.2.2.1.19",
+		"ifOutErrors":     "1.3.6.1.2.1.2.2.1.20",
+		"ifName":          "1.3.6.1.2.1.31.1.1.1.1",
+		"ifHCInOctets":    "1.3.6.1.2.1.31.1.1.1.6",
+		"ifHCInUcastPkts": "1.3.6.1.2.1.31.1.1.1.7",
+		"ifHCOutOctets":   "1.3.6.1.2.1.31.1.1.1.10",
+		"ifHighSpeed":     "1.3.6.1.2.1.31.1.1.1.15",
+		"ifAlias":         "1.3.6.1.2.1.31.1.1.1.18",
+	},
+	"HOST-RESOURCES-MIB": {
+		"hrSystemUptime":           "1.3.6.1.2.1.25.1.1",
+		"hrSystemProcesses":        "1.3.6.1.2.1.25.1.6",
+		"hrMemorySize":             "1.3.6.1.2.1.25.2.2",
+		"hrStorageDescr":           "1.3.6.1.2.1.25.2.3.1.3",
+		"hrStorageSize":            "1.3.6.1.2.1.25.2.3.1.5",
+		"hrStorageUsed":            "1.3.6.1.2.1.25.2.3.1.6",
+		"hrDeviceStatus":           "1.3.6.1.2.1.25.3.2.1.5",
+		"hrPrinterStatus":          "1.3.6.1.2.1.25.3.5.1.1",
+		"hrProcessorLoad":          "1.3.6.1.2.1.25.3.3.1.2",
+		"hrSWRunPerfCPU":           "1.3.6.1.2.1.25.5.1.1.1",
+		"hrSWRunPerfMem":           "1.3.6.1.2.1.25.5.1.1.2",
+		"hrStorageAllocationUnits": "1.3.6.1.2.1.25.2.3.1.4",
+	},
+	"UPS-MIB": {
+		"upsBatteryStatus":             "1.3.6.1.2.1.33.1.2.1",
+		"upsSecondsOnBattery":          "1.3.6.1.2.1.33.1.2.2",
+		"upsEstimatedMinutesRemaining": "1.3.6.1.2.1.33.1.2.3",
+		"upsEstimatedChargeRemaining":  "1.3.6.1.2.1.33.1.2.4",
+		"upsBatteryVoltage":            "1.3.6.1.2.1.33.1.2.5",
+		"upsBatteryTemperature":        "1.3.6.1.2.1.33.1.2.7",
+		"upsInputFrequency":            "1.3.6.1.2.1.33.1.3.3.1.2",
+		"upsInputVoltage":              "1.3.6.1.2.1.33.1.3.3.1.3",
+		"upsOutputSource":              "1.3.6.1.2.1.33.1.4.1",
+		"upsOutputPercentLoad":         "1.3.6.1.2.1.33.1.4.4.1.5",
+		"upsConfigInputVoltage":        "1.3.6.1.2.1.33.1.9.1",
+	},
+	"ENTITY-SENSOR-MIB": {
+		"entPhySensorType":         "1.3.6.1.2.1.99.1.1.1.1",
+		"entPhySensorScale":        "1.3.6.1.2.1.99.1.1.1.2",
+		"entPhySensorPrecision":    "1.3.6.1.2.1.99.1.1.1.3",
+		"entPhySensorValue":        "1.3.6.1.2.1.99.1.1.1.4",
+		"entPhySensorOperStatus":   "1.3.6.1.2.1.99.1.1.1.5",
+		"entPhySensorUnitsDisplay": "1.3.6.1.2.1.99.1.1.1.6",
+	},
+}
+
+// definition is an OID assignment read from a MIB file, resolved on lookup because its parent may be
+// defined in a module loaded later
+type definition struct {
+	parent     string
+	components []int
+}
+
+// MIB resolves symbolic object names to numeric OIDs
+type MIB struct {
+	mu       sync.RWMutex
+	modules  map[string]map[string]definition // module to object name to definition
+	resolved map[string]string                // cache of "module::name" and "name"
+}
+
+func NewMIB() *MIB {
+	return &MIB{modules: make(map[string]map[string]definition), resolved: make(map[string]string)}
+}
+
+var (
+	mibTokenPattern = regexp.MustCompile(`"[^"]*"|::=|[{}(),;]|[A-Za-z0-9_.-]+`)
+	numericOID      = regexp.MustCompile(`^\.?[0-9]+(\.[0-9]+)*$`)
+
+	// Macros whose value notation assigns an OID
+	oidMacros = map[string]bool{
+		"OBJECT-TYPE":        true,
+		"OBJECT-IDENTITY":    true,
+		"MODULE-IDENTITY":    true,
+		"NOTIFICATION-TYPE":  true,
+		"TRAP-TYPE":          false, // SMIv1 traps are enterprise plus number, not part of the OID tree
+		"OBJECT-GROUP":       true,
+		"NOTIFICATION-GROUP": true,
+		"MODULE-COMPLIANCE":  true,
+		"AGENT-CAPABILITIES": true,
+	}
+)
+
+// LoadDir loads every MIB file in dir, files that do not parse are reported but do not stop the others
+func (m *MIB) LoadDir(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	var failed []string
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		if err := m.LoadFile(filepath.Join(dir, entry.Name())); err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", entry.Name(), err))
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("some MIB files could not be loaded: %s", strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+func (m *MIB) LoadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return m.Parse(string(data))
+}
+
+// Parse reads the OID assignments of one or more SMIv1/SMIv2 modules. Only the value notation is
+// interpreted; syntax, access and descriptions are skipped.
+func (m *MIB) Parse(text string) error {
+	tokens := mibTokenPattern.FindAllString(stripComments(text), -1)
+
+	module := ""
+	parsed := make(map[string]map[string]definition)
+
+	for i := 0; i < len(tokens); i++ {
+		if tokens[i] != "::=" || i == 0 {
+			continue
+		}
+
+		// <module> DEFINITIONS ::= BEGIN
+		if tokens[i-1] == "DEFINITIONS" && i >= 2 {
+			module = tokens[i-2]
+			parsed[module] = make(map[string]definition)
+			continue
+		}
+		if i+1 >= len(tokens) || tokens[i+1] != "{" || module == "" {
+			continue
+		}
+
+		name := assignedName(tokens[:i])
+		if name == "" {
+			continue
+		}
+
+		end := i + 2
+		for end < len(tokens) && tokens[end] != "}" {
+			end++
+		}
+		if end >= len(tokens) {
+			return fmt.Errorf("unterminated value of %s", name)
+		}
+
+		def, err := parseOIDValue(tokens[i+2 : end])
+		if err != nil {
+			return fmt.Errorf("%s::%s: %v", module, name, err)
+		}
+		parsed[module][name] = def
+		i = end
+	}
+
+	if len(parsed) == 0 {
+		return fmt.Errorf("no MIB module found")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for module, definitions := range parsed {
+		m.modules[module] = definitions
+	}
+	m.resolved = make(map[string]string)
+	return nil
+}
+
+// assignedName finds the object a "::= { ... }" value belongs to by walking back to its macro keyword
+func assignedName(tokens []string) string {
+	n := len(tokens)
+
+	// name OBJECT IDENTIFIER ::= { ... }
+	if n >= 3 && tokens[n-1] == "IDENTIFIER" && tokens[n-2] == "OBJECT" {
+		return tokens[n-3]
+	}
+
+	for j := n - 1; j > 0; j-- {
+		if tokens[j] == "::=" {
+			return ""
+		}
+		if assigns, ok := oidMacros[tokens[j]]; ok {
+			if !assigns {
+				return ""
+			}
+			return tokens[j-1]
+		}
+	}
+	return ""
+}
+
+// parseOIDValue reads "parent 1", "parent sub(1) 2" or an absolute "iso(1) org(3) dod(6) 1"
+func parseOIDValue(tokens []string) (definition, error) {
+	var def definition
+	for j := 0; j < len(tokens); j++ {
+		token := tokens[j]
+
+		// name(number) contributes its number
+		if j+1 < len(tokens) && tokens[j+1] == "(" {
+			if j+3 >= len(tokens) || tokens[j+3] != ")" {
+				return def, fmt.Errorf("malformed component %s", token)
+			}
+			token = tokens[j+2]
+			j += 3
+		}
+
+		if n, err := strconv.Atoi(token); err == nil {
+			def.components = append(def.components, n)
+			continue
+		}
+		if j > 0 {
+			return def, fmt.Errorf("unexpected %q", token)
+		}
+		def.parent = token
+	}
+
+	if def.parent == "" && len(def.components) == 0 {
+		return def, fmt.Errorf("empty OID value")
+	}
+	return def, nil
+}
+
+// stripComments removes ASN.1 comments, which run from "--" to the next "--" or the end of the line.
+// Quoted strings, which may span lines, are kept as they are.
+func stripComments(text string) string {
+	var b strings.Builder
+	inString := false
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case c == '"':
+			inString = !inString
+		case !inString && c == '-' && i+1 < len(text) && text[i+1] == '-':
+			i += 2
+			for i < len(text) && text[i] != '\n' && !(text[i] == '-' && i+1 < len(text) && text[i+1] == '-') {
+				i++
+			}
+			if i < len(text) && text[i] == '-' {
+				i++ // closing "--"
+				continue
+			}
+			if i < len(text) {
+				b.WriteByte('\n')
+			}
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
+// Resolve translates "IF-MIB::ifHCInOctets.3", "ifHCInOctets.3" or a numeric OID into a numeric OID
+// without a leading dot. Instance suffixes after the name are kept.
+func (m *MIB) Resolve(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if numericOID.MatchString(name) {
+		return strings.TrimPrefix(name, "."), nil
+	}
+
+	module, object := "", name
+	if i := strings.Index(name, "::"); i >= 0 {
+		module, object = name[:i], name[i+2:]
+	}
+	suffix := ""
+	if i := strings.Index(object, "."); i >= 0 {
+		object, suffix = object[:i], object[i:]
+		if !numericOID.MatchString(suffix) {
+			return "", fmt.Errorf("invalid instance %q in %s", suffix, name)
+		}
+	}
+
+	oid, err := m.resolve(module, object, 0)
+	if err != nil {
+		return "", err
+	}
+	return oid + suffix, nil
+}
+
+func (m *MIB) resolve(module, object string, depth int) (string, error) {
+	if depth > 64 {
+		return "", fmt.Errorf("OID of %s is defined in a loop", object)
+	}
+
+	key := module + "::" + object
+	m.mu.RLock()
+	cached, ok := m.resolved[key]
+	m.mu.RUnlock()
+	if ok {
+		return cached, nil
+	}
+
+	def, found := m.lookup(module, object)
+	var oid string
+	switch {
+	case found:
+		var parts []string
+		if def.parent != "" {
+			parent, err := m.resolve("", def.parent, depth+1)
+			if err != nil {
+				return "", err
+			}
+			parts = append(parts, parent)
+		}
+		for _, c := range def.components {
+			parts = append(parts, strconv.Itoa(c))
+		}
+		oid = strings.Join(parts, ".")
+	case builtin(module, object) != "":
+		oid = builtin(module, object)
+	default:
+		if module != "" {
+			return "", fmt.Errorf("unknown object %s::%s", module, object)
+		}
+		return "", fmt.Errorf("unknown object %s", object)
+	}
+
+	m.mu.Lock()
+	m.resolved[key] = oid
+	m.mu.Unlock()
+	return oid, nil
+}
+
+// lookup finds a definition in module, or in any loaded module when module is empty
+func (m *MIB) lookup(module, object string) (definition, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if module != "" {
+		def, ok := m.modules[module][object]
+		return def, ok
+	}
+	for _, definitions := range m.modules {
+		if def, ok := definitions[object]; ok {
+			return def, true
+		}
+	}
+	return definition{}, false
+}
+
+func builtin(module, object string) string {
+	if oid, ok := baseOIDs[object]; ok && (module == "" || module == "SNMPv2-SMI" || module == "RFC1155-SMI") {
+		return oid
+	}
+	if module != "" {
+		return builtinSymbols[module][object]
+	}
+	for _, symbols := range builtinSymbols {
+		if oid, ok := symbols[object]; ok {
+			return oid
+		}
+	}
+	return ""
+}
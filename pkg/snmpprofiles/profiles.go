@@ -0,0 +1,235 @@
+package snmpprofiles
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/ZEGIFTED/MS.GoMonitor/monitors"
+	"github.com/gosnmp/gosnmp"
+)
+
+//go:embed profiles/*.json
+var builtinProfiles embed.FS
+
+const oidSysObjectID = ".1.3.6.1.2.1.1.2.0"
+
+// GenericProfile holds the standard MIB-II and HOST-RESOURCES metrics, it is used for devices no
+// other profile matches
+const GenericProfile = "generic"
+
+// Profile is a reusable metric template for a family of devices. Metric OIDs may be numeric or
+// symbolic names such as IF-MIB::ifHCInOctets, they are resolved when the profile is used.
+type Profile struct {
+	Name         string                      `json:"name"`
+	Description  string                      `json:"description"`
+	SysObjectIDs []string                    `json:"sysObjectIds"` // sysObjectID prefixes the profile applies to
+	Extends      string                      `json:"extends,omitempty"`
+	Tables       []string                    `json:"tables,omitempty"`
+	Metrics      []monitors.SNMPMetricConfig `json:"metrics"`
+}
+
+// Registry holds the known profiles and the MIB used to resolve their symbolic OIDs
+type Registry struct {
+	mu       sync.RWMutex
+	profiles map[string]Profile
+	MIB      *MIB
+}
+
+// Default is the registry used by the SNMP plugin, it starts with the profiles shipped in the binary
+var Default = NewRegistry()
+
+func NewRegistry() *Registry {
+	r := &Registry{profiles: make(map[string]Profile), MIB: NewMIB()}
+	if err := r.load(builtinProfiles, "profiles"); err != nil {
+		panic(fmt.Sprintf("invalid built-in SNMP profile: %v", err))
+	}
+	return r
+}
+
+// LoadDir adds the profiles in dir, a profile with the name of an existing one replaces it
+func (r *Registry) LoadDir(dir string) error {
+	return r.load(os.DirFS(dir), ".")
+}
+
+func (r *Registry) load(fsys fs.FS, dir string) error {
+	paths, err := fs.Glob(fsys, path.Join(dir, "*.json"))
+	if err != nil {
+		return err
+	}
+
+	for _, file := range paths {
+		data, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return err
+		}
+
+		var profile Profile
+		if err := json.Unmarshal(data, &profile); err != nil {
+			return fmt.Errorf("%s: %v", path.Base(file), err)
+		}
+		if profile.Name == "" {
+			profile.Name = strings.TrimSuffix(path.Base(file), ".json")
+		}
+		if err := profile.validate(); err != nil {
+			return fmt.Errorf("%s: %v", path.Base(file), err)
+		}
+
+		r.mu.Lock()
+		r.profiles[profile.Name] = profile
+		r.mu.Unlock()
+	}
+	return nil
+}
+
+func (p Profile) validate() error {
+	for _, table := range p.Tables {
+		switch table {
+		case monitors.TableInterfaces, monitors.TableStorage, monitors.TableProcessors, monitors.TableUPS, monitors.TableSensors:
+		default:
+			return fmt.Errorf("unknown SNMP table %q", table)
+		}
+	}
+	for _, metric := range p.Metrics {
+		if metric.OID == "" || metric.Name == "" {
+			return fmt.Errorf("metric needs an oid and a name")
+		}
+	}
+	return nil
+}
+
+// Names lists the known profiles
+func (r *Registry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	names := make([]string, 0, len(r.profiles))
+	for name := range r.profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ByName returns the named profile with the metrics and tables of the profiles it extends
+func (r *Registry) ByName(name string) (Profile, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.expand(name, 0)
+}
+
+func (r *Registry) expand(name string, depth int) (Profile, error) {
+	profile, ok := r.profiles[name]
+	if !ok {
+		return Profile{}, fmt.Errorf("unknown SNMP profile %q", name)
+	}
+	if profile.Extends == "" {
+		return profile, nil
+	}
+	if depth > 8 {
+		return Profile{}, fmt.Errorf("SNMP profile %q extends itself", name)
+	}
+
+	parent, err := r.expand(profile.Extends, depth+1)
+	if err != nil {
+		return Profile{}, err
+	}
+	profile.Metrics = append(append([]monitors.SNMPMetricConfig{}, parent.Metrics...), profile.Metrics...)
+	if profile.Tables == nil {
+		profile.Tables = parent.Tables
+	}
+	return profile, nil
+}
+
+// Match returns the profile with the longest sysObjectID prefix matching sysObjectID
+func (r *Registry) Match(sysObjectID string) (Profile, bool) {
+	sysObjectID = strings.TrimPrefix(sysObjectID, ".")
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	best, bestLength := "", -1
+	for name, profile := range r.profiles {
+		for _, prefix := range profile.SysObjectIDs {
+			prefix = strings.TrimPrefix(prefix, ".")
+			if sysObjectID != prefix && !strings.HasPrefix(sysObjectID, prefix+".") {
+				continue
+			}
+			// Ties go to the lowest name so the choice does not depend on map order
+			if len(prefix) > bestLength || (len(prefix) == bestLength && name < best) {
+				best, bestLength = name, len(prefix)
+			}
+		}
+	}
+	if best == "" {
+		return Profile{}, false
+	}
+
+	profile, err := r.expand(best, 0)
+	return profile, err == nil
+}
+
+// Select picks the profile for the device behind snmp, which must be connected. A non-empty name
+// forces that profile instead of matching the device's sysObjectID.
+func (r *Registry) Select(snmp *gosnmp.GoSNMP, name string) (Profile, string, error) {
+	if name != "" {
+		profile, err := r.ByName(name)
+		return profile, "", err
+	}
+
+	packet, err := snmp.Get([]string{oidSysObjectID})
+	if err != nil {
+		return Profile{}, "", fmt.Errorf("reading sysObjectID: %v", err)
+	}
+
+	sysObjectID := ""
+	if len(packet.Variables) > 0 {
+		if oid, ok := packet.Variables[0].Value.(string); ok {
+			sysObjectID = strings.TrimPrefix(oid, ".")
+		}
+	}
+
+	profile, ok := r.Match(sysObjectID)
+	if !ok {
+		return Profile{}, sysObjectID, fmt.Errorf("no SNMP profile for sysObjectID %s", sysObjectID)
+	}
+	return profile, sysObjectID, nil
+}
+
+// ResolveMetrics returns metrics with every symbolic OID replaced by its numeric form
+func (r *Registry) ResolveMetrics(metrics []monitors.SNMPMetricConfig) ([]monitors.SNMPMetricConfig, error) {
+	resolved := make([]monitors.SNMPMetricConfig, len(metrics))
+	for i, metric := range metrics {
+		oid, err := r.MIB.Resolve(metric.OID)
+		if err != nil {
+			return nil, fmt.Errorf("metric %s: %v", metric.Name, err)
+		}
+		metric.OID = oid
+		resolved[i] = metric
+	}
+	return resolved, nil
+}
+
+// LoadMIBDirs loads the MIB files of every directory into the default registry's MIB
+func LoadMIBDirs(dirs []string) error {
+	var failed []string
+	for _, dir := range dirs {
+		dir = strings.TrimSpace(dir)
+		if dir == "" {
+			continue
+		}
+		if err := Default.MIB.LoadDir(dir); err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", dir, err))
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%s", strings.Join(failed, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+package utils
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ConfigString reads a string value from a service Configuration map
+func ConfigString(config map[string]any, key, defaultValue string) string {
+	if v, ok := config[key].(string); ok && strings.TrimSpace(v) != "" {
+		return strings.TrimSpace(v)
+	}
+	return defaultValue
+}
+
+// ConfigFloat reads a numeric value from a service Configuration map.
+// JSON numbers decode as float64 but numeric strings are accepted as well.
+func ConfigFloat(config map[string]any, key string, defaultValue float64) float64 {
+	switch v := config[key].(type) {
+	case float64:
+		return v
+	case int:
+		return float64(v)
+	case string:
+		if f, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil {
+			return f
+		}
+	}
+	return defaultValue
+}
+
+// ConfigInt reads an integer value from a service Configuration map
+func ConfigInt(config map[string]any, key string, defaultValue int) int {
+	return int(ConfigFloat(config, key, float64(defaultValue)))
+}
+
+// ConfigBool reads a boolean value from a service Configuration map
+func ConfigBool(config map[string]any, key string, defaultValue bool) bool {
+	switch v := config[key].(type) {
+	case bool:
+		return v
+	case string:
+		if b, err := strconv.ParseBool(strings.TrimSpace(v)); err == nil {
+			return b
+		}
+	}
+	return defaultValue
+}
+
+// ConfigDuration reads a duration from a service Configuration map.
+// Plain numbers are treated as seconds (matching the existing "timeout" key),
+// strings are parsed with time.ParseDuration.
+func ConfigDuration(config map[string]any, key string, defaultValue time.Duration) time.Duration {
+	switch v := config[key].(type) {
+	case float64:
+		return time.Duration(v * float64(time.Second))
+	case string:
+		if d, err := time.ParseDuration(strings.TrimSpace(v)); err == nil {
+			return d
+		}
+		if f, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil {
+			return time.Duration(f * float64(time.Second))
+		}
+	}
+	return defaultValue
+}
+
+// ConfigStringSlice reads a list of strings from a service Configuration map.
+// A JSON array, a JSON encoded string or a comma separated string are accepted.
+func ConfigStringSlice(config map[string]any, key string) []string {
+	var values []string
+
+	switch v := config[key].(type) {
+	case []any:
+		for _, item := range v {
+			if s, ok := item.(string); ok && strings.TrimSpace(s) != "" {
+				values = append(values, strings.TrimSpace(s))
+			}
+		}
+	case []string:
+		for _, s := range v {
+			if strings.TrimSpace(s) != "" {
+				values = append(values, strings.TrimSpace(s))
+			}
+		}
+	case string:
+		if err := json.Unmarshal([]byte(v), &values); err == nil {
+			return values
+		}
+		for _, s := range strings.Split(v, ",") {
+			if strings.TrimSpace(s) != "" {
+				values = append(values, strings.TrimSpace(s))
+			}
+		}
+	}
+
+	return values
+}
+
+// ConfigMap reads a nested object from a service Configuration map
+func ConfigMap(config map[string]any, key string) map[string]any {
+	switch v := config[key].(type) {
+	case map[string]any:
+		return v
+	case string:
+		var m map[string]any
+		if err := json.Unmarshal([]byte(v), &m); err == nil {
+			return m
+		}
+	}
+	return nil
+}
+
+// ConfigMapSlice reads a list of nested objects from a service Configuration map.
+// Like snmpMetrics, the list may be stored either as a JSON array or a JSON encoded string.
+func ConfigMapSlice(config map[string]any, key string) []map[string]any {
+	var items []map[string]any
+
+	switch v := config[key].(type) {
+	case []any:
+		for _, item := range v {
+			if m, ok := item.(map[string]any); ok {
+				items = append(items, m)
+			}
+		}
+	case []map[string]any:
+		items = v
+	case string:
+		if err := json.Unmarshal([]byte(v), &items); err != nil {
+			return nil
+		}
+	}
+
+	return items
+}
@@ -35,4 +35,99 @@ VALUES
     'http://localhost/health', 
     'https://your-webhook.com/alert',
     '{"PATH": "/usr/local/bin:/usr/bin:/bin"}'
-);
\ No newline at end of file
+);
+
+-- Create PingMetricData table to store ICMP reachability samples (PostgreSQL)
+CREATE TABLE IF NOT EXISTS "PingMetricData" (
+    "Id" BIGSERIAL PRIMARY KEY,
+    "SystemMonitorId" UUID NOT NULL,
+    "Target" VARCHAR(255) NOT NULL,
+    "Address" VARCHAR(64) NOT NULL,
+    "Sent" INT NOT NULL,
+    "Received" INT NOT NULL,
+    "PacketLoss" DOUBLE PRECISION NOT NULL,
+    "MinRtt" DOUBLE PRECISION NOT NULL,
+    "AvgRtt" DOUBLE PRECISION NOT NULL,
+    "MaxRtt" DOUBLE PRECISION NOT NULL,
+    "Jitter" DOUBLE PRECISION NOT NULL,
+    "CollectedAt" TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS "IX_PingMetricData_SystemMonitorId" ON "PingMetricData" ("SystemMonitorId", "CollectedAt");
+
+-- Create NetworkPathHistory table to store traced routes over time (PostgreSQL)
+CREATE TABLE IF NOT EXISTS "NetworkPathHistory" (
+    "Id" BIGSERIAL PRIMARY KEY,
+    "SystemMonitorId" UUID NOT NULL,
+    "Destination" VARCHAR(255) NOT NULL,
+    "Address" VARCHAR(64) NOT NULL,
+    "Fingerprint" CHAR(40) NOT NULL,
+    "Hops" JSONB NOT NULL,
+    "Reached" BOOLEAN NOT NULL,
+    "CollectedAt" TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS "IX_NetworkPathHistory_SystemMonitorId" ON "NetworkPathHistory" ("SystemMonitorId", "Destination", "CollectedAt");
+
+-- Create NetworkMetricData table to store per-row SNMP table samples such as interfaces and disks (PostgreSQL)
+CREATE TABLE IF NOT EXISTS "NetworkMetricData" (
+    "Id" BIGSERIAL PRIMARY KEY,
+    "SystemMonitorId" UUID NOT NULL,
+    "DeviceIP" VARCHAR(64) NOT NULL,
+    "MetricName" VARCHAR(128) NOT NULL,
+    "Labels" JSONB NOT NULL,
+    "Value" DOUBLE PRECISION NOT NULL,
+    "CollectedAt" TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS "IX_NetworkMetricData_SystemMonitorId" ON "NetworkMetricData" ("SystemMonitorId", "MetricName", "CollectedAt");
+
+-- Create DiscoveredHosts table to remember hosts found by network discovery between runs (PostgreSQL)
+CREATE TABLE IF NOT EXISTS "DiscoveredHosts" (
+    "Address" VARCHAR(64) PRIMARY KEY,
+    "Range" VARCHAR(64) NOT NULL,
+    "SystemMonitorId" UUID NULL,
+    "Present" BOOLEAN NOT NULL,
+    "Details" JSONB NOT NULL,
+    "FirstSeen" TIMESTAMPTZ NOT NULL,
+    "LastSeen" TIMESTAMPTZ NOT NULL
+);
+
+-- Create ServiceDependencies table to keep the parents of services between restarts (PostgreSQL)
+CREATE TABLE IF NOT EXISTS "ServiceDependencies" (
+    "ChildId" UUID NOT NULL,
+    "ParentId" UUID NOT NULL,
+    "Source" VARCHAR(32) NOT NULL,
+    "UpdatedAt" TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY ("ChildId", "ParentId", "Source")
+);
+
+-- Create ServiceTimelineEvents table to annotate service history with events such as syslog messages (PostgreSQL)
+CREATE TABLE IF NOT EXISTS "ServiceTimelineEvents" (
+    "Id" BIGSERIAL PRIMARY KEY,
+    "SystemMonitorId" UUID NOT NULL,
+    "Source" VARCHAR(32) NOT NULL,
+    "Severity" VARCHAR(16) NOT NULL,
+    "Title" VARCHAR(255) NOT NULL,
+    "Message" TEXT NOT NULL,
+    "OccurredAt" TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS "IX_ServiceTimelineEvents_SystemMonitorId" ON "ServiceTimelineEvents" ("SystemMonitorId", "OccurredAt");
+
+-- Create FlowAggregates table to store per minute top talkers, protocols and conversations of flow exporters (PostgreSQL)
+CREATE TABLE IF NOT EXISTS "FlowAggregates" (
+    "Id" BIGSERIAL PRIMARY KEY,
+    "SystemMonitorId" UUID NULL,
+    "Exporter" VARCHAR(64) NOT NULL,
+    "IfIndex" BIGINT NOT NULL,
+    "Direction" VARCHAR(8) NOT NULL,
+    "Dimension" VARCHAR(16) NOT NULL,
+    "Key" VARCHAR(128) NOT NULL,
+    "Bytes" BIGINT NOT NULL,
+    "Packets" BIGINT NOT NULL,
+    "WindowStart" TIMESTAMPTZ NOT NULL,
+    "WindowEnd" TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS "IX_FlowAggregates_Exporter" ON "FlowAggregates" ("Exporter", "IfIndex", "WindowStart");
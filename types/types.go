@@ -1,6 +1,8 @@
 package mstypes
 
 import (
+	"time"
+
 	"github.com/google/uuid"
 	"github.com/slack-go/slack"
 )
@@ -94,6 +96,116 @@ type NetworkDeviceMetric struct {
 	// OutboundTraffic      float64
 }
 
+// NetworkTableMetric is one sample of an SNMP table row, e.g. an interface or a disk, identified by its labels
+type NetworkTableMetric struct {
+	SystemMonitorId string
+	DeviceIP        string
+	MetricName      string
+	Labels          map[string]string // e.g. ifIndex, ifName and ifAlias of an interface
+	Value           float64
+	CollectedAt     time.Time
+}
+
+// SensorReading is a typed reading of a UPS, PDU or environmental sensor, converted to its unit
+type SensorReading struct {
+	Source    string  `json:"source"` // SNMP table the reading came from, ups or sensors
+	Index     string  `json:"index"`
+	Name      string  `json:"name"`
+	Type      string  `json:"type"` // e.g. temperature, humidity, batteryCharge or onBattery
+	Value     float64 `json:"value"`
+	Unit      string  `json:"unit,omitempty"`
+	Precision int     `json:"precision"`         // Digits after the decimal point
+	Nominal   float64 `json:"nominal,omitempty"` // Configured nominal value, e.g. of the input voltage
+	Status    string  `json:"status"`            // ok, unavailable or nonoperational; the state of a battery
+	Severity  string  `json:"severity,omitempty"`
+}
+
+// FacilityStatus is the power and environment view of a device for the dashboard
+type FacilityStatus struct {
+	OnBattery bool            `json:"onBattery"`
+	Severity  string          `json:"severity,omitempty"` // Worst severity of the readings
+	Readings  []SensorReading `json:"readings"`
+	UpdatedAt time.Time       `json:"updatedAt"`
+}
+
+// DiscoveredHost is a host found by a network discovery sweep together with the inventory entry
+// proposed for it
+type DiscoveredHost struct {
+	Address     string   `json:"address"`
+	Hostname    string   `json:"hostname,omitempty"`
+	Range       string   `json:"range"`
+	Methods     []string `json:"methods"` // How the host answered: icmp, tcp and/or snmp
+	RTTMs       float64  `json:"rttMs,omitempty"`
+	OpenPorts   []int    `json:"openPorts,omitempty"`
+	SysName     string   `json:"sysName,omitempty"`
+	SysDescr    string   `json:"sysDescr,omitempty"`
+	SysObjectID string   `json:"sysObjectId,omitempty"`
+	Profile     string   `json:"snmpProfile,omitempty"`
+	Credential  string   `json:"snmpCredential,omitempty"` // Name of the discovery credential the agent accepted
+
+	SuggestedName          string         `json:"suggestedName"`
+	SuggestedDevice        string         `json:"suggestedDevice"`
+	SuggestedPlugins       []string       `json:"suggestedPlugins"`
+	SuggestedConfiguration map[string]any `json:"suggestedConfiguration,omitempty"`
+
+	SystemMonitorId string    `json:"systemMonitorId,omitempty"` // Set when the host is already in the inventory
+	Present         bool      `json:"present"`
+	FirstSeen       time.Time `json:"firstSeen"`
+	LastSeen        time.Time `json:"lastSeen"`
+}
+
+// PingMetric represents a single ICMP reachability sample for a monitored service
+type PingMetric struct {
+	SystemMonitorId string
+	Target          string
+	Address         string
+	Sent            int
+	Received        int
+	PacketLoss      float64
+	MinRTT          float64 // Milliseconds
+	AvgRTT          float64 // Milliseconds
+	MaxRTT          float64 // Milliseconds
+	Jitter          float64 // Milliseconds
+	CollectedAt     time.Time
+}
+
+// NetworkPathRecord represents a traced route towards a monitored service
+type NetworkPathRecord struct {
+	SystemMonitorId string
+	Destination     string
+	Address         string
+	Fingerprint     string
+	Hops            string // JSON encoded hop list
+	Reached         bool
+	CollectedAt     time.Time
+}
+
+// FlowAggregate is one entry of a minute of flow traffic through an exporter interface, the interface
+// total is stored with the dimension "total"
+type FlowAggregate struct {
+	SystemMonitorId string // Empty when the exporter left the inventory
+	Exporter        string
+	IfIndex         int64
+	Direction       string
+	Dimension       string
+	Key             string
+	Bytes           int64
+	Packets         int64
+	WindowStart     time.Time
+	WindowEnd       time.Time
+}
+
+// ServiceTimelineEvent annotates a service's history with something that happened outside the checks,
+// e.g. a configuration change reported over syslog
+type ServiceTimelineEvent struct {
+	SystemMonitorId string
+	Source          string // syslog
+	Severity        string
+	Title           string
+	Message         string
+	OccurredAt      time.Time
+}
+
 // ProcessResourceUsage represents a single process entry returned by the Agent API endpoint.
 type ProcessResourceUsage struct {
 	Username      string  `json:"username"`
@@ -168,7 +280,27 @@ type AgentThresholdResponse struct {
 	Config Config `json:"config"`
 }
 
+// AgentContainerResponse is returned by the agent's api/v1/agent/container endpoint
 type AgentContainerResponse struct {
+	Containers []ContainerStats `json:"containers"`
+}
+
+// ContainerStats is the state and resource usage of a single container
+type ContainerStats struct {
+	ID               string    `json:"id"`
+	Name             string    `json:"name"`
+	Image            string    `json:"image"`
+	State            string    `json:"state"`  // created, running, restarting, exited, paused, dead
+	Health           string    `json:"health"` // healthy, unhealthy, starting or empty without a HEALTHCHECK
+	RestartCount     int       `json:"restartCount"`
+	OOMKilled        bool      `json:"oomKilled"`
+	ExitCode         int       `json:"exitCode"`
+	StartedAt        time.Time `json:"startedAt"`
+	CPUPercent       float64   `json:"cpuPercent"`
+	MemoryUsageBytes float64   `json:"memoryUsageBytes"`
+	MemoryLimitBytes float64   `json:"memoryLimitBytes"`
+	MemoryPercent    float64   `json:"memoryPercent"`
+	StatsError       string    `json:"statsError,omitempty"` // Why the stats sample is missing, e.g. a timeout
 }
 
 // import (
@@ -255,6 +387,7 @@ type EmailTemplateData struct {
 	Items            []string
 	TableData        [][]string
 	ProcessTableData ProcessResponse
+	NetworkPath      []string
 	ExtraFields      map[string]interface{}
 
 	Logo Logo